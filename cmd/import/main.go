@@ -0,0 +1,138 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/collaborators"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/importer"
+	"github.mathworks.com/development/signindatatrackerws/pkg/ippolicy"
+	"github.mathworks.com/development/signindatatrackerws/pkg/repository/adapter"
+	"go.uber.org/zap"
+)
+
+// import loads historical sign-ins from CSV or NDJSON files into the sign-in table. Rerunning
+// it with the same checkpoint file continues after the last written batch of every file.
+func main() {
+	overrides := flag.String("overrides", "", "location of the overrides.properties file")
+	tableName := flag.String("table", "signindatatracker", "table to import into")
+	format := flag.String("format", "", "ndjson or csv, detected from the file extension when empty")
+	checkpointFile := flag.String("checkpoint-file", "data/import/checkpoints.json", "where the import position of every file is stored")
+	errorReport := flag.String("error-report", "data/import/rejected.ndjson", "NDJSON report of rejected rows")
+	workers := flag.Int("workers", 4, "concurrent BatchWriteItem calls")
+	maxWriteCapacity := flag.Int("max-write-capacity", 100, "write capacity units per second, 0 disables throttling")
+	maxRetries := flag.Int("max-retries", 8, "retries of a batch with unprocessed items before the import stops")
+	initialBackoff := flag.Duration("initial-backoff", 100*time.Millisecond, "first retry delay")
+	maxBackoff := flag.Duration("max-backoff", 20*time.Second, "upper bound of the retry delay")
+	dedupeWindow := flag.Int("dedupe-window", 1000000, "number of recent uniqueId/timestamp keys checked for duplicates")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatalf("usage: import [flags] file...")
+	}
+	// deferred first so it runs last, after the report is closed and the sketches are flushed
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+	zap.ReplaceGlobals(logger)
+
+	appContext := bootstrap.BuildApplicationContext(logger.Named("signindatatrackerws.import.context"), *overrides)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	checkpoints, err := importer.NewCheckpointStore(*checkpointFile)
+	if err != nil {
+		logger.Fatal("Failed to load checkpoints", zap.Error(err))
+	}
+	ipPolicies, err := ippolicy.FromConfig(appContext.AppConfigData.IpPolicy)
+	if err != nil {
+		logger.Fatal("Failed to read ip policies", zap.Error(err))
+	}
+	report, err := importer.NewErrorReport(*errorReport)
+	if err != nil {
+		logger.Fatal("Failed to open the error report", zap.Error(err))
+	}
+	defer report.Close()
+
+	// a shared redis read cache has to drop the profiles the import touched
+	repo := adapter.CachedSignInRepoFactory(*tableName)
+	activeUsers := collaborators.NewActiveUsersService()
+	defer collaborators.FlushActiveUsers()
+	im := importer.NewImporter(repo, checkpoints, report, importer.Config{
+		Workers:                   *workers,
+		MaxWriteCapacityPerSecond: *maxWriteCapacity,
+		MaxRetries:                *maxRetries,
+		InitialBackoff:            *initialBackoff,
+		MaxBackoff:                *maxBackoff,
+		DedupeWindow:              *dedupeWindow,
+		IpPolicies:                ipPolicies,
+		// the same updates the service makes after a save, without the webhooks for historical rows
+		AfterWrite: func(ctx context.Context, record domain.SaveSignInInfo) {
+			if err := repo.UpdateSignInSummary(ctx, record); err != nil {
+				logger.Warn("Could not update sign-in summary", zap.String("uniqueId", record.UniqueId), zap.Error(err))
+			}
+			if err := repo.UpdateSignInRollup(ctx, record); err != nil {
+				logger.Warn("Could not update sign-in rollup", zap.String("uniqueId", record.UniqueId), zap.Error(err))
+			}
+			activeUsers.RecordSignIn(record)
+		},
+	}, logger.Named("importer"))
+
+	for _, file := range flag.Args() {
+		checkpoint, err := importFile(ctx, im, file, *format)
+		if err != nil {
+			logger.Error("Import stopped, rerun to continue from the checkpoint", zap.String("file", file), zap.Int("line", checkpoint.Line), zap.Error(err))
+			exitCode = 1
+			return
+		}
+		logger.Info("Imported file", zap.String("file", file), zap.Int("imported", checkpoint.Imported),
+			zap.Int("rejected", checkpoint.Rejected), zap.Int("duplicates", checkpoint.Duplicates))
+	}
+}
+
+func importFile(ctx context.Context, im *importer.Importer, file string, format string) (importer.FileCheckpoint, error) {
+	var err error
+	if format == "" {
+		if format, err = importer.DetectFormat(file); err != nil {
+			return importer.FileCheckpoint{}, err
+		}
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return importer.FileCheckpoint{}, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return importer.FileCheckpoint{}, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	reader, err := importer.NewRecordReader(r, format)
+	if err != nil {
+		return importer.FileCheckpoint{}, err
+	}
+	return im.ImportFile(ctx, file, reader)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/envelope"
+	"go.uber.org/zap"
+)
+
+// reencrypt rewrites the sign-ins that are still plain text or encrypted under an older key with
+// the current key. Run it after rotating the key and keep the old key until it finished.
+func main() {
+	overrides := flag.String("overrides", "", "location of the overrides.properties file")
+	tableName := flag.String("table", "signindatatracker", "table to re-encrypt")
+	pageSize := flag.Int("page-size", 500, "items per scan page")
+	maxCapacity := flag.Int("max-capacity", 100, "read and write capacity units per second, 0 disables throttling")
+	flag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+	zap.ReplaceGlobals(logger)
+
+	appContext := bootstrap.BuildApplicationContext(logger.Named("signindatatrackerws.reencrypt.context"), *overrides)
+	if !appContext.AppConfigData.Encryption.Enabled {
+		logger.Fatal("Field encryption is disabled, set app.signindatatracker.encryption.enabled")
+	}
+	db, err := appContext.GetDB()
+	if err != nil {
+		logger.Fatal("Failed to create the DynamoDB client", zap.Error(err))
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	keyId := appContext.EncryptionKeyId()
+	logger.Info("Re-encrypting", zap.String("table", *tableName), zap.String("keyId", keyId))
+	progress, err := envelope.NewReencryptor(db, *tableName, keyId, *pageSize, *maxCapacity, logger.Named("reencryptor")).Run(ctx)
+	if err != nil {
+		logger.Fatal("Re-encryption stopped, rerun to continue", zap.Int64("reencrypted", progress.Reencrypted), zap.Error(err))
+	}
+	logger.Info("Re-encrypted table", zap.Int64("scanned", progress.Scanned), zap.Int64("reencrypted", progress.Reencrypted),
+		zap.Int64("skipped", progress.Skipped))
+}
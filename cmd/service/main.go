@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+
 	"github.mathworks.com/development/mito/pkg/config" // used for setting the configuration override (configfiles.AppDefault)
 	"github.mathworks.com/development/mitoapp/pkg/debug"
 	"github.mathworks.com/development/mitoapp/pkg/host"       // dependency injection and application hosting (lifecycle)
 	"github.mathworks.com/development/mitoapp/pkg/webservice" // used for configuring all the mito dependencies
 	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/collaborators"
 	"github.mathworks.com/development/signindatatrackerws/pkg/controllers"
+	"github.mathworks.com/development/signindatatrackerws/pkg/export"
 	"github.mathworks.com/development/signindatatrackerws/pkg/filters"
+	"github.mathworks.com/development/signindatatrackerws/pkg/metrics"
+	"github.mathworks.com/development/signindatatrackerws/pkg/tracing"
+	"github.mathworks.com/development/signindatatrackerws/pkg/webhooks"
 	"go.uber.org/zap"
 )
 
@@ -17,7 +28,15 @@ type App struct {
 	PersistSignInDataController *controllers.PersistSignInDataController
 	GetSignInDataController     *controllers.RetrieveSignInDataController
 	HealthController            *controllers.HealthController
+	WebhookAdminController      *controllers.WebhookAdminController
+	StatsController             *controllers.StatsController
+	SearchController            *controllers.SearchController
+	ExportAdminController       *controllers.ExportAdminController
+	CacheAdminController        *controllers.CacheAdminController
+	MetricsController           *controllers.MetricsController
+	AuditAdminController        *controllers.AuditAdminController
 	Filters                     *filters.AKFilter
+	RateLimitFilter             *filters.RateLimitFilter
 	DebugMessageClient          *debug.MessageClient
 }
 
@@ -27,30 +46,63 @@ var factories = []interface{}{
 	controllers.PersistSignInControllerFactory,
 	controllers.RetrieveSignInControllerFactory,
 	controllers.HealthControllerFactory,
+	controllers.WebhookAdminControllerFactory,
+	controllers.StatsControllerFactory,
+	controllers.SearchControllerFactory,
+	controllers.ExportAdminControllerFactory,
+	controllers.CacheAdminControllerFactory,
+	controllers.MetricsControllerFactory,
+	controllers.AuditAdminControllerFactory,
 	filters.NewAKFilter,
+	filters.NewRateLimitFilter,
 }
 
 var app *App
 
+// appDefaults are the mito defaults, the signindatatracker keys are also the lowest layer of
+// the application configuration. The DynamoDB endpoint, table, region and environment have no
+// default: a deployment that does not set them must fail, not talk to DynamoDB Local.
+var appDefaults = config.AppDefault{
+	"mito.http.contextroot":                    "/signindatatrackerws",
+	"mito.config.env.filter":                   "^signinartifacts.*",
+	"mito.http.headerstocontext":               `user-agent|userAgent,X-Forwarded-For|xForwardedFor,Accept-Language|acceptLanguage,X-MW-Caller-Id|xMWCallerId`,
+	"mito.http.truststore.validatecertificate": "false",
+	"signindatatracker.dynamo.ttlinyears":      "4",
+	"mito.debug":                               "false",
+}
+
 func main() {
+	// -set key=value overrides a configuration key and may be repeated
+	configFlags, err := bootstrap.ParseConfigFlags(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Invalid command line: %v", err)
+	}
 
-	configuration := webservice.Defaults(config.AppDefault{
-		"mito.http.contextroot":                    "/signindatatrackerws",
-		"mito.config.env.filter":                   "^signinartifacts.*",
-		"mito.http.headerstocontext":               `user-agent|userAgent,X-Forwarded-For|xForwardedFor,Accept-Language|acceptLanguage,X-MW-Caller-Id|xMWCallerId`,
-		"mito.http.truststore.validatecertificate": "false",
-		"signindatatracker.dynamo.endpoint":        "http://localhost:8000",
-		"signindatatracker.dynamo.region":          "localhost",
-		"signindatatracker.dynamo.tablename":       "signintracker",
-		"signindatatracker.dynamo.ttlinyears":      "4",
-		"signindatatracker.dynamo.env":             "local",
-		"mito.debug":                               "false",
-	})
+	configuration := webservice.Defaults(appDefaults)
 
 	// instantiate an empty app struct to be filled in
 	app = new(App)
 	//Bootstrap App
-	bootstrap.BuildApplicationContext(zap.L().Named("signindatatrackerws.app.context"), "")
+	appContext := bootstrap.BuildApplicationContextFrom(zap.L().Named("signindatatrackerws.app.context"), "", bootstrap.ConfigSources{
+		Defaults:     appDefaults,
+		Flags:        configFlags,
+		RequiredKeys: bootstrap.ServiceRequiredKeys,
+	})
+	stopWatching := make(chan struct{})
+	defer close(stopWatching)
+	appContext.WatchConfig(zap.L().Named("signindatatrackerws.app.config"), stopWatching)
+	tracingConfig := appContext.AppConfigData.Tracing
+	shutdownTracing, err := tracing.Init(tracing.Config{
+		Exporter:     tracingConfig.Exporter,
+		OTLPEndpoint: tracingConfig.OTLPEndpoint,
+		Insecure:     tracingConfig.OTLPInsecure,
+		ServiceName:  "signindatatrackerws",
+		SampleRatio:  tracingConfig.SampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	metrics.LimitSources(strings.Split(appContext.AppConfigData.Metrics.Sources, ","))
 
 	// recursively construct the app using the provided constructor functions
 	host.Start("signindatatrackerws", app,
@@ -60,4 +112,16 @@ func main() {
 
 		debug.Constructors,
 	)
+
+	if err := collaborators.ShutdownIngestion(); err != nil {
+		zap.L().Warn("Ingestion queue was not drained, remaining records are replayed on start", zap.Error(err))
+	}
+	collaborators.FlushActiveUsers()
+	webhooks.ShutdownDispatcher()
+	export.ShutdownManager()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		zap.L().Warn("Could not flush pending spans", zap.Error(err))
+	}
 }
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/stream"
+	"go.uber.org/zap"
+)
+
+// streamconsumer tails the sign-in table's DynamoDB Stream and fans new records out to
+// the configured sinks, keeping analytics off the request path.
+func main() {
+	overrides := flag.String("overrides", "", "location of the overrides.properties file")
+	streamArn := flag.String("stream-arn", "", "stream to read, defaults to the latest stream of the table")
+	tableName := flag.String("table", "signindatatracker", "table whose latest stream is read when -stream-arn is empty")
+	checkpointFile := flag.String("checkpoint-file", "data/streamconsumer/checkpoints.json", "where shard positions are stored")
+	sinkNames := flag.String("sinks", "ndjson", "comma separated sinks: ndjson, webhook, aggregator")
+	ndjsonFile := flag.String("ndjson-file", "", "NDJSON output file, stdout when empty")
+	webhookUrl := flag.String("webhook-url", "", "endpoint for the webhook sink")
+	webhookSecret := flag.String("webhook-secret", "", "HMAC secret for the webhook sink")
+	aggregateFile := flag.String("aggregate-file", "data/streamconsumer/aggregates.json", "snapshot file of the aggregator sink")
+	pollInterval := flag.Duration("poll-interval", time.Second, "delay between stream polls")
+	flag.Parse()
+
+	logger, err := zap.NewProduction()
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+	zap.ReplaceGlobals(logger)
+
+	appContext := bootstrap.BuildApplicationContext(logger.Named("signindatatrackerws.streamconsumer.context"), *overrides)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	arn := *streamArn
+	if arn == "" {
+		arn, err = latestStreamArn(ctx, appContext, *tableName)
+		if err != nil {
+			logger.Fatal("Failed to resolve the table stream", zap.Error(err))
+		}
+	}
+
+	sinks, closers, err := buildSinks(strings.Split(*sinkNames, ","), *ndjsonFile, *webhookUrl, *webhookSecret, *aggregateFile)
+	if err != nil {
+		logger.Fatal("Failed to initialize sinks", zap.Error(err))
+	}
+	defer func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}()
+
+	checkpoints, err := stream.NewFileCheckpointStore(*checkpointFile)
+	if err != nil {
+		logger.Fatal("Failed to load checkpoints", zap.Error(err))
+	}
+	client, err := appContext.GetStreams()
+	if err != nil {
+		logger.Fatal("Failed to initialize DynamoDB Streams client", zap.Error(err))
+	}
+
+	var decryptor stream.ItemDecryptor
+	encryptor, err := appContext.FieldEncryptor()
+	if err != nil {
+		logger.Fatal("Failed to set up field decryption", zap.Error(err))
+	}
+	if encryptor != nil {
+		decryptor = encryptor
+	}
+
+	logger.Info("Starting stream consumer", zap.String("streamArn", arn), zap.String("sinks", *sinkNames))
+	consumer := stream.NewConsumer(client, arn, checkpoints, sinks, *pollInterval, decryptor, logger.Named("consumer"))
+	if err := consumer.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		logger.Fatal("Stream consumer stopped", zap.Error(err))
+	}
+}
+
+func latestStreamArn(ctx context.Context, appContext *bootstrap.ApplicationContext, tableName string) (string, error) {
+	db, err := appContext.GetDB()
+	if err != nil {
+		return "", err
+	}
+	out, err := db.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+	if err != nil {
+		return "", err
+	}
+	if out.Table.LatestStreamArn == nil {
+		return "", errors.New("streams are not enabled on table " + tableName)
+	}
+	return aws.ToString(out.Table.LatestStreamArn), nil
+}
+
+func buildSinks(names []string, ndjsonFile, webhookUrl, webhookSecret, aggregateFile string) ([]stream.Sink, []*os.File, error) {
+	var sinks []stream.Sink
+	var closers []*os.File
+	for _, name := range names {
+		switch strings.TrimSpace(name) {
+		case "ndjson":
+			if ndjsonFile == "" {
+				sinks = append(sinks, stream.NewNDJSONSink(os.Stdout))
+				continue
+			}
+			sink, file, err := stream.NewNDJSONFileSink(ndjsonFile)
+			if err != nil {
+				return nil, closers, err
+			}
+			sinks = append(sinks, sink)
+			closers = append(closers, file)
+		case "webhook":
+			if webhookUrl == "" {
+				return nil, closers, errors.New("-webhook-url is required for the webhook sink")
+			}
+			sinks = append(sinks, stream.NewWebhookSink(webhookUrl, webhookSecret, 10*time.Second))
+		case "aggregator":
+			sink, err := stream.NewAggregatorSink(aggregateFile)
+			if err != nil {
+				return nil, closers, err
+			}
+			sinks = append(sinks, sink)
+		default:
+			return nil, closers, errors.New("unknown sink " + name)
+		}
+	}
+	return sinks, closers, nil
+}
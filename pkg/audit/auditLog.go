@@ -0,0 +1,342 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	ActionRead   = "read"
+	ActionSearch = "search"
+	ActionStats  = "stats"
+	ActionExport = "export"
+
+	logFile = "audit.log"
+	// tornSuffix names the file an unfinished last line is moved to when the log is opened
+	tornSuffix = ".torn"
+	// headExportEvery is how many entries are appended between two exports of the head to the service log,
+	// the exported heads show entries removed from the end of the file
+	headExportEvery = 100
+)
+
+// genesisHash is the PrevHash of the first entry.
+var genesisHash = strings.Repeat("0", sha256.Size*2)
+
+var (
+	auditLogOnce sync.Once
+	auditLog     *FileLog
+)
+
+// LogFactory returns the process wide audit log, nil when auditing is disabled.
+func LogFactory() *FileLog {
+	auditLogOnce.Do(func() {
+		config := bootstrap.GetApplicationContext().AppConfigData.Audit
+		if !config.Enabled {
+			return
+		}
+		var key []byte
+		if config.ChainSecret != "" {
+			key = []byte(config.ChainSecret)
+		}
+		l, err := OpenFileLog(filepath.Join(config.Directory, logFile), key)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		if key == nil {
+			l.logger.Warn("Audit log hashes are not keyed, set app.signindatatracker.audit.chainsecret to keep the chain from being rewritten")
+		}
+		if l.tornTail > 0 {
+			l.logger.Warn("Audit log ended in an unfinished entry, it was moved aside",
+				zap.Int64("bytes", l.tornTail), zap.String("location", l.location+tornSuffix))
+		}
+		if verification := l.Verify(); !verification.Valid {
+			// keep serving, the break stays visible through the admin endpoint
+			l.logger.Error("Audit log hash chain is broken",
+				zap.Uint64("brokenAt", verification.BrokenAt), zap.String("error", verification.Error))
+		}
+		auditLog = l
+	})
+	return auditLog
+}
+
+type Query struct {
+	UniqueId string
+	CallerId string
+	Limit    int
+}
+
+// FileLog appends entries to a single JSON lines file. Every entry carries the hash of the one
+// before it, so editing or removing a line breaks the chain from that line on. With a key the hashes
+// are HMACs and cannot be recomputed by whoever rewrites the file, the head is exported to the service
+// log every headExportEvery entries so removing entries from the end shows too.
+type FileLog struct {
+	mu       sync.Mutex
+	location string
+	file     *os.File
+	// size is the length of the complete entries, readers stop there and never see a line being appended
+	size     int64
+	lastSeq  uint64
+	lastHash string
+	// key keys the entry hashes, nil hashes them with plain SHA-256
+	key []byte
+	// tornTail is the length of the unfinished last line moved aside by OpenFileLog
+	tornTail int64
+	logger   *zap.Logger
+	now      func() time.Time
+}
+
+// OpenFileLog opens the log at location, key is nil for an unkeyed chain. An unfinished last line,
+// left by a write that never returned, is moved to location+tornSuffix instead of failing the open.
+func OpenFileLog(location string, key []byte) (*FileLog, error) {
+	if err := os.MkdirAll(filepath.Dir(location), 0750); err != nil {
+		return nil, err
+	}
+	l := &FileLog{location: location, lastHash: genesisHash, key: key, logger: zap.L().Named("signindatatrackerws.audit"), now: time.Now}
+	size, torn, err := trimTornTail(location)
+	if err != nil {
+		return nil, err
+	}
+	l.size, l.tornTail = size, torn
+	// unreadable lines are left where they are for Verify to report, the chain continues from the
+	// last entry that could be read so the log stays open for appends
+	err = l.scan(size, func(line int, entry domain.AuditEntry, unreadable error) bool {
+		if unreadable != nil {
+			l.logger.Warn("Skipping unreadable audit entry", zap.Int("line", line), zap.Error(unreadable))
+			return true
+		}
+		l.lastSeq, l.lastHash = entry.Seq, entry.Hash
+		return true
+	})
+	if err != nil {
+		l.logger.Error("Audit log could not be read to the end, appending after the last entry read",
+			zap.Uint64("seq", l.lastSeq), zap.Error(err))
+	}
+	file, err := os.OpenFile(location, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l.file = file
+	l.exportHead()
+	return l, nil
+}
+
+// Append fills in the sequence, time and hashes of entry and syncs it to disk before returning.
+func (l *FileLog) Append(entry domain.AuditEntry) (domain.AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry.Seq = l.lastSeq + 1
+	entry.Time = l.now().UTC().Format(time.RFC3339Nano)
+	entry.PrevHash = l.lastHash
+	hash, err := l.hashEntry(entry)
+	if err != nil {
+		return entry, err
+	}
+	entry.Hash = hash
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return entry, err
+	}
+	line = append(line, '\n')
+	if _, err := l.file.Write(line); err != nil {
+		// drop what made it to the file so the next entry does not land on a partial line
+		_ = l.file.Truncate(l.size)
+		return entry, err
+	}
+	if err := l.file.Sync(); err != nil {
+		_ = l.file.Truncate(l.size)
+		return entry, err
+	}
+	l.size += int64(len(line))
+	l.lastSeq, l.lastHash = entry.Seq, entry.Hash
+	if entry.Seq%headExportEvery == 0 {
+		l.exportHead()
+	}
+	return entry, nil
+}
+
+// Query returns the newest entries matching every set field of query, newest first.
+func (l *FileLog) Query(query Query) ([]domain.AuditEntry, error) {
+	var matches []domain.AuditEntry
+	err := l.scan(l.completeSize(), func(_ int, entry domain.AuditEntry, unreadable error) bool {
+		if unreadable != nil {
+			return true
+		}
+		if (query.UniqueId == "" || entry.UniqueId == query.UniqueId) && (query.CallerId == "" || entry.CallerId == query.CallerId) {
+			matches = append(matches, entry)
+			if query.Limit > 0 && len(matches) > query.Limit {
+				matches = matches[1:]
+			}
+		}
+		return true
+	})
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches, err
+}
+
+// Verify walks the whole chain and reports the first entry whose hash or link does not match.
+func (l *FileLog) Verify() domain.AuditVerification {
+	verification := domain.AuditVerification{Valid: true, TornTail: l.tornTail}
+	prevHash, prevSeq := genesisHash, uint64(0)
+	err := l.scan(l.completeSize(), func(line int, entry domain.AuditEntry, unreadable error) bool {
+		verification.Entries++
+		hash, err := l.hashEntry(entry)
+		switch {
+		case unreadable != nil:
+			verification.Error = fmt.Sprintf("line %d is unreadable: %v", line, unreadable)
+		case err != nil:
+			verification.Error = err.Error()
+		case entry.Seq != prevSeq+1:
+			verification.Error = fmt.Sprintf("entry %d follows entry %d", entry.Seq, prevSeq)
+		case entry.PrevHash != prevHash:
+			verification.Error = fmt.Sprintf("entry %d does not link to the entry before it", entry.Seq)
+		case entry.Hash != hash:
+			verification.Error = fmt.Sprintf("entry %d does not match its hash", entry.Seq)
+		default:
+			prevHash, prevSeq = entry.Hash, entry.Seq
+			return true
+		}
+		verification.Valid, verification.BrokenAt = false, prevSeq+1
+		return false
+	})
+	if err != nil {
+		verification.Valid, verification.BrokenAt, verification.Error = false, prevSeq+1, err.Error()
+	}
+	return verification
+}
+
+func (l *FileLog) Close() error {
+	return l.file.Close()
+}
+
+// completeSize is the length of the entries appended so far, it is all the lock is taken for so reading
+// the file does not hold up Append.
+func (l *FileLog) completeSize() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size
+}
+
+// exportHead writes the sequence and hash of the last entry to the service log.
+func (l *FileLog) exportHead() {
+	l.logger.Info("Audit log head", zap.Uint64("seq", l.lastSeq), zap.String("hash", l.lastHash))
+}
+
+// scan reads the first size bytes of the file, visit returns false to stop early. A line that does not
+// parse is passed to visit with its error instead of ending the scan.
+func (l *FileLog) scan(size int64, visit func(line int, entry domain.AuditEntry, unreadable error) bool) error {
+	file, err := os.Open(l.location)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(io.LimitReader(file, size))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry domain.AuditEntry
+		unreadable := json.Unmarshal(scanner.Bytes(), &entry)
+		if unreadable != nil {
+			entry = domain.AuditEntry{}
+		}
+		if !visit(line, entry, unreadable) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// hashEntry hashes the entry without its own hash, PrevHash is part of the input.
+func (l *FileLog) hashEntry(entry domain.AuditEntry) (string, error) {
+	entry.Hash = ""
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	var digest hash.Hash
+	if l.key != nil {
+		digest = hmac.New(sha256.New, l.key)
+	} else {
+		digest = sha256.New()
+	}
+	digest.Write(content)
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// trimTornTail moves anything after the last newline of the file to location+tornSuffix. It returns the
+// length of the complete lines and of the part moved aside.
+func trimTornTail(location string) (size int64, torn int64, err error) {
+	file, err := os.OpenFile(location, os.O_RDWR, 0600)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	// walk back from the end a chunk at a time until a newline turns up
+	chunk := make([]byte, 64*1024)
+	end := info.Size()
+	size = 0
+	for offset := end; offset > 0 && size == 0; {
+		length := int64(len(chunk))
+		if offset < length {
+			length = offset
+		}
+		offset -= length
+		if _, err := file.ReadAt(chunk[:length], offset); err != nil {
+			return 0, 0, err
+		}
+		for i := length - 1; i >= 0; i-- {
+			if chunk[i] == '\n' {
+				size = offset + i + 1
+				break
+			}
+		}
+	}
+	if size == end {
+		return size, 0, nil
+	}
+	tail := make([]byte, end-size)
+	if _, err := file.ReadAt(tail, size); err != nil {
+		return 0, 0, err
+	}
+	aside, err := os.OpenFile(location+tornSuffix, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer aside.Close()
+	if _, err := aside.Write(append(tail, '\n')); err != nil {
+		return 0, 0, err
+	}
+	if err := aside.Sync(); err != nil {
+		return 0, 0, err
+	}
+	if err := file.Truncate(size); err != nil {
+		return 0, 0, err
+	}
+	return size, end - size, nil
+}
@@ -0,0 +1,157 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+func openTestLog(t *testing.T, location string) *FileLog {
+	return openKeyedTestLog(t, location, nil)
+}
+
+func openKeyedTestLog(t *testing.T, location string, key []byte) *FileLog {
+	l, err := OpenFileLog(location, key)
+	assert.Nil(t, err)
+	l.now = func() time.Time { return time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC) }
+	return l
+}
+
+func TestAppendChainsEntriesAcrossRestarts(t *testing.T) {
+	location := filepath.Join(t.TempDir(), logFile)
+	l := openTestLog(t, location)
+	first, err := l.Append(domain.AuditEntry{CallerId: "MWA", Action: ActionRead, Route: "/v1/lastSignIn", UniqueId: "MWA-1", ResultCount: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), first.Seq)
+	assert.Equal(t, genesisHash, first.PrevHash)
+	assert.Nil(t, l.Close())
+
+	l = openTestLog(t, location)
+	second, err := l.Append(domain.AuditEntry{CallerId: "PAT", Action: ActionRead, Route: "/v1/getSignInDetails", UniqueId: "MWA-1", ResultCount: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), second.Seq)
+	assert.Equal(t, first.Hash, second.PrevHash)
+	_, err = l.Append(domain.AuditEntry{CallerId: "PAT", Action: ActionRead, Route: "/v1/lastSignIn", UniqueId: "PAT-9"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, domain.AuditVerification{Entries: 3, Valid: true}, l.Verify())
+
+	byProfile, err := l.Query(Query{UniqueId: "MWA-1", Limit: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{2, 1}, []uint64{byProfile[0].Seq, byProfile[1].Seq})
+	byCaller, err := l.Query(Query{CallerId: "PAT", Limit: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(byCaller))
+	assert.Equal(t, "PAT-9", byCaller[0].UniqueId)
+}
+
+func TestVerifyFindsEditedAndRemovedEntries(t *testing.T) {
+	location := filepath.Join(t.TempDir(), logFile)
+	l := openTestLog(t, location)
+	for _, uniqueId := range []string{"MWA-1", "MWA-2", "MWA-3"} {
+		_, err := l.Append(domain.AuditEntry{CallerId: "MWA", Action: ActionRead, UniqueId: uniqueId, ResultCount: 2})
+		assert.Nil(t, err)
+	}
+	content, err := os.ReadFile(location)
+	assert.Nil(t, err)
+	lines := strings.SplitAfter(string(content), "\n")
+
+	edited := strings.Replace(string(content), `"uniqueId":"MWA-2","resultCount":2`, `"uniqueId":"MWA-2","resultCount":0`, 1)
+	assert.Nil(t, os.WriteFile(location, []byte(edited), 0600))
+	verification := l.Verify()
+	assert.False(t, verification.Valid)
+	assert.Equal(t, uint64(2), verification.BrokenAt)
+
+	assert.Nil(t, os.WriteFile(location, []byte(lines[0]+lines[2]), 0600))
+	verification = l.Verify()
+	assert.False(t, verification.Valid)
+	assert.Equal(t, uint64(2), verification.BrokenAt)
+	assert.Equal(t, "entry 3 follows entry 1", verification.Error)
+}
+
+func TestOpenSetsAsideUnfinishedLastEntry(t *testing.T) {
+	location := filepath.Join(t.TempDir(), logFile)
+	l := openTestLog(t, location)
+	for _, uniqueId := range []string{"MWA-1", "MWA-2"} {
+		_, err := l.Append(domain.AuditEntry{CallerId: "MWA", Action: ActionRead, UniqueId: uniqueId})
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, l.Close())
+	file, err := os.OpenFile(location, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.Nil(t, err)
+	_, err = file.WriteString(`{"seq":3,"time":"2026-10`)
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	l = openTestLog(t, location)
+	third, err := l.Append(domain.AuditEntry{CallerId: "MWA", Action: ActionRead, UniqueId: "MWA-3"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), third.Seq)
+	assert.Equal(t, domain.AuditVerification{Entries: 3, Valid: true, TornTail: 24}, l.Verify())
+	torn, err := os.ReadFile(location + tornSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"seq\":3,\"time\":\"2026-10\n", string(torn))
+}
+
+func TestOpenSkipsUnreadableEntryAndVerifyReportsIt(t *testing.T) {
+	location := filepath.Join(t.TempDir(), logFile)
+	l := openTestLog(t, location)
+	for _, uniqueId := range []string{"MWA-1", "MWA-2"} {
+		_, err := l.Append(domain.AuditEntry{CallerId: "MWA", Action: ActionRead, UniqueId: uniqueId})
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, l.Close())
+	content, err := os.ReadFile(location)
+	assert.Nil(t, err)
+	lines := strings.SplitAfter(string(content), "\n")
+	assert.Nil(t, os.WriteFile(location, []byte(lines[0]+"not an entry\n"), 0600))
+
+	l = openTestLog(t, location)
+	next, err := l.Append(domain.AuditEntry{CallerId: "MWA", Action: ActionRead, UniqueId: "MWA-3"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), next.Seq)
+	verification := l.Verify()
+	assert.False(t, verification.Valid)
+	assert.Equal(t, uint64(2), verification.BrokenAt)
+	assert.True(t, strings.HasPrefix(verification.Error, "line 2 is unreadable"))
+	matches, err := l.Query(Query{CallerId: "MWA"})
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{2, 1}, []uint64{matches[0].Seq, matches[1].Seq})
+}
+
+func TestKeyedChainDoesNotVerifyWithoutTheKey(t *testing.T) {
+	location := filepath.Join(t.TempDir(), logFile)
+	l := openKeyedTestLog(t, location, []byte("secret"))
+	for _, uniqueId := range []string{"MWA-1", "MWA-2"} {
+		_, err := l.Append(domain.AuditEntry{CallerId: "MWA", Action: ActionRead, UniqueId: uniqueId})
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, domain.AuditVerification{Entries: 2, Valid: true}, l.Verify())
+	assert.Nil(t, l.Close())
+
+	// a chain rebuilt without the key does not pass for the keyed one
+	l = openTestLog(t, location)
+	verification := l.Verify()
+	assert.False(t, verification.Valid)
+	assert.Equal(t, uint64(1), verification.BrokenAt)
+}
+
+func TestQueryReadsOnlyCompleteEntries(t *testing.T) {
+	location := filepath.Join(t.TempDir(), logFile)
+	l := openTestLog(t, location)
+	_, err := l.Append(domain.AuditEntry{CallerId: "MWA", Action: ActionRead, UniqueId: "MWA-1"})
+	assert.Nil(t, err)
+
+	// a line still being written by Append, readers no longer take the lock for the whole scan
+	_, err = l.file.WriteString(`{"seq":2,"callerId":"MW`)
+	assert.Nil(t, err)
+	matches, err := l.Query(Query{CallerId: "MWA"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(matches))
+	assert.True(t, l.Verify().Valid)
+}
@@ -0,0 +1,191 @@
+package authz
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	TokenHeader    = "Authorization"
+	CallerIdHeader = "X-MW-Caller-Id"
+	// AnonymousCallerId names callers whose key carries no verifiable subject
+	AnonymousCallerId = "anonymous"
+)
+
+var ErrInvalidToken = errors.New("access key is not a signed RS256 token")
+
+type accessKeyHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type accessKeyClaims struct {
+	Subject string          `json:"sub"`
+	Scope   json.RawMessage `json:"scope"`
+}
+
+// ClaimsResolver turns an access key that the access key filter already accepted into a Caller.
+// The caller is the token's subject. Scopes come from the token's scope claim; keys issued
+// without one fall back to the grants configured for the subject. The X-MW-Caller-Id header is
+// not signed, anyone can set it, so it never decides who the caller is.
+type ClaimsResolver struct {
+	keys   *AccessKeyring
+	grants map[string][]string
+}
+
+func NewClaimsResolver(publicKey string, grants map[string][]string) (*ClaimsResolver, error) {
+	if _, err := ParsePublicKey(publicKey); err != nil {
+		return nil, err
+	}
+	return NewKeyringClaimsResolver(NewAccessKeyring(map[string]string{DefaultKeyId: publicKey}), grants), nil
+}
+
+// NewKeyringClaimsResolver verifies scope claims with the keys active in keys at the time of the
+// request, a reload of the ring applies at once.
+func NewKeyringClaimsResolver(keys *AccessKeyring, grants map[string][]string) *ClaimsResolver {
+	return &ClaimsResolver{keys: keys, grants: grants}
+}
+
+// NewGrantsResolver resolves callers from the configured grants only, for deployments whose
+// access key public key cannot be parsed here.
+func NewGrantsResolver(grants map[string][]string) *ClaimsResolver {
+	return &ClaimsResolver{grants: grants}
+}
+
+// Resolve never fails the request itself, a caller without scopes is refused by the routes.
+// A token whose claims cannot be verified here resolves to a caller without identity or scopes.
+func (cr *ClaimsResolver) Resolve(request *http.Request) Caller {
+	claims, err := cr.verify(BearerToken(request))
+	if err != nil || claims.Subject == "" {
+		return Caller{}
+	}
+	caller := Caller{Id: claims.Subject, Subject: claims.Subject, Scopes: parseScopeClaim(claims.Scope)}
+	if len(caller.Scopes) == 0 {
+		caller.Scopes = cr.grants[caller.Id]
+	}
+	return caller
+}
+
+func (cr *ClaimsResolver) verify(token string) (accessKeyClaims, error) {
+	var claims accessKeyClaims
+	if cr.keys == nil {
+		return claims, ErrInvalidToken
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, ErrInvalidToken
+	}
+	var header accessKeyHeader
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "RS256" {
+		return claims, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, ErrInvalidToken
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	verified := false
+	for _, key := range cr.keys.RSAKeys(header.Kid) {
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return claims, ErrInvalidToken
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return claims, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// ParsePublicKey accepts the access key public key either PEM armored or as bare base64 DER.
+func ParsePublicKey(publicKey string) (*rsa.PublicKey, error) {
+	der := []byte(publicKey)
+	if block, _ := pem.Decode([]byte(publicKey)); block != nil {
+		der = block.Bytes
+	} else if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey)); err == nil {
+		der = decoded
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse access key public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("access key public key is %T, not RSA", key)
+	}
+	return rsaKey, nil
+}
+
+// ParseGrants reads the per caller id fallback grants, written as
+// "<callerId>=<scope>,<scope>;<callerId>=<scope>". For access keys the caller id is the token's
+// subject.
+func ParseGrants(value string) map[string][]string {
+	grants := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		callerId, scopes, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found || callerId == "" {
+			continue
+		}
+		for _, scope := range strings.Split(scopes, ",") {
+			if scope = strings.TrimSpace(scope); scope != "" {
+				grants[callerId] = append(grants[callerId], scope)
+			}
+		}
+	}
+	return grants
+}
+
+// BearerToken returns the token of the Authorization header, with or without the Bearer prefix.
+func BearerToken(request *http.Request) string {
+	token := strings.TrimSpace(request.Header.Get(TokenHeader))
+	if len(token) > 7 && strings.EqualFold(token[:7], "Bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+	return token
+}
+
+// TokenKeyId returns the kid of the access key's header, empty for keys issued without one.
+func TokenKeyId(token string) string {
+	segment, _, _ := strings.Cut(token, ".")
+	var header accessKeyHeader
+	if decodeSegment(segment, &header) != nil {
+		return ""
+	}
+	return header.Kid
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, target)
+}
+
+// parseScopeClaim accepts the OAuth space separated string as well as a JSON array.
+func parseScopeClaim(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+	var joined string
+	if err := json.Unmarshal(raw, &joined); err == nil {
+		return strings.Fields(joined)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package authz
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultKeyId is the key id of app.signindatatracker.ak.public.
+const DefaultKeyId = "default"
+
+// KeyringChange lists the key ids a Set added, removed or replaced. Invalid keys are kept for
+// the access key filter but cannot be used to read scope claims.
+type KeyringChange struct {
+	Added    []string
+	Removed  []string
+	Replaced []string
+	Invalid  []string
+}
+
+func (c KeyringChange) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Replaced) == 0
+}
+
+// AccessKeyring holds the access key public keys that are active, by key id. During a rotation
+// the old and the new key are both in it.
+type AccessKeyring struct {
+	mu     sync.RWMutex
+	keys   map[string]string
+	parsed map[string]*rsa.PublicKey
+}
+
+func NewAccessKeyring(keys map[string]string) *AccessKeyring {
+	ring := &AccessKeyring{}
+	ring.Set(keys)
+	return ring
+}
+
+// Set replaces the active keys.
+func (kr *AccessKeyring) Set(keys map[string]string) KeyringChange {
+	var change KeyringChange
+	parsed := make(map[string]*rsa.PublicKey, len(keys))
+	for kid, key := range keys {
+		rsaKey, err := ParsePublicKey(key)
+		if err != nil {
+			change.Invalid = append(change.Invalid, kid)
+		} else {
+			parsed[kid] = rsaKey
+		}
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	for kid, key := range keys {
+		old, ok := kr.keys[kid]
+		if !ok {
+			change.Added = append(change.Added, kid)
+		} else if old != key {
+			change.Replaced = append(change.Replaced, kid)
+		}
+	}
+	for kid := range kr.keys {
+		if _, ok := keys[kid]; !ok {
+			change.Removed = append(change.Removed, kid)
+		}
+	}
+	kr.keys, kr.parsed = keys, parsed
+	for _, list := range [][]string{change.Added, change.Removed, change.Replaced, change.Invalid} {
+		sort.Strings(list)
+	}
+	return change
+}
+
+// Key returns the public key kid as it was configured.
+func (kr *AccessKeyring) Key(kid string) (string, bool) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	key, ok := kr.keys[kid]
+	return key, ok
+}
+
+// Ids returns the active key ids in order.
+func (kr *AccessKeyring) Ids() []string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	ids := make([]string, 0, len(kr.keys))
+	for kid := range kr.keys {
+		ids = append(ids, kid)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// RSAKeys returns the key kid, or every active key when the token names no kid or one the ring
+// does not know.
+func (kr *AccessKeyring) RSAKeys(kid string) []*rsa.PublicKey {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	if key, ok := kr.parsed[kid]; ok {
+		return []*rsa.PublicKey{key}
+	}
+	if _, ok := kr.keys[kid]; ok {
+		return nil
+	}
+	keys := make([]*rsa.PublicKey, 0, len(kr.parsed))
+	for _, key := range kr.parsed {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// ParseKeyList reads public keys written as "kid=key;kid=key". Base64 keys may end with =, only
+// the first one separates the kid.
+func ParseKeyList(value string) map[string]string {
+	keys := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		kid, key, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if found && strings.TrimSpace(kid) != "" && strings.TrimSpace(key) != "" {
+			keys[strings.TrimSpace(kid)] = strings.TrimSpace(key)
+		}
+	}
+	return keys
+}
+
+// LoadKeyDirectory reads one public key per <kid>.pem or <kid>.pub file of directory.
+func LoadKeyDirectory(directory string) (map[string]string, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("read access key directory: %w", err)
+	}
+	keys := make(map[string]string)
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".pem" && ext != ".pub") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(directory, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read access key %s: %w", entry.Name(), err)
+		}
+		keys[strings.TrimSuffix(entry.Name(), ext)] = strings.TrimSpace(string(content))
+	}
+	return keys, nil
+}
+
+// KeyringWatcher reloads an AccessKeyring with load on an interval. A load that fails or finds
+// no key at all keeps the active keys, a half written file must not lock every caller out.
+type KeyringWatcher struct {
+	ring     *AccessKeyring
+	load     func() (map[string]string, error)
+	interval time.Duration
+	logger   *zap.Logger
+}
+
+func NewKeyringWatcher(ring *AccessKeyring, load func() (map[string]string, error), interval time.Duration, logger *zap.Logger) *KeyringWatcher {
+	return &KeyringWatcher{ring: ring, load: load, interval: interval, logger: logger}
+}
+
+// Start reloads the keys in the background until stop is closed.
+func (w *KeyringWatcher) Start(stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				w.Reload()
+			}
+		}
+	}()
+}
+
+// Reload loads the keys once and logs what changed.
+func (w *KeyringWatcher) Reload() {
+	keys, err := w.load()
+	if err != nil {
+		w.logger.Error("Access key public keys could not be reloaded, the active keys are kept", zap.Error(err))
+		return
+	}
+	if len(keys) == 0 {
+		w.logger.Error("Access key public key reload found no keys, the active keys are kept")
+		return
+	}
+	change := w.ring.Set(keys)
+	if change.Empty() {
+		return
+	}
+	w.logger.Info("Access key public keys refreshed",
+		zap.Strings("added", change.Added), zap.Strings("removed", change.Removed),
+		zap.Strings("replaced", change.Replaced), zap.Strings("active", w.ring.Ids()))
+	if len(change.Invalid) > 0 {
+		w.logger.Warn("Access key public keys cannot be parsed, their scope claims are ignored", zap.Strings("kids", change.Invalid))
+	}
+}
@@ -0,0 +1,107 @@
+package authz
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func signTokenWithKid(t *testing.T, kid string, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.Nil(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestKeyringReportsRotations(t *testing.T) {
+	_, oldKey := generateKey(t)
+	_, newKey := generateKey(t)
+	ring := NewAccessKeyring(map[string]string{"2025": oldKey})
+
+	change := ring.Set(map[string]string{"2025": oldKey, "2026": newKey, "broken": "not a key"})
+	assert.Equal(t, []string{"2026", "broken"}, change.Added)
+	assert.Equal(t, []string{"broken"}, change.Invalid)
+	assert.Equal(t, []string{"2025", "2026", "broken"}, ring.Ids())
+	assert.Len(t, ring.RSAKeys("2026"), 1)
+	assert.Len(t, ring.RSAKeys(""), 2)
+	assert.Empty(t, ring.RSAKeys("broken"))
+
+	change = ring.Set(map[string]string{"2026": oldKey})
+	assert.Equal(t, []string{"2025", "broken"}, change.Removed)
+	assert.Equal(t, []string{"2026"}, change.Replaced)
+	_, ok := ring.Key("2025")
+	assert.False(t, ok)
+}
+
+func TestResolveVerifiesScopesWithTheTokensKey(t *testing.T) {
+	oldKey, oldPublic := generateKey(t)
+	newKey, newPublic := generateKey(t)
+	ring := NewAccessKeyring(map[string]string{"old": oldPublic, "new": newPublic})
+	resolver := NewKeyringClaimsResolver(ring, map[string][]string{"MWA-1": {ScopeReadSelf}})
+	claims := map[string]interface{}{"sub": "MWA-1", "scope": ScopeReadAny}
+
+	request := httptest.NewRequest("GET", "/v1/lastSignIn", nil)
+	request.Header.Set(CallerIdHeader, "MWA")
+	for _, token := range []string{signTokenWithKid(t, "new", newKey, claims), signToken(t, oldKey, claims)} {
+		request.Header.Set(TokenHeader, token)
+		assert.Equal(t, []string{ScopeReadAny}, resolver.Resolve(request).Scopes)
+	}
+	assert.Equal(t, "new", TokenKeyId(signTokenWithKid(t, "new", newKey, claims)))
+
+	// a token naming the wrong kid is not tried against the other keys
+	request.Header.Set(TokenHeader, signTokenWithKid(t, "new", oldKey, claims))
+	assert.Empty(t, resolver.Resolve(request).Scopes)
+
+	ring.Set(map[string]string{"new": newPublic})
+	request.Header.Set(TokenHeader, signToken(t, oldKey, claims))
+	assert.Empty(t, resolver.Resolve(request).Scopes)
+
+	request.Header.Set(TokenHeader, signToken(t, newKey, map[string]interface{}{"sub": "MWA-1"}))
+	assert.Equal(t, []string{ScopeReadSelf}, resolver.Resolve(request).Scopes)
+}
+
+func TestKeyringWatcherKeepsKeysWhenAReloadFails(t *testing.T) {
+	_, public := generateKey(t)
+	directory := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(directory, "2026.pem"), []byte(public), 0600))
+	assert.Nil(t, os.WriteFile(filepath.Join(directory, "README"), []byte("not a key"), 0600))
+
+	ring := NewAccessKeyring(nil)
+	var loadErr error
+	watcher := NewKeyringWatcher(ring, func() (map[string]string, error) {
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		return LoadKeyDirectory(directory)
+	}, 0, zap.NewNop())
+	watcher.Reload()
+	assert.Equal(t, []string{"2026"}, ring.Ids())
+
+	loadErr = errors.New("overrides file is being written")
+	watcher.Reload()
+	assert.Equal(t, []string{"2026"}, ring.Ids())
+
+	loadErr = nil
+	assert.Nil(t, os.Remove(filepath.Join(directory, "2026.pem")))
+	watcher.Reload()
+	assert.Equal(t, []string{"2026"}, ring.Ids())
+}
+
+func TestParseKeyListKeepsBase64Padding(t *testing.T) {
+	assert.Equal(t, map[string]string{"2025": "MIIBIj==", "2026": "MIIBCg="},
+		ParseKeyList(" 2025=MIIBIj== ; 2026=MIIBCg=;=orphan;empty="))
+}
@@ -0,0 +1,111 @@
+package authz
+
+import (
+	"context"
+	"strings"
+)
+
+// Scopes granted to access key callers. signin:write may be narrowed to a single source with
+// signin:write:<sourceId>; signin:admin implies every other scope. signin:read:pseudonymized
+// searches and counts across every profile, but without the raw uniqueIds, IPs and exact times,
+// and cannot look up a single profile by its uniqueId. signin:metrics only scrapes /admin/metrics.
+const (
+	ScopeWrite             = "signin:write"
+	ScopeReadSelf          = "signin:read:self"
+	ScopeReadAny           = "signin:read:any"
+	ScopeReadPseudonymized = "signin:read:pseudonymized"
+	ScopeMetrics           = "signin:metrics"
+	ScopeAdmin             = "signin:admin"
+)
+
+type Caller struct {
+	// Id identifies the calling service as its authenticator verified it: the subject of the
+	// access key, the caller id claim of the token or the caller of the client certificate
+	Id string
+	// Subject is the profile the access key was issued for, it is what signin:read:self may read
+	Subject string
+	Scopes  []string
+	// Method names the authenticator that identified the caller
+	Method string
+}
+
+type callerKey struct{}
+
+// WithCaller stores the authenticated caller on the request context for the controllers.
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller stored by WithCaller. Requests that never passed the
+// access key filter have no caller and must be refused.
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+func (c Caller) Has(scope string) bool {
+	for _, granted := range c.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAny reports whether the caller holds at least one of scopes. An empty list only requires
+// an authenticated caller.
+func (c Caller) HasAny(scopes []string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	for _, scope := range scopes {
+		if c.Has(scope) {
+			return true
+		}
+		if scope == ScopeWrite && len(c.writableSources()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// CanWrite reports whether the caller may save sign-ins for sourceId.
+func (c Caller) CanWrite(sourceId string) bool {
+	if c.Has(ScopeWrite) {
+		return true
+	}
+	if sourceId == "" {
+		return false
+	}
+	for _, source := range c.writableSources() {
+		if source == sourceId {
+			return true
+		}
+	}
+	return false
+}
+
+// CanRead reports whether the caller may read the sign-in history of uniqueId. Pseudonymized
+// readers may not look up a raw uniqueId, the response would pair it with its pseudonym.
+func (c Caller) CanRead(uniqueId string) bool {
+	if c.Has(ScopeReadAny) {
+		return true
+	}
+	return c.Has(ScopeReadSelf) && c.Subject != "" && c.Subject == uniqueId
+}
+
+// Pseudonymized reports whether the caller's reads have to be pseudonymized, signin:read:any
+// and signin:admin see the raw data.
+func (c Caller) Pseudonymized() bool {
+	return c.Has(ScopeReadPseudonymized) && !c.Has(ScopeReadAny)
+}
+
+func (c Caller) writableSources() []string {
+	var sources []string
+	for _, granted := range c.Scopes {
+		if strings.HasPrefix(granted, ScopeWrite+":") && len(granted) > len(ScopeWrite)+1 {
+			sources = append(sources, strings.TrimPrefix(granted, ScopeWrite+":"))
+		}
+	}
+	return sources
+}
@@ -0,0 +1,149 @@
+package authz
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func generateKey(t *testing.T) (*rsa.PrivateKey, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Nil(t, err)
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.Nil(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestResolveReadsScopesFromSignedToken(t *testing.T) {
+	key, publicKey := generateKey(t)
+	resolver, err := NewClaimsResolver(publicKey, nil)
+	assert.Nil(t, err)
+
+	request := httptest.NewRequest("GET", "/v1/lastSignIn?uniqueId=MWA-1", nil)
+	request.Header.Set(TokenHeader, "Bearer "+signToken(t, key, map[string]interface{}{"sub": "MWA-1", "scope": "signin:read:self signin:write:web"}))
+	request.Header.Set(CallerIdHeader, "MWA")
+	caller := resolver.Resolve(request)
+
+	assert.Equal(t, Caller{Id: "MWA-1", Subject: "MWA-1", Scopes: []string{ScopeReadSelf, "signin:write:web"}}, caller)
+	assert.True(t, caller.CanRead("MWA-1"))
+	assert.False(t, caller.CanRead("MWA-2"))
+	assert.True(t, caller.CanWrite("web"))
+	assert.False(t, caller.CanWrite("desktop"))
+	assert.False(t, caller.CanWrite(""))
+	assert.True(t, caller.HasAny([]string{ScopeWrite}))
+	assert.False(t, caller.HasAny([]string{ScopeReadAny}))
+	assert.False(t, caller.Has(ScopeAdmin))
+}
+
+func TestResolveIgnoresTokensSignedByAnotherKey(t *testing.T) {
+	_, publicKey := generateKey(t)
+	otherKey, _ := generateKey(t)
+	resolver, err := NewClaimsResolver(publicKey, ParseGrants("PAT=signin:read:any"))
+	assert.Nil(t, err)
+
+	request := httptest.NewRequest("GET", "/v1/lastSignIn", nil)
+	request.Header.Set(TokenHeader, signToken(t, otherKey, map[string]interface{}{"sub": "PAT-1", "scope": []string{ScopeAdmin}}))
+	request.Header.Set(CallerIdHeader, "PAT")
+	caller := resolver.Resolve(request)
+
+	assert.Equal(t, Caller{}, caller)
+	assert.False(t, caller.Has(ScopeAdmin))
+}
+
+func TestResolveFallsBackToGrantsWithoutScopeClaim(t *testing.T) {
+	key, publicKey := generateKey(t)
+	resolver, err := NewClaimsResolver(publicKey, ParseGrants(" MWA=signin:write, signin:read:any ; PAT=signin:read:self;broken"))
+	assert.Nil(t, err)
+
+	request := httptest.NewRequest("POST", "/v1/saveSignInData", nil)
+	request.Header.Set(TokenHeader, signToken(t, key, map[string]interface{}{"sub": "MWA"}))
+	caller := resolver.Resolve(request)
+	assert.Equal(t, "MWA", caller.Id)
+	assert.Equal(t, []string{ScopeWrite, ScopeReadAny}, caller.Scopes)
+	assert.True(t, caller.CanWrite(""))
+	assert.True(t, caller.CanRead("PAT-1"))
+
+	request.Header.Set(TokenHeader, signToken(t, key, map[string]interface{}{"sub": "unknown"}))
+	assert.False(t, resolver.Resolve(request).HasAny([]string{ScopeWrite, ScopeReadSelf, ScopeReadAny}))
+}
+
+func TestResolveIgnoresSpoofedCallerIdHeader(t *testing.T) {
+	key, publicKey := generateKey(t)
+	resolver, err := NewClaimsResolver(publicKey, ParseGrants("MWA=signin:write,signin:read:any"))
+	assert.Nil(t, err)
+
+	request := httptest.NewRequest("GET", "/v1/lastSignIn", nil)
+	request.Header.Set(TokenHeader, signToken(t, key, map[string]interface{}{"sub": "PAT-1"}))
+	request.Header.Set(CallerIdHeader, "MWA")
+	caller := resolver.Resolve(request)
+
+	assert.Equal(t, "PAT-1", caller.Id)
+	assert.Empty(t, caller.Scopes)
+	assert.False(t, caller.HasAny([]string{ScopeWrite, ScopeReadSelf, ScopeReadAny}))
+}
+
+func TestAdminImpliesEveryScope(t *testing.T) {
+	caller := Caller{Id: "ops", Scopes: []string{ScopeAdmin}}
+	assert.True(t, caller.CanWrite("web"))
+	assert.True(t, caller.CanRead("MWA-1"))
+	assert.True(t, caller.HasAny([]string{ScopeReadAny}))
+
+	_, ok := CallerFromContext(context.Background())
+	assert.False(t, ok)
+	stored, ok := CallerFromContext(WithCaller(context.Background(), caller))
+	assert.True(t, ok)
+	assert.Equal(t, caller, stored)
+}
+
+func TestOnlyPseudonymizedReadersArePseudonymized(t *testing.T) {
+	analytics := Caller{Id: "analytics", Scopes: []string{ScopeReadPseudonymized}}
+	assert.True(t, analytics.Pseudonymized())
+	assert.False(t, analytics.CanWrite("web"))
+
+	assert.False(t, Caller{Scopes: []string{ScopeReadPseudonymized, ScopeReadAny}}.Pseudonymized())
+	assert.False(t, Caller{Scopes: []string{ScopeAdmin}}.Pseudonymized())
+	assert.False(t, Caller{Subject: "MWA-1", Scopes: []string{ScopeReadSelf}}.Pseudonymized())
+}
+
+func TestPseudonymizedReadersCannotLookUpARawUniqueId(t *testing.T) {
+	analytics := Caller{Id: "analytics", Subject: "analytics", Scopes: []string{ScopeReadPseudonymized}}
+	assert.False(t, analytics.CanRead("MWA-1"))
+	assert.True(t, analytics.HasAny([]string{ScopeReadAny, ScopeReadPseudonymized}))
+
+	assert.True(t, Caller{Scopes: []string{ScopeReadPseudonymized, ScopeReadAny}}.CanRead("MWA-1"))
+	assert.True(t, Caller{Subject: "MWA-1", Scopes: []string{ScopeReadPseudonymized, ScopeReadSelf}}.CanRead("MWA-1"))
+}
+
+func TestMetricsScopeGrantsNothingElse(t *testing.T) {
+	scraper := Caller{Id: "prometheus", Subject: "prometheus", Scopes: []string{ScopeMetrics}}
+	assert.True(t, scraper.HasAny([]string{ScopeMetrics}))
+	assert.False(t, scraper.HasAny([]string{ScopeWrite, ScopeReadSelf, ScopeReadAny, ScopeReadPseudonymized, ScopeAdmin}))
+	assert.False(t, scraper.CanRead("prometheus"))
+	assert.False(t, scraper.CanWrite("web"))
+	assert.True(t, Caller{Scopes: []string{ScopeAdmin}}.HasAny([]string{ScopeMetrics}))
+}
+
+func TestParsePublicKeyRejectsGarbage(t *testing.T) {
+	_, err := NewClaimsResolver("not a key", nil)
+	assert.NotNil(t, err)
+}
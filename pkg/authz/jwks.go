@@ -0,0 +1,182 @@
+package authz
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrUnknownKeyId = errors.New("token is signed with an unknown key")
+
+// minRefetchInterval bounds how often an unknown kid triggers a fetch, a flood of tokens with
+// made up kids must not turn into a flood of JWKS requests.
+const minRefetchInterval = time.Minute
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// KeySet caches the signing keys of a JWKS document read from a file or an http(s) URL. Keys
+// are refetched after refresh and, at most once per minute, when a token names a kid the set
+// does not know, which picks up a rotation before the refresh is due. A failed fetch keeps the
+// keys of the last good one. Fetches run outside the lock, one at a time: known kids are served
+// from the cached keys meanwhile and only unknown kids wait for the running fetch.
+type KeySet struct {
+	source    string
+	refresh   time.Duration
+	client    *http.Client
+	mu        sync.Mutex
+	keys      map[string]interface{}
+	fetched   time.Time
+	attempted time.Time
+	// fetching is closed when the running fetch finishes, nil while none runs
+	fetching chan struct{}
+	now      func() time.Time
+}
+
+func NewKeySet(source string, refresh time.Duration) *KeySet {
+	return &KeySet{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+	}
+}
+
+// Key returns the public key for kid, an *rsa.PublicKey or an *ecdsa.PublicKey.
+func (ks *KeySet) Key(ctx context.Context, kid string) (interface{}, error) {
+	ks.mu.Lock()
+	now := ks.now()
+	if key, ok := ks.keys[kid]; ok {
+		if now.Sub(ks.fetched) >= ks.refresh {
+			ks.startFetch(now)
+		}
+		ks.mu.Unlock()
+		return key, nil
+	}
+	done := ks.startFetch(now)
+	ks.mu.Unlock()
+	if done == nil {
+		return nil, ErrUnknownKeyId
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if key, ok := ks.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKeyId
+}
+
+// startFetch returns the channel of the running fetch, starting one when none runs and the last
+// attempt is minRefetchInterval old. It returns nil when no fetch runs. ks.mu must be held.
+func (ks *KeySet) startFetch(now time.Time) chan struct{} {
+	if ks.fetching != nil {
+		return ks.fetching
+	}
+	if now.Sub(ks.attempted) < minRefetchInterval {
+		return nil
+	}
+	ks.attempted = now
+	done := make(chan struct{})
+	ks.fetching = done
+	go func() {
+		// not the caller's context, the result is shared with every caller waiting for it
+		keys, err := ks.load(context.Background())
+		ks.mu.Lock()
+		if err == nil {
+			ks.keys, ks.fetched = keys, now
+		}
+		ks.fetching = nil
+		ks.mu.Unlock()
+		close(done)
+	}()
+	return done
+}
+
+func (ks *KeySet) load(ctx context.Context) (map[string]interface{}, error) {
+	var content []byte
+	var err error
+	if strings.HasPrefix(ks.source, "https://") || strings.HasPrefix(ks.source, "http://") {
+		content, err = ks.download(ctx)
+	} else {
+		content, err = os.ReadFile(ks.source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(content)
+}
+
+func (ks *KeySet) download(ctx context.Context) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := ks.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", ks.source, response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, 1<<20))
+}
+
+// ParseJWKS reads the RSA and P-256 signing keys of a JWKS document, other keys are skipped.
+func ParseJWKS(content []byte) (map[string]interface{}, error) {
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+	keys := make(map[string]interface{}, len(document.Keys))
+	for _, jwk := range document.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch {
+		case jwk.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+			if errN != nil || errE != nil {
+				return nil, fmt.Errorf("jwks key %s is not a valid RSA key", jwk.Kid)
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case jwk.Kty == "EC" && jwk.Crv == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+			y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+			key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if errX != nil || errY != nil || !key.Curve.IsOnCurve(key.X, key.Y) {
+				return nil, fmt.Errorf("jwks key %s is not a valid P-256 key", jwk.Kid)
+			}
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,155 @@
+package authz
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidOIDCToken = errors.New("invalid OIDC token")
+	ErrTokenExpired     = errors.New("OIDC token is expired or not yet valid")
+	ErrWrongAudience    = errors.New("OIDC token is meant for another audience")
+)
+
+// clockLeeway tolerates the clock skew between the issuer and this service.
+const clockLeeway = time.Minute
+
+type oidcHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type oidcClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	Expiry    json.Number     `json:"exp"`
+	NotBefore json.Number     `json:"nbf"`
+	Scope     json.RawMessage `json:"scope"`
+	// scp is where Azure AD puts the scopes
+	Scp json.RawMessage `json:"scp"`
+}
+
+// OIDCVerifier validates JWTs of an OpenID Connect issuer against the issuer's JWKS, RS256 and
+// ES256 signatures are accepted.
+type OIDCVerifier struct {
+	keys          *KeySet
+	issuer        string
+	audience      string
+	callerIdClaim string
+	now           func() time.Time
+}
+
+// NewOIDCVerifier maps the claim callerIdClaim, azp or client_id for most issuers, to the caller
+// id; tokens without it use their subject.
+func NewOIDCVerifier(keys *KeySet, issuer, audience, callerIdClaim string) *OIDCVerifier {
+	return &OIDCVerifier{keys: keys, issuer: issuer, audience: audience, callerIdClaim: callerIdClaim, now: time.Now}
+}
+
+// Issued reports whether token claims to come from the issuer. It does not verify anything, it
+// only tells OIDC tokens apart from access keys that share the Authorization header.
+func (v *OIDCVerifier) Issued(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	var claims oidcClaims
+	return decodeSegment(parts[1], &claims) == nil && claims.Issuer == v.issuer
+}
+
+// Verify checks the signature, issuer, audience and validity period of token and returns the
+// caller it identifies, with the scopes of its scope or scp claim.
+func (v *OIDCVerifier) Verify(ctx context.Context, token string) (Caller, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Caller{}, ErrInvalidOIDCToken
+	}
+	var header oidcHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Caller{}, ErrInvalidOIDCToken
+	}
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return Caller{}, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature) {
+		return Caller{}, ErrInvalidOIDCToken
+	}
+
+	var claims oidcClaims
+	if err := decodeSegment(parts[1], &claims); err != nil || claims.Issuer != v.issuer {
+		return Caller{}, ErrInvalidOIDCToken
+	}
+	if !audienceContains(claims.Audience, v.audience) {
+		return Caller{}, ErrWrongAudience
+	}
+	now := v.now()
+	expiry, err := claims.Expiry.Int64()
+	if err != nil || now.After(time.Unix(expiry, 0).Add(clockLeeway)) {
+		return Caller{}, ErrTokenExpired
+	}
+	if notBefore, err := claims.NotBefore.Int64(); err == nil && now.Add(clockLeeway).Before(time.Unix(notBefore, 0)) {
+		return Caller{}, ErrTokenExpired
+	}
+
+	caller := Caller{Id: claims.Subject, Subject: claims.Subject, Scopes: parseScopeClaim(claims.Scope)}
+	if len(caller.Scopes) == 0 {
+		caller.Scopes = parseScopeClaim(claims.Scp)
+	}
+	if v.callerIdClaim != "" {
+		var all map[string]interface{}
+		if decodeSegment(parts[1], &all) == nil {
+			if callerId, ok := all[v.callerIdClaim].(string); ok && callerId != "" {
+				caller.Id = callerId
+			}
+		}
+	}
+	return caller, nil
+}
+
+func verifySignature(alg string, key interface{}, signed string, signature []byte) bool {
+	digest := sha256.Sum256([]byte(signed))
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256" && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) == nil
+	case *ecdsa.PublicKey:
+		// JWS carries r and s as two fixed size big endian integers, not ASN.1
+		if alg != "ES256" || len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k, digest[:], r, s)
+	}
+	return false
+}
+
+// audienceContains accepts aud as a single string or as an array.
+func audienceContains(raw json.RawMessage, audience string) bool {
+	if audience == "" {
+		return true
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		var single string
+		if err := json.Unmarshal(raw, &single); err != nil {
+			return false
+		}
+		list = []string{single}
+	}
+	for _, aud := range list {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
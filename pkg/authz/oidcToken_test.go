@@ -0,0 +1,184 @@
+package authz
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testIssuer = "https://login.example.com/"
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig",
+		"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func writeJWKS(t *testing.T, location string, keys ...map[string]string) {
+	content, err := json.Marshal(map[string]interface{}{"keys": keys})
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(location, content, 0600))
+}
+
+func signOIDC(t *testing.T, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	alg := "RS256"
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		assert.Nil(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		assert.Nil(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func partnerClaims(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss": testIssuer, "sub": "svc-partner", "aud": []string{"signindatatracker"}, "azp": "PARTNER",
+		"exp": now.Add(time.Hour).Unix(), "scope": "signin:read:pseudonymized",
+	}
+}
+
+func TestVerifyMapsOIDCClaimsToCaller(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	location := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, location, rsaJWK("k1", &key.PublicKey))
+	verifier := NewOIDCVerifier(NewKeySet(location, time.Hour), testIssuer, "signindatatracker", "azp")
+
+	token := signOIDC(t, "k1", key, partnerClaims(time.Now()))
+	assert.True(t, verifier.Issued(token))
+	caller, err := verifier.Verify(context.Background(), token)
+	assert.Nil(t, err)
+	assert.Equal(t, Caller{Id: "PARTNER", Subject: "svc-partner", Scopes: []string{ScopeReadPseudonymized}}, caller)
+
+	expired := partnerClaims(time.Now().Add(-3 * time.Hour))
+	_, err = verifier.Verify(context.Background(), signOIDC(t, "k1", key, expired))
+	assert.Equal(t, ErrTokenExpired, err)
+
+	otherAudience := partnerClaims(time.Now())
+	otherAudience["aud"] = "billing"
+	_, err = verifier.Verify(context.Background(), signOIDC(t, "k1", key, otherAudience))
+	assert.Equal(t, ErrWrongAudience, err)
+
+	forged, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, err = verifier.Verify(context.Background(), signOIDC(t, "k1", forged, partnerClaims(time.Now())))
+	assert.Equal(t, ErrInvalidOIDCToken, err)
+
+	otherIssuer := partnerClaims(time.Now())
+	otherIssuer["iss"] = "https://elsewhere.example.com/"
+	assert.False(t, verifier.Issued(signOIDC(t, "k1", key, otherIssuer)))
+}
+
+func TestKeySetPicksUpRotatedKeys(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	location := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, location, rsaJWK("old", &oldKey.PublicKey))
+	keys := NewKeySet(location, time.Hour)
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	verifier := NewOIDCVerifier(keys, testIssuer, "signindatatracker", "azp")
+
+	_, err := verifier.Verify(context.Background(), signOIDC(t, "old", oldKey, partnerClaims(now)))
+	assert.Nil(t, err)
+
+	writeJWKS(t, location, map[string]string{
+		"kty": "EC", "kid": "new", "crv": "P-256",
+		"x": base64.RawURLEncoding.EncodeToString(newKey.X.FillBytes(make([]byte, 32))),
+		"y": base64.RawURLEncoding.EncodeToString(newKey.Y.FillBytes(make([]byte, 32))),
+	})
+	// the first fetch was just now, an unknown kid is only refetched a minute later
+	_, err = verifier.Verify(context.Background(), signOIDC(t, "new", newKey, partnerClaims(now)))
+	assert.Equal(t, ErrUnknownKeyId, err)
+	now = now.Add(2 * time.Minute)
+	_, err = verifier.Verify(context.Background(), signOIDC(t, "new", newKey, partnerClaims(now)))
+	assert.Nil(t, err)
+	_, err = verifier.Verify(context.Background(), signOIDC(t, "old", oldKey, partnerClaims(now)))
+	assert.Equal(t, ErrUnknownKeyId, err)
+}
+
+func TestKeySetServesCachedKeysWhileFetching(t *testing.T) {
+	first, _ := rsa.GenerateKey(rand.Reader, 2048)
+	second, _ := rsa.GenerateKey(rand.Reader, 2048)
+	var mu sync.Mutex
+	requests := 0
+	body, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{rsaJWK("k1", &first.PublicKey)}})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		n, content := requests, body
+		mu.Unlock()
+		if n > 1 {
+			<-release
+		}
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+	keys := NewKeySet(server.URL, time.Hour)
+	now := time.Now()
+	keys.now = func() time.Time { return now }
+	_, err := keys.Key(context.Background(), "k1")
+	assert.Nil(t, err)
+
+	mu.Lock()
+	body, _ = json.Marshal(map[string]interface{}{"keys": []map[string]string{rsaJWK("k1", &first.PublicKey), rsaJWK("k2", &second.PublicKey)}})
+	mu.Unlock()
+	now = now.Add(2 * time.Hour)
+	// the refresh is due and hangs, the cached key is still served
+	cached := make(chan error, 1)
+	go func() {
+		_, err := keys.Key(context.Background(), "k1")
+		cached <- err
+	}()
+	select {
+	case err := <-cached:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("a known kid waited for the refresh")
+	}
+
+	// unknown kids wait for the running fetch instead of starting their own
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := keys.Key(context.Background(), "k2")
+			assert.Nil(t, err)
+			assert.Equal(t, &second.PublicKey, key)
+		}()
+	}
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 2, requests)
+}
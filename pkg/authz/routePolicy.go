@@ -0,0 +1,31 @@
+package authz
+
+// RoutePolicy is the authentication a route requires, every controller declares one for its
+// paths and the access key filter enforces it before the controller runs.
+type RoutePolicy string
+
+const (
+	// RoutePublic routes are served without credentials, health checks and the like
+	RoutePublic RoutePolicy = "public"
+	// RouteAuthenticated routes need a caller that one of the authenticators accepted, the
+	// controller checks its scopes
+	RouteAuthenticated RoutePolicy = "authenticated"
+	// RouteAdmin routes are refused by the filter already unless the caller holds signin:admin
+	RouteAdmin RoutePolicy = "admin"
+)
+
+func (p RoutePolicy) Valid() bool {
+	return p == RoutePublic || p == RouteAuthenticated || p == RouteAdmin
+}
+
+// RoutePolicies maps request paths to their policy.
+type RoutePolicies map[string]RoutePolicy
+
+// For returns the policy of path. Paths no controller declared need an authenticated caller,
+// a route registered without metadata is never public by accident.
+func (rp RoutePolicies) For(path string) RoutePolicy {
+	if policy, ok := rp[path]; ok {
+		return policy
+	}
+	return RouteAuthenticated
+}
@@ -2,10 +2,18 @@ package bootstrap
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.mathworks.com/development/accesskeyfilter-go/pkg/accesskeyclient"
+	"github.mathworks.com/development/signindatatrackerws/pkg/envelope"
+	"github.mathworks.com/development/signindatatrackerws/pkg/resilience"
 	"go.uber.org/zap"
 )
 
@@ -28,48 +36,175 @@ func SetApplicationContext(ctx *ApplicationContext, log *zap.Logger) {
 }
 
 func BuildApplicationContext(log *zap.Logger, overridesLoc string) *ApplicationContext {
+	return BuildApplicationContextFrom(log, overridesLoc, ConfigSources{})
+}
+
+// BuildApplicationContextFrom layers sources around the overrides file, the process stops
+// when the result does not validate.
+func BuildApplicationContextFrom(log *zap.Logger, overridesLoc string, sources ConfigSources) *ApplicationContext {
 
 	configData := new(AppConfigData)
 	configData.OverridesLocation = overridesLoc
+	configData.Sources = sources
 	configData.BootstrapConfigData(log)
 	cxt := new(ApplicationContext)
 	cxt.AppConfigData = configData
+	if configData.Resilience.Enabled {
+		// every repository shares the breakers, GetDB builds a client per call
+		cxt.dbExecutor = resilience.NewExecutor(resilience.Policy{
+			MaxAttempts:    configData.Resilience.MaxAttempts,
+			InitialBackoff: time.Duration(configData.Resilience.InitialBackoffMillis) * time.Millisecond,
+			MaxBackoff:     time.Duration(configData.Resilience.MaxBackoffMillis) * time.Millisecond,
+		}, resilience.NewBreakerSet(configData.Resilience.BreakerFailures,
+			time.Duration(configData.Resilience.BreakerOpenSeconds)*time.Second), log.Named("dynamo.resilience"))
+	}
 	cxt.GetDB = func() (DynamoDBClientInterface, error) {
 		client, err := cxt.getDb(log, *configData)
 		return client, err
 	}
+	cxt.GetStreams = func() (DynamoDBStreamsClientInterface, error) {
+		client, err := cxt.getStreams(log, *configData)
+		return client, err
+	}
 	appContext = cxt
 	return cxt
 }
 
 type ApplicationContextInterface interface {
 	getDb(log *zap.Logger, data AppConfigData) (DynamoDBClientInterface, error)
+	getStreams(log *zap.Logger, data AppConfigData) (DynamoDBStreamsClientInterface, error)
 }
 
 type ApplicationContext struct {
 	AppConfigData *AppConfigData
 	GetDB         func() (DynamoDBClientInterface, error)
+	GetStreams    func() (DynamoDBStreamsClientInterface, error)
 	dbClient      DynamoDBClientInterface
 	akClient      *accesskeyclient.AccessKeyClient
 	GetAkClient   func() (*accesskeyclient.AccessKeyClient, error)
+	dbExecutor    *resilience.Executor
+	encryptorOnce sync.Once
+	encryptor     *envelope.FieldEncryptor
+	encryptorErr  error
+	configMu      sync.RWMutex
+	// config is the reloaded configuration, nil until the first reload
+	config    *AppConfigData
+	listeners []func(*AppConfigData)
+}
+
+// DynamoBreakers returns nil when the resilience decorator is disabled.
+func (cxt *ApplicationContext) DynamoBreakers() *resilience.BreakerSet {
+	if cxt.dbExecutor == nil {
+		return nil
+	}
+	return cxt.dbExecutor.Breakers()
 }
 
 func (cxt *ApplicationContext) getDb(log *zap.Logger, appConfig AppConfigData) (DynamoDBClientInterface, error) {
 	logger := log.With(zap.String("DynamoDB", "signindatatracker.dynamo"))
-	var cfg aws.Config
-	var err error
-	if appConfig.Dynamo.Env == LocalEnvironment {
-		cfg, err = cxt.getLocalConfig(appConfig)
-	} else {
-		cfg, err = cxt.getAWSConfig(appConfig)
+	cfg, err := cxt.loadConfig(appConfig)
+	if err != nil {
+		logger.Error("Failed to load AWS DynamoDB configuration", zap.Error(err))
+		return nil, err
+	}
+
+	encrypt := func(client DynamoDBClientInterface) DynamoDBClientInterface { return client }
+	if appConfig.Encryption.Enabled {
+		encryptor, err := cxt.fieldEncryptor(cfg)
+		if err != nil {
+			logger.Error("Failed to set up field encryption", zap.Error(err))
+			return nil, err
+		}
+		encrypt = func(client DynamoDBClientInterface) DynamoDBClientInterface {
+			return NewEncryptingDynamoDBClient(client, encryptor, splitList(appConfig.Encryption.Tables))
+		}
 	}
 
+	if cxt.dbExecutor == nil {
+		return encrypt(NewTracedDynamoDBClient(NewInstrumentedDynamoDBClient(NewDynamoDBClient(cfg)))), nil
+	}
+	// retries happen in the decorator so that they count against the breaker, the metrics and
+	// spans see every attempt
+	cfg.RetryMaxAttempts = 1
+	client := encrypt(NewTracedDynamoDBClient(NewInstrumentedDynamoDBClient(NewDynamoDBClient(cfg))))
+	return NewResilientDynamoDBClient(client, cxt.dbExecutor), nil
+}
+
+// EncryptionKeyId is the key GetDB clients encrypt new items with, empty before the first
+// GetDB call or when encryption is disabled.
+func (cxt *ApplicationContext) EncryptionKeyId() string {
+	if cxt.encryptor == nil {
+		return ""
+	}
+	return cxt.encryptor.CurrentKeyId()
+}
+
+// FieldEncryptor returns the encryptor GetDB clients use, nil when encryption is disabled. Readers
+// that bypass GetDB, like the stream consumer, decrypt items with it.
+func (cxt *ApplicationContext) FieldEncryptor() (*envelope.FieldEncryptor, error) {
+	if !cxt.AppConfigData.Encryption.Enabled {
+		return nil, nil
+	}
+	cfg, err := cxt.loadConfig(*cxt.AppConfigData)
 	if err != nil {
-		logger.Error("Failed to load AWS DynamoDB configuration", zap.Error(err))
+		return nil, err
+	}
+	return cxt.fieldEncryptor(cfg)
+}
+
+// fieldEncryptor is shared by every client GetDB builds so that they reuse the data key and the
+// unwrapped keys.
+func (cxt *ApplicationContext) fieldEncryptor(cfg aws.Config) (*envelope.FieldEncryptor, error) {
+	cxt.encryptorOnce.Do(func() {
+		conf := cxt.AppConfigData.Encryption
+		var provider envelope.KeyProvider
+		switch conf.Provider {
+		case envelope.ProviderLocal:
+			provider, cxt.encryptorErr = envelope.LoadLocalKeyring(conf.KeyringLocation)
+		case envelope.ProviderKMS:
+			if conf.KMSKeyId == "" {
+				cxt.encryptorErr = errors.New("encryption provider kms needs a key id")
+				return
+			}
+			provider = envelope.NewKMSKeyProviderFromConfig(cfg, conf.KMSKeyId)
+		default:
+			cxt.encryptorErr = fmt.Errorf("unknown encryption provider %q", conf.Provider)
+		}
+		if cxt.encryptorErr != nil {
+			return
+		}
+		cxt.encryptor = envelope.NewFieldEncryptor(provider, splitList(conf.Fields),
+			time.Duration(conf.DataKeyMaxAgeSeconds)*time.Second)
+	})
+	return cxt.encryptor, cxt.encryptorErr
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (cxt *ApplicationContext) getStreams(log *zap.Logger, appConfig AppConfigData) (DynamoDBStreamsClientInterface, error) {
+	logger := log.With(zap.String("DynamoDBStreams", "signindatatracker.dynamo"))
+	cfg, err := cxt.loadConfig(appConfig)
+	if err != nil {
+		logger.Error("Failed to load AWS DynamoDB Streams configuration", zap.Error(err))
 		return nil, err
 	}
 
-	return NewDynamoDBClient(cfg), nil
+	return NewDynamoDBStreamsClient(cfg), nil
+}
+
+func (cxt *ApplicationContext) loadConfig(appConfig AppConfigData) (aws.Config, error) {
+	if appConfig.Dynamo.Env == LocalEnvironment {
+		return cxt.getLocalConfig(appConfig)
+	}
+	return cxt.getAWSConfig(appConfig)
 }
 
 func (cxt *ApplicationContext) getLocalConfig(appConfig AppConfigData) (aws.Config, error) {
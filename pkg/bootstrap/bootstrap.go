@@ -1,8 +1,11 @@
 package bootstrap
 
 import (
-	"github.mathworks.com/development/opi-utils-go/pkg/configutils"
-	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
 	"go.uber.org/zap"
 )
 
@@ -17,50 +20,413 @@ type AppConfigData struct {
 	Db                DatabaseConfig
 	AccessKey         AccessKeyConfig
 	Dynamo            DynamoConfig
+	UserAgent         UserAgentConfig
+	Risk              RiskConfig
+	Stats             StatsConfig
+	Search            SearchConfig
+	Export            ExportConfig
+	Ingest            IngestConfig
+	Webhook           WebhookConfig
+	Resilience        ResilienceConfig
+	Cache             CacheConfig
+	Tracing           TracingConfig
+	Metrics           MetricsConfig
+	Authz             AuthzConfig
+	Authn             AuthnConfig
+	RateLimit         RateLimitConfig
+	Audit             AuditConfig
+	Encryption        EncryptionConfig
+	Pseudonym         PseudonymConfig
+	IpPolicy          IpPolicyConfig
 	AppCallerId       string
 	AppRunTime        string
 	OverridesLocation string
-	logger            *zap.Logger
+	// ConfigReloadSeconds is how often the overrides file is checked for changes, 0 disables
+	// the reload
+	ConfigReloadSeconds int
+	// Sources layer defaults, the environment and flags around the overrides file
+	Sources ConfigSources
+	logger  *zap.Logger
+	// values holds what every key resolved to, for the reload
+	values map[string]string
 }
 type DatabaseConfig struct {
 	DbHost string
 	DbPort string
 	Name   string
 }
+
+// AccessKeyConfig holds the public keys access keys are verified with. AccessKeyPublic is the key
+// with id "default", PublicKeys adds more as "kid=key;kid=key" and KeyDirectory one <kid>.pem
+// file per key. The keys are reloaded every ReloadSeconds, several are active during a rotation.
 type AccessKeyConfig struct {
 	AccessKeyPublic string
 	AccessKeyHost   string
+	PublicKeys      string
+	KeyDirectory    string
+	ReloadSeconds   int
 }
 type DynamoConfig struct {
-	EndPoint  string
-	TableName string
-	Region    string
-	Env       string
+	EndPoint             string
+	TableName            string
+	SummaryTableName     string
+	RollupTableName      string
+	ActiveUsersTableName string
+	// ActiveUsersFlushSeconds is how often a process merges its active user sketches into the table
+	ActiveUsersFlushSeconds int
+	Region                  string
+	Env                     string
+}
+type UserAgentConfig struct {
+	RulesLocation string
+}
+type RiskConfig struct {
+	GeoDatabaseLocation string
+	MaxVelocityKmh      int
+	BurstWindowSeconds  int
+	BurstThreshold      int
+	RiskyThreshold      int
+}
+type StatsConfig struct {
+	RollupThresholdDays int
+	DefaultRangeDays    int
+	// RollupCoverageStart is the first UTC day, 2006-01-02, the rollups hold every sign-in of.
+	// Days before it are queried, rollups are not used at all while it is empty.
+	RollupCoverageStart string
+}
+type SearchConfig struct {
+	IndexName    string
+	ScanSegments int
+	AllowScan    bool
+	DefaultLimit int
+	MaxLimit     int
+}
+type ExportConfig struct {
+	Directory                string
+	TotalSegments            int
+	Workers                  int
+	PageSize                 int
+	MaxReadCapacityPerSecond int
+}
+type IngestConfig struct {
+	Async                  bool
+	Directory              string
+	QueueCapacity          int
+	Workers                int
+	BatchSize              int
+	BatchLingerMillis      int
+	InitialBackoffMillis   int
+	MaxBackoffMillis       int
+	MaxSegmentBytes        int
+	ShutdownTimeoutSeconds int
+	// MaxAttempts bounds the retries of writes that fail for good, the records are then moved
+	// to deadletter.ndjson in Directory
+	MaxAttempts int
+}
+type WebhookConfig struct {
+	Enabled               bool
+	Directory             string
+	MaxAttempts           int
+	InitialBackoffSeconds int
+	MaxBackoffSeconds     int
+	TimeoutSeconds        int
+	PollIntervalMillis    int
+	// Concurrency bounds the subscriptions delivered to at the same time, each one gets its
+	// deliveries in order
+	Concurrency int
+}
+
+type ResilienceConfig struct {
+	Enabled              bool
+	MaxAttempts          int
+	InitialBackoffMillis int
+	MaxBackoffMillis     int
+	BreakerFailures      int
+	BreakerOpenSeconds   int
+	DivertWritesWhenOpen bool
+}
+
+// CacheConfig configures the read cache. Backend is none, memory or redis and defaults to none:
+// a memory cache is not invalidated by writes on other instances, so it only suits a single one.
+type CacheConfig struct {
+	Backend            string
+	Capacity           int
+	TTLSeconds         int
+	RedisAddress       string
+	RedisPassword      string
+	RedisDB            int
+	RedisPoolSize      int
+	RedisTimeoutMillis int
+}
+
+type TracingConfig struct {
+	Exporter     string
+	OTLPEndpoint string
+	OTLPInsecure bool
+	SampleRatio  float64
+}
+
+// MetricsConfig lists the sourceIds counted in a series of their own, comma separated. Other
+// sourceIds share the "other" series, while it is empty the first sources seen get their own.
+type MetricsConfig struct {
+	Sources string
+}
+
+// AuthzConfig turns scope checks on. Enabled defaults to whether Grants are set, so a deployment
+// that predates scopes keeps letting every authenticated caller through until grants for its callers
+// are configured, set enabled=true to enforce scopes carried only by token claims.
+type AuthzConfig struct {
+	Enabled bool
+	// Grants holds the scopes of callers whose access keys carry no scope claim, by the key's
+	// subject, "<callerId>=<scope>,<scope>;<callerId>=<scope>"
+	Grants string
+}
+
+// AuthnConfig lists the authenticators to try, comma separated from accesskey, jwt and mtls;
+// accesskey claims every request and has to be the last one.
+type AuthnConfig struct {
+	Chain                 string
+	JWTIssuer             string
+	JWTAudience           string
+	JWTJWKS               string
+	JWTJWKSRefreshSeconds int
+	JWTCallerIdClaim      string
+	MTLSCAFile            string
+	// MTLSSubjects maps certificate common or DNS names to caller ids, "name=callerId;..."
+	MTLSSubjects string
+}
+
+type RateLimitConfig struct {
+	Enabled bool
+	// Store is memory for per instance buckets or redis to share them, the Redis connection
+	// settings are the cache's
+	Store             string
+	RequestsPerMinute int
+	Burst             int
+	Overrides         string
+}
+
+type AuditConfig struct {
+	Enabled   bool
+	Directory string
+	// ChainSecret keys the entry hashes so the chain cannot be rewritten without it. Set it before the
+	// first entry is written, entries hashed without it no longer verify once it is set.
+	ChainSecret string
+}
+
+type EncryptionConfig struct {
+	Enabled bool
+	// Fields and Tables are comma separated attribute and table names
+	Fields string
+	Tables string
+	// Provider is local for a keyring file or kms, KMSKeyId is the key new items are wrapped with
+	Provider             string
+	KeyringLocation      string
+	KMSKeyId             string
+	DataKeyMaxAgeSeconds int
+	// IpDigestSecret keys the HMACs stored in place of IP addresses in the summary and rollup
+	// sets, required when ipAddress is encrypted
+	IpDigestSecret string
 }
 
+// EncryptedFields returns the fields stored encrypted in table, none when encryption is disabled.
+func (conf EncryptionConfig) EncryptedFields(table string) []string {
+	if !conf.Enabled {
+		return nil
+	}
+	for _, encrypted := range splitList(conf.Tables) {
+		if encrypted == table {
+			return splitList(conf.Fields)
+		}
+	}
+	return nil
+}
+
+// IpDigested reports whether the summary and rollup IP sets hold digests instead of addresses.
+func (conf EncryptionConfig) IpDigested() bool {
+	if !conf.Enabled {
+		return false
+	}
+	for _, field := range strings.Split(conf.Fields, ",") {
+		if strings.TrimSpace(field) == "ipAddress" {
+			return true
+		}
+	}
+	return false
+}
+
+// PseudonymConfig is used for callers with only signin:read:pseudonymized, they are refused
+// while no Secret is set. Changing the secret changes every pseudonym.
+type PseudonymConfig struct {
+	Secret                      string
+	TimestampGranularitySeconds int
+}
+
+// IpPolicyConfig decides how sign-in IPs are stored. Policies defines ids for the modes full,
+// truncate, hash and drop as "id=mode;id=mode", Regions and Sources assign them as
+// "key=id;key=id". The modes are ids of their own.
+type IpPolicyConfig struct {
+	Policies   string
+	Regions    string
+	Sources    string
+	Default    string
+	HashSecret string
+}
+
+// BootstrapConfigData stops the process when the configuration is not valid, every problem
+// found is reported.
 func (appConfig *AppConfigData) BootstrapConfigData(logger *zap.Logger) {
 	err := appConfig.loadOverrides()
 	if err != nil {
-		logger.Error("Error loading overrides.properties: " + err.Error())
+		logger.Error("Error loading overrides.properties", zap.Error(err))
+		log.Fatalf("%v", err)
 	}
 }
-func (appConfig *AppConfigData) loadOverrides() error {
-	loc := DefaultOverridesLocation
+func (appConfig *AppConfigData) overridesLocation() string {
 	if appConfig.OverridesLocation != "" {
-		loc = appConfig.OverridesLocation
+		return appConfig.OverridesLocation
 	}
-	props, err := configutils.ReadPropFile(loc)
-	if err != nil {
-		return err
+	return DefaultOverridesLocation
+}
+
+// ReadAccessKeyConfig reads the access key settings from the overrides file again, for the key
+// reload. It leaves AccessKey alone, the rest of the service keeps the values it started with.
+func (appConfig *AppConfigData) ReadAccessKeyConfig() (AccessKeyConfig, error) {
+	r := newConfigReader(appConfig.overridesLocation(), appConfig.Sources, nil)
+	conf := readAccessKeyConfig(r)
+	return conf, r.err(appConfig.overridesLocation())
+}
+
+func readAccessKeyConfig(r *configReader) AccessKeyConfig {
+	return AccessKeyConfig{
+		AccessKeyPublic: r.String("app.signindatatracker.ak.public", ""),
+		AccessKeyHost:   r.String("app.signindatatracker.ak.host", ""),
+		PublicKeys:      r.String("app.signindatatracker.ak.publickeys", ""),
+		KeyDirectory:    r.String("app.signindatatracker.ak.keydirectory", ""),
+		ReloadSeconds:   r.Int("app.signindatatracker.ak.reloadseconds", 30),
 	}
-	runTime := utils.GetValueFromMap(props, "app.runTime", "local")
+}
+
+func (appConfig *AppConfigData) loadOverrides() error {
+	r := newConfigReader(appConfig.overridesLocation(), appConfig.Sources, nil)
+	appConfig.read(r)
+	r.validate()
+	appConfig.values = r.values
+	return r.err(appConfig.overridesLocation())
+}
+
+// read fills the configuration from r, every key with its default.
+func (appConfig *AppConfigData) read(r *configReader) {
+	runTime := r.String("app.runTime", "local")
 	appConfig.AppRunTime = runTime
 	appConfig.AppCallerId = "SIGNINDATATRACKINGWS"
-	appConfig.AccessKey.AccessKeyPublic = utils.GetValueFromMap(props, "app.signindatatracker.ak.public", "")
-	appConfig.AccessKey.AccessKeyHost = utils.GetValueFromMap(props, "app.signindatatracker.ak.host", "")
-	appConfig.Dynamo.EndPoint = utils.GetValueFromMap(props, "app.signindatatracker.dynamo.endpoint", "")
-	appConfig.Dynamo.TableName = utils.GetValueFromMap(props, "app.signindatatracker.dynamo.tablename", "")
-	appConfig.Dynamo.Region = utils.GetValueFromMap(props, "app.signindatatracker.dynamo.region", "")
-	appConfig.Dynamo.Env = utils.GetValueFromMap(props, "app.signindatatracker.dynamo.env", "")
-	return nil
+	appConfig.AccessKey = readAccessKeyConfig(r)
+	appConfig.Dynamo.EndPoint = r.String("app.signindatatracker.dynamo.endpoint", "")
+	appConfig.Dynamo.TableName = r.String("app.signindatatracker.dynamo.tablename", "")
+	appConfig.Dynamo.SummaryTableName = r.String("app.signindatatracker.dynamo.summarytablename", "")
+	appConfig.Dynamo.RollupTableName = r.String("app.signindatatracker.dynamo.rolluptablename", "")
+	appConfig.Dynamo.ActiveUsersTableName = r.String("app.signindatatracker.dynamo.activeuserstablename", "")
+	appConfig.Dynamo.ActiveUsersFlushSeconds = r.Int("app.signindatatracker.dynamo.activeusersflushseconds", 10)
+	appConfig.Dynamo.Region = r.String("app.signindatatracker.dynamo.region", "")
+	appConfig.Dynamo.Env = r.String("app.signindatatracker.dynamo.env", "")
+	appConfig.UserAgent.RulesLocation = r.String("app.signindatatracker.useragent.rules", "configfiles/useragent.rules.json")
+	appConfig.Risk.GeoDatabaseLocation = r.String("app.signindatatracker.risk.geodatabase", "configfiles/geoip.json")
+	appConfig.Risk.MaxVelocityKmh = r.Int("app.signindatatracker.risk.maxvelocitykmh", 900)
+	appConfig.Risk.BurstWindowSeconds = r.Int("app.signindatatracker.risk.burstwindowseconds", 300)
+	appConfig.Risk.BurstThreshold = r.Int("app.signindatatracker.risk.burstthreshold", 10)
+	appConfig.Risk.RiskyThreshold = r.Int("app.signindatatracker.risk.riskythreshold", 50)
+	appConfig.Stats.RollupThresholdDays = r.Int("app.signindatatracker.stats.rollupthresholddays", 31)
+	appConfig.Stats.DefaultRangeDays = r.Int("app.signindatatracker.stats.defaultrangedays", 30)
+	appConfig.Stats.RollupCoverageStart = r.String("app.signindatatracker.stats.rollupcoveragestart", "")
+	if start := appConfig.Stats.RollupCoverageStart; start != "" {
+		if _, err := time.Parse("2006-01-02", start); err != nil {
+			r.problems = append(r.problems, fmt.Sprintf("app.signindatatracker.stats.rollupcoveragestart: %q is not a 2006-01-02 day", start))
+		}
+	}
+	appConfig.Search.IndexName = r.String("app.signindatatracker.search.indexname", "UniqueIdReferenceIdIndex")
+	appConfig.Search.ScanSegments = r.Int("app.signindatatracker.search.scansegments", 4)
+	appConfig.Search.AllowScan = r.Bool("app.signindatatracker.search.allowscan", true)
+	appConfig.Search.DefaultLimit = r.Int("app.signindatatracker.search.defaultlimit", 50)
+	appConfig.Search.MaxLimit = r.Int("app.signindatatracker.search.maxlimit", 500)
+	appConfig.Export.Directory = r.String("app.signindatatracker.export.directory", "data/exports")
+	appConfig.Export.TotalSegments = r.Int("app.signindatatracker.export.totalsegments", 8)
+	appConfig.Export.Workers = r.Int("app.signindatatracker.export.workers", 4)
+	appConfig.Export.PageSize = r.Int("app.signindatatracker.export.pagesize", 500)
+	appConfig.Export.MaxReadCapacityPerSecond = r.Int("app.signindatatracker.export.maxreadcapacitypersecond", 100)
+	appConfig.Ingest.Async = r.Bool("app.signindatatracker.ingest.async", false)
+	appConfig.Ingest.Directory = r.String("app.signindatatracker.ingest.directory", "data/ingest")
+	appConfig.Ingest.QueueCapacity = r.Int("app.signindatatracker.ingest.queuecapacity", 10000)
+	appConfig.Ingest.Workers = r.Int("app.signindatatracker.ingest.workers", 4)
+	appConfig.Ingest.BatchSize = r.Int("app.signindatatracker.ingest.batchsize", 25)
+	appConfig.Ingest.BatchLingerMillis = r.Int("app.signindatatracker.ingest.batchlingermillis", 50)
+	appConfig.Ingest.InitialBackoffMillis = r.Int("app.signindatatracker.ingest.initialbackoffmillis", 100)
+	appConfig.Ingest.MaxBackoffMillis = r.Int("app.signindatatracker.ingest.maxbackoffmillis", 30000)
+	appConfig.Ingest.MaxSegmentBytes = r.Int("app.signindatatracker.ingest.maxsegmentbytes", 4*1024*1024)
+	appConfig.Ingest.ShutdownTimeoutSeconds = r.Int("app.signindatatracker.ingest.shutdowntimeoutseconds", 30)
+	appConfig.Ingest.MaxAttempts = r.Int("app.signindatatracker.ingest.maxattempts", 5)
+	appConfig.Webhook.Enabled = r.Bool("app.signindatatracker.webhook.enabled", true)
+	appConfig.Webhook.Directory = r.String("app.signindatatracker.webhook.directory", "data/webhooks")
+	appConfig.Webhook.MaxAttempts = r.Int("app.signindatatracker.webhook.maxattempts", 8)
+	appConfig.Webhook.InitialBackoffSeconds = r.Int("app.signindatatracker.webhook.initialbackoffseconds", 5)
+	appConfig.Webhook.MaxBackoffSeconds = r.Int("app.signindatatracker.webhook.maxbackoffseconds", 3600)
+	appConfig.Webhook.TimeoutSeconds = r.Int("app.signindatatracker.webhook.timeoutseconds", 10)
+	appConfig.Webhook.PollIntervalMillis = r.Int("app.signindatatracker.webhook.pollintervalmillis", 1000)
+	appConfig.Webhook.Concurrency = r.Int("app.signindatatracker.webhook.concurrency", 8)
+	appConfig.Resilience.Enabled = r.Bool("app.signindatatracker.resilience.enabled", true)
+	appConfig.Resilience.MaxAttempts = r.Int("app.signindatatracker.resilience.maxattempts", 3)
+	appConfig.Resilience.InitialBackoffMillis = r.Int("app.signindatatracker.resilience.initialbackoffmillis", 50)
+	appConfig.Resilience.MaxBackoffMillis = r.Int("app.signindatatracker.resilience.maxbackoffmillis", 1000)
+	appConfig.Resilience.BreakerFailures = r.Int("app.signindatatracker.resilience.breakerfailures", 5)
+	appConfig.Resilience.BreakerOpenSeconds = r.Int("app.signindatatracker.resilience.breakeropenseconds", 30)
+	appConfig.Resilience.DivertWritesWhenOpen = r.Bool("app.signindatatracker.resilience.divertwrites", true)
+	appConfig.Cache.Backend = r.String("app.signindatatracker.cache.backend", "none")
+	appConfig.Cache.Capacity = r.Int("app.signindatatracker.cache.capacity", 10000)
+	appConfig.Cache.TTLSeconds = r.Int("app.signindatatracker.cache.ttlseconds", 15)
+	appConfig.Cache.RedisAddress = r.String("app.signindatatracker.cache.redis.address", "localhost:6379")
+	appConfig.Cache.RedisPassword = r.String("app.signindatatracker.cache.redis.password", "")
+	appConfig.Cache.RedisDB = r.Int("app.signindatatracker.cache.redis.db", 0)
+	appConfig.Cache.RedisPoolSize = r.Int("app.signindatatracker.cache.redis.poolsize", 8)
+	appConfig.Cache.RedisTimeoutMillis = r.Int("app.signindatatracker.cache.redis.timeoutmillis", 200)
+	appConfig.Tracing.Exporter = r.String("app.signindatatracker.tracing.exporter", "none")
+	appConfig.Tracing.OTLPEndpoint = r.String("app.signindatatracker.tracing.otlpendpoint", "localhost:4318")
+	appConfig.Tracing.OTLPInsecure = r.Bool("app.signindatatracker.tracing.otlpinsecure", false)
+	appConfig.Tracing.SampleRatio = float64(r.Int("app.signindatatracker.tracing.samplepercent", 100)) / 100
+	appConfig.Metrics.Sources = r.String("app.signindatatracker.metrics.sources", "")
+	appConfig.Authz.Grants = r.String("app.signindatatracker.authz.grants", "")
+	appConfig.Authz.Enabled = r.Bool("app.signindatatracker.authz.enabled", appConfig.Authz.Grants != "")
+	appConfig.Authn.Chain = r.String("app.signindatatracker.authn.chain", "accesskey")
+	appConfig.Authn.JWTIssuer = r.String("app.signindatatracker.authn.jwt.issuer", "")
+	appConfig.Authn.JWTAudience = r.String("app.signindatatracker.authn.jwt.audience", "")
+	appConfig.Authn.JWTJWKS = r.String("app.signindatatracker.authn.jwt.jwks", "")
+	appConfig.Authn.JWTJWKSRefreshSeconds = r.Int("app.signindatatracker.authn.jwt.jwksrefreshseconds", 3600)
+	appConfig.Authn.JWTCallerIdClaim = r.String("app.signindatatracker.authn.jwt.calleridclaim", "azp")
+	appConfig.Authn.MTLSCAFile = r.String("app.signindatatracker.authn.mtls.cafile", "")
+	appConfig.Authn.MTLSSubjects = r.String("app.signindatatracker.authn.mtls.subjects", "")
+	appConfig.RateLimit.Enabled = r.Bool("app.signindatatracker.ratelimit.enabled", true)
+	appConfig.RateLimit.Store = r.String("app.signindatatracker.ratelimit.store", "memory")
+	appConfig.RateLimit.RequestsPerMinute = r.Int("app.signindatatracker.ratelimit.requestsperminute", 600)
+	appConfig.RateLimit.Burst = r.Int("app.signindatatracker.ratelimit.burst", 0)
+	appConfig.RateLimit.Overrides = r.String("app.signindatatracker.ratelimit.overrides", "")
+	appConfig.Audit.Enabled = r.Bool("app.signindatatracker.audit.enabled", true)
+	appConfig.Audit.Directory = r.String("app.signindatatracker.audit.directory", "data/audit")
+	appConfig.Audit.ChainSecret = r.String("app.signindatatracker.audit.chainsecret", "")
+	appConfig.Encryption.Enabled = r.Bool("app.signindatatracker.encryption.enabled", false)
+	appConfig.Encryption.Fields = r.String("app.signindatatracker.encryption.fields", "ipAddress,userAgent")
+	appConfig.Encryption.Tables = r.String("app.signindatatracker.encryption.tables", "signindatatracker")
+	appConfig.Encryption.Provider = r.String("app.signindatatracker.encryption.provider", "local")
+	appConfig.Encryption.KeyringLocation = r.String("app.signindatatracker.encryption.keyring", "configfiles/keyring.json")
+	appConfig.Encryption.KMSKeyId = r.String("app.signindatatracker.encryption.kmskeyid", "")
+	appConfig.Encryption.DataKeyMaxAgeSeconds = r.Int("app.signindatatracker.encryption.datakeymaxageseconds", 300)
+	appConfig.Encryption.IpDigestSecret = r.String("app.signindatatracker.encryption.ipdigestsecret", "")
+	if appConfig.Encryption.IpDigested() && appConfig.Encryption.IpDigestSecret == "" {
+		r.problems = append(r.problems, "app.signindatatracker.encryption.ipdigestsecret is required when ipAddress is encrypted")
+	}
+	appConfig.Pseudonym.Secret = r.String("app.signindatatracker.pseudonym.secret", "")
+	appConfig.Pseudonym.TimestampGranularitySeconds = r.Int("app.signindatatracker.pseudonym.timestampgranularityseconds", 3600)
+	appConfig.IpPolicy.Policies = r.String("app.signindatatracker.ippolicy.policies", "")
+	appConfig.IpPolicy.Regions = r.String("app.signindatatracker.ippolicy.regions", "")
+	appConfig.IpPolicy.Sources = r.String("app.signindatatracker.ippolicy.sources", "")
+	appConfig.IpPolicy.Default = r.String("app.signindatatracker.ippolicy.default", "full")
+	appConfig.IpPolicy.HashSecret = r.String("app.signindatatracker.ippolicy.hashsecret", "")
+	appConfig.ConfigReloadSeconds = r.Int("app.signindatatracker.config.reloadseconds", 30)
 }
@@ -0,0 +1,200 @@
+package bootstrap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.mathworks.com/development/opi-utils-go/pkg/configutils"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils/env"
+)
+
+// ServiceRequiredKeys are the keys the web service cannot start without.
+var ServiceRequiredKeys = []string{
+	"app.signindatatracker.dynamo.endpoint",
+	"app.signindatatracker.dynamo.tablename",
+	"app.signindatatracker.dynamo.region",
+	"app.signindatatracker.ak.host",
+}
+
+// ConfigSources are the layers a configuration key is looked up in besides the overrides file.
+// The precedence, lowest first, is Defaults, the overrides file, the environment and Flags.
+type ConfigSources struct {
+	// Defaults are the mito application defaults, keys may leave out the "app." prefix
+	Defaults map[string]string
+	Flags    ConfigFlags
+	// RequiredKeys fail the load when they end up empty. Defaults never satisfy them, they have
+	// to be set for the deployment.
+	RequiredKeys []string
+}
+
+// ConfigFlags collects repeated -set key=value command line flags.
+type ConfigFlags map[string]string
+
+func (f ConfigFlags) String() string {
+	entries := make([]string, 0, len(f))
+	for key, value := range f {
+		entries = append(entries, key+"="+value)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+func (f ConfigFlags) Set(value string) error {
+	key, v, found := strings.Cut(value, "=")
+	if !found || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("config flag %q is not key=value", value)
+	}
+	f[strings.TrimSpace(key)] = v
+	return nil
+}
+
+// ParseConfigFlags collects the -set key=value flags of args, also written --set and -set=key=value.
+// Every other argument is left alone: the command line FlagSet is mito's, a flag.Parse here would
+// refuse the flags mito defines itself.
+func ParseConfigFlags(args []string) (ConfigFlags, error) {
+	flags := ConfigFlags{}
+	for i := 0; i < len(args); i++ {
+		name, value, inline := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || name != "set" {
+			continue
+		}
+		if !inline {
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("config flag %s needs a key=value", args[i])
+			}
+			i++
+			value = args[i]
+		}
+		if err := flags.Set(value); err != nil {
+			return nil, err
+		}
+	}
+	return flags, nil
+}
+
+// EnvName is the environment variable that overrides key,
+// app.signindatatracker.dynamo.endpoint is APP_SIGNINDATATRACKER_DYNAMO_ENDPOINT.
+func EnvName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// ConfigError reports every problem of a load at once, not only the first one.
+type ConfigError struct {
+	Location string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid configuration (%s):\n  - %s", e.Location, strings.Join(e.Problems, "\n  - "))
+}
+
+// configReader resolves typed keys through the config sources and records the value every key
+// resolved to, a reload compares them to find what changed.
+type configReader struct {
+	sources  ConfigSources
+	props    map[string]interface{}
+	values   map[string]string
+	problems []string
+	// pinned keys keep their value on a reload, a different value is only reported in changed
+	pinned  map[string]string
+	changed []string
+}
+
+func newConfigReader(location string, sources ConfigSources, pinned map[string]string) *configReader {
+	r := &configReader{sources: sources, values: make(map[string]string), pinned: pinned}
+	props, err := configutils.ReadPropFile(location)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("read overrides file: %v", err))
+		props = make(map[string]interface{})
+	}
+	r.props = props
+	return r
+}
+
+func (r *configReader) lookup(key string) (string, bool) {
+	if value, ok := r.sources.Flags[key]; ok {
+		return value, true
+	}
+	if value := env.GetEnv(EnvName(key), ""); value != "" {
+		return value, true
+	}
+	if value, ok := r.props[key].(string); ok {
+		return value, true
+	}
+	if r.required(key) {
+		return "", false
+	}
+	if value, ok := r.sources.Defaults[key]; ok {
+		return value, true
+	}
+	if value, ok := r.sources.Defaults[strings.TrimPrefix(key, "app.")]; ok {
+		return value, true
+	}
+	return "", false
+}
+
+func (r *configReader) required(key string) bool {
+	for _, required := range r.sources.RequiredKeys {
+		if required == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *configReader) raw(key, def string) string {
+	value, ok := r.lookup(key)
+	if !ok {
+		value = def
+	}
+	if pinned, ok := r.pinned[key]; ok {
+		if pinned != value {
+			r.changed = append(r.changed, key)
+		}
+		value = pinned
+	}
+	r.values[key] = value
+	return value
+}
+
+func (r *configReader) String(key, def string) string {
+	return r.raw(key, def)
+}
+
+func (r *configReader) Int(key string, def int) int {
+	value := r.raw(key, strconv.Itoa(def))
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s: %q is not an integer", key, value))
+		return def
+	}
+	return parsed
+}
+
+func (r *configReader) Bool(key string, def bool) bool {
+	value := r.raw(key, strconv.FormatBool(def))
+	parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s: %q is not a boolean", key, value))
+		return def
+	}
+	return parsed
+}
+
+// validate adds the required keys that resolved to nothing to the problems.
+func (r *configReader) validate() {
+	for _, key := range r.sources.RequiredKeys {
+		if strings.TrimSpace(r.values[key]) == "" {
+			r.problems = append(r.problems, fmt.Sprintf("%s is required, set it in the overrides file, as %s or with -set", key, EnvName(key)))
+		}
+	}
+}
+
+func (r *configReader) err(location string) error {
+	if len(r.problems) == 0 {
+		return nil
+	}
+	return &ConfigError{Location: location, Problems: r.problems}
+}
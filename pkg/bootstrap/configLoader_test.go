@@ -0,0 +1,146 @@
+package bootstrap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func writeOverrides(t *testing.T, location string, lines ...string) {
+	assert.Nil(t, os.WriteFile(location, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+}
+
+func TestConfigSourcesPrecedence(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "overrides.properties")
+	writeOverrides(t, location,
+		"app.signindatatracker.dynamo.tablename=fromFile",
+		"app.signindatatracker.dynamo.region=fromFile",
+		"app.signindatatracker.search.maxlimit=100")
+	t.Setenv(EnvName("app.signindatatracker.dynamo.region"), "fromEnv")
+	t.Setenv(EnvName("app.signindatatracker.search.maxlimit"), "200")
+	appConfig := &AppConfigData{OverridesLocation: location, Sources: ConfigSources{
+		Defaults: map[string]string{
+			"signindatatracker.dynamo.endpoint":  "http://localhost:8000",
+			"signindatatracker.dynamo.tablename": "fromDefaults",
+		},
+		Flags: ConfigFlags{"app.signindatatracker.search.maxlimit": "300"},
+	}}
+	assert.Nil(t, appConfig.loadOverrides())
+	assert.Equal(t, "http://localhost:8000", appConfig.Dynamo.EndPoint)
+	assert.Equal(t, "fromFile", appConfig.Dynamo.TableName)
+	assert.Equal(t, "fromEnv", appConfig.Dynamo.Region)
+	assert.Equal(t, 300, appConfig.Search.MaxLimit)
+	assert.Equal(t, 50, appConfig.Search.DefaultLimit)
+}
+
+func TestParseConfigFlagsLeavesOtherFlagsAlone(t *testing.T) {
+	flags, err := ParseConfigFlags([]string{"-config", "mito.properties", "-set", "a.b=1", "--set=c.d=x=y", "-settle", "-v"})
+	assert.Nil(t, err)
+	assert.Equal(t, ConfigFlags{"a.b": "1", "c.d": "x=y"}, flags)
+
+	_, err = ParseConfigFlags([]string{"-set"})
+	assert.NotNil(t, err)
+	_, err = ParseConfigFlags([]string{"-set", "novalue"})
+	assert.NotNil(t, err)
+}
+
+func TestConfigValidationReportsEveryProblem(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "overrides.properties")
+	writeOverrides(t, location,
+		"app.signindatatracker.dynamo.region=us-east-1",
+		"app.signindatatracker.search.maxlimit=lots",
+		"app.signindatatracker.authz.enabled=maybe")
+	appConfig := &AppConfigData{OverridesLocation: location, Sources: ConfigSources{RequiredKeys: ServiceRequiredKeys}}
+	err := appConfig.loadOverrides()
+	configErr, ok := err.(*ConfigError)
+	assert.True(t, ok)
+	assert.Len(t, configErr.Problems, 5)
+	assert.Contains(t, err.Error(), "app.signindatatracker.search.maxlimit: \"lots\" is not an integer")
+	assert.Contains(t, err.Error(), "APP_SIGNINDATATRACKER_AK_HOST")
+
+	missing := &AppConfigData{OverridesLocation: filepath.Join(t.TempDir(), "missing.properties")}
+	assert.NotNil(t, missing.loadOverrides())
+}
+
+func TestEncryptedIpAddressNeedsDigestSecret(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "overrides.properties")
+	writeOverrides(t, location, "app.signindatatracker.encryption.enabled=true")
+	appConfig := &AppConfigData{OverridesLocation: location}
+	err := appConfig.loadOverrides()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "app.signindatatracker.encryption.ipdigestsecret is required")
+
+	writeOverrides(t, location, "app.signindatatracker.encryption.enabled=true", "app.signindatatracker.encryption.fields=userAgent")
+	assert.Nil(t, appConfig.loadOverrides())
+}
+
+func TestAuthzDefaultsToEnabledOnlyWithGrants(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "overrides.properties")
+	writeOverrides(t, location, "app.signindatatracker.dynamo.region=us-east-1")
+	appConfig := &AppConfigData{OverridesLocation: location}
+	assert.Nil(t, appConfig.loadOverrides())
+	assert.False(t, appConfig.Authz.Enabled)
+
+	writeOverrides(t, location, "app.signindatatracker.authz.grants=MWA=signin:read:any")
+	assert.Nil(t, appConfig.loadOverrides())
+	assert.True(t, appConfig.Authz.Enabled)
+
+	writeOverrides(t, location, "app.signindatatracker.authz.enabled=true")
+	assert.Nil(t, appConfig.loadOverrides())
+	assert.True(t, appConfig.Authz.Enabled)
+}
+
+func TestServiceConfigFailsWithoutDynamoKeys(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "overrides.properties")
+	writeOverrides(t, location, "app.signindatatracker.ak.host=https://ak.example.com")
+	appConfig := &AppConfigData{OverridesLocation: location, Sources: ConfigSources{
+		Defaults: map[string]string{
+			"signindatatracker.dynamo.endpoint":  "http://localhost:8000",
+			"signindatatracker.dynamo.tablename": "signintracker",
+			"signindatatracker.dynamo.region":    "localhost",
+		},
+		RequiredKeys: ServiceRequiredKeys,
+	}}
+	err := appConfig.loadOverrides()
+	configErr, ok := err.(*ConfigError)
+	assert.True(t, ok)
+	assert.Len(t, configErr.Problems, 3)
+	assert.Contains(t, err.Error(), "APP_SIGNINDATATRACKER_DYNAMO_ENDPOINT")
+	assert.Contains(t, err.Error(), "APP_SIGNINDATATRACKER_DYNAMO_TABLENAME")
+	assert.Contains(t, err.Error(), "APP_SIGNINDATATRACKER_DYNAMO_REGION")
+	assert.Equal(t, "", appConfig.Dynamo.Env)
+}
+
+func TestReloadAppliesOnlyReloadableKeys(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "overrides.properties")
+	writeOverrides(t, location,
+		"app.signindatatracker.dynamo.tablename=signins",
+		"app.signindatatracker.search.maxlimit=100",
+		"app.signindatatracker.cache.ttlseconds=15")
+	appConfig := &AppConfigData{OverridesLocation: location}
+	assert.Nil(t, appConfig.loadOverrides())
+	cxt := &ApplicationContext{AppConfigData: appConfig}
+	var reloaded []*AppConfigData
+	cxt.OnConfigReload(func(config *AppConfigData) { reloaded = append(reloaded, config) })
+
+	writeOverrides(t, location,
+		"app.signindatatracker.dynamo.tablename=renamed",
+		"app.signindatatracker.search.maxlimit=20",
+		"app.signindatatracker.cache.ttlseconds=15",
+		"app.signindatatracker.webhook.timeoutseconds=30")
+	assert.Nil(t, cxt.reloadConfig(zap.NewNop()))
+	assert.Len(t, reloaded, 1)
+	assert.Equal(t, 20, cxt.Config().Search.MaxLimit)
+	assert.Equal(t, 30, cxt.Config().Webhook.TimeoutSeconds)
+	assert.Equal(t, "signins", cxt.Config().Dynamo.TableName)
+	assert.Equal(t, 100, appConfig.Search.MaxLimit, "the startup configuration is never modified")
+
+	writeOverrides(t, location, "app.signindatatracker.search.maxlimit=none")
+	assert.NotNil(t, cxt.reloadConfig(zap.NewNop()))
+	assert.Equal(t, 20, cxt.Config().Search.MaxLimit)
+	assert.Len(t, reloaded, 1)
+}
@@ -0,0 +1,125 @@
+package bootstrap
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReloadableKeys are safe to change while the service runs, the components that read them
+// subscribe with OnConfigReload or read Config when they need the value. A change to any other
+// key is logged and waits for a restart. cache.redis.timeoutmillis is not among them, it is
+// part of the Redis connection pool built at start. There is no retention key to reload, the
+// signindatatracker.dynamo.ttlinyears default is not read by the service.
+var ReloadableKeys = map[string]bool{
+	"app.signindatatracker.search.defaultlimit":           true,
+	"app.signindatatracker.search.maxlimit":               true,
+	"app.signindatatracker.cache.ttlseconds":              true,
+	"app.signindatatracker.ratelimit.requestsperminute":   true,
+	"app.signindatatracker.ratelimit.burst":               true,
+	"app.signindatatracker.ratelimit.overrides":           true,
+	"app.signindatatracker.webhook.timeoutseconds":        true,
+	"app.signindatatracker.ingest.shutdowntimeoutseconds": true,
+}
+
+// Config returns the current configuration. AppConfigData stays the one the service started
+// with; the returned value differs from it in reloaded keys only and is never modified.
+func (cxt *ApplicationContext) Config() *AppConfigData {
+	cxt.configMu.RLock()
+	defer cxt.configMu.RUnlock()
+	if cxt.config == nil {
+		return cxt.AppConfigData
+	}
+	return cxt.config
+}
+
+// OnConfigReload calls listener with the new configuration after every reload that changed a
+// reloadable key.
+func (cxt *ApplicationContext) OnConfigReload(listener func(*AppConfigData)) {
+	cxt.configMu.Lock()
+	defer cxt.configMu.Unlock()
+	cxt.listeners = append(cxt.listeners, listener)
+}
+
+// WatchConfig checks the overrides file every ConfigReloadSeconds and reloads it when it
+// changed, until stop is closed.
+func (cxt *ApplicationContext) WatchConfig(logger *zap.Logger, stop <-chan struct{}) {
+	interval := time.Duration(cxt.AppConfigData.ConfigReloadSeconds) * time.Second
+	if interval <= 0 {
+		return
+	}
+	location := cxt.AppConfigData.overridesLocation()
+	stamp := fileStamp(location)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if current := fileStamp(location); current != stamp {
+					stamp = current
+					_ = cxt.reloadConfig(logger)
+				}
+			}
+		}
+	}()
+}
+
+func fileStamp(location string) string {
+	info, err := os.Stat(location)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", info.ModTime(), info.Size())
+}
+
+// reloadConfig reads the configuration again with every key that is not reloadable pinned to
+// its running value. A configuration that does not validate is rejected as a whole.
+func (cxt *ApplicationContext) reloadConfig(logger *zap.Logger) error {
+	current := cxt.Config()
+	pinned := make(map[string]string, len(current.values))
+	for key, value := range current.values {
+		if !ReloadableKeys[key] {
+			pinned[key] = value
+		}
+	}
+	location := current.overridesLocation()
+	next := &AppConfigData{OverridesLocation: current.OverridesLocation, Sources: current.Sources, logger: current.logger}
+	r := newConfigReader(location, current.Sources, pinned)
+	next.read(r)
+	r.validate()
+	next.values = r.values
+	if err := r.err(location); err != nil {
+		logger.Error("Configuration change rejected, the running configuration is kept", zap.Error(err))
+		return err
+	}
+	if len(r.changed) > 0 {
+		sort.Strings(r.changed)
+		logger.Warn("Configuration keys changed that only apply after a restart", zap.Strings("keys", r.changed))
+	}
+
+	var applied []string
+	for key := range ReloadableKeys {
+		if next.values[key] != current.values[key] {
+			applied = append(applied, key)
+		}
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+	sort.Strings(applied)
+	cxt.configMu.Lock()
+	cxt.config = next
+	listeners := append([]func(*AppConfigData){}, cxt.listeners...)
+	cxt.configMu.Unlock()
+	for _, listener := range listeners {
+		listener(next)
+	}
+	logger.Info("Configuration reloaded", zap.Strings("keys", applied))
+	return nil
+}
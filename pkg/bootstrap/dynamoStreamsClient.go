@@ -0,0 +1,37 @@
+package bootstrap
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+)
+
+// DynamoDBStreamsClientAdapter is an adapter for the AWS DynamoDB Streams client.
+type DynamoDBStreamsClientAdapter struct {
+	client *dynamodbstreams.Client
+}
+
+func (d *DynamoDBStreamsClientAdapter) DescribeStream(ctx context.Context, params *dynamodbstreams.DescribeStreamInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.DescribeStreamOutput, error) {
+	return d.client.DescribeStream(ctx, params, optFns...)
+}
+
+func (d *DynamoDBStreamsClientAdapter) GetShardIterator(ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	return d.client.GetShardIterator(ctx, params, optFns...)
+}
+
+func (d *DynamoDBStreamsClientAdapter) GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error) {
+	return d.client.GetRecords(ctx, params, optFns...)
+}
+
+type DynamoDBStreamsClientInterface interface {
+	DescribeStream(ctx context.Context, params *dynamodbstreams.DescribeStreamInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.DescribeStreamOutput, error)
+	GetShardIterator(ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error)
+	GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error)
+}
+
+func NewDynamoDBStreamsClient(cfg aws.Config) DynamoDBStreamsClientInterface {
+	return &DynamoDBStreamsClientAdapter{
+		client: dynamodbstreams.NewFromConfig(cfg),
+	}
+}
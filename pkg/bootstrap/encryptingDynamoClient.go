@@ -0,0 +1,155 @@
+package bootstrap
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/envelope"
+)
+
+// EncryptingDynamoDBClient encrypts the sensitive attributes of items written to the configured
+// tables and decrypts them again in every read, the repositories only ever see plain text.
+// Filter and key conditions are evaluated by DynamoDB on the stored ciphertext, so encrypted
+// attributes cannot be searched on.
+type EncryptingDynamoDBClient struct {
+	client    DynamoDBClientInterface
+	encryptor *envelope.FieldEncryptor
+	tables    map[string]bool
+}
+
+func NewEncryptingDynamoDBClient(client DynamoDBClientInterface, encryptor *envelope.FieldEncryptor, tables []string) DynamoDBClientInterface {
+	encrypted := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		encrypted[table] = true
+	}
+	return &EncryptingDynamoDBClient{client: client, encryptor: encryptor, tables: encrypted}
+}
+
+func (c *EncryptingDynamoDBClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return c.client.DescribeTable(ctx, params, optFns...)
+}
+
+func (c *EncryptingDynamoDBClient) GetItem(ctx context.Context, input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	out, err := c.client.GetItem(ctx, input)
+	if err != nil || out == nil || out.Item == nil || !c.tables[aws.ToString(input.TableName)] {
+		return out, err
+	}
+	decrypted := *out
+	decrypted.Item, err = c.encryptor.Decrypt(ctx, out.Item)
+	if err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
+
+func (c *EncryptingDynamoDBClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	out, err := c.client.Query(ctx, params, optFns...)
+	if err != nil || out == nil || !c.tables[aws.ToString(params.TableName)] {
+		return out, err
+	}
+	decrypted := *out
+	decrypted.Items, err = c.decryptAll(ctx, out.Items)
+	if err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
+
+func (c *EncryptingDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	out, err := c.client.Scan(ctx, params, optFns...)
+	if err != nil || out == nil || !c.tables[aws.ToString(params.TableName)] {
+		return out, err
+	}
+	decrypted := *out
+	decrypted.Items, err = c.decryptAll(ctx, out.Items)
+	if err != nil {
+		return nil, err
+	}
+	return &decrypted, nil
+}
+
+func (c *EncryptingDynamoDBClient) ListTables(ctx context.Context, params *dynamodb.ListTablesInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error) {
+	return c.client.ListTables(ctx, params, optFns...)
+}
+
+func (c *EncryptingDynamoDBClient) PutItem(ctx context.Context, input *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	if !c.tables[aws.ToString(input.TableName)] {
+		return c.client.PutItem(ctx, input, optFns...)
+	}
+	item, err := c.encryptor.Encrypt(ctx, input.Item)
+	if err != nil {
+		return nil, err
+	}
+	params := *input
+	params.Item = item
+	return c.client.PutItem(ctx, &params, optFns...)
+}
+
+// UpdateItem passes updates through unencrypted. It is used on the summary and rollup tables
+// only, the repository stores keyed digests instead of IP addresses in their sets.
+func (c *EncryptingDynamoDBClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return c.client.UpdateItem(ctx, params, optFns...)
+}
+
+func (c *EncryptingDynamoDBClient) BatchWriteItem(ctx context.Context, input *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	params := *input
+	params.RequestItems = make(map[string][]types.WriteRequest, len(input.RequestItems))
+	for table, writes := range input.RequestItems {
+		if !c.tables[table] {
+			params.RequestItems[table] = writes
+			continue
+		}
+		encrypted := make([]types.WriteRequest, 0, len(writes))
+		for _, write := range writes {
+			if write.PutRequest != nil {
+				item, err := c.encryptor.Encrypt(ctx, write.PutRequest.Item)
+				if err != nil {
+					return nil, err
+				}
+				write = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+			}
+			encrypted = append(encrypted, write)
+		}
+		params.RequestItems[table] = encrypted
+	}
+	out, err := c.client.BatchWriteItem(ctx, &params, optFns...)
+	if err != nil || out == nil || len(out.UnprocessedItems) == 0 {
+		return out, err
+	}
+	// callers unmarshal and retry the unprocessed items, they are encrypted again on the retry
+	decrypted := *out
+	decrypted.UnprocessedItems = make(map[string][]types.WriteRequest, len(out.UnprocessedItems))
+	for table, writes := range out.UnprocessedItems {
+		if !c.tables[table] {
+			decrypted.UnprocessedItems[table] = writes
+			continue
+		}
+		plain := make([]types.WriteRequest, 0, len(writes))
+		for _, write := range writes {
+			if write.PutRequest != nil {
+				item, err := c.encryptor.Decrypt(ctx, write.PutRequest.Item)
+				if err != nil {
+					return nil, err
+				}
+				write = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+			}
+			plain = append(plain, write)
+		}
+		decrypted.UnprocessedItems[table] = plain
+	}
+	return &decrypted, nil
+}
+
+func (c *EncryptingDynamoDBClient) decryptAll(ctx context.Context, items []map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	decrypted := make([]map[string]types.AttributeValue, len(items))
+	for i, item := range items {
+		plain, err := c.encryptor.Decrypt(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		decrypted[i] = plain
+	}
+	return decrypted, nil
+}
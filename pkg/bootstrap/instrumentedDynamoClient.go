@@ -0,0 +1,144 @@
+package bootstrap
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/metrics"
+)
+
+// InstrumentedDynamoDBClient records latency, outcome and consumed capacity of every DynamoDB
+// call. It asks for the total consumed capacity on calls that did not request it.
+type InstrumentedDynamoDBClient struct {
+	client DynamoDBClientInterface
+}
+
+func NewInstrumentedDynamoDBClient(client DynamoDBClientInterface) DynamoDBClientInterface {
+	return &InstrumentedDynamoDBClient{client: client}
+}
+
+func observeDynamoCall(operation string, table string, start time.Time, err error, consumed ...types.ConsumedCapacity) {
+	metrics.DynamoLatency.WithLabelValues(operation, table).Observe(time.Since(start).Seconds())
+	metrics.DynamoRequests.WithLabelValues(operation, table, metrics.Outcome(err)).Inc()
+	for _, capacity := range consumed {
+		if capacity.CapacityUnits != nil {
+			metrics.DynamoConsumedCapacity.WithLabelValues(operation, aws.ToString(capacity.TableName)).Add(*capacity.CapacityUnits)
+		}
+	}
+}
+
+func withTotalCapacity(requested types.ReturnConsumedCapacity) types.ReturnConsumedCapacity {
+	if requested == "" {
+		return types.ReturnConsumedCapacityTotal
+	}
+	return requested
+}
+
+func consumedCapacity(capacity *types.ConsumedCapacity) []types.ConsumedCapacity {
+	if capacity == nil {
+		return nil
+	}
+	return []types.ConsumedCapacity{*capacity}
+}
+
+func (c *InstrumentedDynamoDBClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	start := time.Now()
+	out, err := c.client.DescribeTable(ctx, params, optFns...)
+	observeDynamoCall("DescribeTable", aws.ToString(params.TableName), start, err)
+	return out, err
+}
+
+func (c *InstrumentedDynamoDBClient) GetItem(ctx context.Context, input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	params := *input
+	params.ReturnConsumedCapacity = withTotalCapacity(params.ReturnConsumedCapacity)
+	start := time.Now()
+	out, err := c.client.GetItem(ctx, &params)
+	var consumed []types.ConsumedCapacity
+	if out != nil {
+		consumed = consumedCapacity(out.ConsumedCapacity)
+	}
+	observeDynamoCall("GetItem", aws.ToString(params.TableName), start, err, consumed...)
+	return out, err
+}
+
+func (c *InstrumentedDynamoDBClient) Query(ctx context.Context, input *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	params := *input
+	params.ReturnConsumedCapacity = withTotalCapacity(params.ReturnConsumedCapacity)
+	start := time.Now()
+	out, err := c.client.Query(ctx, &params, optFns...)
+	var consumed []types.ConsumedCapacity
+	if out != nil {
+		consumed = consumedCapacity(out.ConsumedCapacity)
+	}
+	observeDynamoCall("Query", aws.ToString(params.TableName), start, err, consumed...)
+	return out, err
+}
+
+func (c *InstrumentedDynamoDBClient) Scan(ctx context.Context, input *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	params := *input
+	params.ReturnConsumedCapacity = withTotalCapacity(params.ReturnConsumedCapacity)
+	start := time.Now()
+	out, err := c.client.Scan(ctx, &params, optFns...)
+	var consumed []types.ConsumedCapacity
+	if out != nil {
+		consumed = consumedCapacity(out.ConsumedCapacity)
+	}
+	observeDynamoCall("Scan", aws.ToString(params.TableName), start, err, consumed...)
+	return out, err
+}
+
+func (c *InstrumentedDynamoDBClient) ListTables(ctx context.Context, params *dynamodb.ListTablesInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error) {
+	start := time.Now()
+	out, err := c.client.ListTables(ctx, params, optFns...)
+	observeDynamoCall("ListTables", "", start, err)
+	return out, err
+}
+
+func (c *InstrumentedDynamoDBClient) PutItem(ctx context.Context, input *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	params := *input
+	params.ReturnConsumedCapacity = withTotalCapacity(params.ReturnConsumedCapacity)
+	start := time.Now()
+	out, err := c.client.PutItem(ctx, &params, optFns...)
+	var consumed []types.ConsumedCapacity
+	if out != nil {
+		consumed = consumedCapacity(out.ConsumedCapacity)
+	}
+	observeDynamoCall("PutItem", aws.ToString(params.TableName), start, err, consumed...)
+	return out, err
+}
+
+func (c *InstrumentedDynamoDBClient) UpdateItem(ctx context.Context, input *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	params := *input
+	params.ReturnConsumedCapacity = withTotalCapacity(params.ReturnConsumedCapacity)
+	start := time.Now()
+	out, err := c.client.UpdateItem(ctx, &params, optFns...)
+	var consumed []types.ConsumedCapacity
+	if out != nil {
+		consumed = consumedCapacity(out.ConsumedCapacity)
+	}
+	observeDynamoCall("UpdateItem", aws.ToString(params.TableName), start, err, consumed...)
+	return out, err
+}
+
+func (c *InstrumentedDynamoDBClient) BatchWriteItem(ctx context.Context, input *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	params := *input
+	params.ReturnConsumedCapacity = withTotalCapacity(params.ReturnConsumedCapacity)
+	tables := make([]string, 0, len(params.RequestItems))
+	for table := range params.RequestItems {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	start := time.Now()
+	out, err := c.client.BatchWriteItem(ctx, &params, optFns...)
+	var consumed []types.ConsumedCapacity
+	if out != nil {
+		consumed = out.ConsumedCapacity
+	}
+	observeDynamoCall("BatchWriteItem", strings.Join(tables, ","), start, err, consumed...)
+	return out, err
+}
@@ -0,0 +1,83 @@
+package bootstrap
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.mathworks.com/development/signindatatrackerws/pkg/resilience"
+)
+
+// ResilientDynamoDBClient decorates a DynamoDBClientInterface with retries and a circuit
+// breaker per operation.
+type ResilientDynamoDBClient struct {
+	client   DynamoDBClientInterface
+	executor *resilience.Executor
+}
+
+func NewResilientDynamoDBClient(client DynamoDBClientInterface, executor *resilience.Executor) DynamoDBClientInterface {
+	return &ResilientDynamoDBClient{client: client, executor: executor}
+}
+
+func (r *ResilientDynamoDBClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.DescribeTableOutput, err error) {
+	err = r.executor.Do(ctx, "DescribeTable", func() error {
+		out, err = r.client.DescribeTable(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (r *ResilientDynamoDBClient) GetItem(ctx context.Context, input *dynamodb.GetItemInput) (out *dynamodb.GetItemOutput, err error) {
+	err = r.executor.Do(ctx, "GetItem", func() error {
+		out, err = r.client.GetItem(ctx, input)
+		return err
+	})
+	return out, err
+}
+
+func (r *ResilientDynamoDBClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.QueryOutput, err error) {
+	err = r.executor.Do(ctx, "Query", func() error {
+		out, err = r.client.Query(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (r *ResilientDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.ScanOutput, err error) {
+	err = r.executor.Do(ctx, "Scan", func() error {
+		out, err = r.client.Scan(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (r *ResilientDynamoDBClient) ListTables(ctx context.Context, params *dynamodb.ListTablesInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.ListTablesOutput, err error) {
+	err = r.executor.Do(ctx, "ListTables", func() error {
+		out, err = r.client.ListTables(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (r *ResilientDynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.PutItemOutput, err error) {
+	err = r.executor.Do(ctx, "PutItem", func() error {
+		out, err = r.client.PutItem(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (r *ResilientDynamoDBClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.UpdateItemOutput, err error) {
+	err = r.executor.Do(ctx, "UpdateItem", func() error {
+		out, err = r.client.UpdateItem(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
+
+func (r *ResilientDynamoDBClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (out *dynamodb.BatchWriteItemOutput, err error) {
+	err = r.executor.Do(ctx, "BatchWriteItem", func() error {
+		out, err = r.client.BatchWriteItem(ctx, params, optFns...)
+		return err
+	})
+	return out, err
+}
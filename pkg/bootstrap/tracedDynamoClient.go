@@ -0,0 +1,102 @@
+package bootstrap
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.mathworks.com/development/signindatatrackerws/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracedDynamoDBClient starts a client span for every DynamoDB call as a child of the span in
+// the call's context.
+type TracedDynamoDBClient struct {
+	client DynamoDBClientInterface
+}
+
+func NewTracedDynamoDBClient(client DynamoDBClientInterface) DynamoDBClientInterface {
+	return &TracedDynamoDBClient{client: client}
+}
+
+func startDynamoSpan(ctx context.Context, operation string, tables ...string) (context.Context, trace.Span) {
+	return tracing.StartClientSpan(ctx, "DynamoDB."+operation,
+		attribute.String("db.system", "dynamodb"),
+		attribute.String("db.operation", operation),
+		attribute.StringSlice("aws.dynamodb.table_names", tables))
+}
+
+func (c *TracedDynamoDBClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	ctx, span := startDynamoSpan(ctx, "DescribeTable", aws.ToString(params.TableName))
+	out, err := c.client.DescribeTable(ctx, params, optFns...)
+	tracing.EndSpan(span, err)
+	return out, err
+}
+
+func (c *TracedDynamoDBClient) GetItem(ctx context.Context, input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	ctx, span := startDynamoSpan(ctx, "GetItem", aws.ToString(input.TableName))
+	out, err := c.client.GetItem(ctx, input)
+	tracing.EndSpan(span, err)
+	return out, err
+}
+
+func (c *TracedDynamoDBClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	ctx, span := startDynamoSpan(ctx, "Query", aws.ToString(params.TableName))
+	if params.IndexName != nil {
+		span.SetAttributes(attribute.String("aws.dynamodb.index_name", *params.IndexName))
+	}
+	out, err := c.client.Query(ctx, params, optFns...)
+	if out != nil {
+		span.SetAttributes(attribute.Int("aws.dynamodb.count", int(out.Count)))
+	}
+	tracing.EndSpan(span, err)
+	return out, err
+}
+
+func (c *TracedDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	ctx, span := startDynamoSpan(ctx, "Scan", aws.ToString(params.TableName))
+	if params.Segment != nil {
+		span.SetAttributes(attribute.Int("aws.dynamodb.segment", int(*params.Segment)))
+	}
+	out, err := c.client.Scan(ctx, params, optFns...)
+	if out != nil {
+		span.SetAttributes(attribute.Int("aws.dynamodb.count", int(out.Count)), attribute.Int("aws.dynamodb.scanned_count", int(out.ScannedCount)))
+	}
+	tracing.EndSpan(span, err)
+	return out, err
+}
+
+func (c *TracedDynamoDBClient) ListTables(ctx context.Context, params *dynamodb.ListTablesInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error) {
+	ctx, span := startDynamoSpan(ctx, "ListTables")
+	out, err := c.client.ListTables(ctx, params, optFns...)
+	tracing.EndSpan(span, err)
+	return out, err
+}
+
+func (c *TracedDynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	ctx, span := startDynamoSpan(ctx, "PutItem", aws.ToString(params.TableName))
+	out, err := c.client.PutItem(ctx, params, optFns...)
+	tracing.EndSpan(span, err)
+	return out, err
+}
+
+func (c *TracedDynamoDBClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	ctx, span := startDynamoSpan(ctx, "UpdateItem", aws.ToString(params.TableName))
+	out, err := c.client.UpdateItem(ctx, params, optFns...)
+	tracing.EndSpan(span, err)
+	return out, err
+}
+
+func (c *TracedDynamoDBClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	tables := make([]string, 0, len(params.RequestItems))
+	for table := range params.RequestItems {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	ctx, span := startDynamoSpan(ctx, "BatchWriteItem", tables...)
+	out, err := c.client.BatchWriteItem(ctx, params, optFns...)
+	tracing.EndSpan(span, err)
+	return out, err
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+const (
+	BackendNone   = "none"
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Cache stores serialized values. Implementations must be safe for concurrent use.
+type Cache interface {
+	Get(key string) (value []byte, found bool, err error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(keys ...string) error
+}
+
+// Metrics counts cache outcomes for the admin endpoint.
+type Metrics struct {
+	backend       string
+	hits          atomic.Int64
+	misses        atomic.Int64
+	errors        atomic.Int64
+	invalidations atomic.Int64
+}
+
+func NewMetrics(backend string) *Metrics {
+	return &Metrics{backend: backend}
+}
+
+func (m *Metrics) Hit()              { m.hits.Add(1) }
+func (m *Metrics) Miss()             { m.misses.Add(1) }
+func (m *Metrics) Error()            { m.errors.Add(1) }
+func (m *Metrics) Invalidated(n int) { m.invalidations.Add(int64(n)) }
+
+func (m *Metrics) Snapshot() domain.CacheStats {
+	stats := domain.CacheStats{
+		Backend:       m.backend,
+		Hits:          m.hits.Load(),
+		Misses:        m.misses.Load(),
+		Errors:        m.errors.Load(),
+		Invalidations: m.invalidations.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
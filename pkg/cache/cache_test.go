@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// standInServer is an in-process stand-in for a Redis server that understands the commands
+// RedisCache sends.
+type standInServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	values   map[string][]byte
+	expiry   map[string]time.Time
+	commands []string
+}
+
+func startStandInServer(t *testing.T) *standInServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &standInServer{listener: listener, values: map[string][]byte{}, expiry: map[string]time.Time{}}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *standInServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		request, err := readReply(reader)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range request.([]interface{}) {
+			args = append(args, string(arg.([]byte)))
+		}
+		conn.Write([]byte(s.execute(args)))
+	}
+}
+
+func (s *standInServer) execute(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, strings.ToUpper(args[0]))
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		if args[1] != "secret" {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case "GET":
+		value, ok := s.values[args[1]]
+		if !ok || time.Now().After(s.expiry[args[1]]) {
+			return "$-1\r\n"
+		}
+		return "$" + strconv.Itoa(len(value)) + "\r\n" + string(value) + "\r\n"
+	case "SET":
+		millis, _ := strconv.Atoi(args[4])
+		s.values[args[1]] = []byte(args[2])
+		s.expiry[args[1]] = time.Now().Add(time.Duration(millis) * time.Millisecond)
+		return "+OK\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				deleted++
+			}
+		}
+		return ":" + strconv.Itoa(deleted) + "\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsedAndExpires(t *testing.T) {
+	c := NewLRUCache(2)
+	now := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return now }
+
+	assert.NoError(t, c.Set("a", []byte("1"), time.Minute))
+	assert.NoError(t, c.Set("b", []byte("2"), time.Second))
+	_, found, _ := c.Get("a")
+	assert.True(t, found)
+	assert.NoError(t, c.Set("c", []byte("3"), time.Minute))
+	_, found, _ = c.Get("b")
+	assert.False(t, found, "b was the least recently used entry")
+
+	now = now.Add(2 * time.Minute)
+	_, found, _ = c.Get("a")
+	assert.False(t, found)
+	assert.Equal(t, 1, c.Len())
+
+	assert.NoError(t, c.Delete("c"))
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestRedisCacheAgainstStandIn(t *testing.T) {
+	server := startStandInServer(t)
+	c := NewRedisCache(RedisConfig{Address: server.listener.Addr().String(), Password: "secret", PoolSize: 2, Timeout: time.Second})
+
+	_, found, err := c.Get("profile:MWA-1")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.NoError(t, c.Set("profile:MWA-1", []byte(`{"uniqueId":"MWA-1"}`), time.Minute))
+	value, found, err := c.Get("profile:MWA-1")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, `{"uniqueId":"MWA-1"}`, string(value))
+
+	assert.NoError(t, c.Delete("profile:MWA-1", "last:MWA-1"))
+	_, found, _ = c.Get("profile:MWA-1")
+	assert.False(t, found)
+
+	// the pooled connection authenticated once
+	server.mu.Lock()
+	assert.Equal(t, []string{"AUTH", "GET", "SET", "GET", "DEL", "GET"}, server.commands)
+	server.mu.Unlock()
+
+	wrong := NewRedisCache(RedisConfig{Address: server.listener.Addr().String(), Password: "nope", Timeout: time.Second})
+	_, _, err = wrong.Get("profile:MWA-1")
+	assert.IsType(t, RedisError(""), err)
+}
+
+func TestMetricsSnapshot(t *testing.T) {
+	m := NewMetrics(BackendMemory)
+	m.Hit()
+	m.Hit()
+	m.Hit()
+	m.Miss()
+	m.Invalidated(3)
+	stats := m.Snapshot()
+	assert.Equal(t, int64(3), stats.Hits)
+	assert.Equal(t, 0.75, stats.HitRatio)
+	assert.Equal(t, int64(3), stats.Invalidations)
+}
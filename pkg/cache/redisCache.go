@@ -0,0 +1,205 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+type RedisConfig struct {
+	Address  string
+	Password string
+	DB       int
+	PoolSize int
+	Timeout  time.Duration
+}
+
+// RedisCache speaks the Redis protocol (RESP) so any compatible server can back the cache.
+// Connections are pooled and dropped after any error, the next call dials again.
+type RedisCache struct {
+	config RedisConfig
+	pool   chan *redisConn
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// RedisError is an error reply from the server.
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func NewRedisCache(config RedisConfig) *RedisCache {
+	if config.PoolSize < 1 {
+		config.PoolSize = 1
+	}
+	return &RedisCache{config: config, pool: make(chan *redisConn, config.PoolSize)}
+}
+
+func (r *RedisCache) Get(key string) ([]byte, bool, error) {
+	reply, err := r.do("GET", key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected reply to GET: %v", reply)
+	}
+	return value, true, nil
+}
+
+func (r *RedisCache) Set(key string, value []byte, ttl time.Duration) error {
+	_, err := r.do("SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+func (r *RedisCache) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := r.do(append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+// Do sends a raw command over the cache's connection pool, for state that is shared through the
+// same Redis but is not a cache entry.
+func (r *RedisCache) Do(args ...string) (interface{}, error) {
+	return r.do(args...)
+}
+
+func (r *RedisCache) do(args ...string) (interface{}, error) {
+	c, err := r.get()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.command(r.config.Timeout, args...)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// the connection state is unknown after a network or protocol error
+		c.conn.Close()
+		return nil, err
+	}
+	r.put(c)
+	return reply, err
+}
+
+func (r *RedisCache) get() (*redisConn, error) {
+	select {
+	case c := <-r.pool:
+		return c, nil
+	default:
+	}
+	conn, err := net.DialTimeout("tcp", r.config.Address, r.config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn)}
+	if r.config.Password != "" {
+		if _, err := c.command(r.config.Timeout, "AUTH", r.config.Password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if r.config.DB != 0 {
+		if _, err := c.command(r.config.Timeout, "SELECT", strconv.Itoa(r.config.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (r *RedisCache) put(c *redisConn) {
+	select {
+	case r.pool <- c:
+	default:
+		c.conn.Close()
+	}
+}
+
+func (c *redisConn) command(timeout time.Duration, args ...string) (interface{}, error) {
+	if timeout > 0 {
+		if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := c.conn.Write(encodeCommand(args...)); err != nil {
+		return nil, err
+	}
+	return readReply(c.reader)
+}
+
+// encodeCommand writes args as a RESP array of bulk strings.
+func encodeCommand(args ...string) []byte {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(arg))+"\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	return buf
+}
+
+// readReply returns string for simple strings, int64 for integers, []byte for bulk strings,
+// []interface{} for arrays and nil for null replies. Error replies are returned as RedisError.
+func readReply(reader *bufio.Reader) (interface{}, error) {
+	line, err := readLine(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, RedisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, value); err != nil {
+			return nil, err
+		}
+		return value[:size], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			value, err := readReply(reader)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,222 @@
+package collaborators
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/hyperloglog"
+	"github.mathworks.com/development/signindatatrackerws/pkg/repository/adapter"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ErrorCodeInvalidActiveUsersRequest = 5321
+	ErrorCodeActiveUsersDisabled       = 5322
+	// confidenceFactor widens the standard error to a ~95% interval
+	confidenceFactor        = 2
+	maxActiveUsersRangeDays = 366
+)
+
+var (
+	activeUserSketchesOnce   sync.Once
+	sharedActiveUserSketches *ActiveUserSketches
+)
+
+type ActiveUsersService struct {
+	logger   *zap.Logger
+	repo     adapter.ActiveUsersRepoInterface
+	sketches *ActiveUserSketches
+	now      func() time.Time
+}
+
+func NewActiveUsersService() *ActiveUsersService {
+	logger := zap.L().Named("signindatatrackerws.activeUsers")
+	repo := adapter.ActiveUsersRepoFactory()
+	activeUserSketchesOnce.Do(func() {
+		sharedActiveUserSketches = NewActiveUserSketches(repo, logger)
+		if repo.Enabled() {
+			interval := bootstrap.GetApplicationContext().AppConfigData.Dynamo.ActiveUsersFlushSeconds
+			sharedActiveUserSketches.Start(time.Duration(interval)*time.Second, nil)
+		}
+	})
+	return &ActiveUsersService{
+		logger:   logger,
+		repo:     repo,
+		sketches: sharedActiveUserSketches,
+		now:      time.Now,
+	}
+}
+
+// FlushActiveUsers writes the sketches of this process on shutdown.
+func FlushActiveUsers() {
+	if sharedActiveUserSketches != nil {
+		sharedActiveUserSketches.Flush()
+	}
+}
+
+type sketchKey struct {
+	sketchId string
+	day      string
+}
+
+// ActiveUserSketches collects the sign-ins of this process in memory and merges them into the
+// stored sketches on an interval. A sketch item is then written once per process and interval
+// instead of once per new user, which keeps the global sketch of the day from becoming a hot
+// key. A merge that fails stays pending for the next flush.
+type ActiveUserSketches struct {
+	mu      sync.Mutex
+	pending map[sketchKey]*hyperloglog.Sketch
+	repo    adapter.ActiveUsersRepoInterface
+	logger  *zap.Logger
+}
+
+func NewActiveUserSketches(repo adapter.ActiveUsersRepoInterface, logger *zap.Logger) *ActiveUserSketches {
+	return &ActiveUserSketches{pending: make(map[sketchKey]*hyperloglog.Sketch), repo: repo, logger: logger}
+}
+
+func (s *ActiveUserSketches) Add(sketchId string, day string, uniqueId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := sketchKey{sketchId: sketchId, day: day}
+	sketch, ok := s.pending[key]
+	if !ok {
+		sketch = hyperloglog.New()
+		s.pending[key] = sketch
+	}
+	sketch.Add(uniqueId)
+}
+
+// Start flushes every interval until stop is closed.
+func (s *ActiveUserSketches) Start(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				s.Flush()
+			}
+		}
+	}()
+}
+
+func (s *ActiveUserSketches) Flush() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[sketchKey]*hyperloglog.Sketch)
+	s.mu.Unlock()
+	for key, sketch := range pending {
+		if err := s.repo.MergeSketch(key.sketchId, key.day, sketch); err != nil {
+			s.logger.Warn("Could not update active user sketch, retrying with the next flush",
+				zap.String("sketchId", key.sketchId), zap.String("day", key.day), zap.Error(err))
+			s.keep(key, sketch)
+		}
+	}
+}
+
+func (s *ActiveUserSketches) keep(key sketchKey, sketch *hyperloglog.Sketch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if newer, ok := s.pending[key]; ok {
+		newer.Merge(sketch)
+		return
+	}
+	s.pending[key] = sketch
+}
+
+// RecordSignIn adds the profile to the global and the per-source sketch of the sign-in day.
+func (as *ActiveUsersService) RecordSignIn(signIn domain.SaveSignInInfo) {
+	if !as.repo.Enabled() {
+		return
+	}
+	millis, err := strconv.ParseInt(signIn.TimeStamp, 10, 64)
+	if err != nil {
+		as.logger.Warn("Skipping active user rollup, invalid timestamp", zap.String("timestamp", signIn.TimeStamp))
+		return
+	}
+	day := time.UnixMilli(millis).UTC().Format(adapter.RollupDayLayout)
+	sketchIds := []string{adapter.AllSourcesSketchId}
+	if signIn.SourceId != "" {
+		sketchIds = append(sketchIds, adapter.SourceSketchId(signIn.SourceId))
+	}
+	for _, sketchId := range sketchIds {
+		as.sketches.Add(sketchId, day, signIn.UniqueId)
+	}
+}
+
+func (as *ActiveUsersService) FindActiveUsers(request domain.RequestActiveUsersInput) (domain.ActiveUsersStats, domain.ErrorResponse, int) {
+	if !as.repo.Enabled() {
+		errresp := domain.ErrorResponse{
+			ErrorCode:    ErrorCodeActiveUsersDisabled,
+			ErrorMessage: "Active user rollups are not enabled",
+		}
+		return domain.ActiveUsersStats{}, errresp, http.StatusNotImplemented
+	}
+	to, err := utils.ParseTimeParam(request.To, as.now())
+	if err != nil {
+		return domain.ActiveUsersStats{}, invalidActiveUsersRequest(err), http.StatusBadRequest
+	}
+	from, err := utils.ParseTimeParam(request.From, to)
+	if err != nil {
+		return domain.ActiveUsersStats{}, invalidActiveUsersRequest(err), http.StatusBadRequest
+	}
+	if from.After(to) {
+		return domain.ActiveUsersStats{}, invalidActiveUsersRequest(fmt.Errorf("from is after to")), http.StatusBadRequest
+	}
+	if to.Sub(from) > maxActiveUsersRangeDays*24*time.Hour {
+		return domain.ActiveUsersStats{}, invalidActiveUsersRequest(fmt.Errorf("range exceeds %d days", maxActiveUsersRangeDays)), http.StatusBadRequest
+	}
+
+	sketchId := adapter.AllSourcesSketchId
+	if request.SourceId != "" {
+		sketchId = adapter.SourceSketchId(request.SourceId)
+	}
+	fromDay := from.UTC().Format(adapter.RollupDayLayout)
+	toDay := to.UTC().Format(adapter.RollupDayLayout)
+	sketches, err := as.repo.FindSketches(sketchId, fromDay, toDay)
+	if err != nil {
+		errresp, status := dbCallFailed(err, "Could not execute findActiveUsers")
+		return domain.ActiveUsersStats{}, errresp, status
+	}
+
+	merged := hyperloglog.New()
+	days := make([]domain.DailyActiveUsers, 0, len(sketches))
+	for _, daily := range sketches {
+		merged.Merge(daily.Sketch)
+		days = append(days, domain.DailyActiveUsers{Day: daily.Day, Estimate: daily.Sketch.Estimate()})
+	}
+	estimate := merged.Estimate()
+	margin := uint64(math.Ceil(float64(estimate) * confidenceFactor * hyperloglog.StandardError()))
+	lower := uint64(0)
+	if estimate > margin {
+		lower = estimate - margin
+	}
+
+	return domain.ActiveUsersStats{
+		From:          fromDay,
+		To:            toDay,
+		SourceId:      request.SourceId,
+		Estimate:      estimate,
+		StandardError: hyperloglog.StandardError(),
+		LowerBound:    lower,
+		UpperBound:    estimate + margin,
+		Days:          days,
+	}, domain.ErrorResponse{}, http.StatusOK
+}
+
+func invalidActiveUsersRequest(err error) domain.ErrorResponse {
+	return domain.ErrorResponse{
+		ErrorCode:    ErrorCodeInvalidActiveUsersRequest,
+		ErrorMessage: "Invalid active users request",
+		Error:        err.Error(),
+	}
+}
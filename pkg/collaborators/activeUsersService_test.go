@@ -0,0 +1,64 @@
+package collaborators
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/hyperloglog"
+	"github.mathworks.com/development/signindatatrackerws/pkg/repository/adapter"
+	"go.uber.org/zap"
+)
+
+// fakeSketchRepo max-merges like the table does and fails the first merges when asked to.
+type fakeSketchRepo struct {
+	stored   map[string]*hyperloglog.Sketch
+	merges   int
+	failures int
+}
+
+func (f *fakeSketchRepo) MergeSketch(sketchId string, day string, sketch *hyperloglog.Sketch) error {
+	f.merges++
+	if f.failures > 0 {
+		f.failures--
+		return adapter.ErrSketchContention
+	}
+	stored, ok := f.stored[sketchId+"/"+day]
+	if !ok {
+		stored = hyperloglog.New()
+		f.stored[sketchId+"/"+day] = stored
+	}
+	stored.Merge(sketch)
+	return nil
+}
+
+func (f *fakeSketchRepo) FindSketches(sketchId string, fromDay string, toDay string) ([]adapter.DailySketch, error) {
+	return nil, errors.New("not used")
+}
+
+func (f *fakeSketchRepo) Enabled() bool {
+	return true
+}
+
+func TestActiveUserSketchesMergeInMemoryAndKeepFailedFlushes(t *testing.T) {
+	repo := &fakeSketchRepo{stored: map[string]*hyperloglog.Sketch{}, failures: 1}
+	sketches := NewActiveUserSketches(repo, zap.L().Named("test-log-zap"))
+	for i := 0; i < 500; i++ {
+		sketches.Add(adapter.AllSourcesSketchId, "2022-08-24", "MWA-"+strconv.Itoa(i))
+	}
+
+	// one write for 500 sign-ins, and a lost race keeps the sketch for the next flush
+	sketches.Flush()
+	assert.Equal(t, 1, repo.merges)
+	assert.Empty(t, repo.stored)
+
+	sketches.Add(adapter.AllSourcesSketchId, "2022-08-24", "MWA-500")
+	sketches.Flush()
+	assert.Equal(t, 2, repo.merges)
+	estimate := repo.stored[adapter.AllSourcesSketchId+"/2022-08-24"].Estimate()
+	assert.InDelta(t, 501, float64(estimate), 501*3*hyperloglog.StandardError())
+
+	sketches.Flush()
+	assert.Equal(t, 2, repo.merges)
+}
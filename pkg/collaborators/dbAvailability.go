@@ -0,0 +1,28 @@
+package collaborators
+
+import (
+	"errors"
+	"net/http"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/resilience"
+)
+
+const ErrorCodeDatabaseUnavailable = 5504
+
+// dbCallFailed fast-fails with 503 while the DynamoDB breaker is open so callers can retry
+// later, any other failure keeps the 5500 response.
+func dbCallFailed(err error, message string) (domain.ErrorResponse, int) {
+	if errors.Is(err, resilience.ErrCircuitOpen) {
+		return domain.ErrorResponse{
+			ErrorCode:    ErrorCodeDatabaseUnavailable,
+			ErrorMessage: "DynamoDB is temporarily unavailable",
+			Error:        err.Error(),
+		}, http.StatusServiceUnavailable
+	}
+	return domain.ErrorResponse{
+		ErrorCode:    5500,
+		ErrorMessage: message,
+		Error:        err.Error(),
+	}, http.StatusBadRequest
+}
@@ -0,0 +1,97 @@
+package collaborators
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/ingest"
+)
+
+const ErrorCodeIngestionUnavailable = 5503
+
+var (
+	ingestionOnce  sync.Once
+	ingestionQueue *ingest.Queue
+	// ingestionStarted is set once the factory ran, sync saves only start it to divert writes
+	ingestionStarted atomic.Bool
+)
+
+// IngestionQueueFactory returns the process wide write-behind queue, replaying whatever the
+// previous run left in the write-ahead log.
+func IngestionQueueFactory() *ingest.Queue {
+	ingestionOnce.Do(func() {
+		config := bootstrap.GetApplicationContext().AppConfigData.Ingest
+		wal, replay, err := ingest.OpenWriteAheadLog(config.Directory, int64(config.MaxSegmentBytes))
+		if err != nil {
+			log.Fatalf("Failed to initialize ingestion write-ahead log: %v", err)
+		}
+		service := NewSignInTrackingService()
+		ingestionQueue = ingest.NewQueue(ingest.Config{
+			Capacity:           config.QueueCapacity,
+			Workers:            config.Workers,
+			BatchSize:          config.BatchSize,
+			BatchLinger:        time.Duration(config.BatchLingerMillis) * time.Millisecond,
+			InitialBackoff:     time.Duration(config.InitialBackoffMillis) * time.Millisecond,
+			MaxBackoff:         time.Duration(config.MaxBackoffMillis) * time.Millisecond,
+			MaxAttempts:        config.MaxAttempts,
+			DeadLetterLocation: filepath.Join(config.Directory, "deadletter.ndjson"),
+		}, wal, replay, &signInBatchProcessor{service: service}, service.logger.Named("ingest"))
+		ingestionQueue.Start()
+		ingestionStarted.Store(true)
+	})
+	return ingestionQueue
+}
+
+// ShutdownIngestion drains the queue when it was started, records that cannot be written in
+// time are replayed on the next start.
+func ShutdownIngestion() error {
+	if !ingestionStarted.Load() {
+		return nil
+	}
+	// the current configuration, the timeout may have been reloaded since the start
+	config := bootstrap.GetApplicationContext().Config().Ingest
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeoutSeconds)*time.Second)
+	defer cancel()
+	return IngestionQueueFactory().Shutdown(ctx)
+}
+
+// signInBatchProcessor runs the same steps as SaveSignInData for a batch of queued sign-ins.
+type signInBatchProcessor struct {
+	service *SignInTrackingService
+}
+
+func (p *signInBatchProcessor) Prepare(records []domain.SaveSignInInfo) {
+	for i := range records {
+		p.service.assessRisk(context.Background(), &records[i])
+	}
+}
+
+func (p *signInBatchProcessor) Write(records []domain.SaveSignInInfo) ([]domain.SaveSignInInfo, error) {
+	// BatchWriteItem rejects a batch with the same key twice, the later sign-in wins like it
+	// would with two PutItem calls
+	latest := map[string]int{}
+	unique := make([]domain.SaveSignInInfo, 0, len(records))
+	for _, record := range records {
+		key := record.UniqueId + "\x00" + record.TimeStamp
+		if i, ok := latest[key]; ok {
+			unique[i] = record
+			continue
+		}
+		latest[key] = len(unique)
+		unique = append(unique, record)
+	}
+	unprocessed, _, err := p.service.repo.BatchSaveSignInTrackingInfo(unique)
+	return unprocessed, err
+}
+
+func (p *signInBatchProcessor) Complete(records []domain.SaveSignInInfo) {
+	for _, record := range records {
+		p.service.afterSave(context.Background(), record)
+	}
+}
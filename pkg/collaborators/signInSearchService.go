@@ -0,0 +1,98 @@
+package collaborators
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/repository/adapter"
+	"github.mathworks.com/development/signindatatrackerws/pkg/search"
+	"github.mathworks.com/development/signindatatrackerws/pkg/tracing"
+	"go.uber.org/zap"
+)
+
+const ErrorCodeInvalidSearchRequest = 5330
+
+type SignInSearchService struct {
+	logger  *zap.Logger
+	repo    adapter.SignInRepoInterface
+	planner search.Planner
+	// mu guards config, its limits follow a configuration reload
+	mu     sync.RWMutex
+	config bootstrap.SearchConfig
+}
+
+func NewSignInSearchService() *SignInSearchService {
+	appContext := bootstrap.GetApplicationContext()
+	config := appContext.Config().Search
+	service := &SignInSearchService{
+		logger: zap.L().Named("signindatatrackerws.signinSearch"),
+		repo:   adapter.InstrumentedSignInRepoFactory(SignInTrackerTable),
+		planner: search.Planner{
+			IndexName:    config.IndexName,
+			ScanSegments: config.ScanSegments,
+			AllowScan:    config.AllowScan,
+			Encrypted:    appContext.Config().Encryption.EncryptedFields(SignInTrackerTable),
+		},
+		config: config,
+	}
+	appContext.OnConfigReload(func(config *bootstrap.AppConfigData) {
+		service.mu.Lock()
+		defer service.mu.Unlock()
+		service.config.DefaultLimit = config.Search.DefaultLimit
+		service.config.MaxLimit = config.Search.MaxLimit
+	})
+	return service
+}
+
+func (ss *SignInSearchService) SearchSignIns(ctx context.Context, request domain.SearchRequest) (domain.SearchResponse, domain.ErrorResponse, int) {
+	ctx, span := tracing.StartSpan(ctx, "SignInSearchService.SearchSignIns")
+	defer span.End()
+	plan, err := ss.planner.Plan(request.Filter)
+	if err != nil {
+		return domain.SearchResponse{}, invalidSearchRequest(err), http.StatusBadRequest
+	}
+	ss.mu.RLock()
+	defaultLimit, maxLimit := ss.config.DefaultLimit, ss.config.MaxLimit
+	ss.mu.RUnlock()
+	limit := request.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	page, err := ss.repo.SearchSignIns(ctx, plan, limit, request.NextToken)
+	if err == search.ErrInvalidToken {
+		return domain.SearchResponse{}, invalidSearchRequest(err), http.StatusBadRequest
+	}
+	if err != nil {
+		errresp, status := dbCallFailed(err, "Could not execute searchSignIns")
+		return domain.SearchResponse{}, errresp, status
+	}
+	tracing.Logger(ctx, ss.logger).Debug("Search executed", zap.String("accessPath", plan.AccessPath), zap.Int("scanned", page.ScannedCount), zap.Int("count", len(page.Items)))
+
+	response := domain.SearchResponse{
+		Items:     page.Items,
+		Count:     len(page.Items),
+		NextToken: page.NextToken,
+	}
+	if response.Items == nil {
+		response.Items = []domain.SignInInfo{}
+	}
+	if request.Explain {
+		response.Explain = plan.Explain(page.ScannedCount)
+	}
+	return response, domain.ErrorResponse{}, http.StatusOK
+}
+
+func invalidSearchRequest(err error) domain.ErrorResponse {
+	return domain.ErrorResponse{
+		ErrorCode:    ErrorCodeInvalidSearchRequest,
+		ErrorMessage: "Invalid search request",
+		Error:        err.Error(),
+	}
+}
@@ -0,0 +1,242 @@
+package collaborators
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/envelope"
+	"github.mathworks.com/development/signindatatrackerws/pkg/repository/adapter"
+	"github.mathworks.com/development/signindatatrackerws/pkg/tracing"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	GroupByDay      = "day"
+	GroupByHour     = "hour"
+	GroupBySourceId = "sourceId"
+	GroupByRegion   = "region"
+	GroupByCalledId = "calledId"
+
+	StatsSourceQuery  = "query"
+	StatsSourceRollup = "rollup"
+
+	ErrorCodeInvalidStatsRequest = 5320
+	hourBucketLayout             = "2006-01-02T15"
+)
+
+var statsGroupKeys = map[string]func(domain.SignInInfo) string{
+	GroupByDay:      func(s domain.SignInInfo) string { return timeBucket(s.TimeStamp, adapter.RollupDayLayout) },
+	GroupByHour:     func(s domain.SignInInfo) string { return timeBucket(s.TimeStamp, hourBucketLayout) },
+	GroupBySourceId: func(s domain.SignInInfo) string { return s.SourceId },
+	GroupByRegion:   func(s domain.SignInInfo) string { return s.Region },
+	GroupByCalledId: func(s domain.SignInInfo) string { return s.CalledId },
+}
+
+type SignInStatsService struct {
+	logger *zap.Logger
+	repo   adapter.SignInRepoInterface
+	config bootstrap.StatsConfig
+	now    func() time.Time
+	// rollupCoverage is the first day the rollups are complete for, zero when they are not served
+	rollupCoverage time.Time
+	// ipDigest matches the queried IPs to the digests of the rollup sets, nil when IPs are kept as is
+	ipDigest *envelope.Digester
+}
+
+func NewSignInStatsService() *SignInStatsService {
+	appConfig := bootstrap.GetApplicationContext().AppConfigData
+	service := &SignInStatsService{
+		logger: zap.L().Named("signindatatrackerws.signinStats"),
+		repo:   adapter.InstrumentedSignInRepoFactory(SignInTrackerTable),
+		config: appConfig.Stats,
+		now:    time.Now,
+	}
+	if appConfig.Stats.RollupCoverageStart != "" {
+		// validated when the configuration is read
+		service.rollupCoverage, _ = time.Parse(adapter.RollupDayLayout, appConfig.Stats.RollupCoverageStart)
+	}
+	if appConfig.Encryption.IpDigested() {
+		service.ipDigest = envelope.NewDigester([]byte(appConfig.Encryption.IpDigestSecret))
+	}
+	return service
+}
+
+func (ss *SignInStatsService) FindSignInStats(ctx context.Context, request domain.RequestStatsInput) (domain.SignInStats, domain.ErrorResponse, int) {
+	ctx, span := tracing.StartSpan(ctx, "SignInStatsService.FindSignInStats")
+	defer span.End()
+	groupBy := request.GroupBy
+	if groupBy == "" {
+		groupBy = GroupByDay
+	}
+	groupKey, ok := statsGroupKeys[groupBy]
+	if !ok {
+		return domain.SignInStats{}, invalidStatsRequest(fmt.Errorf("unsupported groupBy %q", groupBy)), http.StatusBadRequest
+	}
+	end, err := utils.ParseTimeParam(request.EndTime, ss.now())
+	if err != nil {
+		return domain.SignInStats{}, invalidStatsRequest(err), http.StatusBadRequest
+	}
+	start, err := utils.ParseTimeParam(request.StartTime, end.AddDate(0, 0, -ss.config.DefaultRangeDays))
+	if err != nil {
+		return domain.SignInStats{}, invalidStatsRequest(err), http.StatusBadRequest
+	}
+	if start.After(end) {
+		return domain.SignInStats{}, invalidStatsRequest(fmt.Errorf("startTime is after endTime")), http.StatusBadRequest
+	}
+
+	stats := domain.SignInStats{
+		UniqueId:  request.UniqueID,
+		GroupBy:   groupBy,
+		StartTime: start.UTC().Format(time.RFC3339),
+		EndTime:   end.UTC().Format(time.RFC3339),
+	}
+
+	// long daily ranges are served from the pre-aggregated rollups when they are maintained,
+	// the partial days at either end and the days before the rollups are complete are queried
+	if groupBy == GroupByDay && ss.repo.RollupEnabled() && end.Sub(start) > time.Duration(ss.config.RollupThresholdDays)*24*time.Hour {
+		if firstDay, endDay, ok := ss.rollupDays(start, end); ok {
+			if err := ss.mixRollupStats(ctx, &stats, request.UniqueID, start, end, firstDay, endDay); err != nil {
+				errresp, status := statsQueryFailed(err)
+				return domain.SignInStats{}, errresp, status
+			}
+			return stats, domain.ErrorResponse{}, http.StatusOK
+		}
+	}
+
+	signIns, err := ss.repo.QuerySignInsBetween(ctx, request.UniqueID, strconv.FormatInt(start.UnixMilli(), 10), strconv.FormatInt(end.UnixMilli(), 10))
+	if err != nil {
+		errresp, status := statsQueryFailed(err)
+		return domain.SignInStats{}, errresp, status
+	}
+	stats.Source = StatsSourceQuery
+	stats.Total, stats.DistinctIps, stats.Buckets = AggregateSignIns(signIns, groupKey)
+	return stats, domain.ErrorResponse{}, http.StatusOK
+}
+
+// rollupDays returns the whole UTC days of [start, end] covered by the rollups, as the first day and the
+// exclusive end day. ok is false when there is no such day.
+func (ss *SignInStatsService) rollupDays(start time.Time, end time.Time) (firstDay time.Time, endDay time.Time, ok bool) {
+	if ss.rollupCoverage.IsZero() {
+		return time.Time{}, time.Time{}, false
+	}
+	firstDay = start.UTC().Truncate(24 * time.Hour)
+	if firstDay.Before(start) {
+		firstDay = firstDay.AddDate(0, 0, 1)
+	}
+	if firstDay.Before(ss.rollupCoverage) {
+		firstDay = ss.rollupCoverage
+	}
+	endDay = end.UTC().Truncate(24 * time.Hour)
+	return firstDay, endDay, firstDay.Before(endDay)
+}
+
+// mixRollupStats fills the stats from the rollups of [firstDay, endDay) and the sign-ins queried around them.
+func (ss *SignInStatsService) mixRollupStats(ctx context.Context, stats *domain.SignInStats, uniqueId string, start time.Time, end time.Time, firstDay time.Time, endDay time.Time) error {
+	rollups, err := ss.repo.QueryDailyRollups(ctx, uniqueId, firstDay, endDay.AddDate(0, 0, -1))
+	if err != nil {
+		return err
+	}
+	signIns := []domain.SignInInfo{}
+	if start.Before(firstDay) {
+		head, err := ss.repo.QuerySignInsBetween(ctx, uniqueId, strconv.FormatInt(start.UnixMilli(), 10), strconv.FormatInt(firstDay.UnixMilli()-1, 10))
+		if err != nil {
+			return err
+		}
+		signIns = append(signIns, head...)
+	}
+	tail, err := ss.repo.QuerySignInsBetween(ctx, uniqueId, strconv.FormatInt(endDay.UnixMilli(), 10), strconv.FormatInt(end.UnixMilli(), 10))
+	if err != nil {
+		return err
+	}
+	signIns = append(signIns, tail...)
+
+	allIps := map[string]bool{}
+	for i := range signIns {
+		signIns[i].IpAddress = ss.ipDigest.Digest(signIns[i].IpAddress)
+		if signIns[i].IpAddress != "" {
+			allIps[signIns[i].IpAddress] = true
+		}
+	}
+	for _, rollup := range rollups {
+		for _, ip := range rollup.Ips {
+			allIps[ip] = true
+		}
+	}
+	// the queried days never overlap the rollup days, so the buckets only need merging in order
+	queryTotal, _, queryBuckets := AggregateSignIns(signIns, statsGroupKeys[GroupByDay])
+	rollupTotal, _, rollupBuckets := AggregateRollups(rollups)
+	buckets := append(queryBuckets, rollupBuckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Key < buckets[j].Key })
+
+	stats.Source = StatsSourceRollup
+	stats.Total = queryTotal + rollupTotal
+	stats.DistinctIps = len(allIps)
+	stats.Buckets = buckets
+	return nil
+}
+
+// AggregateSignIns counts sign-ins and distinct IPs per bucket, buckets are sorted by key.
+func AggregateSignIns(signIns []domain.SignInInfo, groupKey func(domain.SignInInfo) string) (total int, distinctIps int, buckets []domain.StatsBucket) {
+	counts := map[string]int{}
+	ips := map[string]map[string]bool{}
+	allIps := map[string]bool{}
+	for _, signIn := range signIns {
+		key := groupKey(signIn)
+		counts[key]++
+		if ips[key] == nil {
+			ips[key] = map[string]bool{}
+		}
+		if signIn.IpAddress != "" {
+			ips[key][signIn.IpAddress] = true
+			allIps[signIn.IpAddress] = true
+		}
+	}
+	buckets = make([]domain.StatsBucket, 0, len(counts))
+	for key, count := range counts {
+		buckets = append(buckets, domain.StatsBucket{Key: key, Count: count, DistinctIps: len(ips[key])})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Key < buckets[j].Key })
+	return len(signIns), len(allIps), buckets
+}
+
+func AggregateRollups(rollups []domain.SignInRollup) (total int, distinctIps int, buckets []domain.StatsBucket) {
+	allIps := map[string]bool{}
+	buckets = make([]domain.StatsBucket, 0, len(rollups))
+	for _, rollup := range rollups {
+		total += rollup.Count
+		for _, ip := range rollup.Ips {
+			allIps[ip] = true
+		}
+		key := rollup.Bucket[len(adapter.DailyRollupPrefix):]
+		buckets = append(buckets, domain.StatsBucket{Key: key, Count: rollup.Count, DistinctIps: len(rollup.Ips)})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Key < buckets[j].Key })
+	return total, len(allIps), buckets
+}
+
+func timeBucket(timestamp string, layout string) string {
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ""
+	}
+	return time.UnixMilli(millis).UTC().Format(layout)
+}
+
+func invalidStatsRequest(err error) domain.ErrorResponse {
+	return domain.ErrorResponse{
+		ErrorCode:    ErrorCodeInvalidStatsRequest,
+		ErrorMessage: "Invalid statistics request",
+		Error:        err.Error(),
+	}
+}
+
+func statsQueryFailed(err error) (domain.ErrorResponse, int) {
+	return dbCallFailed(err, "Could not execute findSignInStats")
+}
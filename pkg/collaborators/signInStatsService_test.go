@@ -0,0 +1,127 @@
+package collaborators
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/repository/adapter"
+	"go.uber.org/zap"
+)
+
+func TestAggregateSignIns(t *testing.T) {
+	signIns := []domain.SignInInfo{
+		{TimeStamp: "1661285996251", IpAddress: "10.0.0.1", SourceId: "web"},
+		{TimeStamp: "1661285996252", IpAddress: "10.0.0.1", SourceId: "desktop"},
+		{TimeStamp: "1661372396251", IpAddress: "10.0.0.2", SourceId: "web"},
+	}
+
+	t.Run("Group By Day", func(t *testing.T) {
+		total, distinctIps, buckets := AggregateSignIns(signIns, statsGroupKeys[GroupByDay])
+		assert.Equal(t, 3, total)
+		assert.Equal(t, 2, distinctIps)
+		assert.Equal(t, []domain.StatsBucket{
+			{Key: "2022-08-23", Count: 2, DistinctIps: 1},
+			{Key: "2022-08-24", Count: 1, DistinctIps: 1},
+		}, buckets)
+	})
+
+	t.Run("Group By Source", func(t *testing.T) {
+		_, _, buckets := AggregateSignIns(signIns, statsGroupKeys[GroupBySourceId])
+		assert.Equal(t, []domain.StatsBucket{
+			{Key: "desktop", Count: 1, DistinctIps: 1},
+			{Key: "web", Count: 2, DistinctIps: 2},
+		}, buckets)
+	})
+}
+
+func TestAggregateRollups(t *testing.T) {
+	total, distinctIps, buckets := AggregateRollups([]domain.SignInRollup{
+		{Bucket: "day#2022-08-24", Count: 4, Ips: []string{"10.0.0.1", "10.0.0.2"}},
+		{Bucket: "day#2022-08-23", Count: 1, Ips: []string{"10.0.0.1"}},
+	})
+	assert.Equal(t, 5, total)
+	assert.Equal(t, 2, distinctIps)
+	assert.Equal(t, "2022-08-23", buckets[0].Key)
+}
+
+// statsRepo serves the rollups and sign-ins of one profile, the other repository methods are not used by the stats
+type statsRepo struct {
+	adapter.SignInRepoInterface
+	rollups []domain.SignInRollup
+	signIns []domain.SignInInfo
+}
+
+func (r *statsRepo) RollupEnabled() bool { return true }
+
+func (r *statsRepo) QueryDailyRollups(ctx context.Context, partitionKey string, startDay time.Time, endDay time.Time) ([]domain.SignInRollup, error) {
+	result := []domain.SignInRollup{}
+	for _, rollup := range r.rollups {
+		if rollup.Bucket >= adapter.DailyRollupBucket(startDay) && rollup.Bucket <= adapter.DailyRollupBucket(endDay) {
+			result = append(result, rollup)
+		}
+	}
+	return result, nil
+}
+
+func (r *statsRepo) QuerySignInsBetween(ctx context.Context, partitionKey string, startMillis string, endMillis string) ([]domain.SignInInfo, error) {
+	start, _ := strconv.ParseInt(startMillis, 10, 64)
+	end, _ := strconv.ParseInt(endMillis, 10, 64)
+	result := []domain.SignInInfo{}
+	for _, signIn := range r.signIns {
+		millis, _ := strconv.ParseInt(signIn.TimeStamp, 10, 64)
+		if millis >= start && millis <= end {
+			result = append(result, signIn)
+		}
+	}
+	return result, nil
+}
+
+func TestRollupStatsQueryPartialAndUncoveredDays(t *testing.T) {
+	at := func(value string) string {
+		parsed, _ := time.Parse(time.RFC3339, value)
+		return strconv.FormatInt(parsed.UnixMilli(), 10)
+	}
+	repo := &statsRepo{signIns: []domain.SignInInfo{
+		{TimeStamp: at("2022-08-01T06:00:00Z"), IpAddress: "10.0.0.1"},
+		{TimeStamp: at("2022-08-01T13:00:00Z"), IpAddress: "10.0.0.1"},
+		{TimeStamp: at("2022-08-05T10:00:00Z"), IpAddress: "10.0.0.2"},
+		{TimeStamp: at("2022-09-05T03:00:00Z"), IpAddress: "10.0.0.1"},
+		{TimeStamp: at("2022-09-05T07:00:00Z"), IpAddress: "10.0.0.3"},
+	}}
+	// rollups exist before the coverage start too, they are incomplete there and must not be used
+	for day := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC); day.Before(time.Date(2022, 9, 6, 0, 0, 0, 0, time.UTC)); day = day.AddDate(0, 0, 1) {
+		repo.rollups = append(repo.rollups, domain.SignInRollup{Bucket: adapter.DailyRollupBucket(day), Count: 2, Ips: []string{"10.0.0.1"}})
+	}
+	service := &SignInStatsService{
+		logger:         zap.NewNop(),
+		repo:           repo,
+		config:         bootstrap.StatsConfig{RollupThresholdDays: 31, DefaultRangeDays: 30},
+		now:            time.Now,
+		rollupCoverage: time.Date(2022, 8, 10, 0, 0, 0, 0, time.UTC),
+	}
+
+	stats, _, status := service.FindSignInStats(context.Background(), domain.RequestStatsInput{
+		UniqueID: "user", StartTime: "2022-08-01T12:00:00Z", EndTime: "2022-09-05T06:00:00Z"})
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, StatsSourceRollup, stats.Source)
+	// 08-01 after noon, 08-05 and 09-05 until six are queried, 08-10 to 09-04 come from 26 rollups
+	assert.Equal(t, 3+26*2, stats.Total)
+	assert.Equal(t, 2, stats.DistinctIps)
+	assert.Equal(t, domain.StatsBucket{Key: "2022-08-01", Count: 1, DistinctIps: 1}, stats.Buckets[0])
+	assert.Equal(t, domain.StatsBucket{Key: "2022-08-10", Count: 2, DistinctIps: 1}, stats.Buckets[2])
+	assert.Equal(t, domain.StatsBucket{Key: "2022-09-05", Count: 1, DistinctIps: 1}, stats.Buckets[len(stats.Buckets)-1])
+	assert.Len(t, stats.Buckets, 2+26+1)
+
+	service.rollupCoverage = time.Time{}
+	stats, _, _ = service.FindSignInStats(context.Background(), domain.RequestStatsInput{
+		UniqueID: "user", StartTime: "2022-08-01T12:00:00Z", EndTime: "2022-09-05T06:00:00Z"})
+	assert.Equal(t, StatsSourceQuery, stats.Source)
+	assert.Equal(t, 3, stats.Total)
+}
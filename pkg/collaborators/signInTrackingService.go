@@ -1,42 +1,159 @@
 package collaborators
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
 	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/ingest"
+	"github.mathworks.com/development/signindatatrackerws/pkg/ippolicy"
 	"github.mathworks.com/development/signindatatrackerws/pkg/repository/adapter"
+	"github.mathworks.com/development/signindatatrackerws/pkg/resilience"
+	"github.mathworks.com/development/signindatatrackerws/pkg/risk"
+	"github.mathworks.com/development/signindatatrackerws/pkg/tracing"
+	"github.mathworks.com/development/signindatatrackerws/pkg/useragent"
+	"github.mathworks.com/development/signindatatrackerws/pkg/webhooks"
 	"go.uber.org/zap"
 )
 
 type SignInTrackingServiceInterface interface {
-	FindSignInTrackingInfo(input domain.RequestInput) (domain.RequestInput, error)
-	SaveSignInData(input domain.SaveSignInInfo) (domain.SaveSignInInfo, error)
-	FindSignInTrackingDetails(request domain.RequestDetailsInput) ([]domain.SignInInfo, domain.ErrorResponse, int)
-	PingDB() error
+	SaveSignInData(ctx context.Context, request domain.SaveSignInInfo) (domain.SaveSignInInfo, domain.ErrorResponse, int)
+	EnqueueSignInData(ctx context.Context, request domain.SaveSignInInfo) (domain.SaveSignInInfo, domain.ErrorResponse, int)
+	AsyncIngestion() bool
+	DivertsWrites() bool
+	FindUniqueSignInInfo(ctx context.Context, request domain.RequestInput) (domain.SignInInfo, domain.ErrorResponse, int)
+	FindSignInTrackingDetails(ctx context.Context, request domain.RequestDetailsInput) ([]domain.SignInInfo, domain.ErrorResponse, int)
+	FindLastSignIn(ctx context.Context, request domain.RequestDetailsInput) (domain.LastSignInResponse, domain.ErrorResponse, int)
+	FindRiskySignIns(ctx context.Context, request domain.RequestDetailsInput) ([]domain.SignInInfo, domain.ErrorResponse, int)
+	FindSignInReferenceIds(ctx context.Context, request domain.RequestReferenceIdInput) ([]domain.SignInInfo, domain.ErrorResponse, int)
+	FindSignInPeriodDetails(ctx context.Context, request domain.RequestTimestampInput) ([]domain.SignInInfo, domain.ErrorResponse, int)
+	PingDB(ctx context.Context) (*dynamodb.ListTablesOutput, error)
 }
 
+var _ SignInTrackingServiceInterface = (*SignInTrackingService)(nil)
+
 type SignInTrackingService struct {
-	logger *zap.Logger
-	repo   adapter.SignInRepoInterface
-	keys   []string
+	logger      *zap.Logger
+	repo        adapter.SignInRepoInterface
+	uaParser    useragent.UserAgentParserInterface
+	riskEngine  risk.RiskEngineInterface
+	publisher   webhooks.PublisherInterface
+	activeUsers *ActiveUsersService
+	ipPolicies  *ippolicy.Policies
+	// asyncIngestion routes saves through the write-behind queue
+	asyncIngestion bool
+	// divertWrites queues saves while the DynamoDB breaker is open
+	divertWrites bool
+	keys         []string
 }
 
 const SignInTrackerTable = "signindatatracker"
 
 func NewSignInTrackingService() *SignInTrackingService {
+	logger := zap.L().Named("signindatatrackerws.signinTracking")
+	appConfig := bootstrap.GetApplicationContext().AppConfigData
+	ipPolicies, err := ippolicy.FromConfig(appConfig.IpPolicy)
+	if err != nil {
+		log.Fatalf("Failed to read ip policies: %v", err)
+	}
 	svc := &SignInTrackingService{
 
-		logger: zap.L().Named("signindatatrackerws.signinTracking"),
-		repo:   adapter.SignInRepoFactory(SignInTrackerTable),
+		logger:   logger,
+		repo:     adapter.CachedSignInRepoFactory(SignInTrackerTable),
+		uaParser: useragent.NewParser(appConfig.UserAgent.RulesLocation, logger),
+		riskEngine: risk.NewEngine(appConfig.Risk,
+			risk.NewFileGeoLocator(appConfig.Risk.GeoDatabaseLocation, logger), logger.Named("risk")),
+		publisher:      webhooks.DispatcherFactory(),
+		activeUsers:    NewActiveUsersService(),
+		ipPolicies:     ipPolicies,
+		asyncIngestion: appConfig.Ingest.Async,
+		divertWrites:   appConfig.Resilience.Enabled && appConfig.Resilience.DivertWritesWhenOpen,
 	}
 	return svc
 }
 
-func (ps *SignInTrackingService) SaveSignInData(request domain.SaveSignInInfo) (domain.SaveSignInInfo, domain.ErrorResponse, int) {
+func (ps *SignInTrackingService) SaveSignInData(ctx context.Context, request domain.SaveSignInInfo) (domain.SaveSignInInfo, domain.ErrorResponse, int) {
+	ctx, span := tracing.StartSpan(ctx, "SignInTrackingService.SaveSignInData")
+	defer span.End()
+
+	signInInfo, errresp, status := ps.newSignInRecord(request)
+	if errresp.ErrorCode != 0 {
+		return domain.SaveSignInInfo{}, errresp, status
+	}
+	ps.assessRisk(ctx, &signInInfo)
+
+	profiles, err := ps.repo.SaveSignInTrackingInfo(ctx, signInInfo)
+	if err != nil && errors.Is(err, resilience.ErrCircuitOpen) {
+		if ps.divertWrites {
+			queueErr := IngestionQueueFactory().Enqueue(signInInfo)
+			if queueErr == nil {
+				tracing.Logger(ctx, ps.logger).Warn("DynamoDB unavailable, sign-in diverted to the ingestion queue", zap.String("uniqueId", signInInfo.UniqueId))
+				return signInInfo, domain.ErrorResponse{}, http.StatusAccepted
+			}
+			tracing.Logger(ctx, ps.logger).Error("Could not divert sign-in to the ingestion queue", zap.Error(queueErr))
+		}
+		errresp, status := dbCallFailed(err, "Could not save SignInData")
+		return domain.SaveSignInInfo{}, errresp, status
+	}
+	if err != nil {
+		errresp := domain.ErrorResponse{
+			ErrorCode:    5500,
+			ErrorMessage: "Could not save SignInData",
+			Error:        err.Error(),
+		}
+		return domain.SaveSignInInfo{}, errresp, http.StatusInternalServerError
+	}
+	ps.afterSave(ctx, profiles)
+
+	return profiles, domain.ErrorResponse{}, http.StatusOK
+}
+
+// EnqueueSignInData stamps and validates the sign-in like SaveSignInData but leaves risk
+// evaluation and the write to the ingestion queue workers.
+func (ps *SignInTrackingService) EnqueueSignInData(ctx context.Context, request domain.SaveSignInInfo) (domain.SaveSignInInfo, domain.ErrorResponse, int) {
+	ctx, span := tracing.StartSpan(ctx, "SignInTrackingService.EnqueueSignInData")
+	defer span.End()
+
+	signInInfo, errresp, status := ps.newSignInRecord(request)
+	if errresp.ErrorCode != 0 {
+		return domain.SaveSignInInfo{}, errresp, status
+	}
+	err := IngestionQueueFactory().Enqueue(signInInfo)
+	if errors.Is(err, ingest.ErrQueueFull) || errors.Is(err, ingest.ErrQueueClosed) {
+		errresp := domain.ErrorResponse{
+			ErrorCode:    ErrorCodeIngestionUnavailable,
+			ErrorMessage: "Sign-in ingestion is temporarily unavailable",
+			Error:        err.Error(),
+		}
+		return domain.SaveSignInInfo{}, errresp, http.StatusServiceUnavailable
+	}
+	if err != nil {
+		errresp := domain.ErrorResponse{
+			ErrorCode:    5500,
+			ErrorMessage: "Could not save SignInData",
+			Error:        err.Error(),
+		}
+		return domain.SaveSignInInfo{}, errresp, http.StatusInternalServerError
+	}
+	return signInInfo, domain.ErrorResponse{}, http.StatusAccepted
+}
+
+func (ps *SignInTrackingService) AsyncIngestion() bool {
+	return ps.asyncIngestion
+}
+
+func (ps *SignInTrackingService) DivertsWrites() bool {
+	return ps.divertWrites
+}
+
+func (ps *SignInTrackingService) newSignInRecord(request domain.SaveSignInInfo) (domain.SaveSignInInfo, domain.ErrorResponse, int) {
 
 	// Check if uniqueId is empty
 	if request.UniqueId == "" {
@@ -51,45 +168,67 @@ func (ps *SignInTrackingService) SaveSignInData(request domain.SaveSignInInfo) (
 		request.ReferenceId = "NULL"
 	}
 
-	signInInfo := domain.SaveSignInInfo{
-		UniqueId:    request.UniqueId,
-		TimeStamp:   strconv.FormatInt(int64(time.Now().UnixMilli()), 10),
-		CalledId:    request.CalledId,
-		SourceId:    request.SourceId,
-		IpAddress:   request.IpAddress,
-		Region:      request.Region,
-		UserAgent:   request.UserAgent,
-		ReferenceId: request.ReferenceId,
-		SsoOrgId:    request.SsoOrgId,
+	uaDetails := ps.uaParser.Parse(request.UserAgent)
+
+	record := domain.SaveSignInInfo{
+		UniqueId:       request.UniqueId,
+		TimeStamp:      strconv.FormatInt(int64(time.Now().UnixMilli()), 10),
+		CalledId:       request.CalledId,
+		SourceId:       request.SourceId,
+		IpAddress:      request.IpAddress,
+		Region:         request.Region,
+		UserAgent:      request.UserAgent,
+		ReferenceId:    request.ReferenceId,
+		SsoOrgId:       request.SsoOrgId,
+		Device:         uaDetails.Device,
+		Os:             uaDetails.Os,
+		OsVersion:      uaDetails.OsVersion,
+		Browser:        uaDetails.Browser,
+		BrowserVersion: uaDetails.BrowserVersion,
 	}
+	// before anything else sees the record, the ingestion queue keeps it on disk and risk
+	// evaluation already works with the stored IP
+	ps.ipPolicies.Minimize(&record)
+	return record, domain.ErrorResponse{}, http.StatusOK
+}
 
-	profiles, err := ps.repo.SaveSignInTrackingInfo(signInInfo)
+func (ps *SignInTrackingService) assessRisk(ctx context.Context, signInInfo *domain.SaveSignInInfo) {
+	// a failed history lookup must not block the sign-in from being recorded
+	history, err := ps.repo.FindSignInTrackingDetails(ctx, signInInfo.UniqueId)
 	if err != nil {
-		errresp := domain.ErrorResponse{
-			ErrorCode:    5500,
-			ErrorMessage: "Could not save SignInData",
-			Error:        err.Error(),
-		}
-		return domain.SaveSignInInfo{}, errresp, http.StatusInternalServerError
+		tracing.Logger(ctx, ps.logger).Warn("Could not load sign-in history for risk evaluation", zap.Error(err))
+		return
 	}
+	assessment := ps.riskEngine.Evaluate(*signInInfo, history)
+	signInInfo.RiskScore = assessment.Score
+	signInInfo.RiskReasons = assessment.Reasons
+}
 
-	return profiles, domain.ErrorResponse{}, http.StatusOK
+func (ps *SignInTrackingService) afterSave(ctx context.Context, profiles domain.SaveSignInInfo) {
+	if err := ps.repo.UpdateSignInSummary(ctx, profiles); err != nil {
+		// the sign-in itself is stored, a stale summary is preferable to a failed save
+		tracing.Logger(ctx, ps.logger).Warn("Could not update sign-in summary", zap.String("uniqueId", profiles.UniqueId), zap.Error(err))
+	}
+	if err := ps.repo.UpdateSignInRollup(ctx, profiles); err != nil {
+		tracing.Logger(ctx, ps.logger).Warn("Could not update sign-in rollup", zap.String("uniqueId", profiles.UniqueId), zap.Error(err))
+	}
+	ps.activeUsers.RecordSignIn(profiles)
+	ps.publisher.Publish(profiles)
 }
-func (ps *SignInTrackingService) FindUniqueSignInInfo(request domain.RequestInput) (domain.SignInInfo, domain.ErrorResponse, int) {
+
+func (ps *SignInTrackingService) FindUniqueSignInInfo(ctx context.Context, request domain.RequestInput) (domain.SignInInfo, domain.ErrorResponse, int) {
+	ctx, span := tracing.StartSpan(ctx, "SignInTrackingService.FindUniqueSignInInfo")
+	defer span.End()
 	// Define your condition and tableName
 	condition := map[string]interface{}{
 		"uniqueId":  request.UniqueID,  // Use UniqueID from RequestInput
 		"timestamp": request.Timestamp, // Use ReferenceId from RequestInput
 	}
 	// Call the FindUniqueSignInInfo function
-	profiles, err := ps.repo.FindUniqueSignInInfo(condition)
+	profiles, err := ps.repo.FindUniqueSignInInfo(ctx, condition)
 	if err != nil {
-		errresp := domain.ErrorResponse{
-			ErrorCode:    5500,
-			ErrorMessage: "Could not execute findSignInTrackingInfo",
-			Error:        err.Error(),
-		}
-		return domain.SignInInfo{}, errresp, http.StatusBadRequest
+		errresp, status := dbCallFailed(err, "Could not execute findSignInTrackingInfo")
+		return domain.SignInInfo{}, errresp, status
 	}
 
 	// Map the DynamoDB output to RequestInput
@@ -107,57 +246,97 @@ func (ps *SignInTrackingService) FindUniqueSignInInfo(request domain.RequestInpu
 	return resultOutput, domain.ErrorResponse{}, http.StatusOK
 }
 
-func (ps *SignInTrackingService) FindSignInTrackingDetails(request domain.RequestDetailsInput) ([]domain.SignInInfo, domain.ErrorResponse, int) {
+func (ps *SignInTrackingService) FindSignInTrackingDetails(ctx context.Context, request domain.RequestDetailsInput) ([]domain.SignInInfo, domain.ErrorResponse, int) {
+	ctx, span := tracing.StartSpan(ctx, "SignInTrackingService.FindSignInTrackingDetails")
+	defer span.End()
 
 	// Call the FindSignInTrackingDetails function
-	profiles, err := ps.repo.FindSignInTrackingDetails(request.UniqueID)
+	profiles, err := ps.repo.FindSignInTrackingDetails(ctx, request.UniqueID)
 	if err != nil {
-		errresp := domain.ErrorResponse{
-			ErrorCode:    5500,
-			ErrorMessage: "Could not execute findSignInTrackingInfo",
-			Error:        err.Error(),
-		}
-		return []domain.SignInInfo{}, errresp, http.StatusBadRequest
+		errresp, status := dbCallFailed(err, "Could not execute findSignInTrackingInfo")
+		return []domain.SignInInfo{}, errresp, status
 	}
 
 	return profiles, domain.ErrorResponse{}, http.StatusOK
 }
 
-func (ps *SignInTrackingService) FindSignInReferenceIds(request domain.RequestReferenceIdInput) ([]domain.SignInInfo, domain.ErrorResponse, int) {
+func (ps *SignInTrackingService) FindLastSignIn(ctx context.Context, request domain.RequestDetailsInput) (domain.LastSignInResponse, domain.ErrorResponse, int) {
+	ctx, span := tracing.StartSpan(ctx, "SignInTrackingService.FindLastSignIn")
+	defer span.End()
 
-	// Call the FindSignInTrackingDetails function
-	profiles, err := ps.repo.GetSignInForReferenceId(request)
+	lastSignIn, found, err := ps.repo.FindLastSignIn(ctx, request.UniqueID)
 	if err != nil {
+		errresp, status := dbCallFailed(err, "Could not execute findLastSignIn")
+		return domain.LastSignInResponse{}, errresp, status
+	}
+	if !found {
 		errresp := domain.ErrorResponse{
-			ErrorCode:    5500,
-			ErrorMessage: "Could not execute findSignInTrackingInfo",
-			Error:        err.Error(),
+			ErrorCode:    5404,
+			ErrorMessage: "No sign-in found for UniqueId",
 		}
-		return []domain.SignInInfo{}, errresp, http.StatusBadRequest
+		return domain.LastSignInResponse{}, errresp, http.StatusNotFound
+	}
+
+	response := domain.LastSignInResponse{LastSignIn: lastSignIn}
+	summary, found, err := ps.repo.FindSignInSummary(ctx, request.UniqueID)
+	if err != nil {
+		tracing.Logger(ctx, ps.logger).Warn("Could not read sign-in summary", zap.String("uniqueId", request.UniqueID), zap.Error(err))
+	} else if found {
+		response.Summary = &summary
+	}
+	return response, domain.ErrorResponse{}, http.StatusOK
+}
+
+func (ps *SignInTrackingService) FindRiskySignIns(ctx context.Context, request domain.RequestDetailsInput) ([]domain.SignInInfo, domain.ErrorResponse, int) {
+	ctx, span := tracing.StartSpan(ctx, "SignInTrackingService.FindRiskySignIns")
+	defer span.End()
+
+	profiles, err := ps.repo.FindSignInTrackingDetails(ctx, request.UniqueID)
+	if err != nil {
+		errresp, status := dbCallFailed(err, "Could not execute findRiskySignIns")
+		return []domain.SignInInfo{}, errresp, status
+	}
+
+	risky := []domain.SignInInfo{}
+	for _, profile := range profiles {
+		if ps.riskEngine.IsRisky(profile.RiskScore) {
+			risky = append(risky, profile)
+		}
+	}
+	return risky, domain.ErrorResponse{}, http.StatusOK
+}
+
+func (ps *SignInTrackingService) FindSignInReferenceIds(ctx context.Context, request domain.RequestReferenceIdInput) ([]domain.SignInInfo, domain.ErrorResponse, int) {
+	ctx, span := tracing.StartSpan(ctx, "SignInTrackingService.FindSignInReferenceIds")
+	defer span.End()
+
+	// Call the FindSignInTrackingDetails function
+	profiles, err := ps.repo.GetSignInForReferenceId(ctx, request)
+	if err != nil {
+		errresp, status := dbCallFailed(err, "Could not execute findSignInTrackingInfo")
+		return []domain.SignInInfo{}, errresp, status
 	}
 
 	return profiles, domain.ErrorResponse{}, http.StatusOK
 }
 
-func (ps *SignInTrackingService) FindSignInPeriodDetails(request domain.RequestTimestampInput) ([]domain.SignInInfo, domain.ErrorResponse, int) {
+func (ps *SignInTrackingService) FindSignInPeriodDetails(ctx context.Context, request domain.RequestTimestampInput) ([]domain.SignInInfo, domain.ErrorResponse, int) {
+	ctx, span := tracing.StartSpan(ctx, "SignInTrackingService.FindSignInPeriodDetails")
+	defer span.End()
 
 	// Call the FindSignInTrackingDetails function
-	profiles, err := ps.repo.GetSignInBetweenTimeStamps(request)
+	profiles, err := ps.repo.GetSignInBetweenTimeStamps(ctx, request)
 	if err != nil {
-		errresp := domain.ErrorResponse{
-			ErrorCode:    5500,
-			ErrorMessage: "Could not execute findSignInTrackingInfo",
-			Error:        err.Error(),
-		}
-		return []domain.SignInInfo{}, errresp, http.StatusBadRequest
+		errresp, status := dbCallFailed(err, "Could not execute findSignInTrackingInfo")
+		return []domain.SignInInfo{}, errresp, status
 	}
 
 	return profiles, domain.ErrorResponse{}, http.StatusOK
 }
 
-func (ps *SignInTrackingService) PingDB() (*dynamodb.ListTablesOutput, error) {
+func (ps *SignInTrackingService) PingDB(ctx context.Context) (*dynamodb.ListTablesOutput, error) {
 
-	table, err := ps.repo.PingDB()
+	table, err := ps.repo.PingDB(ctx)
 	if err != nil {
 		_ = domain.ErrorResponse{
 			ErrorCode:    5700,
@@ -8,6 +8,7 @@ import (
 	"github.mathworks.com/development/mito/pkg/core"
 	"github.mathworks.com/development/mito/pkg/mwhttp"
 	"github.mathworks.com/development/opi-utils-go/pkg/configutils"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
 	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
 	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
 	"go.uber.org/zap"
@@ -19,6 +20,7 @@ var AliveControllerConstants = &ControllerMetaData{
 	LoggerName:      "alive.controller",
 	JsonContentType: "application/json",
 	AllowedMethods:  []string{http.MethodGet},
+	Auth:            authz.RouteAuthenticated,
 }
 
 func AliveControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *AliveController {
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.mathworks.com/development/mito/pkg/config"
+	"github.mathworks.com/development/mito/pkg/core"
+	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/audit"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ErrorCodeAuditDisabled = 5350
+	ErrorCodeAuditStore    = 5550
+	ParamCallerId          = "callerId"
+	ParamLimit             = "limit"
+	defaultAuditLimit      = 100
+	maxAuditLimit          = 1000
+)
+
+var AuditAdminControllerConstants = &ControllerMetaData{
+	Name:            "auditAdmin",
+	Path:            []string{"/v1/admin/audit", "/v1/admin/audit/verify"},
+	LoggerName:      "auditAdmin.controller",
+	JsonContentType: "application/json",
+	AllowedMethods:  []string{http.MethodGet},
+	Scopes:          []string{authz.ScopeAdmin},
+	Auth:            authz.RouteAdmin,
+}
+
+func AuditAdminControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *AuditAdminController {
+	controller := &AuditAdminController{
+		logger:   zap.L().Named(AuditAdminControllerConstants.Name),
+		auditLog: audit.LogFactory(),
+	}
+	registry.AddServiceProvider(AuditAdminControllerConstants.Name, controller, core.PublicRoute)
+	for _, path := range AuditAdminControllerConstants.Path {
+		router.AddRoute(path, AuditAdminControllerConstants.Name)
+	}
+	return controller
+}
+
+type AuditAdminController struct {
+	logger   *zap.Logger
+	auditLog *audit.FileLog
+}
+
+// Receive lists audit entries by uniqueId and/or callerId, newest first, or verifies the chain.
+func (aac AuditAdminController) Receive(message core.Message, ctx core.Context) (core.Message, error) {
+	var ar = new(domain.MonoResponse)
+	packet, err := utils.HttpMsgExtractor(message, aac.logger, AuditAdminControllerConstants.AllowedMethods, &ar)
+	if err != nil {
+		return packet.Response, nil
+	}
+	if _, response, ok := authorize(AuditAdminControllerConstants, packet.Request.Request, aac.logger); !ok {
+		return response, nil
+	}
+	if aac.auditLog == nil {
+		errresp := domain.ErrorResponse{
+			ErrorCode:    ErrorCodeAuditDisabled,
+			ErrorMessage: "Audit log is disabled",
+		}
+		return utils.DispatchJsonResponse(errresp, aac.logger, http.StatusNotFound)
+	}
+
+	switch packet.Request.Request.URL.Path {
+	case "/v1/admin/audit":
+		return aac.handleQuery(packet)
+	case "/v1/admin/audit/verify":
+		return utils.DispatchJsonResponse(aac.auditLog.Verify(), aac.logger, http.StatusOK)
+	}
+	return nil, fmt.Errorf("invalid Path: %s", packet.Request.Request.URL.Path)
+}
+
+func (aac AuditAdminController) handleQuery(packet *utils.HttpPacket) (core.Message, error) {
+	query := audit.Query{
+		UniqueId: extractQueryParamHelper(packet.QueryParams, ParamUniqueID),
+		CallerId: extractQueryParamHelper(packet.QueryParams, ParamCallerId),
+		Limit:    defaultAuditLimit,
+	}
+	if query.UniqueId == "" && query.CallerId == "" {
+		return mwhttp.NewSimpleResponseText(http.StatusBadRequest, "uniqueId or callerId is required"), nil
+	}
+	if raw := extractQueryParamHelper(packet.QueryParams, ParamLimit); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxAuditLimit {
+			return mwhttp.NewSimpleResponseText(http.StatusBadRequest, "Invalid limit"), nil
+		}
+		query.Limit = limit
+	}
+	entries, err := aac.auditLog.Query(query)
+	if err != nil {
+		errresp := domain.ErrorResponse{
+			ErrorCode:    ErrorCodeAuditStore,
+			ErrorMessage: "Could not read audit log",
+			Error:        err.Error(),
+		}
+		return utils.DispatchJsonResponse(errresp, aac.logger, http.StatusInternalServerError)
+	}
+	return utils.DispatchJsonResponse(entries, aac.logger, http.StatusOK)
+}
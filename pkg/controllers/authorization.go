@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"github.mathworks.com/development/mito/pkg/core"
+	"github.mathworks.com/development/signindatatrackerws/pkg/audit"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/pseudonym"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ErrorCodeForbidden        = 4403
+	ErrorCodePseudonymization = 5560
+)
+
+// authorize checks the caller attached by the access key filter against the route's scopes.
+// When it returns false the 403 response is already built.
+func authorize(meta *ControllerMetaData, request *http.Request, logger *zap.Logger) (authz.Caller, core.Message, bool) {
+	caller, ok := authz.CallerFromContext(request.Context())
+	if !ok || !caller.HasAny(meta.Scopes) {
+		response, _ := forbidden(request, logger, "Caller is not allowed to use "+request.URL.Path)
+		return caller, response, false
+	}
+	return caller, nil, true
+}
+
+func forbidden(request *http.Request, logger *zap.Logger, message string) (core.Message, error) {
+	caller, _ := authz.CallerFromContext(request.Context())
+	logger.Info("Request refused", zap.String("callerId", caller.Id), zap.String("path", request.URL.Path), zap.String("reason", message))
+	errresp := domain.ErrorResponse{
+		ErrorCode:    ErrorCodeForbidden,
+		ErrorMessage: message,
+		RequestID:    request.Header.Get("mathworks-requestid"),
+	}
+	return utils.DispatchJsonResponse(errresp, logger, http.StatusForbidden)
+}
+
+// recordAudit adds who made the request to entry and appends it to the audit log. A failed
+// write is logged, it does not fail the request.
+func recordAudit(auditLog *audit.FileLog, request *http.Request, logger *zap.Logger, entry domain.AuditEntry) {
+	if auditLog == nil {
+		return
+	}
+	caller, _ := authz.CallerFromContext(request.Context())
+	entry.CallerId = caller.Id
+	entry.Subject = caller.Subject
+	entry.Route = request.URL.Path
+	entry.RequestId = request.Header.Get("mathworks-requestid")
+	if _, err := auditLog.Append(entry); err != nil {
+		logger.Error("Failed to write audit entry", zap.String("route", entry.Route), zap.String("uniqueId", entry.UniqueId), zap.Error(err))
+	}
+}
+
+// pseudonymizerMissing refuses pseudonymized callers while no pseudonym secret is configured.
+func pseudonymizerMissing(caller authz.Caller, pseudonymizer *pseudonym.Pseudonymizer, request *http.Request, logger *zap.Logger) (core.Message, bool) {
+	if !caller.Pseudonymized() || pseudonymizer != nil {
+		return nil, false
+	}
+	response, _ := forbidden(request, logger, "Pseudonymized reads are not configured")
+	return response, true
+}
+
+// dispatchRead answers a read with data, pseudonymized when the caller on ctx may only read
+// pseudonymized sign-ins.
+func dispatchRead(ctx context.Context, pseudonymizer *pseudonym.Pseudonymizer, data interface{}, logger *zap.Logger) (core.Message, error) {
+	if caller, _ := authz.CallerFromContext(ctx); caller.Pseudonymized() {
+		pseudonymized, err := pseudonymizer.Apply(data)
+		if err != nil {
+			logger.Error("Failed to pseudonymize response", zap.Error(err))
+			errresp := domain.ErrorResponse{
+				ErrorCode:    ErrorCodePseudonymization,
+				ErrorMessage: "Could not pseudonymize the response",
+				Error:        err.Error(),
+			}
+			return utils.DispatchJsonResponse(errresp, logger, http.StatusInternalServerError)
+		}
+		data = pseudonymized
+	}
+	return utils.DispatchJsonResponse(data, logger, http.StatusOK)
+}
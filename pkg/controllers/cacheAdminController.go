@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.mathworks.com/development/mito/pkg/config"
+	"github.mathworks.com/development/mito/pkg/core"
+	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/cache"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/repository/adapter"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var CacheAdminControllerConstants = &ControllerMetaData{
+	Name:            "cacheAdmin",
+	Path:            []string{"/v1/admin/cache"},
+	LoggerName:      "cacheAdmin.controller",
+	JsonContentType: "application/json",
+	AllowedMethods:  []string{http.MethodGet},
+	Scopes:          []string{authz.ScopeAdmin},
+	Auth:            authz.RouteAdmin,
+}
+
+func CacheAdminControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *CacheAdminController {
+	_, metrics := adapter.ReadCacheFactory()
+	controller := &CacheAdminController{
+		logger:  zap.L().Named(CacheAdminControllerConstants.Name),
+		metrics: metrics,
+	}
+	registry.AddServiceProvider(CacheAdminControllerConstants.Name, controller, core.PublicRoute)
+	router.AddRoute(CacheAdminControllerConstants.Path[0], CacheAdminControllerConstants.Name)
+	return controller
+}
+
+type CacheAdminController struct {
+	logger  *zap.Logger
+	metrics *cache.Metrics
+}
+
+// Receive reports the read cache hit and miss counters since the process started.
+func (cac CacheAdminController) Receive(message core.Message, ctx core.Context) (core.Message, error) {
+	var ar = new(domain.MonoResponse)
+	packet, err := utils.HttpMsgExtractor(message, cac.logger, CacheAdminControllerConstants.AllowedMethods, &ar)
+	if err != nil {
+		return packet.Response, nil
+	}
+	if _, response, ok := authorize(CacheAdminControllerConstants, packet.Request.Request, cac.logger); !ok {
+		return response, nil
+	}
+	return utils.DispatchJsonResponse(cac.metrics.Snapshot(), cac.logger, http.StatusOK)
+}
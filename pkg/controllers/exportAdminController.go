@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.mathworks.com/development/mito/pkg/config"
+	"github.mathworks.com/development/mito/pkg/core"
+	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/audit"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/collaborators"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/export"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ErrorCodeInvalidExport  = 5340
+	ErrorCodeExportNotFound = 5341
+	ErrorCodeExportConflict = 5342
+	ErrorCodeExportStore    = 5540
+)
+
+var ExportAdminControllerConstants = &ControllerMetaData{
+	Name:            "exportAdmin",
+	Path:            []string{"/v1/admin/exports", "/v1/admin/exports/status", "/v1/admin/exports/resume", "/v1/admin/exports/cancel"},
+	LoggerName:      "exportAdmin.controller",
+	JsonContentType: "application/json",
+	AllowedMethods:  []string{http.MethodGet, http.MethodPost},
+	Scopes:          []string{authz.ScopeAdmin},
+	Auth:            authz.RouteAdmin,
+}
+
+func ExportAdminControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *ExportAdminController {
+	controller := &ExportAdminController{
+		logger:   zap.L().Named(ExportAdminControllerConstants.Name),
+		manager:  export.ManagerFactory(collaborators.SignInTrackerTable),
+		auditLog: audit.LogFactory(),
+	}
+	registry.AddServiceProvider(ExportAdminControllerConstants.Name, controller, core.PublicRoute)
+	for _, path := range ExportAdminControllerConstants.Path {
+		router.AddRoute(path, ExportAdminControllerConstants.Name)
+	}
+	return controller
+}
+
+type ExportAdminController struct {
+	logger   *zap.Logger
+	manager  *export.Manager
+	auditLog *audit.FileLog
+}
+
+func (eac ExportAdminController) Receive(message core.Message, ctx core.Context) (core.Message, error) {
+	var ar = new(domain.ExportRequest)
+	packet, err := utils.HttpMsgExtractor(message, eac.logger, ExportAdminControllerConstants.AllowedMethods, &ar)
+	if err != nil {
+		return packet.Response, nil
+	}
+	if _, response, ok := authorize(ExportAdminControllerConstants, packet.Request.Request, eac.logger); !ok {
+		return response, nil
+	}
+
+	var pathToHandler = map[string]map[string]func(*utils.HttpPacket, *domain.ExportRequest) (core.Message, error){
+		"/v1/admin/exports": {
+			http.MethodGet:  eac.handleList,
+			http.MethodPost: eac.handleStart,
+		},
+		"/v1/admin/exports/status": {http.MethodGet: eac.handleStatus},
+		"/v1/admin/exports/resume": {http.MethodPost: eac.handleResume},
+		"/v1/admin/exports/cancel": {http.MethodPost: eac.handleCancel},
+	}
+
+	handlers, ok := pathToHandler[packet.Request.Request.URL.Path]
+	if !ok {
+		return nil, fmt.Errorf("invalid Path: %s", packet.Request.Request.URL.Path)
+	}
+	handler, ok := handlers[packet.Method]
+	if !ok {
+		return mwhttp.NewSimpleResponseText(http.StatusMethodNotAllowed, "Unsupported Method"), nil
+	}
+	return handler(packet, ar)
+}
+
+func (eac ExportAdminController) handleList(packet *utils.HttpPacket, request *domain.ExportRequest) (core.Message, error) {
+	return utils.DispatchJsonResponse(eac.manager.List(), eac.logger, http.StatusOK)
+}
+
+func (eac ExportAdminController) handleStart(packet *utils.HttpPacket, request *domain.ExportRequest) (core.Message, error) {
+	job, err := eac.manager.Start(*request)
+	if err != nil {
+		errresp := domain.ErrorResponse{
+			ErrorCode:    ErrorCodeInvalidExport,
+			ErrorMessage: "Could not start export job",
+			Error:        err.Error(),
+		}
+		recordAudit(eac.auditLog, packet.Request.Request, eac.logger, domain.AuditEntry{Action: audit.ActionExport, ErrorCode: ErrorCodeInvalidExport})
+		return utils.DispatchJsonResponse(errresp, eac.logger, http.StatusBadRequest)
+	}
+	// the export copies every profile's sign-ins, the job id ties the entry to its files
+	recordAudit(eac.auditLog, packet.Request.Request, eac.logger, domain.AuditEntry{Action: audit.ActionExport, Detail: job.Id})
+	return utils.DispatchJsonResponse(job, eac.logger, http.StatusAccepted)
+}
+
+func (eac ExportAdminController) handleStatus(packet *utils.HttpPacket, request *domain.ExportRequest) (core.Message, error) {
+	job, err := eac.manager.Status(extractQueryParamHelper(packet.QueryParams, ParamId))
+	if err != nil {
+		return eac.dispatchJobError(err)
+	}
+	return utils.DispatchJsonResponse(job, eac.logger, http.StatusOK)
+}
+
+func (eac ExportAdminController) handleResume(packet *utils.HttpPacket, request *domain.ExportRequest) (core.Message, error) {
+	job, err := eac.manager.Resume(request.Id)
+	if err != nil {
+		return eac.dispatchJobError(err)
+	}
+	return utils.DispatchJsonResponse(job, eac.logger, http.StatusAccepted)
+}
+
+func (eac ExportAdminController) handleCancel(packet *utils.HttpPacket, request *domain.ExportRequest) (core.Message, error) {
+	job, err := eac.manager.Cancel(request.Id)
+	if err != nil {
+		return eac.dispatchJobError(err)
+	}
+	return utils.DispatchJsonResponse(job, eac.logger, http.StatusOK)
+}
+
+func (eac ExportAdminController) dispatchJobError(err error) (core.Message, error) {
+	switch {
+	case errors.Is(err, export.ErrJobNotFound):
+		errresp := domain.ErrorResponse{
+			ErrorCode:    ErrorCodeExportNotFound,
+			ErrorMessage: "Export job not found",
+			Error:        err.Error(),
+		}
+		return utils.DispatchJsonResponse(errresp, eac.logger, http.StatusNotFound)
+	case errors.Is(err, export.ErrJobRunning), errors.Is(err, export.ErrJobFinished):
+		errresp := domain.ErrorResponse{
+			ErrorCode:    ErrorCodeExportConflict,
+			ErrorMessage: "Export job cannot be resumed",
+			Error:        err.Error(),
+		}
+		return utils.DispatchJsonResponse(errresp, eac.logger, http.StatusConflict)
+	}
+	errresp := domain.ErrorResponse{
+		ErrorCode:    ErrorCodeExportStore,
+		ErrorMessage: "Could not update export job",
+		Error:        err.Error(),
+	}
+	return utils.DispatchJsonResponse(errresp, eac.logger, http.StatusInternalServerError)
+}
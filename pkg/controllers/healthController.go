@@ -7,9 +7,11 @@ import (
 	"github.mathworks.com/development/mito/pkg/config"
 	"github.mathworks.com/development/mito/pkg/core"
 	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
 	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
 	"github.mathworks.com/development/signindatatrackerws/pkg/collaborators"
 	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/resilience"
 	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
 
 	"go.uber.org/zap"
@@ -31,10 +33,11 @@ const (
 
 var HealthControllerConstants = &ControllerMetaData{
 	Name:            "healthEndpoint",
-	Path:            []string{"/admin/health/v2"},
+	Path:            []string{"/admin/health/v2", "/admin/health/html"},
 	LoggerName:      "health.controller",
 	JsonContentType: "application/json",
 	AllowedMethods:  []string{http.MethodGet, http.MethodPost},
+	Auth:            authz.RoutePublic,
 }
 
 func HealthControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *HealthController {
@@ -44,8 +47,9 @@ func HealthControllerFactory(conf config.Config, router mwhttp.Router, registry
 		signInDataService: collaborators.NewSignInTrackingService(),
 	}
 	registry.AddServiceProvider(HealthControllerConstants.Name, controller, core.PublicRoute)
-	router.AddRoute(HealthControllerConstants.Path[0], HealthControllerConstants.Name)
-	router.AddRoute("/admin/health/html", HealthControllerConstants.Name)
+	for _, path := range HealthControllerConstants.Path {
+		router.AddRoute(path, HealthControllerConstants.Name)
+	}
 	return controller
 }
 
@@ -68,7 +72,7 @@ func (hc HealthController) Receive(message core.Message, ctx core.Context) (core
 
 	health := &healthAlive.Health{
 		Name:       "signindatatrackerws health status",
-		Components: []*healthAlive.HealthComponent{hc.ProvideDbHealthComponent()},
+		Components: []*healthAlive.HealthComponent{hc.ProvideDbHealthComponent(), hc.ProvideCircuitBreakerHealthComponent()},
 	}
 	health.Check()
 
@@ -128,6 +132,38 @@ func (hc HealthController) ProvideDbHealthComponent() *healthAlive.HealthCompone
 	}
 }
 
+// ProvideCircuitBreakerHealthComponent reports the DynamoDB breakers, an open breaker means
+// reads fail fast and saves go to the ingestion queue.
+func (hc HealthController) ProvideCircuitBreakerHealthComponent() *healthAlive.HealthComponent {
+	breakers := bootstrap.GetApplicationContext().DynamoBreakers()
+	states := []string{}
+	if breakers != nil {
+		for _, state := range breakers.States() {
+			states = append(states, state.Operation+"="+string(state.State))
+		}
+	}
+	return &healthAlive.HealthComponent{
+		Name:        "DynamoDB circuit breakers",
+		Description: "Circuit breaker state per DynamoDB operation: " + strings.Join(states, ", "),
+		Essential:   false,
+		CheckHealthComponentFunc: func() (healthAlive.HealthComponentStatusCode, error) {
+			if breakers == nil {
+				return healthAlive.ComponentOk, nil
+			}
+			var open []string
+			for _, state := range breakers.States() {
+				if state.State != resilience.StateClosed {
+					open = append(open, state.Operation)
+				}
+			}
+			if len(open) > 0 {
+				return healthAlive.Critical, fmt.Errorf("circuit breaker not closed for %s", strings.Join(open, ", "))
+			}
+			return healthAlive.ComponentOk, nil
+		},
+	}
+}
+
 func (hc HealthController) RenderHtml(health *healthAlive.Health) string {
 	t, err := template.ParseFS(healthAlive.Ht, "*.gohtml")
 	if err != nil {
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.mathworks.com/development/mito/pkg/config"
+	"github.mathworks.com/development/mito/pkg/core"
+	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// MetricsControllerConstants give scrapers a scope of their own, signin:admin would let them use
+// every other route too. The route is not RouteAdmin for the same reason.
+var MetricsControllerConstants = &ControllerMetaData{
+	Name:            "metricsEndpoint",
+	Path:            []string{"/admin/metrics"},
+	LoggerName:      "metrics.controller",
+	JsonContentType: "application/json",
+	AllowedMethods:  []string{http.MethodGet},
+	Scopes:          []string{authz.ScopeMetrics},
+	Auth:            authz.RouteAuthenticated,
+}
+
+func MetricsControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *MetricsController {
+	controller := &MetricsController{
+		logger:   zap.L().Named(MetricsControllerConstants.Name),
+		gatherer: prometheus.DefaultGatherer,
+	}
+	registry.AddServiceProvider(MetricsControllerConstants.Name, controller, core.PublicRoute)
+	router.AddRoute(MetricsControllerConstants.Path[0], MetricsControllerConstants.Name)
+	return controller
+}
+
+type MetricsController struct {
+	logger   *zap.Logger
+	gatherer prometheus.Gatherer
+}
+
+// Receive renders the registered metrics in the Prometheus text format.
+func (mc MetricsController) Receive(message core.Message, ctx core.Context) (core.Message, error) {
+	var ar = new(domain.MonoResponse)
+	packet, err := utils.HttpMsgExtractor(message, mc.logger, MetricsControllerConstants.AllowedMethods, &ar)
+	if err != nil {
+		return packet.Response, nil
+	}
+	if _, response, ok := authorize(MetricsControllerConstants, packet.Request.Request, mc.logger); !ok {
+		return response, nil
+	}
+	families, err := mc.gatherer.Gather()
+	if err != nil {
+		// a failing collector should not hide the others
+		mc.logger.Warn("Some metrics could not be gathered", zap.Error(err))
+	}
+	format := expfmt.NewFormat(expfmt.TypeTextPlain)
+	var buf bytes.Buffer
+	encoder := expfmt.NewEncoder(&buf, format)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			mc.logger.Error("Could not encode metrics", zap.Error(err))
+			return mwhttp.NewSimpleResponseText(http.StatusInternalServerError, err.Error()), nil
+		}
+	}
+	return mwhttp.NewSimpleResponseContent(http.StatusOK, string(format), buf.String()), nil
+}
@@ -6,8 +6,10 @@ import (
 	"github.mathworks.com/development/mito/pkg/config"
 	"github.mathworks.com/development/mito/pkg/core"
 	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
 	"github.mathworks.com/development/signindatatrackerws/pkg/collaborators"
 	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/tracing"
 	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -23,6 +25,8 @@ var PersistSignInControllerConstants = &ControllerMetaData{
 	LoggerName:      "saveSignInData.controller",
 	JsonContentType: "application/json",
 	AllowedMethods:  []string{http.MethodPost},
+	Scopes:          []string{authz.ScopeWrite},
+	Auth:            authz.RouteAuthenticated,
 }
 
 func PersistSignInControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *PersistSignInDataController {
@@ -30,6 +34,10 @@ func PersistSignInControllerFactory(conf config.Config, router mwhttp.Router, re
 		logger:            zap.L().Named(PersistSignInControllerConstants.Name),
 		signInDataService: collaborators.NewSignInTrackingService(),
 	}
+	if controller.signInDataService.AsyncIngestion() || controller.signInDataService.DivertsWrites() {
+		// replay records accepted before the last shutdown without waiting for the first save
+		collaborators.IngestionQueueFactory()
+	}
 	registry.AddServiceProvider(PersistSignInControllerConstants.Name, controller, core.PublicRoute)
 	router.AddRoute(PersistSignInControllerConstants.Path[0], PersistSignInControllerConstants.Name)
 	return controller
@@ -48,16 +56,33 @@ func (gl PersistSignInDataController) Receive(message core.Message, ctx core.Con
 	if err != nil {
 		return packet.Response, nil
 	}
+	caller, response, ok := authorize(PersistSignInControllerConstants, packet.Request.Request, gl.logger)
+	if !ok {
+		return response, nil
+	}
 	if packet.Method == http.MethodPost {
+		if !caller.CanWrite(ar.SourceId) {
+			return forbidden(packet.Request.Request, gl.logger, "Caller may not save sign-ins for sourceId "+ar.SourceId)
+		}
 		//TODO: this is where the controller logic goes
-		signindata, errResp, statusCode := gl.signInDataService.SaveSignInData(*ar)
+		save, successCode := gl.signInDataService.SaveSignInData, http.StatusCreated
+		if gl.signInDataService.AsyncIngestion() {
+			save, successCode = gl.signInDataService.EnqueueSignInData, http.StatusAccepted
+		}
+		reqCtx, span := tracing.StartSpan(packet.Request.Request.Context(), PersistSignInControllerConstants.Name+" "+packet.Request.Request.URL.Path)
+		defer span.End()
+		signindata, errResp, statusCode := save(reqCtx, *ar)
 		if (errResp != domain.ErrorResponse{}) {
 			if errResp.ErrorCode == ErrorCodeEmptyUniqueID {
 				gl.logger.Error("UniqueId was empty")
 			}
 			return utils.DispatchJsonResponse(errResp, gl.logger, statusCode)
 		}
-		return utils.DispatchJsonResponse(signindata, gl.logger, http.StatusCreated)
+		if statusCode == http.StatusAccepted {
+			// diverted to the ingestion queue while DynamoDB is unavailable
+			successCode = statusCode
+		}
+		return utils.DispatchJsonResponse(signindata, gl.logger, successCode)
 	}
 	return utils.DispatchJsonResponse(ar, gl.logger, http.StatusNoContent)
 }
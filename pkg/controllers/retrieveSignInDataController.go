@@ -1,12 +1,17 @@
 package controllers
 
 import (
+	"context"
 	"fmt"
 	"github.mathworks.com/development/mito/pkg/config"
 	"github.mathworks.com/development/mito/pkg/core"
 	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/audit"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
 	"github.mathworks.com/development/signindatatrackerws/pkg/collaborators"
 	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/pseudonym"
+	"github.mathworks.com/development/signindatatrackerws/pkg/tracing"
 	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
 	"go.uber.org/zap"
 	"net/http"
@@ -23,16 +28,20 @@ const (
 
 var RetrieveSignInDataControllerConstants = &ControllerMetaData{
 	Name:            "retrieveSignInData",
-	Path:            []string{"/v1/getUniqueSignIn", "/v1/getSignInDetails", "/v1/signInPeriodDetails", "/v1/signInReferenceId"},
+	Path:            []string{"/v1/getUniqueSignIn", "/v1/getSignInDetails", "/v1/signInPeriodDetails", "/v1/signInReferenceId", "/v1/riskySignIns", "/v1/lastSignIn"},
 	LoggerName:      "retrieveSignInData.controller",
 	JsonContentType: "application/json",
 	AllowedMethods:  []string{http.MethodGet},
+	Scopes:          []string{authz.ScopeReadSelf, authz.ScopeReadAny, authz.ScopeReadPseudonymized},
+	Auth:            authz.RouteAuthenticated,
 }
 
 func RetrieveSignInControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *RetrieveSignInDataController {
 	controller := &RetrieveSignInDataController{
 		logger:            zap.L().Named(RetrieveSignInDataControllerConstants.Name),
 		signInDataService: collaborators.NewSignInTrackingService(),
+		auditLog:          audit.LogFactory(),
+		pseudonymizer:     pseudonym.Factory(),
 	}
 	registry.AddServiceProvider(RetrieveSignInDataControllerConstants.Name, controller, core.PublicRoute)
 	for _, path := range RetrieveSignInDataControllerConstants.Path {
@@ -44,6 +53,8 @@ func RetrieveSignInControllerFactory(conf config.Config, router mwhttp.Router, r
 type RetrieveSignInDataController struct {
 	logger            *zap.Logger
 	signInDataService *collaborators.SignInTrackingService
+	auditLog          *audit.FileLog
+	pseudonymizer     *pseudonym.Pseudonymizer
 }
 
 func (rsdc RetrieveSignInDataController) Receive(message core.Message, ctx core.Context) (core.Message, error) {
@@ -54,22 +65,47 @@ func (rsdc RetrieveSignInDataController) Receive(message core.Message, ctx core.
 	if err != nil {
 		return packet.Response, nil
 	}
+	// refused reads are audited as well, they show who tried to look at a profile
+	entry := domain.AuditEntry{Action: audit.ActionRead, UniqueId: extractQueryParamHelper(packet.QueryParams, ParamUniqueID)}
+	caller, response, ok := authorize(RetrieveSignInDataControllerConstants, packet.Request.Request, rsdc.logger)
+	if !ok {
+		entry.ErrorCode = ErrorCodeForbidden
+		recordAudit(rsdc.auditLog, packet.Request.Request, rsdc.logger, entry)
+		return response, nil
+	}
+	// a missing uniqueId is answered with 400 by the handlers
+	if entry.UniqueId != "" && !caller.CanRead(entry.UniqueId) {
+		entry.ErrorCode = ErrorCodeForbidden
+		recordAudit(rsdc.auditLog, packet.Request.Request, rsdc.logger, entry)
+		return forbidden(packet.Request.Request, rsdc.logger, "Caller may not read the sign-ins of "+entry.UniqueId)
+	}
+	if response, missing := pseudonymizerMissing(caller, rsdc.pseudonymizer, packet.Request.Request, rsdc.logger); missing {
+		entry.ErrorCode = ErrorCodeForbidden
+		recordAudit(rsdc.auditLog, packet.Request.Request, rsdc.logger, entry)
+		return response, nil
+	}
 
-	var pathToHandler = map[string]func(map[string][]string) (core.Message, error){
+	var pathToHandler = map[string]func(context.Context, map[string][]string, *domain.AuditEntry) (core.Message, error){
 		"/v1/getUniqueSignIn":     rsdc.handleUniqueSignIn,
 		"/v1/getSignInDetails":    rsdc.handleGetSignInDetails,
 		"/v1/signInPeriodDetails": rsdc.handleSignInPeriodDetails,
 		"/v1/signInReferenceId":   rsdc.handleSignInReferenceId,
+		"/v1/riskySignIns":        rsdc.handleRiskySignIns,
+		"/v1/lastSignIn":          rsdc.handleLastSignIn,
 	}
 
 	handler, ok := pathToHandler[packet.Request.Request.URL.Path]
 	if ok {
-		return handler(packet.QueryParams)
+		reqCtx, span := tracing.StartSpan(packet.Request.Request.Context(), RetrieveSignInDataControllerConstants.Name+" "+packet.Request.Request.URL.Path)
+		defer span.End()
+		response, err := handler(reqCtx, packet.QueryParams, &entry)
+		recordAudit(rsdc.auditLog, packet.Request.Request, rsdc.logger, entry)
+		return response, err
 	}
 	return nil, fmt.Errorf("invalid Path: %s", packet.Request.Request.URL.Path)
 }
 
-func (rsdc RetrieveSignInDataController) handleUniqueSignIn(packet map[string][]string) (core.Message, error) {
+func (rsdc RetrieveSignInDataController) handleUniqueSignIn(ctx context.Context, packet map[string][]string, entry *domain.AuditEntry) (core.Message, error) {
 	uniqueID, _, timestamp, _, err := extractQueryParams(packet)
 	if err != nil {
 		return mwhttp.NewSimpleResponseText(http.StatusBadRequest, err.Error()), nil
@@ -80,15 +116,17 @@ func (rsdc RetrieveSignInDataController) handleUniqueSignIn(packet map[string][]
 		Timestamp: timestamp,
 	}
 
-	pd, errResp, statusCode := rsdc.signInDataService.FindUniqueSignInInfo(requestInput)
+	pd, errResp, statusCode := rsdc.signInDataService.FindUniqueSignInInfo(ctx, requestInput)
 	if errResp.ErrorCode != 0 {
+		entry.ErrorCode = errResp.ErrorCode
 		return utils.DispatchJsonResponse(errResp, rsdc.logger, statusCode)
 	}
 
-	return utils.DispatchJsonResponse(pd, rsdc.logger, http.StatusOK)
+	entry.ResultCount = 1
+	return dispatchRead(ctx, rsdc.pseudonymizer, pd, rsdc.logger)
 }
 
-func (rsdc RetrieveSignInDataController) handleSignInPeriodDetails(packet map[string][]string) (core.Message, error) {
+func (rsdc RetrieveSignInDataController) handleSignInPeriodDetails(ctx context.Context, packet map[string][]string, entry *domain.AuditEntry) (core.Message, error) {
 	uniqueID, _, startTime, endTime, err := extractQueryParams(packet)
 	if err != nil {
 		return mwhttp.NewSimpleResponseText(http.StatusBadRequest, err.Error()), nil
@@ -100,14 +138,16 @@ func (rsdc RetrieveSignInDataController) handleSignInPeriodDetails(packet map[st
 		EndTime:   endTime,
 	}
 
-	pd, errResp, statusCode := rsdc.signInDataService.FindSignInPeriodDetails(requestDetailsInput)
+	pd, errResp, statusCode := rsdc.signInDataService.FindSignInPeriodDetails(ctx, requestDetailsInput)
 	if errResp.ErrorCode != 0 {
+		entry.ErrorCode = errResp.ErrorCode
 		return utils.DispatchJsonResponse(errResp, rsdc.logger, statusCode)
 	}
 
-	return utils.DispatchJsonResponse(pd, rsdc.logger, http.StatusOK)
+	entry.ResultCount = len(pd)
+	return dispatchRead(ctx, rsdc.pseudonymizer, pd, rsdc.logger)
 }
-func (rsdc RetrieveSignInDataController) handleSignInReferenceId(packet map[string][]string) (core.Message, error) {
+func (rsdc RetrieveSignInDataController) handleSignInReferenceId(ctx context.Context, packet map[string][]string, entry *domain.AuditEntry) (core.Message, error) {
 	uniqueID, referenceId, _, _, err := extractQueryParams(packet) // include referenceId here
 	if err != nil {
 		return mwhttp.NewSimpleResponseText(http.StatusBadRequest, err.Error()), nil
@@ -118,15 +158,57 @@ func (rsdc RetrieveSignInDataController) handleSignInReferenceId(packet map[stri
 		ReferenceId: referenceId,
 	}
 
-	pd, errResp, statusCode := rsdc.signInDataService.FindSignInReferenceIds(requestDetailsInput)
+	pd, errResp, statusCode := rsdc.signInDataService.FindSignInReferenceIds(ctx, requestDetailsInput)
+	if errResp.ErrorCode != 0 {
+		entry.ErrorCode = errResp.ErrorCode
+		return utils.DispatchJsonResponse(errResp, rsdc.logger, statusCode)
+	}
+
+	entry.ResultCount = len(pd)
+	return dispatchRead(ctx, rsdc.pseudonymizer, pd, rsdc.logger)
+}
+
+func (rsdc RetrieveSignInDataController) handleGetSignInDetails(ctx context.Context, packet map[string][]string, entry *domain.AuditEntry) (core.Message, error) {
+	uniqueID, _, _, _, err := extractQueryParams(packet)
+	if err != nil {
+		return mwhttp.NewSimpleResponseText(http.StatusBadRequest, err.Error()), nil
+	}
+
+	requestDetailsInput := domain.RequestDetailsInput{
+		UniqueID: uniqueID,
+	}
+
+	pd, errResp, statusCode := rsdc.signInDataService.FindSignInTrackingDetails(ctx, requestDetailsInput)
+	if errResp.ErrorCode != 0 {
+		entry.ErrorCode = errResp.ErrorCode
+		return utils.DispatchJsonResponse(errResp, rsdc.logger, statusCode)
+	}
+
+	entry.ResultCount = len(pd)
+	return dispatchRead(ctx, rsdc.pseudonymizer, pd, rsdc.logger)
+}
+
+func (rsdc RetrieveSignInDataController) handleRiskySignIns(ctx context.Context, packet map[string][]string, entry *domain.AuditEntry) (core.Message, error) {
+	uniqueID, _, _, _, err := extractQueryParams(packet)
+	if err != nil {
+		return mwhttp.NewSimpleResponseText(http.StatusBadRequest, err.Error()), nil
+	}
+
+	requestDetailsInput := domain.RequestDetailsInput{
+		UniqueID: uniqueID,
+	}
+
+	pd, errResp, statusCode := rsdc.signInDataService.FindRiskySignIns(ctx, requestDetailsInput)
 	if errResp.ErrorCode != 0 {
+		entry.ErrorCode = errResp.ErrorCode
 		return utils.DispatchJsonResponse(errResp, rsdc.logger, statusCode)
 	}
 
-	return utils.DispatchJsonResponse(pd, rsdc.logger, http.StatusOK)
+	entry.ResultCount = len(pd)
+	return dispatchRead(ctx, rsdc.pseudonymizer, pd, rsdc.logger)
 }
 
-func (rsdc RetrieveSignInDataController) handleGetSignInDetails(packet map[string][]string) (core.Message, error) {
+func (rsdc RetrieveSignInDataController) handleLastSignIn(ctx context.Context, packet map[string][]string, entry *domain.AuditEntry) (core.Message, error) {
 	uniqueID, _, _, _, err := extractQueryParams(packet)
 	if err != nil {
 		return mwhttp.NewSimpleResponseText(http.StatusBadRequest, err.Error()), nil
@@ -136,12 +218,14 @@ func (rsdc RetrieveSignInDataController) handleGetSignInDetails(packet map[strin
 		UniqueID: uniqueID,
 	}
 
-	pd, errResp, statusCode := rsdc.signInDataService.FindSignInTrackingDetails(requestDetailsInput)
+	pd, errResp, statusCode := rsdc.signInDataService.FindLastSignIn(ctx, requestDetailsInput)
 	if errResp.ErrorCode != 0 {
+		entry.ErrorCode = errResp.ErrorCode
 		return utils.DispatchJsonResponse(errResp, rsdc.logger, statusCode)
 	}
 
-	return utils.DispatchJsonResponse(pd, rsdc.logger, http.StatusOK)
+	entry.ResultCount = 1
+	return dispatchRead(ctx, rsdc.pseudonymizer, pd, rsdc.logger)
 }
 
 func extractQueryParams(queryParams map[string][]string) (uniqueID string, referenceId string, startTime string, endTime string, err error) {
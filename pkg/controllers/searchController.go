@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.mathworks.com/development/mito/pkg/config"
+	"github.mathworks.com/development/mito/pkg/core"
+	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/audit"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/collaborators"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/pseudonym"
+	"github.mathworks.com/development/signindatatrackerws/pkg/tracing"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var SearchControllerConstants = &ControllerMetaData{
+	Name:            "searchSignIns",
+	Path:            []string{"/v1/signIns/search"},
+	LoggerName:      "searchSignIns.controller",
+	JsonContentType: "application/json",
+	AllowedMethods:  []string{http.MethodPost},
+	Scopes:          []string{authz.ScopeReadAny, authz.ScopeReadPseudonymized},
+	Auth:            authz.RouteAuthenticated,
+}
+
+func SearchControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *SearchController {
+	controller := &SearchController{
+		logger:        zap.L().Named(SearchControllerConstants.Name),
+		searchService: collaborators.NewSignInSearchService(),
+		auditLog:      audit.LogFactory(),
+		pseudonymizer: pseudonym.Factory(),
+	}
+	registry.AddServiceProvider(SearchControllerConstants.Name, controller, core.PublicRoute)
+	for _, path := range SearchControllerConstants.Path {
+		router.AddRoute(path, SearchControllerConstants.Name)
+	}
+	return controller
+}
+
+type SearchController struct {
+	logger        *zap.Logger
+	searchService *collaborators.SignInSearchService
+	auditLog      *audit.FileLog
+	pseudonymizer *pseudonym.Pseudonymizer
+}
+
+func (sc SearchController) Receive(message core.Message, ctx core.Context) (core.Message, error) {
+	var ar = new(domain.SearchRequest)
+	packet, err := utils.HttpMsgExtractor(message, sc.logger, SearchControllerConstants.AllowedMethods, &ar)
+	if err != nil {
+		return packet.Response, nil
+	}
+	// a search can read any profile, every one is audited with its filter, refused ones as well
+	entry := domain.AuditEntry{Action: audit.ActionSearch}
+	if filter, err := json.Marshal(ar.Filter); err == nil {
+		entry.Detail = string(filter)
+	}
+	caller, response, ok := authorize(SearchControllerConstants, packet.Request.Request, sc.logger)
+	if !ok {
+		entry.ErrorCode = ErrorCodeForbidden
+		recordAudit(sc.auditLog, packet.Request.Request, sc.logger, entry)
+		return response, nil
+	}
+	if response, missing := pseudonymizerMissing(caller, sc.pseudonymizer, packet.Request.Request, sc.logger); missing {
+		entry.ErrorCode = ErrorCodeForbidden
+		recordAudit(sc.auditLog, packet.Request.Request, sc.logger, entry)
+		return response, nil
+	}
+	if caller.Pseudonymized() {
+		if errResp, statusCode := pseudonymizedSearch(sc.pseudonymizer, ar); errResp.ErrorCode != 0 {
+			sc.logger.Info("Search refused", zap.String("callerId", caller.Id), zap.String("reason", errResp.Error))
+			entry.ErrorCode = errResp.ErrorCode
+			recordAudit(sc.auditLog, packet.Request.Request, sc.logger, entry)
+			errResp.RequestID = packet.Request.Request.Header.Get("mathworks-requestid")
+			return utils.DispatchJsonResponse(errResp, sc.logger, statusCode)
+		}
+	}
+	if packet.Request.Request.URL.Path != "/v1/signIns/search" {
+		return nil, fmt.Errorf("invalid Path: %s", packet.Request.Request.URL.Path)
+	}
+
+	reqCtx, span := tracing.StartSpan(packet.Request.Request.Context(), SearchControllerConstants.Name+" "+packet.Request.Request.URL.Path)
+	defer span.End()
+	pd, errResp, statusCode := sc.searchService.SearchSignIns(reqCtx, *ar)
+	entry.ErrorCode, entry.ResultCount = errResp.ErrorCode, pd.Count
+	recordAudit(sc.auditLog, packet.Request.Request, sc.logger, entry)
+	if errResp.ErrorCode != 0 {
+		return utils.DispatchJsonResponse(errResp, sc.logger, statusCode)
+	}
+
+	return dispatchRead(reqCtx, sc.pseudonymizer, pd, sc.logger)
+}
+
+// pseudonymizedSearch checks the filter of a pseudonymized caller and opens its sealed
+// nextToken. A token that does not open is refused, it is never passed on as a plain token.
+func pseudonymizedSearch(pseudonymizer *pseudonym.Pseudonymizer, request *domain.SearchRequest) (domain.ErrorResponse, int) {
+	if err := pseudonymizer.CheckFilter(request.Filter); err != nil {
+		return domain.ErrorResponse{
+			ErrorCode:    ErrorCodeForbidden,
+			ErrorMessage: "Filter is not allowed for pseudonymized reads",
+			Error:        err.Error(),
+		}, http.StatusForbidden
+	}
+	token, err := pseudonymizer.OpenToken(request.NextToken)
+	if err != nil {
+		return domain.ErrorResponse{
+			ErrorCode:    collaborators.ErrorCodeInvalidSearchRequest,
+			ErrorMessage: "Invalid search request",
+			Error:        err.Error(),
+		}, http.StatusBadRequest
+	}
+	request.NextToken = token
+	return domain.ErrorResponse{}, http.StatusOK
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/pseudonym"
+)
+
+func TestPseudonymizedSearchRefusesReidentifyingFilters(t *testing.T) {
+	p := pseudonym.NewPseudonymizer([]byte("secret"), time.Hour)
+	for _, filter := range []domain.SearchFilter{
+		{Field: "ipAddress", Op: "eq", Value: "203.0.113.77"},
+		{And: []domain.SearchFilter{{Field: "sourceId", Op: "eq", Value: "MWA"}, {Field: "uniqueId", Op: "prefix", Value: "123"}}},
+		{Field: "timestamp", Op: "between", Values: []interface{}{"2026-10-19T10:00:00Z", "2026-10-19T10:00:05Z"}},
+		{Field: "timeStamp", Op: "prefix", Value: "17608"},
+		{Or: []domain.SearchFilter{{Field: "timestamp", Op: "eq", Value: "2026-10-19T10:00:00Z"}}},
+	} {
+		request := &domain.SearchRequest{Filter: filter}
+		errResp, status := pseudonymizedSearch(p, request)
+		assert.Equal(t, http.StatusForbidden, status, filter)
+		assert.Equal(t, ErrorCodeForbidden, errResp.ErrorCode)
+	}
+
+	request := &domain.SearchRequest{Filter: domain.SearchFilter{And: []domain.SearchFilter{
+		{Field: "sourceId", Op: "eq", Value: "MWA"},
+		{Field: "timestamp", Op: "between", Values: []interface{}{"2026-10-19T10:00:00Z", "2026-10-19T12:00:00Z"}},
+	}}}
+	errResp, status := pseudonymizedSearch(p, request)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Zero(t, errResp.ErrorCode)
+}
+
+func TestPseudonymizedSearchRefusesUnsealedTokens(t *testing.T) {
+	p := pseudonym.NewPseudonymizer([]byte("secret"), time.Hour)
+	request := &domain.SearchRequest{Filter: domain.SearchFilter{Field: "sourceId", Op: "eq", Value: "MWA"}, NextToken: "eyJwbGFpbiI6InRva2VuIn0"}
+	_, status := pseudonymizedSearch(p, request)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	sealed, err := p.SealToken("page-2")
+	assert.Nil(t, err)
+	request.NextToken = sealed
+	_, status = pseudonymizedSearch(p, request)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "page-2", request.NextToken)
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.mathworks.com/development/mito/pkg/config"
+	"github.mathworks.com/development/mito/pkg/core"
+	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/audit"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/collaborators"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/pseudonym"
+	"github.mathworks.com/development/signindatatrackerws/pkg/tracing"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	ParamGroupBy  = "groupBy"
+	ParamFrom     = "from"
+	ParamTo       = "to"
+	ParamSourceId = "sourceId"
+)
+
+var StatsControllerConstants = &ControllerMetaData{
+	Name:            "signInStats",
+	Path:            []string{"/v1/stats/signIns", "/v1/stats/activeUsers"},
+	LoggerName:      "signInStats.controller",
+	JsonContentType: "application/json",
+	AllowedMethods:  []string{http.MethodGet},
+	Scopes:          []string{authz.ScopeReadSelf, authz.ScopeReadAny, authz.ScopeReadPseudonymized},
+	Auth:            authz.RouteAuthenticated,
+}
+
+func StatsControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *StatsController {
+	controller := &StatsController{
+		logger:             zap.L().Named(StatsControllerConstants.Name),
+		statsService:       collaborators.NewSignInStatsService(),
+		activeUsersService: collaborators.NewActiveUsersService(),
+		auditLog:           audit.LogFactory(),
+		pseudonymizer:      pseudonym.Factory(),
+	}
+	registry.AddServiceProvider(StatsControllerConstants.Name, controller, core.PublicRoute)
+	for _, path := range StatsControllerConstants.Path {
+		router.AddRoute(path, StatsControllerConstants.Name)
+	}
+	return controller
+}
+
+type StatsController struct {
+	logger             *zap.Logger
+	statsService       *collaborators.SignInStatsService
+	activeUsersService *collaborators.ActiveUsersService
+	auditLog           *audit.FileLog
+	pseudonymizer      *pseudonym.Pseudonymizer
+}
+
+func (sc StatsController) Receive(message core.Message, ctx core.Context) (core.Message, error) {
+	var ar = new(domain.RequestStatsInput)
+	packet, err := utils.HttpMsgExtractor(message, sc.logger, StatsControllerConstants.AllowedMethods, &ar)
+	if err != nil {
+		return packet.Response, nil
+	}
+	// statistics are audited like reads, with the query parameters as the filter
+	entry := domain.AuditEntry{Action: audit.ActionStats, UniqueId: extractQueryParamHelper(packet.QueryParams, ParamUniqueID),
+		Detail: packet.Request.Request.URL.RawQuery}
+	caller, response, ok := authorize(StatsControllerConstants, packet.Request.Request, sc.logger)
+	if !ok {
+		entry.ErrorCode = ErrorCodeForbidden
+		recordAudit(sc.auditLog, packet.Request.Request, sc.logger, entry)
+		return response, nil
+	}
+	// active users are counted across every profile
+	if packet.Request.Request.URL.Path == "/v1/stats/activeUsers" && !caller.Has(authz.ScopeReadAny) && !caller.Has(authz.ScopeReadPseudonymized) {
+		entry.ErrorCode = ErrorCodeForbidden
+		recordAudit(sc.auditLog, packet.Request.Request, sc.logger, entry)
+		return forbidden(packet.Request.Request, sc.logger, "Caller may not read sign-ins of other profiles")
+	}
+	if entry.UniqueId != "" && !caller.CanRead(entry.UniqueId) {
+		entry.ErrorCode = ErrorCodeForbidden
+		recordAudit(sc.auditLog, packet.Request.Request, sc.logger, entry)
+		return forbidden(packet.Request.Request, sc.logger, "Caller may not read the sign-ins of "+entry.UniqueId)
+	}
+	if response, missing := pseudonymizerMissing(caller, sc.pseudonymizer, packet.Request.Request, sc.logger); missing {
+		entry.ErrorCode = ErrorCodeForbidden
+		recordAudit(sc.auditLog, packet.Request.Request, sc.logger, entry)
+		return response, nil
+	}
+
+	var pathToHandler = map[string]func(context.Context, map[string][]string, *domain.AuditEntry) (core.Message, error){
+		"/v1/stats/signIns":     sc.handleSignInStats,
+		"/v1/stats/activeUsers": sc.handleActiveUsers,
+	}
+
+	handler, ok := pathToHandler[packet.Request.Request.URL.Path]
+	if ok {
+		reqCtx, span := tracing.StartSpan(packet.Request.Request.Context(), StatsControllerConstants.Name+" "+packet.Request.Request.URL.Path)
+		defer span.End()
+		response, err := handler(reqCtx, packet.QueryParams, &entry)
+		recordAudit(sc.auditLog, packet.Request.Request, sc.logger, entry)
+		return response, err
+	}
+	return nil, fmt.Errorf("invalid Path: %s", packet.Request.Request.URL.Path)
+}
+
+func (sc StatsController) handleSignInStats(ctx context.Context, packet map[string][]string, entry *domain.AuditEntry) (core.Message, error) {
+	uniqueID := extractQueryParamHelper(packet, ParamUniqueID)
+	if uniqueID == "" {
+		return mwhttp.NewSimpleResponseText(http.StatusBadRequest, InvalidUniqueIdMsg), nil
+	}
+
+	requestStatsInput := domain.RequestStatsInput{
+		UniqueID:  uniqueID,
+		StartTime: extractQueryParamHelper(packet, ParamStartTime),
+		EndTime:   extractQueryParamHelper(packet, ParamEndTime),
+		GroupBy:   extractQueryParamHelper(packet, ParamGroupBy),
+	}
+
+	pd, errResp, statusCode := sc.statsService.FindSignInStats(ctx, requestStatsInput)
+	entry.ErrorCode, entry.ResultCount = errResp.ErrorCode, pd.Total
+	if errResp.ErrorCode != 0 {
+		return utils.DispatchJsonResponse(errResp, sc.logger, statusCode)
+	}
+
+	return dispatchRead(ctx, sc.pseudonymizer, pd, sc.logger)
+}
+
+func (sc StatsController) handleActiveUsers(ctx context.Context, packet map[string][]string, entry *domain.AuditEntry) (core.Message, error) {
+	requestActiveUsersInput := domain.RequestActiveUsersInput{
+		From:     extractQueryParamHelper(packet, ParamFrom),
+		To:       extractQueryParamHelper(packet, ParamTo),
+		SourceId: extractQueryParamHelper(packet, ParamSourceId),
+	}
+
+	pd, errResp, statusCode := sc.activeUsersService.FindActiveUsers(requestActiveUsersInput)
+	entry.ErrorCode = errResp.ErrorCode
+	if errResp.ErrorCode != 0 {
+		return utils.DispatchJsonResponse(errResp, sc.logger, statusCode)
+	}
+
+	return dispatchRead(ctx, sc.pseudonymizer, pd, sc.logger)
+}
@@ -1,9 +1,55 @@
 package controllers
 
+import (
+	"fmt"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+)
+
 type ControllerMetaData struct {
 	Name            string
 	Path            []string
 	LoggerName      string
 	JsonContentType string
 	AllowedMethods  []string
+	// Scopes lists the access key scopes of which the caller needs at least one, routes
+	// without scopes are not authorized beyond the access key filter
+	Scopes []string
+	// Auth is the authentication the access key filter requires for every path of the
+	// controller, it has to be set explicitly
+	Auth authz.RoutePolicy
+}
+
+// Routes lists the metadata of every controller the service registers, the access key filter
+// compiles its route policies from it.
+var Routes = []*ControllerMetaData{
+	AliveControllerConstants,
+	PersistSignInControllerConstants,
+	RetrieveSignInDataControllerConstants,
+	HealthControllerConstants,
+	WebhookAdminControllerConstants,
+	StatsControllerConstants,
+	SearchControllerConstants,
+	ExportAdminControllerConstants,
+	CacheAdminControllerConstants,
+	MetricsControllerConstants,
+	AuditAdminControllerConstants,
+}
+
+// CompileRoutePolicies maps every path of routes to its controller's policy. A controller
+// without a valid policy, or a path declared twice, is an error.
+func CompileRoutePolicies(routes []*ControllerMetaData) (authz.RoutePolicies, error) {
+	policies := make(authz.RoutePolicies)
+	for _, meta := range routes {
+		if !meta.Auth.Valid() {
+			return nil, fmt.Errorf("controller %s declares no valid auth policy: %q", meta.Name, meta.Auth)
+		}
+		for _, path := range meta.Path {
+			if _, ok := policies[path]; ok {
+				return nil, fmt.Errorf("path %s is declared by more than one controller", path)
+			}
+			policies[path] = meta.Auth
+		}
+	}
+	return policies, nil
 }
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+)
+
+// TestEveryRouteHasAnAuthPolicy reads the controllers' source, a controller added without an
+// auth policy, or left out of Routes, fails here instead of defaulting silently.
+func TestEveryRouteHasAnAuthPolicy(t *testing.T) {
+	files, err := parser.ParseDir(token.NewFileSet(), ".", func(info fs.FileInfo) bool { return !strings.HasSuffix(info.Name(), "_test.go") }, 0)
+	assert.Nil(t, err)
+	declared, literalPaths := 0, []string{}
+	for _, file := range files["controllers"].Files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.CompositeLit:
+				if ident, ok := n.Type.(*ast.Ident); ok && ident.Name == "ControllerMetaData" {
+					declared++
+					assert.True(t, hasKey(n, "Auth"), "ControllerMetaData at %v has no Auth policy", n.Pos())
+				}
+			case *ast.CallExpr:
+				if selector, ok := n.Fun.(*ast.SelectorExpr); ok && selector.Sel.Name == "AddRoute" {
+					if literal, ok := n.Args[0].(*ast.BasicLit); ok {
+						path, _ := strconv.Unquote(literal.Value)
+						literalPaths = append(literalPaths, path)
+					}
+				}
+			}
+			return true
+		})
+	}
+	assert.Equal(t, declared, len(Routes), "every controller's metadata has to be listed in Routes")
+
+	policies, err := CompileRoutePolicies(Routes)
+	assert.Nil(t, err)
+	for _, meta := range Routes {
+		assert.True(t, meta.Auth.Valid(), meta.Name)
+		if meta.Auth == authz.RoutePublic {
+			assert.Empty(t, meta.Scopes, "public route %s cannot require scopes", meta.Name)
+		}
+	}
+	// routes added by hand have to live under a declared path
+	for _, path := range literalPaths {
+		covered := false
+		for declaredPath := range policies {
+			covered = covered || strings.HasPrefix(path, declaredPath)
+		}
+		assert.True(t, covered, "route %s is not covered by any controller's metadata", path)
+	}
+}
+
+func TestCompileRoutePoliciesRejectsMissingPolicies(t *testing.T) {
+	_, err := CompileRoutePolicies([]*ControllerMetaData{{Name: "undeclared", Path: []string{"/v1/undeclared"}}})
+	assert.NotNil(t, err)
+	_, err = CompileRoutePolicies([]*ControllerMetaData{
+		{Name: "a", Path: []string{"/v1/a"}, Auth: authz.RoutePublic},
+		{Name: "b", Path: []string{"/v1/a"}, Auth: authz.RouteAdmin},
+	})
+	assert.NotNil(t, err)
+
+	policies, err := CompileRoutePolicies(Routes)
+	assert.Nil(t, err)
+	assert.Equal(t, authz.RoutePublic, policies.For("/admin/health/html"))
+	assert.Equal(t, authz.RouteAdmin, policies.For("/v1/admin/audit/verify"))
+	assert.Equal(t, authz.RouteAuthenticated, policies.For("/v1/unknown"))
+}
+
+func hasKey(literal *ast.CompositeLit, key string) bool {
+	for _, element := range literal.Elts {
+		if kv, ok := element.(*ast.KeyValueExpr); ok {
+			if ident, ok := kv.Key.(*ast.Ident); ok && ident.Name == key {
+				return true
+			}
+		}
+	}
+	return false
+}
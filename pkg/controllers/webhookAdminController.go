@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.mathworks.com/development/mito/pkg/config"
+	"github.mathworks.com/development/mito/pkg/core"
+	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"github.mathworks.com/development/signindatatrackerws/pkg/webhooks"
+	"go.uber.org/zap"
+)
+
+const (
+	ErrorCodeInvalidWebhook  = 5310
+	ErrorCodeWebhookNotFound = 5311
+	ErrorCodeWebhookStore    = 5510
+	ParamId                  = "id"
+)
+
+var WebhookAdminControllerConstants = &ControllerMetaData{
+	Name:            "webhookAdmin",
+	Path:            []string{"/v1/admin/webhooks", "/v1/admin/webhooks/pause", "/v1/admin/webhooks/replay", "/v1/admin/webhooks/deadLetters"},
+	LoggerName:      "webhookAdmin.controller",
+	JsonContentType: "application/json",
+	AllowedMethods:  []string{http.MethodGet, http.MethodPost},
+	Scopes:          []string{authz.ScopeAdmin},
+	Auth:            authz.RouteAdmin,
+}
+
+func WebhookAdminControllerFactory(conf config.Config, router mwhttp.Router, registry core.Registry) *WebhookAdminController {
+	controller := &WebhookAdminController{
+		logger:     zap.L().Named(WebhookAdminControllerConstants.Name),
+		dispatcher: webhooks.DispatcherFactory(),
+	}
+	registry.AddServiceProvider(WebhookAdminControllerConstants.Name, controller, core.PublicRoute)
+	for _, path := range WebhookAdminControllerConstants.Path {
+		router.AddRoute(path, WebhookAdminControllerConstants.Name)
+	}
+	return controller
+}
+
+type WebhookAdminController struct {
+	logger     *zap.Logger
+	dispatcher *webhooks.Dispatcher
+}
+
+func (wac WebhookAdminController) Receive(message core.Message, ctx core.Context) (core.Message, error) {
+	var ar = new(domain.WebhookAdminRequest)
+	packet, err := utils.HttpMsgExtractor(message, wac.logger, WebhookAdminControllerConstants.AllowedMethods, &ar)
+	if err != nil {
+		return packet.Response, nil
+	}
+	if _, response, ok := authorize(WebhookAdminControllerConstants, packet.Request.Request, wac.logger); !ok {
+		return response, nil
+	}
+
+	var pathToHandler = map[string]map[string]func(*utils.HttpPacket, *domain.WebhookAdminRequest) (core.Message, error){
+		"/v1/admin/webhooks": {
+			http.MethodGet:  wac.handleList,
+			http.MethodPost: wac.handleRegister,
+		},
+		"/v1/admin/webhooks/pause":       {http.MethodPost: wac.handlePause},
+		"/v1/admin/webhooks/replay":      {http.MethodPost: wac.handleReplay},
+		"/v1/admin/webhooks/deadLetters": {http.MethodGet: wac.handleDeadLetters},
+	}
+
+	handlers, ok := pathToHandler[packet.Request.Request.URL.Path]
+	if !ok {
+		return nil, fmt.Errorf("invalid Path: %s", packet.Request.Request.URL.Path)
+	}
+	handler, ok := handlers[packet.Method]
+	if !ok {
+		return mwhttp.NewSimpleResponseText(http.StatusMethodNotAllowed, "Unsupported Method"), nil
+	}
+	return handler(packet, ar)
+}
+
+func (wac WebhookAdminController) handleList(packet *utils.HttpPacket, request *domain.WebhookAdminRequest) (core.Message, error) {
+	return utils.DispatchJsonResponse(wac.dispatcher.List(), wac.logger, http.StatusOK)
+}
+
+func (wac WebhookAdminController) handleRegister(packet *utils.HttpPacket, request *domain.WebhookAdminRequest) (core.Message, error) {
+	subscription, err := wac.dispatcher.Register(*request)
+	if err != nil {
+		errresp := domain.ErrorResponse{
+			ErrorCode:    ErrorCodeInvalidWebhook,
+			ErrorMessage: "Could not register webhook subscription",
+			Error:        err.Error(),
+		}
+		return utils.DispatchJsonResponse(errresp, wac.logger, http.StatusBadRequest)
+	}
+	return utils.DispatchJsonResponse(subscription, wac.logger, http.StatusCreated)
+}
+
+func (wac WebhookAdminController) handlePause(packet *utils.HttpPacket, request *domain.WebhookAdminRequest) (core.Message, error) {
+	subscription, err := wac.dispatcher.SetPaused(request.Id, request.Paused)
+	if err != nil {
+		return wac.dispatchStoreError(err)
+	}
+	return utils.DispatchJsonResponse(subscription, wac.logger, http.StatusOK)
+}
+
+func (wac WebhookAdminController) handleReplay(packet *utils.HttpPacket, request *domain.WebhookAdminRequest) (core.Message, error) {
+	replayed, err := wac.dispatcher.Replay(request.Id)
+	if err != nil {
+		return wac.dispatchStoreError(err)
+	}
+	return utils.DispatchJsonResponse(map[string]int{"replayed": replayed}, wac.logger, http.StatusOK)
+}
+
+func (wac WebhookAdminController) handleDeadLetters(packet *utils.HttpPacket, request *domain.WebhookAdminRequest) (core.Message, error) {
+	return utils.DispatchJsonResponse(wac.dispatcher.DeadLetters(extractQueryParamHelper(packet.QueryParams, ParamId)), wac.logger, http.StatusOK)
+}
+
+func (wac WebhookAdminController) dispatchStoreError(err error) (core.Message, error) {
+	if errors.Is(err, webhooks.ErrSubscriptionNotFound) {
+		errresp := domain.ErrorResponse{
+			ErrorCode:    ErrorCodeWebhookNotFound,
+			ErrorMessage: "Webhook subscription not found",
+			Error:        err.Error(),
+		}
+		return utils.DispatchJsonResponse(errresp, wac.logger, http.StatusNotFound)
+	}
+	errresp := domain.ErrorResponse{
+		ErrorCode:    ErrorCodeWebhookStore,
+		ErrorMessage: "Could not update webhook subscription",
+		Error:        err.Error(),
+	}
+	return utils.DispatchJsonResponse(errresp, wac.logger, http.StatusInternalServerError)
+}
@@ -30,30 +30,256 @@ type RequestTimestampInput struct {
 	EndTime   string `json:"endTime"`
 }
 type SaveSignInInfo struct {
-	UniqueId    string `dynamodbav:"uniqueId" json:"uniqueId,omitempty"`
-	TimeStamp   string `dynamodbav:"timestamp" json:"timeStamp,omitempty"`
-	CalledId    string `dynamodbav:"calledId" json:"calledId,omitempty"`
-	IpAddress   string `dynamodbav:"ipAddress" json:"ipAddress,omitempty"`
-	UserAgent   string `dynamodbav:"userAgent" json:"userAgent,omitempty"`
-	SourceId    string `dynamodbav:"sourceId" json:"sourceId,omitempty"`
-	Region      string `dynamodbav:"region" json:"region,omitempty"`
-	ReferenceId string `dynamodbav:"referenceId" json:"referenceId,omitempty"`
-	SsoOrgId    string `dynamodbav:"ssoOrgId" json:"ssoOrgId,omitempty"`
+	UniqueId       string   `dynamodbav:"uniqueId" json:"uniqueId,omitempty"`
+	TimeStamp      string   `dynamodbav:"timestamp" json:"timeStamp,omitempty"`
+	CalledId       string   `dynamodbav:"calledId" json:"calledId,omitempty"`
+	IpAddress      string   `dynamodbav:"ipAddress" json:"ipAddress,omitempty"`
+	UserAgent      string   `dynamodbav:"userAgent" json:"userAgent,omitempty"`
+	SourceId       string   `dynamodbav:"sourceId" json:"sourceId,omitempty"`
+	Region         string   `dynamodbav:"region" json:"region,omitempty"`
+	ReferenceId    string   `dynamodbav:"referenceId" json:"referenceId,omitempty"`
+	SsoOrgId       string   `dynamodbav:"ssoOrgId" json:"ssoOrgId,omitempty"`
+	Device         string   `dynamodbav:"device" json:"device,omitempty"`
+	Os             string   `dynamodbav:"os" json:"os,omitempty"`
+	OsVersion      string   `dynamodbav:"osVersion" json:"osVersion,omitempty"`
+	Browser        string   `dynamodbav:"browser" json:"browser,omitempty"`
+	BrowserVersion string   `dynamodbav:"browserVersion" json:"browserVersion,omitempty"`
+	RiskScore      int      `dynamodbav:"riskScore" json:"riskScore"`
+	RiskReasons    []string `dynamodbav:"riskReasons,omitempty" json:"riskReasons,omitempty"`
+	// IpPolicy is the id of the ingest policy that decided how IpAddress was stored
+	IpPolicy string `dynamodbav:"ipPolicy,omitempty" json:"ipPolicy,omitempty"`
 }
 
 type SignInInfo struct {
-	UniqueId    string `dynamodbav:"uniqueId" json:"uniqueId,omitempty"`
-	TimeStamp   string `dynamodbav:"timestamp" json:"timeStamp,omitempty"`
-	CalledId    string `dynamodbav:"calledId" json:"calledId,omitempty"`
-	IpAddress   string `dynamodbav:"ipAddress" json:"ipAddress,omitempty"`
-	UserAgent   string `dynamodbav:"userAgent" json:"userAgent,omitempty"`
-	SourceId    string `dynamodbav:"sourceId" json:"sourceId,omitempty"`
-	Region      string `dynamodbav:"region" json:"region,omitempty"`
-	ReferenceId string `dynamodbav:"referenceId" json:"referenceId,omitempty"`
+	UniqueId       string   `dynamodbav:"uniqueId" json:"uniqueId,omitempty"`
+	TimeStamp      string   `dynamodbav:"timestamp" json:"timeStamp,omitempty"`
+	CalledId       string   `dynamodbav:"calledId" json:"calledId,omitempty"`
+	IpAddress      string   `dynamodbav:"ipAddress" json:"ipAddress,omitempty"`
+	UserAgent      string   `dynamodbav:"userAgent" json:"userAgent,omitempty"`
+	SourceId       string   `dynamodbav:"sourceId" json:"sourceId,omitempty"`
+	Region         string   `dynamodbav:"region" json:"region,omitempty"`
+	ReferenceId    string   `dynamodbav:"referenceId" json:"referenceId,omitempty"`
+	Device         string   `dynamodbav:"device" json:"device,omitempty"`
+	Os             string   `dynamodbav:"os" json:"os,omitempty"`
+	OsVersion      string   `dynamodbav:"osVersion" json:"osVersion,omitempty"`
+	Browser        string   `dynamodbav:"browser" json:"browser,omitempty"`
+	BrowserVersion string   `dynamodbav:"browserVersion" json:"browserVersion,omitempty"`
+	RiskScore      int      `dynamodbav:"riskScore" json:"riskScore,omitempty"`
+	RiskReasons    []string `dynamodbav:"riskReasons,omitempty" json:"riskReasons,omitempty"`
+	// IpPolicy is the id of the ingest policy that decided how IpAddress was stored
+	IpPolicy string `dynamodbav:"ipPolicy,omitempty" json:"ipPolicy,omitempty"`
+}
+
+type SignInSummary struct {
+	UniqueId            string   `dynamodbav:"uniqueId" json:"uniqueId"`
+	FirstSeen           string   `dynamodbav:"firstSeen" json:"firstSeen"`
+	LastSeen            string   `dynamodbav:"lastSeen" json:"lastSeen"`
+	TotalCount          int      `dynamodbav:"totalCount" json:"totalCount"`
+	DistinctIps         []string `dynamodbav:"distinctIps,stringset,omitempty" json:"distinctIps,omitempty"`
+	DistinctSources     []string `dynamodbav:"distinctSources,stringset,omitempty" json:"distinctSources,omitempty"`
+	DistinctIpCount     int      `dynamodbav:"-" json:"distinctIpCount"`
+	DistinctSourceCount int      `dynamodbav:"-" json:"distinctSourceCount"`
+	// DistinctIpsCapped is set when the summary stopped recording new IPs, the count is a floor
+	DistinctIpsCapped bool `dynamodbav:"-" json:"distinctIpsCapped,omitempty"`
+}
+
+type LastSignInResponse struct {
+	LastSignIn SignInInfo     `json:"lastSignIn"`
+	Summary    *SignInSummary `json:"summary,omitempty"`
+}
+
+type RequestStatsInput struct {
+	UniqueID  string `json:"profileId"`
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+	GroupBy   string `json:"groupBy"`
+}
+
+type SignInRollup struct {
+	UniqueId string   `dynamodbav:"uniqueId" json:"uniqueId"`
+	Bucket   string   `dynamodbav:"bucket" json:"bucket"`
+	Count    int      `dynamodbav:"signInCount" json:"count"`
+	Ips      []string `dynamodbav:"ips,stringset,omitempty" json:"-"`
+}
+
+type StatsBucket struct {
+	Key         string `json:"key"`
+	Count       int    `json:"count"`
+	DistinctIps int    `json:"distinctIps"`
+}
+
+type SignInStats struct {
+	UniqueId    string        `json:"uniqueId"`
+	GroupBy     string        `json:"groupBy"`
+	StartTime   string        `json:"startTime"`
+	EndTime     string        `json:"endTime"`
+	Source      string        `json:"source"`
+	Total       int           `json:"total"`
+	DistinctIps int           `json:"distinctIps"`
+	Buckets     []StatsBucket `json:"buckets"`
+}
+
+type RequestActiveUsersInput struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	SourceId string `json:"sourceId"`
+}
+
+type DailyActiveUsers struct {
+	Day      string `json:"day"`
+	Estimate uint64 `json:"estimate"`
+}
+
+type ActiveUsersStats struct {
+	From          string             `json:"from"`
+	To            string             `json:"to"`
+	SourceId      string             `json:"sourceId,omitempty"`
+	Estimate      uint64             `json:"estimate"`
+	StandardError float64            `json:"standardError"`
+	LowerBound    uint64             `json:"lowerBound"`
+	UpperBound    uint64             `json:"upperBound"`
+	Days          []DailyActiveUsers `json:"days"`
+}
+
+type UserAgentDetails struct {
+	Device         string `json:"device,omitempty"`
+	Os             string `json:"os,omitempty"`
+	OsVersion      string `json:"osVersion,omitempty"`
+	Browser        string `json:"browser,omitempty"`
+	BrowserVersion string `json:"browserVersion,omitempty"`
 }
 
 type ErrorResponse struct {
 	ErrorCode    int    `json:"errorCode"`
 	ErrorMessage string `json:"errorMessage"`
 	Error        string `json:"error"`
+	RequestID    string `json:"requestId,omitempty"`
+}
+
+type WebhookSubscription struct {
+	Id           string   `json:"id"`
+	Url          string   `json:"url"`
+	Secret       string   `json:"secret,omitempty"`
+	SourceIds    []string `json:"sourceIds,omitempty"`
+	SsoOrgIds    []string `json:"ssoOrgIds,omitempty"`
+	MinRiskScore int      `json:"minRiskScore"`
+	Paused       bool     `json:"paused"`
+	CreatedAt    string   `json:"createdAt"`
+}
+
+type WebhookAdminRequest struct {
+	Id           string   `json:"id"`
+	Url          string   `json:"url"`
+	Secret       string   `json:"secret"`
+	SourceIds    []string `json:"sourceIds"`
+	SsoOrgIds    []string `json:"ssoOrgIds"`
+	MinRiskScore int      `json:"minRiskScore"`
+	Paused       bool     `json:"paused"`
+}
+
+type WebhookEvent struct {
+	Id         string         `json:"id"`
+	Type       string         `json:"type"`
+	OccurredAt string         `json:"occurredAt"`
+	Data       SaveSignInInfo `json:"data"`
+}
+
+// SearchFilter is one node of the search filter tree, either a condition on a field or an
+// and/or combination of child filters.
+type SearchFilter struct {
+	And    []SearchFilter `json:"and,omitempty"`
+	Or     []SearchFilter `json:"or,omitempty"`
+	Field  string         `json:"field,omitempty"`
+	Op     string         `json:"op,omitempty"`
+	Value  interface{}    `json:"value,omitempty"`
+	Values []interface{}  `json:"values,omitempty"`
+}
+
+type SearchRequest struct {
+	Filter    SearchFilter `json:"filter"`
+	Limit     int          `json:"limit,omitempty"`
+	NextToken string       `json:"nextToken,omitempty"`
+	Explain   bool         `json:"explain,omitempty"`
+}
+
+type SearchExplain struct {
+	AccessPath       string `json:"accessPath"`
+	IndexName        string `json:"indexName,omitempty"`
+	KeyCondition     string `json:"keyCondition,omitempty"`
+	FilterExpression string `json:"filterExpression,omitempty"`
+	Segments         int    `json:"segments,omitempty"`
+	ScannedCount     int    `json:"scannedCount"`
+}
+
+type SearchResponse struct {
+	Items     []SignInInfo   `json:"items"`
+	Count     int            `json:"count"`
+	NextToken string         `json:"nextToken,omitempty"`
+	Explain   *SearchExplain `json:"explain,omitempty"`
+}
+
+type ExportRequest struct {
+	Id            string `json:"id,omitempty"`
+	Format        string `json:"format,omitempty"`
+	TotalSegments int    `json:"totalSegments,omitempty"`
+	Workers       int    `json:"workers,omitempty"`
+}
+
+type ExportSegment struct {
+	Segment int               `json:"segment"`
+	File    string            `json:"file"`
+	Items   int64             `json:"items"`
+	Offset  int64             `json:"offset"`
+	LastKey map[string]string `json:"lastKey,omitempty"`
+	Done    bool              `json:"done"`
+}
+
+type ExportJob struct {
+	Id               string          `json:"id"`
+	Format           string          `json:"format"`
+	State            string          `json:"state"`
+	TotalSegments    int             `json:"totalSegments"`
+	Workers          int             `json:"workers"`
+	Directory        string          `json:"directory"`
+	ItemsExported    int64           `json:"itemsExported"`
+	SegmentsDone     int             `json:"segmentsDone"`
+	ConsumedCapacity float64         `json:"consumedCapacity"`
+	CreatedAt        string          `json:"createdAt"`
+	UpdatedAt        string          `json:"updatedAt"`
+	Error            string          `json:"error,omitempty"`
+	Segments         []ExportSegment `json:"segments,omitempty"`
+}
+
+type CacheStats struct {
+	Backend       string  `json:"backend"`
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	HitRatio      float64 `json:"hitRatio"`
+	Errors        int64   `json:"errors"`
+	Invalidations int64   `json:"invalidations"`
+}
+
+type AuditEntry struct {
+	Seq         uint64 `json:"seq"`
+	Time        string `json:"time"`
+	CallerId    string `json:"callerId"`
+	Subject     string `json:"subject,omitempty"`
+	Action      string `json:"action"`
+	Route       string `json:"route"`
+	UniqueId    string `json:"uniqueId,omitempty"`
+	ResultCount int    `json:"resultCount"`
+	ErrorCode   int    `json:"errorCode,omitempty"`
+	RequestId   string `json:"requestId,omitempty"`
+	Detail      string `json:"detail,omitempty"`
+	PrevHash    string `json:"prevHash"`
+	Hash        string `json:"hash"`
+}
+
+type AuditVerification struct {
+	Entries  uint64 `json:"entries"`
+	Valid    bool   `json:"valid"`
+	BrokenAt uint64 `json:"brokenAt,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// TornTail is the length of the unfinished last line set aside when the log was opened
+	TornTail int64 `json:"tornTail,omitempty"`
 }
@@ -0,0 +1,31 @@
+package envelope
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// DigestPrefix marks keyed digests stored in place of a value.
+const DigestPrefix = "h-"
+
+// Digester replaces values that have to stay comparable at rest, like the IP sets of the summary
+// and rollup items, with a keyed HMAC. Equal values give equal digests so distinct counts keep
+// working, the values cannot be recovered or guessed without the secret.
+type Digester struct {
+	key []byte
+}
+
+func NewDigester(secret []byte) *Digester {
+	return &Digester{key: secret}
+}
+
+// Digest returns value unchanged on a nil Digester, for deployments without encryption.
+func (d *Digester) Digest(value string) string {
+	if d == nil || value == "" {
+		return value
+	}
+	mac := hmac.New(sha256.New, d.key)
+	mac.Write([]byte(value))
+	return DigestPrefix + hex.EncodeToString(mac.Sum(nil)[:16])
+}
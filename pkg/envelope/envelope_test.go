@@ -0,0 +1,180 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/cache"
+	"go.uber.org/zap"
+)
+
+var fields = []string{"ipAddress", "userAgent"}
+
+func testKeyring(t *testing.T, currentKeyId string) *LocalKeyring {
+	keyring, err := NewLocalKeyring(currentKeyId, map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 32),
+	})
+	assert.Nil(t, err)
+	return keyring
+}
+
+func signIn(uniqueId string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"uniqueId":  &types.AttributeValueMemberS{Value: uniqueId},
+		"ipAddress": &types.AttributeValueMemberS{Value: "203.0.113.7"},
+		"userAgent": &types.AttributeValueMemberS{Value: "Mozilla/5.0"},
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	e := NewFieldEncryptor(testKeyring(t, "k1"), fields, time.Minute)
+	encrypted, err := e.Encrypt(context.Background(), signIn("MWA-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "MWA-1"}, encrypted["uniqueId"])
+	assert.IsType(t, &types.AttributeValueMemberB{}, encrypted["ipAddress"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "k1"}, encrypted[KeyIdAttribute])
+
+	// a fresh encryptor has no cached data keys and has to unwrap
+	decrypted, err := NewFieldEncryptor(testKeyring(t, "k1"), fields, time.Minute).Decrypt(context.Background(), encrypted)
+	assert.Nil(t, err)
+	assert.Equal(t, signIn("MWA-1"), decrypted)
+
+	plain, err := e.Decrypt(context.Background(), signIn("MWA-2"))
+	assert.Nil(t, err)
+	assert.Equal(t, signIn("MWA-2"), plain)
+}
+
+func TestDecryptRejectsSwappedFields(t *testing.T) {
+	e := NewFieldEncryptor(testKeyring(t, "k1"), fields, time.Minute)
+	encrypted, err := e.Encrypt(context.Background(), signIn("MWA-1"))
+	assert.Nil(t, err)
+	encrypted["ipAddress"], encrypted["userAgent"] = encrypted["userAgent"], encrypted["ipAddress"]
+	_, err = e.Decrypt(context.Background(), encrypted)
+	assert.NotNil(t, err)
+}
+
+func TestRotatedKeyEncryptsNewItemsAndOldKeyStillDecrypts(t *testing.T) {
+	old, err := NewFieldEncryptor(testKeyring(t, "k1"), fields, time.Minute).Encrypt(context.Background(), signIn("MWA-1"))
+	assert.Nil(t, err)
+
+	rotated := NewFieldEncryptor(testKeyring(t, "k2"), fields, time.Minute)
+	decrypted, err := rotated.Decrypt(context.Background(), old)
+	assert.Nil(t, err)
+	assert.Equal(t, signIn("MWA-1"), decrypted)
+	encrypted, err := rotated.Encrypt(context.Background(), decrypted)
+	assert.Nil(t, err)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "k2"}, encrypted[KeyIdAttribute])
+
+	retired, err := NewLocalKeyring("k2", map[string][]byte{"k2": bytes.Repeat([]byte{2}, 32)})
+	assert.Nil(t, err)
+	_, err = NewFieldEncryptor(retired, fields, time.Minute).Decrypt(context.Background(), old)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestDataKeyIsReusedUntilItExpires(t *testing.T) {
+	e := NewFieldEncryptor(testKeyring(t, "k1"), fields, time.Minute)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+	first, _ := e.Encrypt(context.Background(), signIn("MWA-1"))
+	second, _ := e.Encrypt(context.Background(), signIn("MWA-2"))
+	assert.Equal(t, first[DataKeyAttribute], second[DataKeyAttribute])
+	now = now.Add(2 * time.Minute)
+	third, _ := e.Encrypt(context.Background(), signIn("MWA-3"))
+	assert.NotEqual(t, first[DataKeyAttribute], third[DataKeyAttribute])
+}
+
+// memoryStore encrypts on PutItem and decrypts on Scan like the encrypting DynamoDB client, the
+// filter expression is applied by hand.
+type memoryStore struct {
+	encryptor *FieldEncryptor
+	items     map[string]map[string]types.AttributeValue
+	puts      int
+}
+
+func (s *memoryStore) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	current := params.ExpressionAttributeValues[":current"].(*types.AttributeValueMemberS).Value
+	out := &dynamodb.ScanOutput{ScannedCount: int32(len(s.items))}
+	for _, item := range s.items {
+		if keyId, ok := item[KeyIdAttribute].(*types.AttributeValueMemberS); ok && keyId.Value == current {
+			continue
+		}
+		decrypted, err := s.encryptor.Decrypt(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, decrypted)
+	}
+	return out, nil
+}
+
+func (s *memoryStore) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	encrypted, err := s.encryptor.Encrypt(ctx, params.Item)
+	if err != nil {
+		return nil, err
+	}
+	s.puts++
+	s.items[params.Item["uniqueId"].(*types.AttributeValueMemberS).Value] = encrypted
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestReencryptorRewritesPlainAndOldItems(t *testing.T) {
+	old, err := NewFieldEncryptor(testKeyring(t, "k1"), fields, time.Minute).Encrypt(context.Background(), signIn("MWA-1"))
+	assert.Nil(t, err)
+	rotated := NewFieldEncryptor(testKeyring(t, "k2"), fields, time.Minute)
+	current, err := rotated.Encrypt(context.Background(), signIn("MWA-3"))
+	assert.Nil(t, err)
+	store := &memoryStore{encryptor: rotated, items: map[string]map[string]types.AttributeValue{
+		"MWA-1": old,
+		"MWA-2": signIn("MWA-2"),
+		"MWA-3": current,
+	}}
+
+	progress, err := NewReencryptor(store, "signindatatracker", "k2", 100, 0, zap.NewNop()).Run(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, ReencryptProgress{Scanned: 3, Reencrypted: 2}, progress)
+	for uniqueId, item := range store.items {
+		assert.Equal(t, &types.AttributeValueMemberS{Value: "k2"}, item[KeyIdAttribute], uniqueId)
+	}
+
+	progress, err = NewReencryptor(store, "signindatatracker", "k2", 100, 0, zap.NewNop()).Run(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), progress.Reencrypted)
+	assert.Equal(t, 2, store.puts)
+}
+
+func TestDigestIsKeyedAndStable(t *testing.T) {
+	digester := NewDigester([]byte("secret"))
+	digest := digester.Digest("203.0.113.7")
+
+	assert.Equal(t, digest, digester.Digest("203.0.113.7"))
+	assert.NotContains(t, digest, "203.0.113")
+	assert.NotEqual(t, digest, NewDigester([]byte("other")).Digest("203.0.113.7"))
+	assert.NotEqual(t, digest, digester.Digest("203.0.113.8"))
+
+	var disabled *Digester
+	assert.Equal(t, "203.0.113.7", disabled.Digest("203.0.113.7"))
+}
+
+func TestSealedCacheStoresCiphertext(t *testing.T) {
+	inner := cache.NewLRUCache(10)
+	sealed := NewSealedCache(inner, NewFieldEncryptor(testKeyring(t, "k1"), fields, time.Minute))
+	assert.Nil(t, sealed.Set("last:MWA-1", []byte(`{"ipAddress":"203.0.113.7"}`), time.Minute))
+
+	stored, _, _ := inner.Get("last:MWA-1")
+	assert.NotContains(t, string(stored), "203.0.113.7")
+	value, found, err := sealed.Get("last:MWA-1")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, `{"ipAddress":"203.0.113.7"}`, string(value))
+
+	// a value copied to another key does not open
+	assert.Nil(t, inner.Set("last:MWA-2", stored, time.Minute))
+	_, _, err = sealed.Get("last:MWA-2")
+	assert.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package envelope
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/cache"
+)
+
+const (
+	// KeyIdAttribute names the key encryption key of an item, DataKeyAttribute holds the item's
+	// wrapped data key. Items without them were written in plain text.
+	KeyIdAttribute   = "encKeyId"
+	DataKeyAttribute = "encDataKey"
+
+	unwrappedKeys   = 1024
+	unwrappedKeyTTL = time.Hour
+)
+
+type dataKey struct {
+	keyId   string
+	plain   []byte
+	wrapped []byte
+	created time.Time
+}
+
+// FieldEncryptor encrypts the configured string attributes of an item with AES-GCM under a data
+// key that is wrapped by the KeyProvider. A data key is reused for maxAge so that writes do not
+// call the provider every time; unwrapped keys are kept in memory for reads.
+type FieldEncryptor struct {
+	provider  KeyProvider
+	fields    []string
+	maxAge    time.Duration
+	mu        sync.Mutex
+	current   *dataKey
+	unwrapped *cache.LRUCache
+	now       func() time.Time
+}
+
+func NewFieldEncryptor(provider KeyProvider, fields []string, maxAge time.Duration) *FieldEncryptor {
+	return &FieldEncryptor{
+		provider:  provider,
+		fields:    fields,
+		maxAge:    maxAge,
+		unwrapped: cache.NewLRUCache(unwrappedKeys),
+		now:       time.Now,
+	}
+}
+
+// Encrypt returns a copy of item with the sensitive fields replaced by binary ciphertext. The key
+// attributes are set even when no field had a value, re-encryption then skips the item.
+func (e *FieldEncryptor) Encrypt(ctx context.Context, item map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	key, err := e.dataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := make(map[string]types.AttributeValue, len(item)+2)
+	for name, value := range item {
+		encrypted[name] = value
+	}
+	for _, field := range e.fields {
+		value, ok := item[field].(*types.AttributeValueMemberS)
+		if !ok || value.Value == "" {
+			continue
+		}
+		// the field name is authenticated so that ciphertexts cannot be swapped between fields
+		sealed, err := seal(key.plain, []byte(value.Value), []byte(field))
+		if err != nil {
+			return nil, err
+		}
+		encrypted[field] = &types.AttributeValueMemberB{Value: sealed}
+	}
+	encrypted[KeyIdAttribute] = &types.AttributeValueMemberS{Value: key.keyId}
+	encrypted[DataKeyAttribute] = &types.AttributeValueMemberB{Value: key.wrapped}
+	return encrypted, nil
+}
+
+// Decrypt returns a copy of item with the sensitive fields in plain text and the key attributes
+// removed. Plain text items are returned unchanged.
+func (e *FieldEncryptor) Decrypt(ctx context.Context, item map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	keyId, ok := item[KeyIdAttribute].(*types.AttributeValueMemberS)
+	if !ok {
+		return item, nil
+	}
+	wrapped, ok := item[DataKeyAttribute].(*types.AttributeValueMemberB)
+	if !ok {
+		return nil, fmt.Errorf("item encrypted with %s has no data key", keyId.Value)
+	}
+	plainKey, err := e.unwrap(ctx, keyId.Value, wrapped.Value)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make(map[string]types.AttributeValue, len(item))
+	for name, value := range item {
+		if name != KeyIdAttribute && name != DataKeyAttribute {
+			decrypted[name] = value
+		}
+	}
+	for _, field := range e.fields {
+		sealed, ok := item[field].(*types.AttributeValueMemberB)
+		if !ok {
+			continue
+		}
+		plain, err := open(plainKey, sealed.Value, []byte(field))
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s: %w", field, err)
+		}
+		decrypted[field] = &types.AttributeValueMemberS{Value: string(plain)}
+	}
+	return decrypted, nil
+}
+
+func (e *FieldEncryptor) CurrentKeyId() string {
+	return e.provider.CurrentKeyId()
+}
+
+func (e *FieldEncryptor) dataKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	keyId := e.provider.CurrentKeyId()
+	if e.current != nil && e.current.keyId == keyId && e.now().Sub(e.current.created) < e.maxAge {
+		return e.current, nil
+	}
+	plain := make([]byte, 32)
+	if _, err := rand.Read(plain); err != nil {
+		return nil, err
+	}
+	wrapped, err := e.provider.WrapKey(ctx, keyId, plain)
+	if err != nil {
+		return nil, err
+	}
+	e.current = &dataKey{keyId: keyId, plain: plain, wrapped: wrapped, created: e.now()}
+	e.unwrapped.Set(keyId+"/"+string(wrapped), plain, unwrappedKeyTTL)
+	return e.current, nil
+}
+
+func (e *FieldEncryptor) unwrap(ctx context.Context, keyId string, wrapped []byte) ([]byte, error) {
+	cacheKey := keyId + "/" + string(wrapped)
+	if plain, found, _ := e.unwrapped.Get(cacheKey); found {
+		return plain, nil
+	}
+	plain, err := e.provider.UnwrapKey(ctx, keyId, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key of %s: %w", keyId, err)
+	}
+	e.unwrapped.Set(cacheKey, plain, unwrappedKeyTTL)
+	return plain, nil
+}
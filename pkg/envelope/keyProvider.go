@@ -0,0 +1,177 @@
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+const (
+	ProviderLocal = "local"
+	ProviderKMS   = "kms"
+)
+
+var ErrUnknownKey = errors.New("unknown key encryption key")
+
+// KeyProvider wraps the per item data keys with a key encryption key that never leaves it.
+// CurrentKeyId names the key new data keys are wrapped with, older keys stay available for
+// unwrapping until every item was re-encrypted.
+type KeyProvider interface {
+	CurrentKeyId() string
+	WrapKey(ctx context.Context, keyId string, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, keyId string, wrapped []byte) ([]byte, error)
+}
+
+type keyringFile struct {
+	CurrentKeyId string            `json:"currentKeyId"`
+	Keys         map[string]string `json:"keys"`
+}
+
+// LocalKeyring holds AES-256 key encryption keys read from a JSON file,
+// {"currentKeyId": "k2", "keys": {"k1": "<base64>", "k2": "<base64>"}}. It is meant for
+// development and tests, production keys belong in KMS.
+type LocalKeyring struct {
+	currentKeyId string
+	keys         map[string][]byte
+}
+
+func LoadLocalKeyring(location string) (*LocalKeyring, error) {
+	content, err := os.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+	var file keyringFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("parse keyring %s: %w", location, err)
+	}
+	keys := make(map[string][]byte, len(file.Keys))
+	for keyId, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("keyring key %s is not 32 base64 encoded bytes", keyId)
+		}
+		keys[keyId] = key
+	}
+	return NewLocalKeyring(file.CurrentKeyId, keys)
+}
+
+func NewLocalKeyring(currentKeyId string, keys map[string][]byte) (*LocalKeyring, error) {
+	if _, ok := keys[currentKeyId]; !ok {
+		return nil, fmt.Errorf("current key %q: %w", currentKeyId, ErrUnknownKey)
+	}
+	return &LocalKeyring{currentKeyId: currentKeyId, keys: keys}, nil
+}
+
+func (k *LocalKeyring) CurrentKeyId() string {
+	return k.currentKeyId
+}
+
+func (k *LocalKeyring) WrapKey(ctx context.Context, keyId string, dataKey []byte) ([]byte, error) {
+	key, ok := k.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", keyId, ErrUnknownKey)
+	}
+	return seal(key, dataKey, []byte(keyId))
+}
+
+func (k *LocalKeyring) UnwrapKey(ctx context.Context, keyId string, wrapped []byte) ([]byte, error) {
+	key, ok := k.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", keyId, ErrUnknownKey)
+	}
+	return open(key, wrapped, []byte(keyId))
+}
+
+// KMSAPI is the part of the KMS client the provider uses.
+type KMSAPI interface {
+	Encrypt(ctx context.Context, params *kms.EncryptInput, optFns ...func(*kms.Options)) (*kms.EncryptOutput, error)
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+}
+
+// KMSKeyProvider wraps data keys with a KMS key. The key id stored on the items is the key's
+// ARN or alias, rotating means pointing the provider at a new key and re-encrypting.
+type KMSKeyProvider struct {
+	client KMSAPI
+	keyId  string
+}
+
+func NewKMSKeyProvider(client KMSAPI, keyId string) *KMSKeyProvider {
+	return &KMSKeyProvider{client: client, keyId: keyId}
+}
+
+func NewKMSKeyProviderFromConfig(cfg aws.Config, keyId string) *KMSKeyProvider {
+	return NewKMSKeyProvider(kms.NewFromConfig(cfg), keyId)
+}
+
+// encryptionContext binds the wrapped keys to this service, KMS refuses to unwrap them for
+// a request without the same context.
+var encryptionContext = map[string]string{"service": "signindatatracker"}
+
+func (p *KMSKeyProvider) CurrentKeyId() string {
+	return p.keyId
+}
+
+func (p *KMSKeyProvider) WrapKey(ctx context.Context, keyId string, dataKey []byte) ([]byte, error) {
+	out, err := p.client.Encrypt(ctx, &kms.EncryptInput{
+		KeyId:             aws.String(keyId),
+		Plaintext:         dataKey,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+func (p *KMSKeyProvider) UnwrapKey(ctx context.Context, keyId string, wrapped []byte) ([]byte, error) {
+	out, err := p.client.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:             aws.String(keyId),
+		CiphertextBlob:    wrapped,
+		EncryptionContext: encryptionContext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// seal encrypts with AES-GCM and prepends the random nonce.
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key []byte, sealed []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is shorter than its nonce")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,112 @@
+package envelope
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/throttle"
+	"go.uber.org/zap"
+)
+
+// ItemStore is the DynamoDB client the job runs against. It has to be the encrypting client:
+// items come back decrypted from Scan and PutItem encrypts them under the current key.
+type ItemStore interface {
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+type ReencryptProgress struct {
+	Scanned     int64
+	Reencrypted int64
+	// Skipped items were rewritten by someone else between the scan and the put
+	Skipped int64
+}
+
+// Reencryptor rewrites every item that is still plain text or encrypted under an older key.
+// It keeps no checkpoint, a rerun scans again and only finds the items that are left.
+type Reencryptor struct {
+	store     ItemStore
+	tableName string
+	keyId     string
+	pageSize  int32
+	limiter   *throttle.CapacityLimiter
+	logger    *zap.Logger
+}
+
+func NewReencryptor(store ItemStore, tableName string, currentKeyId string, pageSize int, maxCapacityPerSecond int, logger *zap.Logger) *Reencryptor {
+	return &Reencryptor{
+		store:     store,
+		tableName: tableName,
+		keyId:     currentKeyId,
+		pageSize:  int32(pageSize),
+		limiter:   throttle.NewCapacityLimiter(maxCapacityPerSecond),
+		logger:    logger,
+	}
+}
+
+func (r *Reencryptor) Run(ctx context.Context) (ReencryptProgress, error) {
+	var progress ReencryptProgress
+	var startKey map[string]types.AttributeValue
+	for {
+		if !r.limiter.Wait(ctx.Done()) {
+			return progress, ctx.Err()
+		}
+		page, err := r.store.Scan(ctx, &dynamodb.ScanInput{
+			TableName:                 aws.String(r.tableName),
+			Limit:                     aws.Int32(r.pageSize),
+			ExclusiveStartKey:         startKey,
+			FilterExpression:          aws.String("attribute_not_exists(#keyId) OR #keyId <> :current"),
+			ExpressionAttributeNames:  map[string]string{"#keyId": KeyIdAttribute},
+			ExpressionAttributeValues: map[string]types.AttributeValue{":current": &types.AttributeValueMemberS{Value: r.keyId}},
+			ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+		})
+		if err != nil {
+			return progress, err
+		}
+		r.consume(page.ConsumedCapacity)
+		progress.Scanned += int64(page.ScannedCount)
+		for _, item := range page.Items {
+			if err := r.rewrite(ctx, item); err != nil {
+				var conditionFailed *types.ConditionalCheckFailedException
+				if !errors.As(err, &conditionFailed) {
+					return progress, err
+				}
+				progress.Skipped++
+				continue
+			}
+			progress.Reencrypted++
+		}
+		r.logger.Info("Re-encrypted page", zap.Int64("scanned", progress.Scanned), zap.Int64("reencrypted", progress.Reencrypted))
+		if len(page.LastEvaluatedKey) == 0 {
+			return progress, nil
+		}
+		startKey = page.LastEvaluatedKey
+	}
+}
+
+func (r *Reencryptor) rewrite(ctx context.Context, item map[string]types.AttributeValue) error {
+	if !r.limiter.Wait(ctx.Done()) {
+		return ctx.Err()
+	}
+	out, err := r.store.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(r.tableName),
+		Item:                      item,
+		ConditionExpression:       aws.String("attribute_not_exists(#keyId) OR #keyId <> :current"),
+		ExpressionAttributeNames:  map[string]string{"#keyId": KeyIdAttribute},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":current": &types.AttributeValueMemberS{Value: r.keyId}},
+		ReturnConsumedCapacity:    types.ReturnConsumedCapacityTotal,
+	})
+	if out != nil {
+		r.consume(out.ConsumedCapacity)
+	}
+	return err
+}
+
+func (r *Reencryptor) consume(capacity *types.ConsumedCapacity) {
+	if capacity != nil && capacity.CapacityUnits != nil {
+		r.limiter.Consume(*capacity.CapacityUnits)
+	}
+}
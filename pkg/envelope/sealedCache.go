@@ -0,0 +1,68 @@
+package envelope
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/cache"
+)
+
+// sealedEntry is a cached value sealed under a wrapped data key, like an encrypted item.
+type sealedEntry struct {
+	KeyId   string `json:"keyId"`
+	DataKey []byte `json:"dataKey"`
+	Sealed  []byte `json:"sealed"`
+}
+
+// SealedCache encrypts the values of a shared cache, the decrypted items read from the table must
+// not sit in plain text in a cache outside the process. The cache key is authenticated so that
+// values cannot be moved between keys.
+type SealedCache struct {
+	cache     cache.Cache
+	encryptor *FieldEncryptor
+}
+
+func NewSealedCache(c cache.Cache, encryptor *FieldEncryptor) *SealedCache {
+	return &SealedCache{cache: c, encryptor: encryptor}
+}
+
+func (s *SealedCache) Get(key string) ([]byte, bool, error) {
+	value, found, err := s.cache.Get(key)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	var entry sealedEntry
+	if err := json.Unmarshal(value, &entry); err != nil {
+		return nil, false, err
+	}
+	plainKey, err := s.encryptor.unwrap(context.Background(), entry.KeyId, entry.DataKey)
+	if err != nil {
+		return nil, false, err
+	}
+	plain, err := open(plainKey, entry.Sealed, []byte(key))
+	if err != nil {
+		return nil, false, err
+	}
+	return plain, true, nil
+}
+
+func (s *SealedCache) Set(key string, value []byte, ttl time.Duration) error {
+	dataKey, err := s.encryptor.dataKey(context.Background())
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(dataKey.plain, value, []byte(key))
+	if err != nil {
+		return err
+	}
+	entry, err := json.Marshal(sealedEntry{KeyId: dataKey.keyId, DataKey: dataKey.wrapped, Sealed: sealed})
+	if err != nil {
+		return err
+	}
+	return s.cache.Set(key, entry, ttl)
+}
+
+func (s *SealedCache) Delete(keys ...string) error {
+	return s.cache.Delete(keys...)
+}
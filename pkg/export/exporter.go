@@ -0,0 +1,424 @@
+package export
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/repository/adapter"
+	"github.mathworks.com/development/signindatatrackerws/pkg/throttle"
+	"go.uber.org/zap"
+)
+
+const (
+	StateRunning     = "running"
+	StateCompleted   = "completed"
+	StateFailed      = "failed"
+	StateCancelled   = "cancelled"
+	StateInterrupted = "interrupted"
+
+	// DynamoDB accepts at most this many segments for a parallel scan
+	maxTotalSegments = 1000000
+	jobFile          = "job.json"
+	// corruptSuffix is appended to job files that cannot be parsed, their job is skipped
+	corruptSuffix = ".corrupt"
+)
+
+var (
+	ErrJobNotFound   = errors.New("export job not found")
+	ErrJobRunning    = errors.New("export job is already running")
+	ErrJobFinished   = errors.New("export job has already completed")
+	ErrInvalidFormat = errors.New("format must be ndjson or csv")
+
+	managerOnce sync.Once
+	manager     *Manager
+)
+
+type SegmentScannerInterface interface {
+	ScanSegmentPage(segment int, totalSegments int, limit int, startKey map[string]types.AttributeValue) (adapter.ScanPage, error)
+}
+
+// Manager runs full-table exports as parallel scans. Every job keeps its state, including the
+// last key and file offset of each segment, in a job file next to its output so an interrupted
+// job continues where it stopped.
+type Manager struct {
+	mu      sync.Mutex
+	logger  *zap.Logger
+	config  bootstrap.ExportConfig
+	scanner SegmentScannerInterface
+	limiter *throttle.CapacityLimiter
+	now     func() time.Time
+	jobs    map[string]*jobRun
+}
+
+type jobRun struct {
+	job  domain.ExportJob
+	stop chan struct{}
+	done chan struct{}
+}
+
+// ManagerFactory returns the process wide export manager.
+func ManagerFactory(tableName string) *Manager {
+	managerOnce.Do(func() {
+		var err error
+		manager, err = NewManager(bootstrap.GetApplicationContext().AppConfigData.Export, adapter.SignInRepoFactory(tableName), zap.L().Named("signindatatrackerws.export"))
+		if err != nil {
+			log.Fatalf("Failed to initialize export jobs: %v", err)
+		}
+	})
+	return manager
+}
+
+// ShutdownManager stops the running jobs of the process wide manager, if one was created.
+func ShutdownManager() {
+	if manager != nil {
+		manager.Shutdown()
+	}
+}
+
+// NewManager loads the jobs found under the export directory. A job file that does not parse is
+// renamed aside and its job skipped, one damaged job does not keep the others from loading.
+func NewManager(config bootstrap.ExportConfig, scanner SegmentScannerInterface, logger *zap.Logger) (*Manager, error) {
+	m := &Manager{
+		logger:  logger,
+		config:  config,
+		scanner: scanner,
+		limiter: throttle.NewCapacityLimiter(config.MaxReadCapacityPerSecond),
+		now:     time.Now,
+		jobs:    map[string]*jobRun{},
+	}
+	locations, err := filepath.Glob(filepath.Join(config.Directory, "*", jobFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, location := range locations {
+		content, err := os.ReadFile(location)
+		if err != nil {
+			return nil, err
+		}
+		var job domain.ExportJob
+		if err := json.Unmarshal(content, &job); err != nil || job.Id == "" {
+			if err := os.Rename(location, location+corruptSuffix); err != nil {
+				return nil, err
+			}
+			logger.Warn("Set aside an unreadable export job", zap.String("location", location+corruptSuffix))
+			continue
+		}
+		if job.State == StateRunning {
+			// the process stopped while the job ran, it waits for an explicit resume
+			job.State = StateInterrupted
+		}
+		m.jobs[job.Id] = &jobRun{job: job}
+	}
+	return m, nil
+}
+
+func (m *Manager) Start(request domain.ExportRequest) (domain.ExportJob, error) {
+	format := request.Format
+	if format == "" {
+		format = FormatNDJSON
+	}
+	if format != FormatNDJSON && format != FormatCSV {
+		return domain.ExportJob{}, ErrInvalidFormat
+	}
+	totalSegments := request.TotalSegments
+	if totalSegments <= 0 {
+		totalSegments = m.config.TotalSegments
+	}
+	if totalSegments < 1 || totalSegments > maxTotalSegments {
+		return domain.ExportJob{}, fmt.Errorf("totalSegments must be between 1 and %d", maxTotalSegments)
+	}
+	workers := request.Workers
+	if workers <= 0 {
+		workers = m.config.Workers
+	}
+	if workers > totalSegments {
+		workers = totalSegments
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	id := newJobId()
+	now := m.now().UTC().Format(time.RFC3339)
+	job := domain.ExportJob{
+		Id:            id,
+		Format:        format,
+		State:         StateRunning,
+		TotalSegments: totalSegments,
+		Workers:       workers,
+		Directory:     filepath.Join(m.config.Directory, id),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Segments:      make([]domain.ExportSegment, totalSegments),
+	}
+	for segment := range job.Segments {
+		job.Segments[segment] = domain.ExportSegment{Segment: segment, File: segmentFileName(segment, format)}
+	}
+	if err := os.MkdirAll(job.Directory, 0750); err != nil {
+		return domain.ExportJob{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run := &jobRun{job: job}
+	m.jobs[id] = run
+	if err := m.persistLocked(run); err != nil {
+		delete(m.jobs, id)
+		return domain.ExportJob{}, err
+	}
+	m.launchLocked(run)
+	return summary(run.job), nil
+}
+
+// Resume restarts an interrupted, failed or cancelled job from its checkpoints.
+func (m *Manager) Resume(id string) (domain.ExportJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.jobs[id]
+	if !ok {
+		return domain.ExportJob{}, ErrJobNotFound
+	}
+	switch run.job.State {
+	case StateRunning:
+		return domain.ExportJob{}, ErrJobRunning
+	case StateCompleted:
+		return domain.ExportJob{}, ErrJobFinished
+	}
+	run.job.State = StateRunning
+	run.job.Error = ""
+	if err := m.persistLocked(run); err != nil {
+		return domain.ExportJob{}, err
+	}
+	m.launchLocked(run)
+	return summary(run.job), nil
+}
+
+func (m *Manager) Cancel(id string) (domain.ExportJob, error) {
+	m.mu.Lock()
+	run, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return domain.ExportJob{}, ErrJobNotFound
+	}
+	if run.job.State != StateRunning {
+		job := summary(run.job)
+		m.mu.Unlock()
+		return job, nil
+	}
+	run.requestStop()
+	done := run.done
+	m.mu.Unlock()
+
+	<-done
+	return m.Status(id)
+}
+
+func (m *Manager) Status(id string) (domain.ExportJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.jobs[id]
+	if !ok {
+		return domain.ExportJob{}, ErrJobNotFound
+	}
+	job := run.job
+	job.Segments = append([]domain.ExportSegment(nil), run.job.Segments...)
+	return job, nil
+}
+
+// List returns all jobs without their per-segment detail, newest first.
+func (m *Manager) List() []domain.ExportJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]domain.ExportJob, 0, len(m.jobs))
+	for _, run := range m.jobs {
+		jobs = append(jobs, summary(run.job))
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt > jobs[j].CreatedAt })
+	return jobs
+}
+
+// Shutdown stops all running jobs, they stay resumable.
+func (m *Manager) Shutdown() {
+	m.mu.Lock()
+	var running []chan struct{}
+	for _, run := range m.jobs {
+		if run.job.State == StateRunning {
+			run.requestStop()
+			running = append(running, run.done)
+		}
+	}
+	m.mu.Unlock()
+	for _, done := range running {
+		<-done
+	}
+}
+
+// requestStop is called with the manager lock held, concurrent cancels close stop only once.
+func (run *jobRun) requestStop() {
+	select {
+	case <-run.stop:
+	default:
+		close(run.stop)
+	}
+}
+
+func (m *Manager) launchLocked(run *jobRun) {
+	run.stop = make(chan struct{})
+	run.done = make(chan struct{})
+	pending := make(chan int, run.job.TotalSegments)
+	for _, segment := range run.job.Segments {
+		if !segment.Done {
+			pending <- segment.Segment
+		}
+	}
+	close(pending)
+	go m.run(run, pending)
+}
+
+func (m *Manager) run(run *jobRun, pending chan int) {
+	defer close(run.done)
+	m.logger.Info("Export job started", zap.String("id", run.job.Id), zap.Int("totalSegments", run.job.TotalSegments), zap.Int("workers", run.job.Workers))
+
+	var failOnce sync.Once
+	var failure error
+	abort := make(chan struct{})
+	var wg sync.WaitGroup
+	for worker := 0; worker < run.job.Workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for segment := range pending {
+				if err := m.exportSegment(run, segment, abort); err != nil {
+					failOnce.Do(func() {
+						failure = err
+						close(abort)
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case failure != nil && !errors.Is(failure, errStopped):
+		run.job.State = StateFailed
+		run.job.Error = failure.Error()
+	case run.job.SegmentsDone == run.job.TotalSegments:
+		run.job.State = StateCompleted
+	default:
+		run.job.State = StateCancelled
+	}
+	if err := m.persistLocked(run); err != nil {
+		m.logger.Error("Could not persist export job", zap.String("id", run.job.Id), zap.Error(err))
+	}
+	m.logger.Info("Export job finished", zap.String("id", run.job.Id), zap.String("state", run.job.State), zap.Int64("items", run.job.ItemsExported))
+}
+
+var errStopped = errors.New("export job stopped")
+
+func (m *Manager) exportSegment(run *jobRun, segment int, abort chan struct{}) error {
+	m.mu.Lock()
+	progress := run.job.Segments[segment]
+	format, directory, totalSegments := run.job.Format, run.job.Directory, run.job.TotalSegments
+	m.mu.Unlock()
+
+	startKey, err := attributevalue.MarshalMap(progress.LastKey)
+	if err != nil {
+		return err
+	}
+	if len(progress.LastKey) == 0 {
+		startKey = nil
+	}
+	location := filepath.Join(directory, progress.File)
+	for {
+		select {
+		case <-run.stop:
+			return errStopped
+		case <-abort:
+			return errStopped
+		default:
+		}
+		if !m.limiter.Wait(run.stop) {
+			return errStopped
+		}
+
+		page, err := m.scanner.ScanSegmentPage(segment, totalSegments, m.config.PageSize, startKey)
+		if err != nil {
+			return fmt.Errorf("segment %d: %w", segment, err)
+		}
+		m.limiter.Consume(page.ConsumedCapacity)
+		offset, err := appendPage(location, progress.Offset, format, page.Items)
+		if err != nil {
+			return fmt.Errorf("segment %d: %w", segment, err)
+		}
+
+		progress.Offset = offset
+		progress.Items += int64(len(page.Items))
+		progress.LastKey = map[string]string{}
+		if err := attributevalue.UnmarshalMap(page.LastKey, &progress.LastKey); err != nil {
+			return fmt.Errorf("segment %d: %w", segment, err)
+		}
+		progress.Done = len(page.LastKey) == 0
+		if progress.Done {
+			progress.LastKey = nil
+		}
+		startKey = page.LastKey
+
+		m.mu.Lock()
+		run.job.Segments[segment] = progress
+		run.job.ItemsExported += int64(len(page.Items))
+		run.job.ConsumedCapacity += page.ConsumedCapacity
+		if progress.Done {
+			run.job.SegmentsDone++
+		}
+		err = m.persistLocked(run)
+		m.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if progress.Done {
+			return nil
+		}
+	}
+}
+
+func (m *Manager) persistLocked(run *jobRun) error {
+	run.job.UpdatedAt = m.now().UTC().Format(time.RFC3339)
+	content, err := json.MarshalIndent(run.job, "", "  ")
+	if err != nil {
+		return err
+	}
+	location := filepath.Join(run.job.Directory, jobFile)
+	tmp := location + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, location)
+}
+
+func summary(job domain.ExportJob) domain.ExportJob {
+	job.Segments = nil
+	return job
+}
+
+func newJobId() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,177 @@
+package export
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/repository/adapter"
+	"go.uber.org/zap"
+)
+
+// fakeScanner serves itemsPerSegment items per segment, pageSize at a time, and can be told to
+// fail once a number of pages were read.
+type fakeScanner struct {
+	mu              sync.Mutex
+	itemsPerSegment int
+	failAfterPages  int
+	pages           int
+}
+
+func (f *fakeScanner) ScanSegmentPage(segment int, totalSegments int, limit int, startKey map[string]types.AttributeValue) (adapter.ScanPage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failAfterPages > 0 && f.pages >= f.failAfterPages {
+		return adapter.ScanPage{}, errors.New("throttled")
+	}
+	f.pages++
+	start := 0
+	if key, ok := startKey["timestamp"].(*types.AttributeValueMemberS); ok {
+		start, _ = strconv.Atoi(key.Value)
+	}
+	page := adapter.ScanPage{ConsumedCapacity: 0.5}
+	for i := start; i < start+limit && i < f.itemsPerSegment; i++ {
+		page.Items = append(page.Items, domain.SignInInfo{UniqueId: "MWA-" + strconv.Itoa(segment), TimeStamp: strconv.Itoa(i)})
+	}
+	if next := start + limit; next < f.itemsPerSegment {
+		page.LastKey = map[string]types.AttributeValue{
+			"uniqueId":  &types.AttributeValueMemberS{Value: "MWA-" + strconv.Itoa(segment)},
+			"timestamp": &types.AttributeValueMemberS{Value: strconv.Itoa(next)},
+		}
+	}
+	return page, nil
+}
+
+func testConfig(directory string) bootstrap.ExportConfig {
+	return bootstrap.ExportConfig{Directory: directory, TotalSegments: 3, Workers: 2, PageSize: 2}
+}
+
+func waitFor(t *testing.T, m *Manager, id string) domain.ExportJob {
+	m.mu.Lock()
+	done := m.jobs[id].done
+	m.mu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("export job did not finish")
+	}
+	job, err := m.Status(id)
+	assert.NoError(t, err)
+	return job
+}
+
+func readLines(t *testing.T, location string) []string {
+	f, err := os.Open(location)
+	assert.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+	var lines []string
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	assert.NoError(t, scanner.Err())
+	return lines
+}
+
+func TestExportAllSegments(t *testing.T) {
+	directory := t.TempDir()
+	m, err := NewManager(testConfig(directory), &fakeScanner{itemsPerSegment: 5}, zap.L().Named("test-log-zap"))
+	assert.NoError(t, err)
+
+	job, err := m.Start(domain.ExportRequest{})
+	assert.NoError(t, err)
+	job = waitFor(t, m, job.Id)
+
+	assert.Equal(t, StateCompleted, job.State)
+	assert.Equal(t, int64(15), job.ItemsExported)
+	assert.Equal(t, 3, job.SegmentsDone)
+	assert.InDelta(t, 4.5, job.ConsumedCapacity, 0.001)
+	for _, segment := range job.Segments {
+		assert.True(t, segment.Done)
+		assert.Len(t, readLines(t, filepath.Join(job.Directory, segment.File)), 5)
+	}
+}
+
+func TestExportResumesFromCheckpoint(t *testing.T) {
+	directory := t.TempDir()
+	scanner := &fakeScanner{itemsPerSegment: 6, failAfterPages: 2}
+	m, err := NewManager(bootstrap.ExportConfig{Directory: directory, TotalSegments: 1, Workers: 1, PageSize: 2}, scanner, zap.L().Named("test-log-zap"))
+	assert.NoError(t, err)
+
+	job, err := m.Start(domain.ExportRequest{Format: FormatCSV})
+	assert.NoError(t, err)
+	job = waitFor(t, m, job.Id)
+	assert.Equal(t, StateFailed, job.State)
+	assert.Equal(t, int64(4), job.ItemsExported)
+	assert.Equal(t, map[string]string{"uniqueId": "MWA-0", "timestamp": "4"}, job.Segments[0].LastKey)
+
+	// a restarted process picks the job up from its job file
+	scanner.failAfterPages = 0
+	restarted, err := NewManager(bootstrap.ExportConfig{Directory: directory, PageSize: 2}, scanner, zap.L().Named("test-log-zap"))
+	assert.NoError(t, err)
+	_, err = restarted.Resume(job.Id)
+	assert.NoError(t, err)
+	job = waitFor(t, restarted, job.Id)
+	assert.Equal(t, StateCompleted, job.State)
+	assert.Equal(t, int64(6), job.ItemsExported)
+
+	f, err := os.Open(filepath.Join(job.Directory, job.Segments[0].File))
+	assert.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	assert.NoError(t, err)
+	records, err := csv.NewReader(gz).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, records, 7)
+	assert.Equal(t, csvHeader, records[0])
+	assert.Equal(t, "5", records[6][1])
+
+	_, err = restarted.Resume(job.Id)
+	assert.Equal(t, ErrJobFinished, err)
+}
+
+func TestUnreadableJobFileIsSetAside(t *testing.T) {
+	directory := t.TempDir()
+	m, err := NewManager(testConfig(directory), &fakeScanner{itemsPerSegment: 2}, zap.L().Named("test-log-zap"))
+	assert.NoError(t, err)
+	job, err := m.Start(domain.ExportRequest{Format: FormatNDJSON})
+	assert.NoError(t, err)
+	waitFor(t, m, job.Id)
+	damaged := filepath.Join(directory, "damaged", jobFile)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(damaged), 0750))
+	assert.NoError(t, os.WriteFile(damaged, []byte(`{"id":`), 0600))
+
+	restarted, err := NewManager(testConfig(directory), &fakeScanner{}, zap.L().Named("test-log-zap"))
+	assert.NoError(t, err)
+	assert.Len(t, restarted.List(), 1)
+	_, err = os.Stat(damaged + corruptSuffix)
+	assert.NoError(t, err)
+}
+
+func TestAppendPageTruncatesUncheckpointedData(t *testing.T) {
+	location := filepath.Join(t.TempDir(), segmentFileName(0, FormatNDJSON))
+	offset, err := appendPage(location, 0, FormatNDJSON, []domain.SignInInfo{{UniqueId: "a"}})
+	assert.NoError(t, err)
+	// a page written without its checkpoint is replaced on resume
+	_, err = appendPage(location, offset, FormatNDJSON, []domain.SignInInfo{{UniqueId: "lost"}})
+	assert.NoError(t, err)
+	_, err = appendPage(location, offset, FormatNDJSON, []domain.SignInInfo{{UniqueId: "b"}})
+	assert.NoError(t, err)
+
+	lines := readLines(t, location)
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[1], `"uniqueId":"b"`)
+}
@@ -0,0 +1,91 @@
+package export
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+var csvHeader = []string{"uniqueId", "timestamp", "calledId", "ipAddress", "userAgent", "sourceId", "region", "referenceId",
+	"device", "os", "osVersion", "browser", "browserVersion", "riskScore", "riskReasons", "ipPolicy"}
+
+func segmentFileName(segment int, format string) string {
+	return fmt.Sprintf("segment-%05d.%s.gz", segment, format)
+}
+
+// appendPage writes one page as its own gzip member at offset and returns the new end of
+// the file. Anything after offset was written by a page whose checkpoint never made it to
+// disk, it is truncated so a resumed job neither loses nor duplicates items. Concatenated
+// gzip members form a valid gzip file.
+func appendPage(location string, offset int64, format string, items []domain.SignInInfo) (int64, error) {
+	f, err := os.OpenFile(location, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if err := f.Truncate(offset); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	gz := gzip.NewWriter(f)
+	switch format {
+	case FormatCSV:
+		err = writeCSV(gz, items, offset == 0)
+	default:
+		err = writeNDJSON(gz, items)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	return f.Seek(0, io.SeekCurrent)
+}
+
+func writeNDJSON(w io.Writer, items []domain.SignInInfo) error {
+	encoder := json.NewEncoder(w)
+	for _, item := range items {
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSV(w io.Writer, items []domain.SignInInfo, header bool) error {
+	writer := csv.NewWriter(w)
+	if header {
+		if err := writer.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	for _, item := range items {
+		record := []string{item.UniqueId, item.TimeStamp, item.CalledId, item.IpAddress, item.UserAgent, item.SourceId, item.Region,
+			item.ReferenceId, item.Device, item.Os, item.OsVersion, item.Browser, item.BrowserVersion,
+			strconv.Itoa(item.RiskScore), strings.Join(item.RiskReasons, ";"), item.IpPolicy}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
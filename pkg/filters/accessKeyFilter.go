@@ -1,18 +1,36 @@
 package filters
 
 import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
 	"github.mathworks.com/development/accesskeyfilter-go/pkg/accesskeyfilter"
 	"github.mathworks.com/development/mito/pkg/config"
 	"github.mathworks.com/development/mito/pkg/core"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
 	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/controllers"
 	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/tracing"
 	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.opentelemetry.io/otel/codes"
 	"go.uber.org/zap"
 )
 
+// ErrorCodeAuthentication is returned for requests without valid credentials.
+const ErrorCodeAuthentication = 4405
+
+// AKFilter authenticates every request with the first authenticator of its chain that finds
+// credentials on it, access keys, OIDC tokens or client certificates. Which routes need
+// credentials, and which an admin, comes from the controllers' metadata.
 type AKFilter struct {
-	logger *zap.Logger
-	Filter *accesskeyfilter.AccessKeyFilter
+	logger   *zap.Logger
+	chain    []Authenticator
+	policies authz.RoutePolicies
+	// authorizing is false when authorization is disabled, every caller is an admin then
+	authorizing bool
 }
 
 func (ak AKFilter) Receive(message core.Message, ctx core.Context) (core.Message, error) {
@@ -24,39 +42,122 @@ func (ak *AKFilter) validate(message core.Message, ctx core.Context) (core.Messa
 	if err != nil {
 		return packet, err
 	}
-	isValidToken, akv := ak.Filter.VerifyToken(httpReq.Request)
-	if isValidToken {
-		return ctx.Send(message)
-	} else {
-		requestid := httpReq.Request.Header.Get("mathworks-requestid")
-		errresp := domain.ErrorResponse{
-			ErrorCode:    4405,
-			ErrorMessage: akv.Message,
-			RequestID:    requestid,
+	// the filter runs first for every route, its span is the parent of the controller's
+	reqCtx, span := tracing.StartServerSpan(httpReq.Request, "AKFilter.Receive")
+	defer span.End()
+	httpReq.Request = httpReq.Request.WithContext(reqCtx)
+
+	policy := ak.policies.For(httpReq.Request.URL.Path)
+	if policy == authz.RoutePublic {
+		return ctx.Send(httpReq)
+	}
+	for _, authenticator := range ak.chain {
+		caller, err := authenticator.Authenticate(httpReq.Request)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		var rejection *Rejection
+		if errors.As(err, &rejection) {
+			span.SetStatus(codes.Error, rejection.Message)
+			return ak.reject(httpReq.Request, ErrorCodeAuthentication, rejection)
+		}
+		if err != nil {
+			return nil, err
 		}
-		return utils.DispatchJsonResponse(errresp, ak.logger, akv.Code)
+		if !ak.authorizing {
+			caller.Scopes = []string{authz.ScopeAdmin}
+		}
+		if policy == authz.RouteAdmin && !caller.Has(authz.ScopeAdmin) {
+			span.SetStatus(codes.Error, "not an admin")
+			return ak.reject(httpReq.Request, controllers.ErrorCodeForbidden, &Rejection{Status: http.StatusForbidden, Message: "Caller is not allowed to use " + httpReq.Request.URL.Path})
+		}
+		httpReq.Request = httpReq.Request.WithContext(authz.WithCaller(reqCtx, caller))
+		return ctx.Send(httpReq)
 	}
+	span.SetStatus(codes.Error, "no credentials")
+	return ak.reject(httpReq.Request, ErrorCodeAuthentication, &Rejection{Status: http.StatusUnauthorized, Message: "Request carries no credentials"})
 }
 
-func initialize(filter *AKFilter, publicKey string, logger *zap.Logger) {
-	keyFunc := func() string {
-		return publicKey
+func (ak *AKFilter) reject(request *http.Request, errorCode int, rejection *Rejection) (core.Message, error) {
+	tracing.Logger(request.Context(), ak.logger).Info("Authentication rejected", zap.String("path", request.URL.Path), zap.String("reason", rejection.Message))
+	requestid := request.Header.Get("mathworks-requestid")
+	errresp := domain.ErrorResponse{
+		ErrorCode:    errorCode,
+		ErrorMessage: rejection.Message,
+		RequestID:    requestid,
 	}
+	return utils.DispatchJsonResponse(errresp, ak.logger, rejection.Status)
+}
+
+func newAccessKeyFilter(keyFunc func() string, logger *zap.Logger) *accesskeyfilter.AccessKeyFilter {
 	// 1. create akfilterconfig object
-	accesskeyfilter.DefaultExclusions = append(accesskeyfilter.DefaultExclusions, "/admin/health/v2", "/admin/health/html")
 	cfg := new(accesskeyfilter.AKFilterConfig)
 	// 2. initialize it with a bunch of params
 	cfg.InitConfig(keyFunc, false, make([]string, 0), make(map[string]string, 0), logger)
-	filter.Filter = &accesskeyfilter.AccessKeyFilter{
+	return &accesskeyfilter.AccessKeyFilter{
 		FilterConfig: cfg,
 	}
+}
 
+// accessKeys collects the public keys of conf, ak.public is the key with id "default" unless
+// ak.publickeys or the key directory define that id themselves.
+func accessKeys(conf bootstrap.AccessKeyConfig) (map[string]string, error) {
+	keys := authz.ParseKeyList(conf.PublicKeys)
+	if conf.KeyDirectory != "" {
+		directoryKeys, err := authz.LoadKeyDirectory(conf.KeyDirectory)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range directoryKeys {
+			keys[kid] = key
+		}
+	}
+	if _, ok := keys[authz.DefaultKeyId]; !ok && conf.AccessKeyPublic != "" {
+		keys[authz.DefaultKeyId] = conf.AccessKeyPublic
+	}
+	return keys, nil
 }
 
 func NewAKFilter(config config.Config, registry core.Registry) *AKFilter {
 	logger := zap.L().Named("signindatatrackerws.akfilter")
 	r := &AKFilter{logger: logger}
-	initialize(r, bootstrap.GetApplicationContext().AppConfigData.AccessKey.AccessKeyPublic, logger)
+	appConfig := bootstrap.GetApplicationContext().AppConfigData
+	policies, err := controllers.CompileRoutePolicies(controllers.Routes)
+	if err != nil {
+		log.Fatalf("Failed to compile route auth policies: %v", err)
+	}
+	r.policies = policies
+
+	keys := authz.NewAccessKeyring(nil)
+	watcher := authz.NewKeyringWatcher(keys, func() (map[string]string, error) {
+		conf, err := appConfig.ReadAccessKeyConfig()
+		if err != nil {
+			return nil, err
+		}
+		return accessKeys(conf)
+	}, time.Duration(appConfig.AccessKey.ReloadSeconds)*time.Second, logger)
+	watcher.Reload()
+	if appConfig.AccessKey.ReloadSeconds > 0 {
+		// the filter lives as long as the process
+		watcher.Start(make(chan struct{}))
+	}
+	accessKey := &accessKeyAuthenticator{
+		keys: keys,
+		newFilter: func(keyFunc func() string) *accesskeyfilter.AccessKeyFilter {
+			return newAccessKeyFilter(keyFunc, logger)
+		},
+	}
+	grants := authz.ParseGrants(appConfig.Authz.Grants)
+	r.authorizing = appConfig.Authz.Enabled
+	accessKey.callers = authz.NewKeyringClaimsResolver(keys, grants)
+	if !appConfig.Authz.Enabled {
+		logger.Warn("Authorization is disabled, every authenticated caller has every scope until app.signindatatracker.authz.grants is set")
+	}
+	chain, err := buildAuthenticators(appConfig.Authn, accessKey, grants)
+	if err != nil {
+		log.Fatalf("Failed to set up authentication: %v", err)
+	}
+	r.chain = chain
 	registry.AddServiceProvider("auth/accesskeys", r)
 	registry.AddRouteFilter("auth/accesskeys", "http/default", "auth/accesskeys", 10)
 	return r
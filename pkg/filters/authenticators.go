@@ -0,0 +1,214 @@
+package filters
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.mathworks.com/development/accesskeyfilter-go/pkg/accesskeyfilter"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+)
+
+const (
+	AuthenticatorAccessKey = "accesskey"
+	AuthenticatorJWT       = "jwt"
+	AuthenticatorMTLS      = "mtls"
+)
+
+// ErrNoCredentials is returned by an authenticator when the request does not carry its kind of
+// credentials, the next authenticator of the chain is tried.
+var ErrNoCredentials = errors.New("request carries no credentials for this authenticator")
+
+// Rejection is returned when the request carries credentials that are not valid, the chain
+// stops and the request is refused with Status.
+type Rejection struct {
+	Status  int
+	Message string
+}
+
+func (r *Rejection) Error() string {
+	return r.Message
+}
+
+// Authenticator identifies the caller of a request. Every authenticator maps its credentials to
+// the same authz.Caller, the controllers do not know how a caller signed in.
+type Authenticator interface {
+	Authenticate(request *http.Request) (authz.Caller, error)
+}
+
+// accessKeyAuthenticator accepts the internal access key. It claims every request, it has to be
+// the last authenticator of a chain.
+type accessKeyAuthenticator struct {
+	keys *authz.AccessKeyring
+	// newFilter builds the access key filter of one key id, keyFunc reads the key from the ring
+	// on every request
+	newFilter func(keyFunc func() string) *accesskeyfilter.AccessKeyFilter
+	mu        sync.Mutex
+	filters   map[string]*accesskeyfilter.AccessKeyFilter
+	// callers resolves the identity and scopes of accepted access keys, the scopes are replaced
+	// when authorization is disabled
+	callers *authz.ClaimsResolver
+}
+
+// Authenticate verifies a key that names its kid with that key only. Keys without a kid, or
+// with one the ring does not know, are tried against every active key, which keeps keys of
+// the old signing key valid while a rotation overlaps.
+func (a *accessKeyAuthenticator) Authenticate(request *http.Request) (authz.Caller, error) {
+	kids := a.keys.Ids()
+	if len(kids) == 0 {
+		return authz.Caller{}, ErrNoCredentials
+	}
+	if kid := authz.TokenKeyId(authz.BearerToken(request)); kid != "" {
+		if _, ok := a.keys.Key(kid); ok {
+			kids = []string{kid}
+		}
+	}
+	var rejection *Rejection
+	for _, kid := range kids {
+		isValidToken, akv := a.filter(kid).VerifyToken(request)
+		if isValidToken {
+			// the id comes from the verified claims only, the caller id header is not signed
+			caller := a.callers.Resolve(request)
+			if caller.Id == "" {
+				caller.Id = authz.AnonymousCallerId
+			}
+			caller.Method = AuthenticatorAccessKey
+			return caller, nil
+		}
+		rejection = &Rejection{Status: akv.Code, Message: akv.Message}
+	}
+	return authz.Caller{}, rejection
+}
+
+func (a *accessKeyAuthenticator) filter(kid string) *accesskeyfilter.AccessKeyFilter {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if filter, ok := a.filters[kid]; ok {
+		return filter
+	}
+	if a.filters == nil {
+		a.filters = make(map[string]*accesskeyfilter.AccessKeyFilter)
+	}
+	filter := a.newFilter(func() string {
+		key, _ := a.keys.Key(kid)
+		return key
+	})
+	a.filters[kid] = filter
+	return filter
+}
+
+// jwtAuthenticator accepts OIDC tokens of the configured issuer. Tokens without a scope claim
+// get the grants of their caller id, like access keys.
+type jwtAuthenticator struct {
+	verifier *authz.OIDCVerifier
+	grants   map[string][]string
+}
+
+func (a *jwtAuthenticator) Authenticate(request *http.Request) (authz.Caller, error) {
+	token := authz.BearerToken(request)
+	if token == "" || !a.verifier.Issued(token) {
+		return authz.Caller{}, ErrNoCredentials
+	}
+	caller, err := a.verifier.Verify(request.Context(), token)
+	if err != nil {
+		return authz.Caller{}, &Rejection{Status: http.StatusUnauthorized, Message: err.Error()}
+	}
+	if len(caller.Scopes) == 0 {
+		caller.Scopes = a.grants[caller.Id]
+	}
+	caller.Method = AuthenticatorJWT
+	return caller, nil
+}
+
+// mtlsAuthenticator maps the client certificate of a TLS connection to a caller id by its common
+// name or one of its DNS names. The certificate is verified against the configured CAs here, the
+// listener may only request it. A TLS terminating load balancer in front of the service hides the
+// certificate and the request falls through to the next authenticator.
+type mtlsAuthenticator struct {
+	roots    *x509.CertPool
+	subjects map[string]string
+	grants   map[string][]string
+}
+
+func (a *mtlsAuthenticator) Authenticate(request *http.Request) (authz.Caller, error) {
+	if request.TLS == nil || len(request.TLS.PeerCertificates) == 0 {
+		return authz.Caller{}, ErrNoCredentials
+	}
+	leaf := request.TLS.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range request.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         a.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return authz.Caller{}, &Rejection{Status: http.StatusUnauthorized, Message: "Client certificate is not trusted"}
+	}
+	for _, name := range append([]string{leaf.Subject.CommonName}, leaf.DNSNames...) {
+		if callerId, ok := a.subjects[name]; ok {
+			return authz.Caller{Id: callerId, Scopes: a.grants[callerId], Method: AuthenticatorMTLS}, nil
+		}
+	}
+	return authz.Caller{}, &Rejection{Status: http.StatusForbidden, Message: "Client certificate " + leaf.Subject.CommonName + " is not mapped to a caller"}
+}
+
+// ParseSubjects reads the client certificate mapping "<common or DNS name>=<callerId>;...".
+func ParseSubjects(value string) map[string]string {
+	subjects := make(map[string]string)
+	for _, entry := range strings.Split(value, ";") {
+		name, callerId, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if found && strings.TrimSpace(name) != "" && strings.TrimSpace(callerId) != "" {
+			subjects[strings.TrimSpace(name)] = strings.TrimSpace(callerId)
+		}
+	}
+	return subjects
+}
+
+// buildAuthenticators creates the chain named by the comma separated config, in its order.
+func buildAuthenticators(conf bootstrap.AuthnConfig, accessKey *accessKeyAuthenticator, grants map[string][]string) ([]Authenticator, error) {
+	var chain []Authenticator
+	names := strings.Split(conf.Chain, ",")
+	for i, name := range names {
+		switch strings.TrimSpace(name) {
+		case AuthenticatorAccessKey:
+			if i != len(names)-1 {
+				return nil, errors.New("the access key authenticator claims every request, it has to come last")
+			}
+			chain = append(chain, accessKey)
+		case AuthenticatorJWT:
+			if conf.JWTIssuer == "" || conf.JWTAudience == "" || conf.JWTJWKS == "" {
+				return nil, errors.New("the jwt authenticator needs an issuer, an audience and a jwks location")
+			}
+			keys := authz.NewKeySet(conf.JWTJWKS, time.Duration(conf.JWTJWKSRefreshSeconds)*time.Second)
+			chain = append(chain, &jwtAuthenticator{
+				verifier: authz.NewOIDCVerifier(keys, conf.JWTIssuer, conf.JWTAudience, conf.JWTCallerIdClaim),
+				grants:   grants,
+			})
+		case AuthenticatorMTLS:
+			pem, err := os.ReadFile(conf.MTLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read mtls ca file: %w", err)
+			}
+			roots := x509.NewCertPool()
+			if !roots.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("mtls ca file %s holds no certificates", conf.MTLSCAFile)
+			}
+			chain = append(chain, &mtlsAuthenticator{roots: roots, subjects: ParseSubjects(conf.MTLSSubjects), grants: grants})
+		default:
+			return nil, fmt.Errorf("unknown authenticator %q", name)
+		}
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no authenticator is configured")
+	}
+	return chain, nil
+}
@@ -0,0 +1,106 @@
+package filters
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+)
+
+func issueCert(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	return cert, key
+}
+
+func testCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	return issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "partners CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+}
+
+func clientCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, commonName string) *x509.Certificate {
+	cert, _ := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	return cert
+}
+
+func TestMTLSMapsTrustedCertificatesToCallers(t *testing.T) {
+	ca, caKey := testCA(t)
+	location := filepath.Join(t.TempDir(), "ca.pem")
+	assert.Nil(t, os.WriteFile(location, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600))
+	chain, err := buildAuthenticators(bootstrap.AuthnConfig{
+		Chain:        "mtls",
+		MTLSCAFile:   location,
+		MTLSSubjects: "partner-a.example.com=PARTNERA",
+	}, nil, map[string][]string{"PARTNERA": {authz.ScopeReadPseudonymized}})
+	assert.Nil(t, err)
+	authenticator := chain[0]
+
+	request := httptest.NewRequest(http.MethodGet, "/v1/lastSignIn", nil)
+	request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert(t, ca, caKey, "partner-a.example.com")}}
+	caller, err := authenticator.Authenticate(request)
+	assert.Nil(t, err)
+	assert.Equal(t, authz.Caller{Id: "PARTNERA", Scopes: []string{authz.ScopeReadPseudonymized}, Method: AuthenticatorMTLS}, caller)
+
+	request.TLS.PeerCertificates = []*x509.Certificate{clientCert(t, ca, caKey, "unknown.example.com")}
+	_, err = authenticator.Authenticate(request)
+	assert.Equal(t, http.StatusForbidden, err.(*Rejection).Status)
+
+	otherCA, otherKey := testCA(t)
+	request.TLS.PeerCertificates = []*x509.Certificate{clientCert(t, otherCA, otherKey, "partner-a.example.com")}
+	_, err = authenticator.Authenticate(request)
+	assert.Equal(t, http.StatusUnauthorized, err.(*Rejection).Status)
+
+	request.TLS = nil
+	_, err = authenticator.Authenticate(request)
+	assert.Equal(t, ErrNoCredentials, err)
+}
+
+func TestBuildAuthenticatorsRejectsInvalidChains(t *testing.T) {
+	for _, conf := range []bootstrap.AuthnConfig{
+		{Chain: "accesskey,jwt", JWTIssuer: "https://login.example.com/", JWTAudience: "signindatatracker", JWTJWKS: "jwks.json"},
+		{Chain: "jwt"},
+		{Chain: "mtls", MTLSCAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		{Chain: "kerberos"},
+	} {
+		_, err := buildAuthenticators(conf, &accessKeyAuthenticator{}, nil)
+		assert.NotNil(t, err, conf.Chain)
+	}
+	chain, err := buildAuthenticators(bootstrap.AuthnConfig{Chain: "jwt, accesskey", JWTIssuer: "https://login.example.com/",
+		JWTAudience: "signindatatracker", JWTJWKS: "jwks.json"}, &accessKeyAuthenticator{}, nil)
+	assert.Nil(t, err)
+	assert.Len(t, chain, 2)
+}
@@ -0,0 +1,166 @@
+package filters
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.mathworks.com/development/mito/pkg/config"
+	"github.mathworks.com/development/mito/pkg/core"
+	"github.mathworks.com/development/mito/pkg/mwhttp"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/cache"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/ratelimit"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const ErrorCodeRateLimited = 4429
+
+var unlimitedPaths = []string{"/admin/health/v2", "/admin/health/html", "/admin/metrics", "/v1/admin/alive"}
+
+type RateLimitFilter struct {
+	logger  *zap.Logger
+	limiter *ratelimit.Limiter
+}
+
+func (rl RateLimitFilter) Receive(message core.Message, ctx core.Context) (core.Message, error) {
+	return rl.limit(message, ctx)
+}
+
+func (rl *RateLimitFilter) limit(message core.Message, ctx core.Context) (core.Message, error) {
+	httpReq, packet, err := utils.CheckForHttpType(message, rl.logger)
+	if err != nil {
+		return packet, err
+	}
+	if rl.limiter == nil {
+		return ctx.Send(httpReq)
+	}
+	callerId := callerKey(httpReq.Request)
+	decision, err := rl.limiter.Allow(httpReq.Request.URL.Path, callerId)
+	if err != nil {
+		// an unreachable shared store must not take the service down with it
+		rl.logger.Warn("Rate limit store failed, request let through", zap.String("callerId", callerId), zap.Error(err))
+		return ctx.Send(httpReq)
+	}
+	if !decision.Allowed {
+		rl.logger.Info("Rate limit exceeded", zap.String("callerId", callerId), zap.String("path", httpReq.Request.URL.Path))
+		errresp := domain.ErrorResponse{
+			ErrorCode:    ErrorCodeRateLimited,
+			ErrorMessage: "Rate limit exceeded",
+			RequestID:    httpReq.Request.Header.Get("mathworks-requestid"),
+		}
+		response, err := utils.DispatchJsonResponse(errresp, rl.logger, http.StatusTooManyRequests)
+		headers := rateLimitHeaders(decision)
+		headers["Retry-After"] = strconv.Itoa(ceilSeconds(decision.RetryAfter))
+		return withHeaders(response, headers), err
+	}
+	response, err := ctx.Send(httpReq)
+	if decision.Limit == 0 {
+		return response, err
+	}
+	return withHeaders(response, rateLimitHeaders(decision)), err
+}
+
+// callerKey identifies the bucket owner by the caller the authenticators verified, its id and
+// then its subject. The X-MW-Caller-Id header is only used on routes without authentication,
+// elsewhere a caller could pick someone else's bucket with it.
+func callerKey(request *http.Request) string {
+	if caller, ok := authz.CallerFromContext(request.Context()); ok {
+		if caller.Id != "" {
+			return caller.Id
+		}
+		if caller.Subject != "" {
+			return caller.Subject
+		}
+		return authz.AnonymousCallerId
+	}
+	if callerId := request.Header.Get(authz.CallerIdHeader); callerId != "" {
+		return callerId
+	}
+	return authz.AnonymousCallerId
+}
+
+func rateLimitHeaders(decision ratelimit.Decision) map[string]string {
+	return map[string]string{
+		"RateLimit-Limit":     strconv.Itoa(decision.Limit),
+		"RateLimit-Remaining": strconv.Itoa(decision.Remaining),
+		"RateLimit-Reset":     strconv.Itoa(ceilSeconds(decision.Reset)),
+	}
+}
+
+// withHeaders adds headers to simple responses, other message types pass unchanged.
+func withHeaders(message core.Message, headers map[string]string) core.Message {
+	response, ok := message.(mwhttp.SimpleResponse)
+	if !ok {
+		return message
+	}
+	if response.Headers == nil {
+		response.Headers = make(map[string]string, len(headers))
+	}
+	for name, value := range headers {
+		response.Headers[name] = value
+	}
+	return response
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func ratePolicies(conf bootstrap.RateLimitConfig) (ratelimit.Policies, error) {
+	policies, err := ratelimit.ParseOverrides(ratelimit.Limit{
+		Requests: conf.RequestsPerMinute,
+		Period:   time.Minute,
+		Burst:    conf.Burst,
+	}, conf.Overrides)
+	if err != nil {
+		return policies, err
+	}
+	// monitoring probes come without a caller id and would share one bucket
+	for _, path := range unlimitedPaths {
+		if _, ok := policies.Routes[path]; !ok {
+			policies.Routes[path] = ratelimit.Limit{}
+		}
+	}
+	return policies, nil
+}
+
+func NewRateLimitFilter(config config.Config, registry core.Registry) *RateLimitFilter {
+	logger := zap.L().Named("signindatatrackerws.ratelimit")
+	r := &RateLimitFilter{logger: logger}
+	appConfig := bootstrap.GetApplicationContext().AppConfigData
+	if appConfig.RateLimit.Enabled {
+		policies, err := ratePolicies(appConfig.RateLimit)
+		if err != nil {
+			log.Fatalf("Failed to read rate limit overrides: %v", err)
+		}
+		var store ratelimit.Store = ratelimit.NewMemoryStore()
+		if appConfig.RateLimit.Store == ratelimit.StoreRedis {
+			store = ratelimit.NewRedisStore(cache.NewRedisCache(cache.RedisConfig{
+				Address:  appConfig.Cache.RedisAddress,
+				Password: appConfig.Cache.RedisPassword,
+				DB:       appConfig.Cache.RedisDB,
+				PoolSize: appConfig.Cache.RedisPoolSize,
+				Timeout:  time.Duration(appConfig.Cache.RedisTimeoutMillis) * time.Millisecond,
+			}))
+		}
+		r.limiter = ratelimit.NewLimiter(store, policies)
+		bootstrap.GetApplicationContext().OnConfigReload(func(config *bootstrap.AppConfigData) {
+			policies, err := ratePolicies(config.RateLimit)
+			if err != nil {
+				logger.Error("Rate limit change rejected, the running limits are kept", zap.Error(err))
+				return
+			}
+			r.limiter.SetPolicies(policies)
+		})
+	}
+	registry.AddServiceProvider("ratelimit", r)
+	// runs after the access key filter so that the caller's subject is known
+	registry.AddRouteFilter("ratelimit", "http/default", "ratelimit", 20)
+	return r
+}
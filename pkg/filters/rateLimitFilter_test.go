@@ -0,0 +1,25 @@
+package filters
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/authz"
+)
+
+func TestCallerKeyPrefersTheAuthenticatedCaller(t *testing.T) {
+	request := httptest.NewRequest("GET", "/v1/lastSignIn", nil)
+	request.Header.Set(authz.CallerIdHeader, "MWA")
+	assert.Equal(t, "MWA", callerKey(request))
+
+	authenticated := request.WithContext(authz.WithCaller(request.Context(), authz.Caller{Id: "PAT", Subject: "PAT-1"}))
+	assert.Equal(t, "PAT", callerKey(authenticated))
+	bySubject := request.WithContext(authz.WithCaller(request.Context(), authz.Caller{Subject: "PAT-1"}))
+	assert.Equal(t, "PAT-1", callerKey(bySubject))
+	// a caller without identity does not get to name its bucket
+	unidentified := request.WithContext(authz.WithCaller(request.Context(), authz.Caller{}))
+	assert.Equal(t, "anonymous", callerKey(unidentified))
+
+	assert.Equal(t, "anonymous", callerKey(httptest.NewRequest("GET", "/v1/admin/alive", nil)))
+}
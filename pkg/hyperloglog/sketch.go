@@ -0,0 +1,106 @@
+package hyperloglog
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+const (
+	// Precision is the number of index bits, 2^12 registers give a ~1.6% standard error
+	// in 4KB which fits comfortably in a DynamoDB item.
+	Precision    = 12
+	RegisterSize = 1 << Precision
+)
+
+var ErrInvalidSketch = errors.New("invalid hyperloglog sketch")
+
+// Sketch is a HyperLogLog cardinality estimator. Its serialized form is the raw register
+// array so sketches can be stored as binary attributes and merged anywhere.
+type Sketch struct {
+	registers []uint8
+}
+
+func New() *Sketch {
+	return &Sketch{registers: make([]uint8, RegisterSize)}
+}
+
+func FromBytes(registers []byte) (*Sketch, error) {
+	if len(registers) != RegisterSize {
+		return nil, ErrInvalidSketch
+	}
+	s := New()
+	copy(s.registers, registers)
+	return s, nil
+}
+
+func (s *Sketch) Bytes() []byte {
+	out := make([]byte, len(s.registers))
+	copy(out, s.registers)
+	return out
+}
+
+// Add records a value and reports whether the sketch changed, callers can skip
+// persisting unchanged sketches.
+func (s *Sketch) Add(value string) bool {
+	h := hash64(value)
+	index := h >> (64 - Precision)
+	rank := uint8(bits.LeadingZeros64(h<<Precision|1<<(Precision-1)) + 1)
+	if rank > s.registers[index] {
+		s.registers[index] = rank
+		return true
+	}
+	return false
+}
+
+// Merge keeps the maximum of every register and reports whether the sketch changed. Merging
+// the same sketch again changes nothing.
+func (s *Sketch) Merge(other *Sketch) bool {
+	changed := false
+	for i, r := range other.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (s *Sketch) Estimate() uint64 {
+	m := float64(RegisterSize)
+	sum := 0.0
+	zeros := 0
+	for _, r := range s.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	// linear counting is more accurate for small cardinalities
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(estimate))
+}
+
+// StandardError is the relative standard error of Estimate.
+func StandardError() float64 {
+	return 1.04 / math.Sqrt(RegisterSize)
+}
+
+// hash64 is FNV-1a finished with the splitmix64 mixer, FNV alone distributes the high
+// bits of short, similar keys poorly. It must stay stable, sketches are persisted.
+func hash64(value string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(value))
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
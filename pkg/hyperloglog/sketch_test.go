@@ -0,0 +1,40 @@
+package hyperloglog
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateWithinErrorBound(t *testing.T) {
+	for _, n := range []int{10, 1000, 100000} {
+		s := New()
+		for i := 0; i < n; i++ {
+			s.Add("MWA-" + strconv.Itoa(i))
+		}
+		// three standard errors
+		assert.InDelta(t, n, s.Estimate(), float64(n)*3*StandardError()+1, "cardinality %d", n)
+	}
+}
+
+func TestAddReportsChange(t *testing.T) {
+	s := New()
+	assert.True(t, s.Add("MWA-1"))
+	assert.False(t, s.Add("MWA-1"))
+}
+
+func TestMergeAndRoundTrip(t *testing.T) {
+	a, b := New(), New()
+	for i := 0; i < 5000; i++ {
+		a.Add("MWA-" + strconv.Itoa(i))
+		b.Add("MWA-" + strconv.Itoa(i+2500))
+	}
+	restored, err := FromBytes(a.Bytes())
+	assert.NoError(t, err)
+	restored.Merge(b)
+	assert.InDelta(t, 7500, restored.Estimate(), 7500*3*StandardError())
+
+	_, err = FromBytes([]byte{1, 2, 3})
+	assert.ErrorIs(t, err, ErrInvalidSketch)
+}
@@ -0,0 +1,101 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileCheckpoint is the import position within one input file. Every row up to Line was
+// written or reported, so a rerun continues after it.
+type FileCheckpoint struct {
+	Line       int  `json:"line"`
+	Imported   int  `json:"imported"`
+	Rejected   int  `json:"rejected"`
+	Duplicates int  `json:"duplicates"`
+	Done       bool `json:"done"`
+}
+
+type CheckpointStore struct {
+	mu          sync.Mutex
+	location    string
+	checkpoints map[string]FileCheckpoint
+}
+
+func NewCheckpointStore(location string) (*CheckpointStore, error) {
+	store := &CheckpointStore{location: location, checkpoints: map[string]FileCheckpoint{}}
+	content, err := os.ReadFile(location)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &store.checkpoints); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *CheckpointStore) Get(file string) FileCheckpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpoints[file]
+}
+
+func (s *CheckpointStore) Save(file string, checkpoint FileCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[file] = checkpoint
+	content, err := json.MarshalIndent(s.checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.location), 0750); err != nil {
+		return err
+	}
+	tmp := s.location + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.location)
+}
+
+type rejectedRow struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Error      string `json:"error"`
+	Raw        string `json:"raw"`
+	RejectedAt string `json:"rejectedAt"`
+}
+
+// ErrorReport appends every rejected row as one NDJSON line.
+type ErrorReport struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func NewErrorReport(location string) (*ErrorReport, error) {
+	if err := os.MkdirAll(filepath.Dir(location), 0750); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(location, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &ErrorReport{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (r *ErrorReport) Reject(file string, line int, reason error, raw string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.encoder.Encode(rejectedRow{File: file, Line: line, Error: reason.Error(), Raw: raw, RejectedAt: time.Now().UTC().Format(time.RFC3339)})
+}
+
+func (r *ErrorReport) Close() error {
+	return r.file.Close()
+}
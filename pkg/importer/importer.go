@@ -0,0 +1,363 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/ippolicy"
+	"github.mathworks.com/development/signindatatrackerws/pkg/throttle"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// BatchWriteItem accepts at most 25 put requests
+const batchSize = 25
+
+type BatchWriterInterface interface {
+	BatchSaveSignInTrackingInfo(requests []domain.SaveSignInInfo) (unprocessed []domain.SaveSignInInfo, consumedCapacity float64, err error)
+}
+
+type Config struct {
+	Workers                   int
+	MaxWriteCapacityPerSecond int
+	MaxRetries                int
+	InitialBackoff            time.Duration
+	MaxBackoff                time.Duration
+	// DedupeWindow is how many recent keys are remembered, a duplicate further apart is
+	// written again, which overwrites the item with the same key
+	DedupeWindow int
+	// IpPolicies minimizes the imported IPs like the ingest path does, nil keeps them as they are
+	IpPolicies *ippolicy.Policies
+	// AfterWrite runs for every written row before the checkpoint passes it, it keeps the summaries,
+	// rollups and sketches the ingest path maintains in step. A batch written again after an interrupted
+	// run is counted again. nil skips it.
+	AfterWrite func(ctx context.Context, record domain.SaveSignInInfo)
+}
+
+// Importer loads historical sign-ins, keeping their original timestamps, with concurrent
+// BatchWriteItem calls throttled on consumed write capacity.
+type Importer struct {
+	logger      *zap.Logger
+	writer      BatchWriterInterface
+	checkpoints *CheckpointStore
+	report      *ErrorReport
+	config      Config
+	limiter     *throttle.CapacityLimiter
+	seen        *keyWindow
+	now         func() time.Time
+}
+
+func NewImporter(writer BatchWriterInterface, checkpoints *CheckpointStore, report *ErrorReport, config Config, logger *zap.Logger) *Importer {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	return &Importer{
+		logger:      logger,
+		writer:      writer,
+		checkpoints: checkpoints,
+		report:      report,
+		config:      config,
+		limiter:     throttle.NewCapacityLimiter(config.MaxWriteCapacityPerSecond),
+		seen:        newKeyWindow(config.DedupeWindow),
+		now:         time.Now,
+	}
+}
+
+type batch struct {
+	seq        int
+	lastLine   int
+	items      []domain.SaveSignInInfo
+	rejected   []rejectedLine
+	duplicates int
+}
+
+// rejectedLine is reported once the checkpoint passes its batch, so a rerun does not report it again.
+type rejectedLine struct {
+	line   int
+	reason error
+	raw    string
+}
+
+// ImportFile imports the rows after the file's checkpoint. The checkpoint only advances over
+// batches that were written completely, so a failed or interrupted run can simply be repeated.
+func (im *Importer) ImportFile(ctx context.Context, file string, reader RecordReaderInterface) (FileCheckpoint, error) {
+	checkpoint := im.checkpoints.Get(file)
+	if checkpoint.Done {
+		im.logger.Info("Skipping imported file", zap.String("file", file))
+		return checkpoint, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tracker := &progressTracker{checkpoint: checkpoint, completed: map[int]batch{}}
+	batches := make(chan batch, im.config.Workers)
+	var failOnce sync.Once
+	var failure error
+	fail := func(err error) {
+		failOnce.Do(func() {
+			failure = err
+			cancel()
+		})
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < im.config.Workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				if err := im.writeBatch(ctx, b.items); err != nil {
+					fail(fmt.Errorf("batch ending at line %d: %w", b.lastLine, err))
+					continue
+				}
+				if im.config.AfterWrite != nil {
+					for _, item := range b.items {
+						im.config.AfterWrite(ctx, item)
+					}
+				}
+				err := tracker.complete(b, func(done batch) error {
+					for _, rejected := range done.rejected {
+						if err := im.report.Reject(file, rejected.line, rejected.reason, rejected.raw); err != nil {
+							return err
+						}
+					}
+					return nil
+				}, func(advanced FileCheckpoint) error {
+					return im.checkpoints.Save(file, advanced)
+				})
+				if err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	err := im.readBatches(ctx, reader, checkpoint.Line, batches)
+	close(batches)
+	wg.Wait()
+	if err != nil {
+		fail(err)
+	}
+	if failure != nil {
+		return tracker.current(), failure
+	}
+
+	final := tracker.current()
+	final.Done = true
+	return final, im.checkpoints.Save(file, final)
+}
+
+func (im *Importer) readBatches(ctx context.Context, reader RecordReaderInterface, skipThrough int, batches chan<- batch) error {
+	current := batch{}
+	send := func() bool {
+		select {
+		case batches <- current:
+			current = batch{seq: current.seq + 1}
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if row.Line <= skipThrough {
+			continue
+		}
+		current.lastLine = row.Line
+
+		if row.Err == nil {
+			row.Err = Validate(&row.Record, im.now())
+		}
+		if row.Err == nil && im.config.IpPolicies != nil {
+			im.config.IpPolicies.Minimize(&row.Record)
+		}
+		switch {
+		case row.Err != nil:
+			current.rejected = append(current.rejected, rejectedLine{line: row.Line, reason: row.Err, raw: row.Raw})
+		case !im.seen.Add(row.Record.UniqueId + "\x00" + row.Record.TimeStamp):
+			current.duplicates++
+		default:
+			current.items = append(current.items, row.Record)
+		}
+
+		if len(current.items) == batchSize && !send() {
+			return ctx.Err()
+		}
+	}
+	// the last batch also carries the position of trailing rejected rows
+	if current.lastLine > 0 && !send() {
+		return ctx.Err()
+	}
+	return nil
+}
+
+func (im *Importer) writeBatch(ctx context.Context, items []domain.SaveSignInInfo) error {
+	pending := items
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > im.config.MaxRetries {
+				return fmt.Errorf("%d items still unprocessed after %d retries", len(pending), im.config.MaxRetries)
+			}
+			if !sleep(ctx, im.backoff(attempt)) {
+				return ctx.Err()
+			}
+		}
+		if !im.limiter.Wait(ctx.Done()) {
+			return ctx.Err()
+		}
+		unprocessed, consumed, err := im.writer.BatchSaveSignInTrackingInfo(pending)
+		im.limiter.Consume(consumed)
+		if err != nil {
+			im.logger.Warn("Batch write failed, retrying", zap.Int("attempt", attempt+1), zap.Error(err))
+			if attempt >= im.config.MaxRetries {
+				return err
+			}
+			continue
+		}
+		pending = unprocessed
+	}
+	return nil
+}
+
+// backoff is exponential with full jitter so throttled workers do not retry in lockstep.
+func (im *Importer) backoff(attempt int) time.Duration {
+	ceiling := im.config.InitialBackoff << (attempt - 1)
+	if ceiling <= 0 || ceiling > im.config.MaxBackoff {
+		ceiling = im.config.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Validate checks a row and normalises its timestamp to epoch milliseconds, the format the
+// service stores. Imported rows must carry their original time.
+func Validate(record *domain.SaveSignInInfo, now time.Time) error {
+	if record.UniqueId == "" {
+		return errors.New("uniqueId is required")
+	}
+	if record.TimeStamp == "" {
+		return errors.New("timestamp is required")
+	}
+	ts, err := utils.ParseTimeParam(record.TimeStamp, time.Time{})
+	if err != nil {
+		return err
+	}
+	if ts.After(now) {
+		return fmt.Errorf("timestamp %s is in the future", record.TimeStamp)
+	}
+	record.TimeStamp = strconv.FormatInt(ts.UnixMilli(), 10)
+	if record.IpAddress != "" && net.ParseIP(record.IpAddress) == nil {
+		return fmt.Errorf("invalid ipAddress %q", record.IpAddress)
+	}
+	if record.RiskScore < 0 || record.RiskScore > 100 {
+		return fmt.Errorf("riskScore %d is outside 0-100", record.RiskScore)
+	}
+	return nil
+}
+
+// progressTracker advances the checkpoint over the contiguous prefix of completed batches,
+// workers finish out of order.
+type progressTracker struct {
+	mu         sync.Mutex
+	next       int
+	completed  map[int]batch
+	checkpoint FileCheckpoint
+}
+
+// complete records b, reports every batch the checkpoint advances over and saves the advanced
+// checkpoint. Both happen under the lock so the report and the checkpoint files move in order.
+func (p *progressTracker) complete(b batch, report func(done batch) error, save func(advanced FileCheckpoint) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.completed[b.seq] = b
+	advanced := false
+	for {
+		done, ok := p.completed[p.next]
+		if !ok {
+			break
+		}
+		if err := report(done); err != nil {
+			if advanced {
+				// keep the batches reported so far from being reported again
+				_ = save(p.checkpoint)
+			}
+			return err
+		}
+		delete(p.completed, p.next)
+		p.next++
+		p.checkpoint.Line = done.lastLine
+		p.checkpoint.Imported += len(done.items)
+		p.checkpoint.Rejected += len(done.rejected)
+		p.checkpoint.Duplicates += done.duplicates
+		advanced = true
+	}
+	if !advanced {
+		return nil
+	}
+	return save(p.checkpoint)
+}
+
+func (p *progressTracker) current() FileCheckpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.checkpoint
+}
+
+// keyWindow remembers the most recent keys, evicting the oldest once full.
+type keyWindow struct {
+	size  int
+	keys  map[string]struct{}
+	order []string
+	head  int
+}
+
+func newKeyWindow(size int) *keyWindow {
+	return &keyWindow{size: size, keys: map[string]struct{}{}}
+}
+
+// Add returns false when the key was already seen within the window.
+func (w *keyWindow) Add(key string) bool {
+	if _, ok := w.keys[key]; ok {
+		return false
+	}
+	if w.size <= 0 {
+		return true
+	}
+	if len(w.order) < w.size {
+		w.order = append(w.order, key)
+	} else {
+		delete(w.keys, w.order[w.head])
+		w.order[w.head] = key
+		w.head = (w.head + 1) % w.size
+	}
+	w.keys[key] = struct{}{}
+	return true
+}
@@ -0,0 +1,187 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"go.uber.org/zap"
+)
+
+// fakeWriter leaves the last item of every first attempt unprocessed and can fail outright
+// once a number of items were stored.
+type fakeWriter struct {
+	mu        sync.Mutex
+	stored    map[string]domain.SaveSignInInfo
+	calls     int
+	failAfter int
+}
+
+func (f *fakeWriter) BatchSaveSignInTrackingInfo(requests []domain.SaveSignInInfo) ([]domain.SaveSignInInfo, float64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.failAfter > 0 && len(f.stored) >= f.failAfter {
+		return nil, 0, errors.New("ProvisionedThroughputExceededException")
+	}
+	var unprocessed []domain.SaveSignInInfo
+	if f.calls%2 == 1 && len(requests) > 1 {
+		unprocessed = requests[len(requests)-1:]
+		requests = requests[:len(requests)-1]
+	}
+	for _, request := range requests {
+		f.stored[request.UniqueId+"/"+request.TimeStamp] = request
+	}
+	return unprocessed, float64(len(requests)), nil
+}
+
+func newTestImporter(t *testing.T, directory string, writer *fakeWriter) *Importer {
+	checkpoints, err := NewCheckpointStore(filepath.Join(directory, "checkpoints.json"))
+	assert.NoError(t, err)
+	report, err := NewErrorReport(filepath.Join(directory, "rejected.ndjson"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { report.Close() })
+	return NewImporter(writer, checkpoints, report, Config{Workers: 3, MaxRetries: 2, DedupeWindow: 100}, zap.L().Named("test-log-zap"))
+}
+
+func ndjsonInput(rows int) string {
+	var b strings.Builder
+	for i := 0; i < rows; i++ {
+		b.WriteString(`{"uniqueId":"MWA-1","timeStamp":"` + time.Unix(1700000000+int64(i), 0).UTC().Format(time.RFC3339) + `","ipAddress":"10.0.0.1"}` + "\n")
+	}
+	return b.String()
+}
+
+func TestImportPreservesTimestampsAndReportsRejects(t *testing.T) {
+	directory := t.TempDir()
+	writer := &fakeWriter{stored: map[string]domain.SaveSignInInfo{}}
+	im := newTestImporter(t, directory, writer)
+
+	input := ndjsonInput(60) +
+		`{"uniqueId":"MWA-1","timeStamp":"2023-11-14T22:13:20Z"}` + "\n" + // duplicate of the first row
+		`{"uniqueId":"","timeStamp":"1700000000000"}` + "\n" +
+		`{"uniqueId":"MWA-2","timeStamp":"1700000000000","ipAddress":"not-an-ip"}` + "\n" +
+		`not json` + "\n"
+	reader, err := NewRecordReader(strings.NewReader(input), FormatNDJSON)
+	assert.NoError(t, err)
+
+	checkpoint, err := im.ImportFile(context.Background(), "signins.ndjson", reader)
+	assert.NoError(t, err)
+	assert.Equal(t, FileCheckpoint{Line: 64, Imported: 60, Rejected: 3, Duplicates: 1, Done: true}, checkpoint)
+	assert.Len(t, writer.stored, 60)
+	assert.Equal(t, "10.0.0.1", writer.stored["MWA-1/1700000000000"].IpAddress)
+
+	report, err := os.ReadFile(filepath.Join(directory, "rejected.ndjson"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(report)), "\n")
+	assert.Len(t, lines, 3)
+	assert.Contains(t, lines[0], `"line":62`)
+	assert.Contains(t, lines[1], "invalid ipAddress")
+
+	// a finished file is skipped on the next run
+	reader, _ = NewRecordReader(strings.NewReader(input), FormatNDJSON)
+	_, err = im.ImportFile(context.Background(), "signins.ndjson", reader)
+	assert.NoError(t, err)
+	assert.Len(t, writer.stored, 60)
+}
+
+func TestImportResumesAfterFailure(t *testing.T) {
+	directory := t.TempDir()
+	writer := &fakeWriter{stored: map[string]domain.SaveSignInInfo{}, failAfter: 30}
+	im := newTestImporter(t, directory, writer)
+
+	reader, _ := NewRecordReader(strings.NewReader(ndjsonInput(100)), FormatNDJSON)
+	checkpoint, err := im.ImportFile(context.Background(), "signins.ndjson", reader)
+	assert.Error(t, err)
+	assert.False(t, checkpoint.Done)
+	assert.Equal(t, 0, checkpoint.Line%batchSize)
+	assert.Equal(t, checkpoint.Line, checkpoint.Imported)
+
+	writer.failAfter = 0
+	resumed := newTestImporter(t, directory, writer)
+	reader, _ = NewRecordReader(strings.NewReader(ndjsonInput(100)), FormatNDJSON)
+	checkpoint, err = resumed.ImportFile(context.Background(), "signins.ndjson", reader)
+	assert.NoError(t, err)
+	assert.True(t, checkpoint.Done)
+	assert.Equal(t, 100, checkpoint.Imported)
+	assert.Len(t, writer.stored, 100)
+}
+
+func TestCSVReaderMapsHeader(t *testing.T) {
+	input := "uniqueId,timestamp,ipAddress,riskScore,riskReasons\n" +
+		"MWA-1,1700000000000,10.0.0.1,70,new_device;new_country\n" +
+		"MWA-2,1700000000000,10.0.0.2,high,\n"
+	reader, err := NewRecordReader(strings.NewReader(input), FormatCSV)
+	assert.NoError(t, err)
+
+	row, err := reader.Next()
+	assert.NoError(t, err)
+	assert.NoError(t, row.Err)
+	assert.Equal(t, 2, row.Line)
+	assert.Equal(t, domain.SaveSignInInfo{UniqueId: "MWA-1", TimeStamp: "1700000000000", IpAddress: "10.0.0.1", RiskScore: 70,
+		RiskReasons: []string{"new_device", "new_country"}}, row.Record)
+
+	row, err = reader.Next()
+	assert.NoError(t, err)
+	assert.Error(t, row.Err)
+
+	_, err = NewRecordReader(bufio.NewReader(strings.NewReader("uniqueId,color\n")), FormatCSV)
+	assert.Error(t, err)
+}
+
+func TestKeyWindowEvictsOldestKeys(t *testing.T) {
+	w := newKeyWindow(2)
+	assert.True(t, w.Add("a"))
+	assert.True(t, w.Add("b"))
+	assert.False(t, w.Add("a"))
+	assert.True(t, w.Add("c"))
+	assert.True(t, w.Add("a"))
+}
+
+func TestRerunReportsRejectsAndRunsAfterWriteOnce(t *testing.T) {
+	directory := t.TempDir()
+	writer := &fakeWriter{stored: map[string]domain.SaveSignInInfo{}, failAfter: batchSize}
+	var mu sync.Mutex
+	afterWrites := 0
+	newImporter := func() *Importer {
+		im := newTestImporter(t, directory, writer)
+		// one worker so the batch after the first fails deterministically
+		im.config.Workers = 1
+		im.config.AfterWrite = func(ctx context.Context, record domain.SaveSignInInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			afterWrites++
+		}
+		return im
+	}
+	rows := strings.SplitAfter(ndjsonInput(2*batchSize), "\n")
+	input := strings.Join(rows[:batchSize], "") + `not json` + "\n" + strings.Join(rows[batchSize:], "")
+
+	reader, _ := NewRecordReader(strings.NewReader(input), FormatNDJSON)
+	checkpoint, err := newImporter().ImportFile(context.Background(), "signins.ndjson", reader)
+	assert.Error(t, err)
+	assert.Equal(t, batchSize, checkpoint.Line)
+	report, err := os.ReadFile(filepath.Join(directory, "rejected.ndjson"))
+	assert.NoError(t, err)
+	assert.Empty(t, report)
+
+	writer.failAfter = 0
+	reader, _ = NewRecordReader(strings.NewReader(input), FormatNDJSON)
+	checkpoint, err = newImporter().ImportFile(context.Background(), "signins.ndjson", reader)
+	assert.NoError(t, err)
+	assert.Equal(t, FileCheckpoint{Line: 2*batchSize + 1, Imported: 2 * batchSize, Rejected: 1, Done: true}, checkpoint)
+	report, err = os.ReadFile(filepath.Join(directory, "rejected.ndjson"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(report)), "\n")
+	assert.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"line":26`)
+	assert.Equal(t, 2*batchSize, afterWrites)
+}
@@ -0,0 +1,168 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+	maxLineBytes = 1024 * 1024
+)
+
+// Row is one input record. Line is the line number for NDJSON and the record number, header
+// included, for CSV.
+type Row struct {
+	Line   int
+	Record domain.SaveSignInInfo
+	Raw    string
+	Err    error
+}
+
+type RecordReaderInterface interface {
+	// Next returns io.EOF after the last row, a row that could not be decoded carries Err
+	Next() (Row, error)
+}
+
+// DetectFormat picks the format from the file extension, ignoring a trailing .gz.
+func DetectFormat(location string) (string, error) {
+	ext := filepath.Ext(strings.TrimSuffix(location, ".gz"))
+	switch strings.ToLower(ext) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".ndjson", ".jsonl", ".json":
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("cannot detect the format of %s, use -format", location)
+}
+
+func NewRecordReader(r io.Reader, format string) (RecordReaderInterface, error) {
+	switch format {
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+		return &ndjsonReader{scanner: scanner}, nil
+	case FormatCSV:
+		return newCSVReader(r)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonReader) Next() (Row, error) {
+	for r.scanner.Scan() {
+		r.line++
+		raw := strings.TrimSpace(r.scanner.Text())
+		if raw == "" {
+			continue
+		}
+		row := Row{Line: r.line, Raw: raw}
+		row.Err = json.Unmarshal([]byte(raw), &row.Record)
+		return row, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
+
+// csvReader maps the header to SaveSignInInfo fields by their json or dynamodb name, so files
+// written by the export job can be imported again.
+type csvReader struct {
+	reader  *csv.Reader
+	line    int
+	columns []int
+}
+
+// csvFields indexes the SaveSignInInfo fields by lower-cased json and dynamodb name.
+var csvFields = func() map[string]int {
+	fields := map[string]int{}
+	t := reflect.TypeOf(domain.SaveSignInInfo{})
+	for i := 0; i < t.NumField(); i++ {
+		for _, tag := range []string{"json", "dynamodbav"} {
+			if name := strings.Split(t.Field(i).Tag.Get(tag), ",")[0]; name != "" {
+				fields[strings.ToLower(name)] = i
+			}
+		}
+	}
+	return fields
+}()
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading the csv header: %w", err)
+	}
+	columns := make([]int, len(header))
+	for i, name := range header {
+		field, ok := csvFields[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown csv column %q", name)
+		}
+		columns[i] = field
+	}
+	return &csvReader{reader: reader, line: 1, columns: columns}, nil
+}
+
+func (r *csvReader) Next() (Row, error) {
+	record, err := r.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return Row{}, io.EOF
+	}
+	r.line++
+	row := Row{Line: r.line, Raw: strings.Join(record, ",")}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		row.Err = err
+		return row, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+	if len(record) != len(r.columns) {
+		row.Err = fmt.Errorf("expected %d columns, got %d", len(r.columns), len(record))
+		return row, nil
+	}
+
+	value := reflect.ValueOf(&row.Record).Elem()
+	for i, column := range r.columns {
+		field := value.Field(column)
+		cell := strings.TrimSpace(record[i])
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(cell)
+		case reflect.Int:
+			if cell == "" {
+				continue
+			}
+			n, err := strconv.Atoi(cell)
+			if err != nil {
+				row.Err = fmt.Errorf("column %d: %q is not a number", i+1, cell)
+				return row, nil
+			}
+			field.SetInt(int64(n))
+		case reflect.Slice:
+			if cell != "" {
+				field.Set(reflect.ValueOf(strings.Split(cell, ";")))
+			}
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,322 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/resilience"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrQueueFull   = errors.New("ingestion queue is full")
+	ErrQueueClosed = errors.New("ingestion queue is shutting down")
+)
+
+// BatchProcessorInterface persists queued sign-ins. Prepare runs once per batch, Write is
+// retried with backoff for the records it returns as unprocessed, Complete runs once every
+// record of the batch was written.
+type BatchProcessorInterface interface {
+	Prepare(records []domain.SaveSignInInfo)
+	Write(records []domain.SaveSignInInfo) (unprocessed []domain.SaveSignInInfo, err error)
+	Complete(records []domain.SaveSignInInfo)
+}
+
+type Config struct {
+	Capacity       int
+	Workers        int
+	BatchSize      int
+	BatchLinger    time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// MaxAttempts bounds the writes of a batch that fail with an error that is not transient,
+	// the records are then moved to DeadLetterLocation. Throttling and an open circuit breaker
+	// are retried until they pass, the queue exists to wait for DynamoDB to come back.
+	MaxAttempts        int
+	DeadLetterLocation string
+}
+
+const defaultMaxAttempts = 5
+
+// Queue is a bounded write-behind queue. Records are in the write-ahead log before Enqueue
+// returns and are acknowledged there only after the processor completed them.
+type Queue struct {
+	logger    *zap.Logger
+	config    Config
+	wal       *WriteAheadLog
+	processor BatchProcessorInterface
+	entries   chan queued
+	slots     chan struct{}
+	replay    []Entry
+
+	mu       sync.RWMutex
+	closed   bool
+	abort    chan struct{}
+	replayed chan struct{}
+	workers  sync.WaitGroup
+}
+
+type queued struct {
+	entry    Entry
+	replayed bool
+}
+
+func NewQueue(config Config, wal *WriteAheadLog, replay []Entry, processor BatchProcessorInterface, logger *zap.Logger) *Queue {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+	if config.BatchSize < 1 {
+		config.BatchSize = 1
+	}
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	return &Queue{
+		logger:    logger,
+		config:    config,
+		wal:       wal,
+		processor: processor,
+		// replayed entries take no slot, the room for them keeps Enqueue from ever blocking
+		entries:  make(chan queued, config.Capacity+len(replay)),
+		slots:    make(chan struct{}, config.Capacity),
+		replay:   replay,
+		abort:    make(chan struct{}),
+		replayed: make(chan struct{}),
+	}
+}
+
+// Start launches the workers and feeds them the records left over from the previous run.
+func (q *Queue) Start() {
+	for i := 0; i < q.config.Workers; i++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+	if len(q.replay) > 0 {
+		q.logger.Info("Replaying write-ahead log", zap.Int("records", len(q.replay)))
+	}
+	go func() {
+		defer close(q.replayed)
+		for _, entry := range q.replay {
+			select {
+			case q.entries <- queued{entry: entry, replayed: true}:
+			case <-q.abort:
+				return
+			}
+		}
+		q.replay = nil
+	}()
+}
+
+func (q *Queue) Enqueue(record domain.SaveSignInInfo) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		return ErrQueueFull
+	}
+	entry, err := q.wal.Append(record)
+	if err != nil {
+		<-q.slots
+		return err
+	}
+	// cannot block: a slot was taken, and entries has room for every slot plus the replay
+	q.entries <- queued{entry: entry}
+	return nil
+}
+
+// Depth is the number of accepted records not yet written.
+func (q *Queue) Depth() int {
+	return len(q.slots)
+}
+
+// Shutdown stops accepting records and drains the queue. When ctx ends first the workers stop
+// after their current batch, records still queued stay in the log for the next start.
+func (q *Queue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	q.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		<-q.replayed
+		close(q.entries)
+		q.workers.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		close(q.abort)
+		<-drained
+		err = ctx.Err()
+	}
+	q.logger.Info("Ingestion queue stopped", zap.Int("undelivered", q.Depth()))
+	if closeErr := q.wal.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (q *Queue) work() {
+	defer q.workers.Done()
+	for {
+		first, ok := <-q.entries
+		if !ok {
+			return
+		}
+		batch := []queued{first}
+		linger := time.NewTimer(q.config.BatchLinger)
+	collect:
+		for len(batch) < q.config.BatchSize {
+			select {
+			case next, ok := <-q.entries:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			case <-linger.C:
+				break collect
+			}
+		}
+		linger.Stop()
+		if !q.process(batch) {
+			return
+		}
+	}
+}
+
+// process returns false when the queue was aborted before the batch was written.
+func (q *Queue) process(batch []queued) bool {
+	records := make([]domain.SaveSignInInfo, 0, len(batch))
+	entries := make([]Entry, 0, len(batch))
+	for _, item := range batch {
+		records = append(records, item.entry.Record)
+		entries = append(entries, item.entry)
+	}
+	q.processor.Prepare(records)
+
+	pending := records
+	failures := 0
+	for attempt := 0; ; attempt++ {
+		unprocessed, err := q.processor.Write(pending)
+		if err == nil && len(unprocessed) == 0 {
+			break
+		}
+		if err != nil && !resilience.IsTransient(err) && !errors.Is(err, resilience.ErrCircuitOpen) {
+			failures++
+		}
+		if failures >= q.config.MaxAttempts {
+			deadErr := q.deadLetter(pending, err)
+			if deadErr == nil {
+				q.logger.Error("Batch write keeps failing, records moved to the dead letter file",
+					zap.Int("records", len(pending)), zap.String("location", q.config.DeadLetterLocation), zap.Error(err))
+				records = written(records, pending)
+				break
+			}
+			q.logger.Error("Could not dead letter records, retrying", zap.Error(deadErr))
+		}
+		if err != nil {
+			q.logger.Warn("Batch write failed, retrying", zap.Int("attempt", attempt+1), zap.Int("records", len(pending)), zap.Error(err))
+		} else {
+			pending = unprocessed
+		}
+		timer := time.NewTimer(q.backoff(attempt))
+		select {
+		case <-q.abort:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+
+	q.processor.Complete(records)
+	if err := q.wal.Ack(entries); err != nil {
+		// the records are stored, at worst they are written again on the next start
+		q.logger.Error("Could not acknowledge write-ahead log entries", zap.Error(err))
+	}
+	for _, item := range batch {
+		if !item.replayed {
+			<-q.slots
+		}
+	}
+	return true
+}
+
+// deadLetter appends the records with the error that rejected them as NDJSON, they are
+// acknowledged in the write-ahead log afterwards and not replayed again.
+func (q *Queue) deadLetter(records []domain.SaveSignInInfo, cause error) error {
+	if q.config.DeadLetterLocation == "" {
+		return errors.New("no dead letter location configured")
+	}
+	if err := os.MkdirAll(filepath.Dir(q.config.DeadLetterLocation), 0750); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(q.config.DeadLetterLocation, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	reason := ""
+	if cause != nil {
+		reason = cause.Error()
+	}
+	encoder := json.NewEncoder(file)
+	for _, record := range records {
+		if err := encoder.Encode(deadLetter{Error: reason, Record: record}); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+type deadLetter struct {
+	Error  string                `json:"error"`
+	Record domain.SaveSignInInfo `json:"record"`
+}
+
+// written returns the records of a batch that are not in failed.
+func written(records []domain.SaveSignInInfo, failed []domain.SaveSignInInfo) []domain.SaveSignInInfo {
+	skip := make(map[string]bool, len(failed))
+	for _, record := range failed {
+		skip[record.UniqueId+"\x00"+record.TimeStamp] = true
+	}
+	kept := make([]domain.SaveSignInInfo, 0, len(records))
+	for _, record := range records {
+		if !skip[record.UniqueId+"\x00"+record.TimeStamp] {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// backoff is exponential with full jitter.
+func (q *Queue) backoff(attempt int) time.Duration {
+	ceiling := q.config.InitialBackoff << attempt
+	if ceiling <= 0 || ceiling > q.config.MaxBackoff {
+		ceiling = q.config.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
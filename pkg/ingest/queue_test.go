@@ -0,0 +1,265 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/resilience"
+	"go.uber.org/zap"
+)
+
+// fakeProcessor fails the first writes and leaves one record unprocessed on the next.
+type fakeProcessor struct {
+	mu        sync.Mutex
+	failures  int
+	partial   bool
+	block     chan struct{}
+	written   map[string]bool
+	completed []string
+}
+
+func (f *fakeProcessor) Prepare(records []domain.SaveSignInInfo) {
+	for i := range records {
+		records[i].RiskScore = 10
+	}
+}
+
+func (f *fakeProcessor) Write(records []domain.SaveSignInInfo) ([]domain.SaveSignInInfo, error) {
+	if f.block != nil {
+		<-f.block
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return nil, errors.New("ProvisionedThroughputExceededException")
+	}
+	if f.partial && len(records) > 1 {
+		f.partial = false
+		for _, record := range records[1:] {
+			f.written[record.UniqueId] = true
+		}
+		return records[:1], nil
+	}
+	for _, record := range records {
+		f.written[record.UniqueId] = true
+	}
+	return nil, nil
+}
+
+func (f *fakeProcessor) Complete(records []domain.SaveSignInInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, record := range records {
+		f.completed = append(f.completed, record.UniqueId)
+	}
+}
+
+func testQueueConfig(capacity int) Config {
+	return Config{Capacity: capacity, Workers: 2, BatchSize: 5, BatchLinger: time.Millisecond, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+}
+
+func openTestQueue(t *testing.T, directory string, capacity int, processor *fakeProcessor) *Queue {
+	wal, replay, err := OpenWriteAheadLog(directory, 256)
+	assert.NoError(t, err)
+	q := NewQueue(testQueueConfig(capacity), wal, replay, processor, zap.L().Named("test-log-zap"))
+	q.Start()
+	return q
+}
+
+func TestQueueWritesWithRetriesAndDrainsOnShutdown(t *testing.T) {
+	directory := t.TempDir()
+	processor := &fakeProcessor{failures: 2, partial: true, written: map[string]bool{}}
+	q := openTestQueue(t, directory, 100, processor)
+
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, q.Enqueue(domain.SaveSignInInfo{UniqueId: "MWA-" + strconv.Itoa(i)}))
+	}
+	assert.NoError(t, q.Shutdown(context.Background()))
+
+	assert.Len(t, processor.written, 20)
+	assert.Len(t, processor.completed, 20)
+	assert.Equal(t, 0, q.Depth())
+	assert.Equal(t, ErrQueueClosed, q.Enqueue(domain.SaveSignInInfo{UniqueId: "late"}))
+
+	// every segment was acknowledged and removed
+	leftovers, _ := filepath.Glob(filepath.Join(directory, "*"+walSuffix))
+	assert.LessOrEqual(t, len(leftovers), 1)
+	_, replay, err := OpenWriteAheadLog(directory, 256)
+	assert.NoError(t, err)
+	assert.Empty(t, replay)
+}
+
+func TestQueueRejectsWhenFullAndReplaysAfterRestart(t *testing.T) {
+	directory := t.TempDir()
+	processor := &fakeProcessor{written: map[string]bool{}, block: make(chan struct{})}
+	q := openTestQueue(t, directory, 3, processor)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, q.Enqueue(domain.SaveSignInInfo{UniqueId: "MWA-" + strconv.Itoa(i)}))
+	}
+	assert.Equal(t, ErrQueueFull, q.Enqueue(domain.SaveSignInInfo{UniqueId: "MWA-3"}))
+
+	// the workers are stuck, shutting down with an expired deadline leaves the records in the log
+	processor.mu.Lock()
+	processor.failures = 1000
+	processor.mu.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(processor.block)
+	}()
+	assert.Equal(t, context.Canceled, q.Shutdown(ctx))
+	assert.Empty(t, processor.written)
+
+	restarted := &fakeProcessor{written: map[string]bool{}}
+	q = openTestQueue(t, directory, 3, restarted)
+	assert.NoError(t, q.Shutdown(context.Background()))
+	assert.Equal(t, map[string]bool{"MWA-0": true, "MWA-1": true, "MWA-2": true}, restarted.written)
+}
+
+func TestWriteAheadLogSkipsAcknowledgedAndTornEntries(t *testing.T) {
+	directory := t.TempDir()
+	wal, _, err := OpenWriteAheadLog(directory, 1<<20)
+	assert.NoError(t, err)
+	first, err := wal.Append(domain.SaveSignInInfo{UniqueId: "a"})
+	assert.NoError(t, err)
+	_, err = wal.Append(domain.SaveSignInInfo{UniqueId: "b"})
+	assert.NoError(t, err)
+	assert.NoError(t, wal.Ack([]Entry{first}))
+	assert.NoError(t, wal.Close())
+
+	f, err := os.OpenFile(wal.walLocation(first.Segment), os.O_WRONLY|os.O_APPEND, 0600)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"seq":3,"record":{"uniq`)
+	assert.NoError(t, err)
+	f.Close()
+
+	reopened, replay, err := OpenWriteAheadLog(directory, 1<<20)
+	assert.NoError(t, err)
+	assert.Len(t, replay, 1)
+	assert.Equal(t, "b", replay[0].Record.UniqueId)
+
+	next, err := reopened.Append(domain.SaveSignInInfo{UniqueId: "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), next.Seq)
+	assert.NotEqual(t, first.Segment, next.Segment)
+}
+
+// rejectingProcessor fails every write of uniqueId with an error that is not transient.
+type rejectingProcessor struct {
+	fakeProcessor
+	uniqueId string
+}
+
+func (r *rejectingProcessor) Write(records []domain.SaveSignInInfo) ([]domain.SaveSignInInfo, error) {
+	for _, record := range records {
+		if record.UniqueId == r.uniqueId {
+			return records, errors.New("ValidationException: item size has exceeded the maximum allowed size")
+		}
+	}
+	return r.fakeProcessor.Write(records)
+}
+
+func TestQueueDeadLettersRecordsThatKeepFailing(t *testing.T) {
+	directory := t.TempDir()
+	wal, replay, err := OpenWriteAheadLog(directory, 256)
+	assert.NoError(t, err)
+	config := testQueueConfig(10)
+	config.BatchSize = 1
+	config.MaxAttempts = 3
+	config.DeadLetterLocation = filepath.Join(directory, "deadletter.ndjson")
+	processor := &rejectingProcessor{fakeProcessor: fakeProcessor{written: map[string]bool{}}, uniqueId: "MWA-bad"}
+	q := NewQueue(config, wal, replay, processor, zap.L().Named("test-log-zap"))
+	q.Start()
+
+	assert.NoError(t, q.Enqueue(domain.SaveSignInInfo{UniqueId: "MWA-bad", TimeStamp: "1"}))
+	assert.NoError(t, q.Enqueue(domain.SaveSignInInfo{UniqueId: "MWA-good", TimeStamp: "2"}))
+	assert.NoError(t, q.Shutdown(context.Background()))
+	assert.Equal(t, map[string]bool{"MWA-good": true}, processor.written)
+	assert.Equal(t, []string{"MWA-good"}, processor.completed)
+
+	content, err := os.ReadFile(config.DeadLetterLocation)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"uniqueId":"MWA-bad"`)
+	assert.Contains(t, string(content), "ValidationException")
+
+	// the dead lettered record is acknowledged and not replayed
+	_, replay, err = OpenWriteAheadLog(directory, 256)
+	assert.NoError(t, err)
+	assert.Empty(t, replay)
+}
+
+func TestEnqueueDoesNotBlockBehindReplay(t *testing.T) {
+	directory := t.TempDir()
+	wal, _, err := OpenWriteAheadLog(directory, 1<<20)
+	assert.NoError(t, err)
+	// more than the blocked workers and the channel can hold
+	for i := 0; i < 14; i++ {
+		_, err := wal.Append(domain.SaveSignInInfo{UniqueId: "MWA-old-" + strconv.Itoa(i)})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, wal.Close())
+
+	processor := &fakeProcessor{written: map[string]bool{}, block: make(chan struct{})}
+	q := openTestQueue(t, directory, 2, processor)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, q.Enqueue(domain.SaveSignInInfo{UniqueId: "MWA-new-0"}))
+		assert.NoError(t, q.Enqueue(domain.SaveSignInInfo{UniqueId: "MWA-new-1"}))
+		assert.Equal(t, ErrQueueFull, q.Enqueue(domain.SaveSignInInfo{UniqueId: "MWA-new-2"}))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Enqueue blocked while the workers were busy with the replay")
+	}
+	close(processor.block)
+	assert.NoError(t, q.Shutdown(context.Background()))
+	assert.Len(t, processor.written, 16)
+}
+
+// breakerProcessor writes through a circuit breaker, like the repository does.
+type breakerProcessor struct {
+	fakeProcessor
+	breaker *resilience.CircuitBreaker
+}
+
+func (b *breakerProcessor) Write(records []domain.SaveSignInInfo) ([]domain.SaveSignInInfo, error) {
+	if !b.breaker.Allow() {
+		return records, fmt.Errorf("BatchWriteItem: %w", resilience.ErrCircuitOpen)
+	}
+	b.breaker.Record(true)
+	return b.fakeProcessor.Write(records)
+}
+
+func TestQueueWaitsForOpenCircuitInsteadOfDeadLettering(t *testing.T) {
+	directory := t.TempDir()
+	wal, replay, err := OpenWriteAheadLog(directory, 256)
+	assert.NoError(t, err)
+	config := testQueueConfig(10)
+	config.MaxAttempts = 2
+	config.DeadLetterLocation = filepath.Join(directory, "deadletter.ndjson")
+	breaker := resilience.NewCircuitBreaker(1, 100*time.Millisecond)
+	breaker.Record(false)
+	processor := &breakerProcessor{fakeProcessor: fakeProcessor{written: map[string]bool{}}, breaker: breaker}
+	q := NewQueue(config, wal, replay, processor, zap.L().Named("test-log-zap"))
+	q.Start()
+
+	assert.NoError(t, q.Enqueue(domain.SaveSignInInfo{UniqueId: "MWA-1", TimeStamp: "1"}))
+	assert.NoError(t, q.Shutdown(context.Background()))
+	assert.Equal(t, map[string]bool{"MWA-1": true}, processor.written)
+	_, err = os.Stat(config.DeadLetterLocation)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,276 @@
+package ingest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+const (
+	walSuffix = ".wal"
+	ackSuffix = ".ack"
+)
+
+// Entry is one record in the write-ahead log.
+type Entry struct {
+	Seq     uint64                `json:"seq"`
+	Segment uint64                `json:"-"`
+	Record  domain.SaveSignInInfo `json:"record"`
+}
+
+// WriteAheadLog appends accepted records to segment files and their acknowledgements to a
+// sibling .ack file. A segment is deleted once it is rotated out and every record in it was
+// acknowledged, whatever is left on disk at startup is replayed.
+type WriteAheadLog struct {
+	mu              sync.Mutex
+	directory       string
+	maxSegmentBytes int64
+	active          *walSegment
+	segments        map[uint64]*walSegment
+	nextSeq         uint64
+	nextSegment     uint64
+}
+
+type walSegment struct {
+	id      uint64
+	file    *os.File
+	ackFile *os.File
+	size    int64
+	records int
+	acked   int
+}
+
+// OpenWriteAheadLog loads the log in directory and returns the entries that were never
+// acknowledged, oldest first.
+func OpenWriteAheadLog(directory string, maxSegmentBytes int64) (*WriteAheadLog, []Entry, error) {
+	if err := os.MkdirAll(directory, 0750); err != nil {
+		return nil, nil, err
+	}
+	w := &WriteAheadLog{directory: directory, maxSegmentBytes: maxSegmentBytes, segments: map[uint64]*walSegment{}, nextSeq: 1, nextSegment: 1}
+	locations, err := filepath.Glob(filepath.Join(directory, "*"+walSuffix))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(locations)
+
+	var pending []Entry
+	for _, location := range locations {
+		id, err := strconv.ParseUint(strings.TrimSuffix(filepath.Base(location), walSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		entries, acked, err := readSegment(location, w.ackLocation(id))
+		if err != nil {
+			return nil, nil, err
+		}
+		segment := &walSegment{id: id, records: len(entries)}
+		for _, entry := range entries {
+			if entry.Seq >= w.nextSeq {
+				w.nextSeq = entry.Seq + 1
+			}
+			if acked[entry.Seq] {
+				segment.acked++
+				continue
+			}
+			entry.Segment = id
+			pending = append(pending, entry)
+		}
+		if id >= w.nextSegment {
+			w.nextSegment = id + 1
+		}
+		if segment.acked == segment.records {
+			if err := w.remove(segment); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		w.segments[id] = segment
+	}
+	return w, pending, nil
+}
+
+// readSegment skips a torn last line, the record in it was never acknowledged to a caller.
+func readSegment(location string, ackLocation string) ([]Entry, map[uint64]bool, error) {
+	var entries []Entry
+	if err := readLines(location, func(line string) {
+		var entry Entry
+		if json.Unmarshal([]byte(line), &entry) == nil && entry.Seq > 0 {
+			entries = append(entries, entry)
+		}
+	}); err != nil {
+		return nil, nil, err
+	}
+	acked := map[uint64]bool{}
+	err := readLines(ackLocation, func(line string) {
+		if seq, err := strconv.ParseUint(line, 10, 64); err == nil {
+			acked[seq] = true
+		}
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+	return entries, acked, nil
+}
+
+func readLines(location string, fn func(string)) error {
+	f, err := os.Open(location)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			fn(line)
+		}
+	}
+	return scanner.Err()
+}
+
+// Append durably records the entry before the caller is told it was accepted.
+func (w *WriteAheadLog) Append(record domain.SaveSignInInfo) (Entry, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.active == nil || w.active.size >= w.maxSegmentBytes {
+		if err := w.rotate(); err != nil {
+			return Entry{}, err
+		}
+	}
+	entry := Entry{Seq: w.nextSeq, Segment: w.active.id, Record: record}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	n, err := w.active.file.Write(append(line, '\n'))
+	w.active.size += int64(n)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := w.active.file.Sync(); err != nil {
+		return Entry{}, err
+	}
+	w.nextSeq++
+	w.active.records++
+	return entry, nil
+}
+
+// Ack marks entries as persisted downstream so they are not replayed.
+func (w *WriteAheadLog) Ack(entries []Entry) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	bySegment := map[uint64][]uint64{}
+	for _, entry := range entries {
+		bySegment[entry.Segment] = append(bySegment[entry.Segment], entry.Seq)
+	}
+	for id, seqs := range bySegment {
+		segment, ok := w.segments[id]
+		if !ok {
+			continue
+		}
+		if segment.ackFile == nil {
+			f, err := os.OpenFile(w.ackLocation(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+			if err != nil {
+				return err
+			}
+			segment.ackFile = f
+		}
+		var b strings.Builder
+		for _, seq := range seqs {
+			b.WriteString(strconv.FormatUint(seq, 10))
+			b.WriteByte('\n')
+		}
+		if _, err := segment.ackFile.WriteString(b.String()); err != nil {
+			return err
+		}
+		if err := segment.ackFile.Sync(); err != nil {
+			return err
+		}
+		segment.acked += len(seqs)
+		if segment != w.active && segment.acked >= segment.records {
+			if err := w.remove(segment); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *WriteAheadLog) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var errs []error
+	for _, segment := range w.segments {
+		errs = append(errs, closeSegment(segment))
+	}
+	return errors.Join(errs...)
+}
+
+func (w *WriteAheadLog) rotate() error {
+	if previous := w.active; previous != nil {
+		w.active = nil
+		if previous.acked >= previous.records {
+			if err := w.remove(previous); err != nil {
+				return err
+			}
+		} else {
+			err := previous.file.Close()
+			previous.file = nil
+			if err != nil {
+				return err
+			}
+		}
+	}
+	id := w.nextSegment
+	f, err := os.OpenFile(w.walLocation(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	w.nextSegment++
+	w.active = &walSegment{id: id, file: f}
+	w.segments[id] = w.active
+	return nil
+}
+
+func (w *WriteAheadLog) remove(segment *walSegment) error {
+	if err := closeSegment(segment); err != nil {
+		return err
+	}
+	delete(w.segments, segment.id)
+	for _, location := range []string{w.walLocation(segment.id), w.ackLocation(segment.id)} {
+		if err := os.Remove(location); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func closeSegment(segment *walSegment) error {
+	var errs []error
+	if segment.file != nil {
+		errs = append(errs, segment.file.Close())
+		segment.file = nil
+	}
+	if segment.ackFile != nil {
+		errs = append(errs, segment.ackFile.Close())
+		segment.ackFile = nil
+	}
+	return errors.Join(errs...)
+}
+
+func (w *WriteAheadLog) walLocation(id uint64) string {
+	return filepath.Join(w.directory, fmt.Sprintf("%020d%s", id, walSuffix))
+}
+
+func (w *WriteAheadLog) ackLocation(id uint64) string {
+	return filepath.Join(w.directory, fmt.Sprintf("%020d%s", id, ackSuffix))
+}
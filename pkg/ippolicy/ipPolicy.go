@@ -0,0 +1,178 @@
+package ippolicy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+// Modes of handling the IP address of a sign-in before it is stored, ordered from the least to
+// the most restrictive. A mode is also a policy id of its own.
+const (
+	ModeFull     = "full"
+	ModeTruncate = "truncate"
+	ModeHash     = "hash"
+	ModeDrop     = "drop"
+
+	hashPrefix = "h-"
+
+	ipv4PrefixBits = 24
+	ipv6PrefixBits = 48
+)
+
+var restrictiveness = map[string]int{ModeFull: 0, ModeTruncate: 1, ModeHash: 2, ModeDrop: 3}
+
+// Policy is what gets recorded on the item, Id names the configured policy so that auditors can
+// tell which rule applied even after the rule was changed.
+type Policy struct {
+	Id   string
+	Mode string
+}
+
+type Policies struct {
+	Default    Policy
+	Regions    map[string]Policy
+	Sources    map[string]Policy
+	hashSecret []byte
+}
+
+func FromConfig(config bootstrap.IpPolicyConfig) (*Policies, error) {
+	return ParsePolicies(config.Policies, config.Regions, config.Sources, config.Default, []byte(config.HashSecret))
+}
+
+// ParsePolicies reads the policy definitions "id=mode;id=mode" and the region and source
+// assignments "key=id;key=id". Ids that are not defined must be one of the modes.
+func ParsePolicies(definitions, regions, sources, defaultId string, hashSecret []byte) (*Policies, error) {
+	defined := map[string]Policy{}
+	for mode := range restrictiveness {
+		defined[mode] = Policy{Id: mode, Mode: mode}
+	}
+	for _, entry := range splitEntries(definitions) {
+		id, mode, found := strings.Cut(entry, "=")
+		id, mode = strings.TrimSpace(id), strings.TrimSpace(mode)
+		if _, known := restrictiveness[mode]; !found || id == "" || !known {
+			return nil, fmt.Errorf("invalid ip policy %q", entry)
+		}
+		defined[id] = Policy{Id: id, Mode: mode}
+	}
+	lookup := func(id string) (Policy, error) {
+		policy, ok := defined[strings.TrimSpace(id)]
+		if !ok {
+			return Policy{}, fmt.Errorf("unknown ip policy %q", id)
+		}
+		if policy.Mode == ModeHash && len(hashSecret) == 0 {
+			return Policy{}, fmt.Errorf("ip policy %q hashes without a hash secret", id)
+		}
+		return policy, nil
+	}
+	assign := func(value string) (map[string]Policy, error) {
+		assigned := map[string]Policy{}
+		for _, entry := range splitEntries(value) {
+			key, id, found := strings.Cut(entry, "=")
+			if !found || strings.TrimSpace(key) == "" {
+				return nil, fmt.Errorf("invalid ip policy assignment %q", entry)
+			}
+			policy, err := lookup(id)
+			if err != nil {
+				return nil, err
+			}
+			assigned[strings.TrimSpace(key)] = policy
+		}
+		return assigned, nil
+	}
+
+	policies := &Policies{hashSecret: hashSecret}
+	var err error
+	if defaultId == "" {
+		defaultId = ModeFull
+	}
+	if policies.Default, err = lookup(defaultId); err != nil {
+		return nil, err
+	}
+	if policies.Regions, err = assign(regions); err != nil {
+		return nil, err
+	}
+	if policies.Sources, err = assign(sources); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// For returns the policy of a sign-in. When both its region and its source have a policy the
+// more restrictive one applies, a source can tighten a regional rule but never loosen it.
+func (p *Policies) For(region, sourceId string) Policy {
+	regionPolicy, hasRegion := p.Regions[region]
+	sourcePolicy, hasSource := p.Sources[sourceId]
+	switch {
+	case hasRegion && hasSource:
+		if restrictiveness[sourcePolicy.Mode] > restrictiveness[regionPolicy.Mode] {
+			return sourcePolicy
+		}
+		return regionPolicy
+	case hasRegion:
+		return regionPolicy
+	case hasSource:
+		return sourcePolicy
+	}
+	return p.Default
+}
+
+// Minimize stores the IP address of record the way its policy demands and records the policy.
+func (p *Policies) Minimize(record *domain.SaveSignInInfo) {
+	policy := p.For(record.Region, record.SourceId)
+	record.IpAddress = p.Apply(policy, record.IpAddress)
+	record.IpPolicy = policy.Id
+}
+
+// Apply returns the IP address to store under policy. A value that is not an IP cannot be
+// truncated and is dropped.
+func (p *Policies) Apply(policy Policy, ip string) string {
+	if ip == "" {
+		return ""
+	}
+	switch policy.Mode {
+	case ModeFull:
+		return ip
+	case ModeTruncate:
+		if network := Network(ip); network != nil {
+			return network.IP.String()
+		}
+		return ""
+	case ModeHash:
+		// keyed, a plain hash of the IPv4 space is reversed by trying every address
+		h := hmac.New(sha256.New, p.hashSecret)
+		h.Write([]byte(ip))
+		return hashPrefix + hex.EncodeToString(h.Sum(nil))
+	}
+	return ""
+}
+
+// Network returns the /24 of an IPv4 or the /48 of an IPv6 address, nil when ip is not an IP.
+func Network(ip string) *net.IPNet {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return nil
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		mask := net.CIDRMask(ipv4PrefixBits, 32)
+		return &net.IPNet{IP: v4.Mask(mask), Mask: mask}
+	}
+	mask := net.CIDRMask(ipv6PrefixBits, 128)
+	return &net.IPNet{IP: parsed.Mask(mask), Mask: mask}
+}
+
+func splitEntries(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ";") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,65 @@
+package ippolicy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+func TestMinimizeAppliesTheMostRestrictivePolicy(t *testing.T) {
+	policies, err := ParsePolicies("gdpr-2024=truncate;partner=hash", "EU=gdpr-2024;CN=drop", "mobile=partner;legacy=full", "", []byte("secret"))
+	assert.Nil(t, err)
+
+	cases := []struct {
+		region, sourceId, policy string
+	}{
+		{"US", "web", ModeFull},
+		{"EU", "web", "gdpr-2024"},
+		{"EU", "legacy", "gdpr-2024"},
+		{"EU", "mobile", "partner"},
+		{"CN", "mobile", ModeDrop},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.policy, policies.For(c.region, c.sourceId).Id, c.region+"/"+c.sourceId)
+	}
+
+	record := domain.SaveSignInInfo{Region: "EU", SourceId: "web", IpAddress: "2001:db8:85a3:8d3:1319:8a2e:370:7348"}
+	policies.Minimize(&record)
+	assert.Equal(t, "2001:db8:85a3::", record.IpAddress)
+	assert.Equal(t, "gdpr-2024", record.IpPolicy)
+
+	record = domain.SaveSignInInfo{Region: "EU", SourceId: "mobile", IpAddress: "203.0.113.77"}
+	policies.Minimize(&record)
+	assert.True(t, strings.HasPrefix(record.IpAddress, hashPrefix))
+	other := domain.SaveSignInInfo{Region: "US", SourceId: "mobile", IpAddress: "203.0.113.77"}
+	policies.Minimize(&other)
+	assert.Equal(t, record.IpAddress, other.IpAddress)
+
+	record = domain.SaveSignInInfo{Region: "CN", IpAddress: "203.0.113.77"}
+	policies.Minimize(&record)
+	assert.Equal(t, "", record.IpAddress)
+	assert.Equal(t, ModeDrop, record.IpPolicy)
+}
+
+func TestTruncateDropsValuesThatAreNoIP(t *testing.T) {
+	policies, err := ParsePolicies("", "", "", ModeTruncate, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "203.0.113.0", policies.Apply(policies.Default, "203.0.113.77"))
+	assert.Equal(t, "", policies.Apply(policies.Default, "unknown"))
+}
+
+func TestParsePoliciesRejectsInvalidConfiguration(t *testing.T) {
+	for _, c := range []struct{ definitions, regions, defaultId string }{
+		{"gdpr=blur", "", ""},
+		{"", "EU=gdpr", ""},
+		{"", "EU", ""},
+		{"", "", "unknown"},
+		// hashing needs a secret
+		{"", "EU=hash", ""},
+	} {
+		_, err := ParsePolicies(c.definitions, c.regions, "", c.defaultId, nil)
+		assert.NotNil(t, err, c)
+	}
+}
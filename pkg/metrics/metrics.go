@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.mathworks.com/development/signindatatrackerws/pkg/resilience"
+)
+
+const namespace = "signindatatracker"
+
+const (
+	SourceUnknown = "unknown"
+	SourceOther   = "other"
+	// maxSourceLabels bounds the sourceId series while no sources are configured
+	maxSourceLabels = 50
+)
+
+const (
+	OutcomeSuccess     = "success"
+	OutcomeThrottled   = "throttled"
+	OutcomeValidation  = "validation"
+	OutcomeConditional = "conditional_failed"
+	OutcomeCircuitOpen = "circuit_open"
+	OutcomeError       = "error"
+)
+
+var (
+	DynamoRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dynamodb_requests_total",
+		Help:      "DynamoDB calls by operation, table and outcome, every retry attempt counts.",
+	}, []string{"operation", "table", "outcome"})
+
+	DynamoLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "dynamodb_request_duration_seconds",
+		Help:      "Latency of single DynamoDB calls.",
+		Buckets:   prometheus.ExponentialBuckets(0.002, 2, 12),
+	}, []string{"operation", "table"})
+
+	DynamoConsumedCapacity = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dynamodb_consumed_capacity_units_total",
+		Help:      "Capacity units DynamoDB reported as consumed.",
+	}, []string{"operation", "table"})
+
+	RepositoryLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_duration_seconds",
+		Help:      "Latency of SignInRepo methods including retries and cache lookups.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"operation", "table", "outcome"})
+
+	SignInsSaved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signins_saved_total",
+		Help:      "Sign-ins written to the table by sourceId and outcome.",
+	}, []string{"sourceId", "outcome"})
+)
+
+// Outcome maps an error to the outcome label, the AWS error codes are the ones the SDK
+// reports for DynamoDB.
+func Outcome(err error) string {
+	if err == nil {
+		return OutcomeSuccess
+	}
+	if errors.Is(err, resilience.ErrCircuitOpen) {
+		return OutcomeCircuitOpen
+	}
+	if retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		return OutcomeThrottled
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "ProvisionedThroughputExceededException", "RequestLimitExceeded":
+			return OutcomeThrottled
+		case "ValidationException":
+			return OutcomeValidation
+		case "ConditionalCheckFailedException", "TransactionCanceledException":
+			return OutcomeConditional
+		}
+	}
+	return OutcomeError
+}
+
+var sourceLabels = struct {
+	mu         sync.Mutex
+	configured bool
+	known      map[string]bool
+}{known: map[string]bool{}}
+
+// LimitSources gives only the listed sourceIds a series of their own, an empty list keeps the
+// default of the first maxSourceLabels sourceIds seen.
+func LimitSources(sourceIds []string) {
+	sourceLabels.mu.Lock()
+	defer sourceLabels.mu.Unlock()
+	sourceLabels.configured, sourceLabels.known = false, map[string]bool{}
+	for _, sourceId := range sourceIds {
+		if sourceId != "" {
+			sourceLabels.configured, sourceLabels.known[sourceId] = true, true
+		}
+	}
+}
+
+// SourceLabel keeps sign-ins without a sourceId in one series and bounds the number of series,
+// the sourceId is chosen by the client.
+func SourceLabel(sourceId string) string {
+	if sourceId == "" {
+		return SourceUnknown
+	}
+	sourceLabels.mu.Lock()
+	defer sourceLabels.mu.Unlock()
+	if sourceLabels.known[sourceId] {
+		return sourceId
+	}
+	if !sourceLabels.configured && len(sourceLabels.known) < maxSourceLabels {
+		sourceLabels.known[sourceId] = true
+		return sourceId
+	}
+	return SourceOther
+}
+
+// RegisterCacheCounters exposes the read cache counters, call it once per process.
+func RegisterCacheCounters(backend string, hits, misses, errs func() float64) {
+	labels := prometheus.Labels{"backend": backend}
+	prometheus.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{Namespace: namespace, Name: "read_cache_hits_total",
+			Help: "Read cache hits.", ConstLabels: labels}, hits),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{Namespace: namespace, Name: "read_cache_misses_total",
+			Help: "Read cache misses.", ConstLabels: labels}, misses),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{Namespace: namespace, Name: "read_cache_errors_total",
+			Help: "Read cache backend failures.", ConstLabels: labels}, errs),
+	)
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/resilience"
+)
+
+func TestOutcomeClassifiesDynamoErrors(t *testing.T) {
+	assert.Equal(t, OutcomeSuccess, Outcome(nil))
+	assert.Equal(t, OutcomeThrottled, Outcome(&types.ProvisionedThroughputExceededException{}))
+	assert.Equal(t, OutcomeThrottled, Outcome(&smithy.GenericAPIError{Code: "ThrottlingException"}))
+	assert.Equal(t, OutcomeValidation, Outcome(fmt.Errorf("put item: %w", &smithy.GenericAPIError{Code: "ValidationException"})))
+	assert.Equal(t, OutcomeConditional, Outcome(&types.ConditionalCheckFailedException{}))
+	assert.Equal(t, OutcomeCircuitOpen, Outcome(fmt.Errorf("PutItem: %w", resilience.ErrCircuitOpen)))
+	assert.Equal(t, OutcomeError, Outcome(errors.New("connection reset")))
+	assert.Equal(t, "unknown", SourceLabel(""))
+}
+
+func TestSourceLabelIsBounded(t *testing.T) {
+	defer LimitSources(nil)
+	LimitSources([]string{"web", "desktop"})
+	assert.Equal(t, "web", SourceLabel("web"))
+	assert.Equal(t, SourceOther, SourceLabel("made-up"))
+
+	LimitSources(nil)
+	for i := 0; i < maxSourceLabels; i++ {
+		assert.Equal(t, fmt.Sprintf("source-%d", i), SourceLabel(fmt.Sprintf("source-%d", i)))
+	}
+	assert.Equal(t, SourceOther, SourceLabel("one-too-many"))
+	assert.Equal(t, "source-0", SourceLabel("source-0"))
+}
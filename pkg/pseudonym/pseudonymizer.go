@@ -0,0 +1,262 @@
+package pseudonym
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/ippolicy"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+)
+
+const (
+	// pseudonyms are prefixed so that they are never mistaken for a real uniqueId
+	pseudonymPrefix = "p-"
+	pseudonymBytes  = 16
+)
+
+var (
+	ErrInvalidToken     = errors.New("invalid sealed nextToken")
+	ErrFilterNotAllowed = errors.New("filter is not allowed for pseudonymized callers")
+)
+
+var (
+	pseudonymizerOnce sync.Once
+	pseudonymizer     *Pseudonymizer
+)
+
+// Factory returns the process wide pseudonymizer, nil when no secret is configured.
+func Factory() *Pseudonymizer {
+	pseudonymizerOnce.Do(func() {
+		config := bootstrap.GetApplicationContext().AppConfigData.Pseudonym
+		if config.Secret == "" {
+			return
+		}
+		pseudonymizer = NewPseudonymizer([]byte(config.Secret), time.Duration(config.TimestampGranularitySeconds)*time.Second)
+	})
+	return pseudonymizer
+}
+
+// Pseudonymizer rewrites read responses for analytics callers. uniqueIds become a keyed HMAC,
+// the same secret always yields the same pseudonym so sign-ins can still be grouped by profile.
+// IPs are cut to their /24 or /48 network and timestamps rounded down to the granularity.
+type Pseudonymizer struct {
+	secret      []byte
+	granularity time.Duration
+	tokenKey    []byte
+}
+
+func NewPseudonymizer(secret []byte, granularity time.Duration) *Pseudonymizer {
+	p := &Pseudonymizer{secret: secret, granularity: granularity}
+	p.tokenKey = p.mac([]byte("nextToken"))
+	return p
+}
+
+func (p *Pseudonymizer) UniqueId(uniqueId string) string {
+	if uniqueId == "" {
+		return ""
+	}
+	return pseudonymPrefix + hex.EncodeToString(p.mac([]byte(uniqueId))[:pseudonymBytes])
+}
+
+// IP returns the network of ip in CIDR notation, values that are not an IP are dropped.
+func (p *Pseudonymizer) IP(ip string) string {
+	network := ippolicy.Network(ip)
+	if network == nil {
+		return ""
+	}
+	return network.String()
+}
+
+// Timestamp rounds epoch milliseconds down to the granularity and keeps the format, any other
+// time format is returned as RFC3339. Values that are not a time are dropped.
+func (p *Pseudonymizer) Timestamp(timestamp string) string {
+	if timestamp == "" || p.granularity <= 0 {
+		return timestamp
+	}
+	if millis, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		step := p.granularity.Milliseconds()
+		return strconv.FormatInt(millis-millis%step, 10)
+	}
+	parsed, err := utils.ParseTimeParam(timestamp, time.Time{})
+	if err != nil {
+		return ""
+	}
+	return parsed.UTC().Truncate(p.granularity).Format(time.RFC3339)
+}
+
+func (p *Pseudonymizer) SignIn(signIn domain.SignInInfo) domain.SignInInfo {
+	signIn.UniqueId = p.UniqueId(signIn.UniqueId)
+	signIn.IpAddress = p.IP(signIn.IpAddress)
+	signIn.TimeStamp = p.Timestamp(signIn.TimeStamp)
+	return signIn
+}
+
+// CheckFilter refuses search filters that would undo the pseudonymization: conditions on the
+// raw uniqueId or IP, and timestamp conditions other than ranges whose bounds fall on the
+// granularity. Those could map a pseudonym back to its profile, address or exact time.
+func (p *Pseudonymizer) CheckFilter(filter domain.SearchFilter) error {
+	for _, child := range append(append([]domain.SearchFilter{}, filter.And...), filter.Or...) {
+		if err := p.CheckFilter(child); err != nil {
+			return err
+		}
+	}
+	switch filter.Field {
+	case "uniqueId", "ipAddress":
+		return fmt.Errorf("%w: %s cannot be filtered on", ErrFilterNotAllowed, filter.Field)
+	case "timestamp", "timeStamp":
+		return p.checkTimestampFilter(filter)
+	}
+	return nil
+}
+
+func (p *Pseudonymizer) checkTimestampFilter(filter domain.SearchFilter) error {
+	var bounds []interface{}
+	switch filter.Op {
+	case "gte", "lte":
+		bounds = []interface{}{filter.Value}
+	case "between":
+		bounds = filter.Values
+	default:
+		return fmt.Errorf("%w: %s is only searchable as a range", ErrFilterNotAllowed, filter.Field)
+	}
+	if p.granularity <= 0 {
+		return nil
+	}
+	step := p.granularity.Milliseconds()
+	for _, bound := range bounds {
+		var text string
+		switch v := bound.(type) {
+		case string:
+			text = v
+		case float64:
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		parsed, err := utils.ParseTimeParam(text, time.Time{})
+		if err != nil || parsed.UnixMilli()%step != 0 {
+			return fmt.Errorf("%w: %s bounds have to fall on a %s boundary", ErrFilterNotAllowed, filter.Field, p.granularity)
+		}
+	}
+	return nil
+}
+
+func (p *Pseudonymizer) SignIns(signIns []domain.SignInInfo) []domain.SignInInfo {
+	pseudonymized := make([]domain.SignInInfo, len(signIns))
+	for i, signIn := range signIns {
+		pseudonymized[i] = p.SignIn(signIn)
+	}
+	return pseudonymized
+}
+
+// Apply pseudonymizes a read response. Types it does not know are refused rather than passed
+// through, a new response type has to be added here before analytics callers can read it.
+func (p *Pseudonymizer) Apply(response interface{}) (interface{}, error) {
+	switch r := response.(type) {
+	case domain.SignInInfo:
+		return p.SignIn(r), nil
+	case []domain.SignInInfo:
+		return p.SignIns(r), nil
+	case domain.LastSignInResponse:
+		r.LastSignIn = p.SignIn(r.LastSignIn)
+		if r.Summary != nil {
+			summary := p.summary(*r.Summary)
+			r.Summary = &summary
+		}
+		return r, nil
+	case domain.SignInStats:
+		r.UniqueId = p.UniqueId(r.UniqueId)
+		return r, nil
+	case domain.ActiveUsersStats:
+		// counts only
+		return r, nil
+	case domain.SearchResponse:
+		r.Items = p.SignIns(r.Items)
+		sealed, err := p.SealToken(r.NextToken)
+		if err != nil {
+			return nil, err
+		}
+		r.NextToken = sealed
+		return r, nil
+	}
+	return nil, fmt.Errorf("no pseudonymization for %T", response)
+}
+
+func (p *Pseudonymizer) summary(summary domain.SignInSummary) domain.SignInSummary {
+	summary.UniqueId = p.UniqueId(summary.UniqueId)
+	summary.FirstSeen = p.Timestamp(summary.FirstSeen)
+	summary.LastSeen = p.Timestamp(summary.LastSeen)
+	var networks []string
+	seen := map[string]bool{}
+	for _, ip := range summary.DistinctIps {
+		if network := p.IP(ip); network != "" && !seen[network] {
+			seen[network] = true
+			networks = append(networks, network)
+		}
+	}
+	summary.DistinctIps = networks
+	return summary
+}
+
+// SealToken encrypts a search nextToken, plain tokens contain the key of the last item and with
+// it a uniqueId.
+func (p *Pseudonymizer) SealToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	aead, err := p.tokenAEAD()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(token)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(token), nil)), nil
+}
+
+func (p *Pseudonymizer) OpenToken(sealed string) (string, error) {
+	if sealed == "" {
+		return "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	aead, err := p.tokenAEAD()
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < aead.NonceSize() {
+		return "", ErrInvalidToken
+	}
+	token, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	return string(token), nil
+}
+
+func (p *Pseudonymizer) tokenAEAD() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(p.tokenKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (p *Pseudonymizer) mac(value []byte) []byte {
+	h := hmac.New(sha256.New, p.secret)
+	h.Write(value)
+	return h.Sum(nil)
+}
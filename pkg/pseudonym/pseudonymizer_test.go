@@ -0,0 +1,67 @@
+package pseudonym
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+func TestUniqueIdPseudonymsAreStablePerSecret(t *testing.T) {
+	p := NewPseudonymizer([]byte("secret"), time.Hour)
+	pseudonym := p.UniqueId("MWA-1")
+	assert.True(t, strings.HasPrefix(pseudonym, pseudonymPrefix))
+	assert.NotContains(t, pseudonym, "MWA-1")
+	assert.Equal(t, pseudonym, NewPseudonymizer([]byte("secret"), time.Minute).UniqueId("MWA-1"))
+	assert.NotEqual(t, pseudonym, p.UniqueId("MWA-2"))
+	assert.NotEqual(t, pseudonym, NewPseudonymizer([]byte("rotated"), time.Hour).UniqueId("MWA-1"))
+	assert.Equal(t, "", p.UniqueId(""))
+}
+
+func TestIPIsTruncatedToItsNetwork(t *testing.T) {
+	p := NewPseudonymizer([]byte("secret"), time.Hour)
+	assert.Equal(t, "203.0.113.0/24", p.IP("203.0.113.77"))
+	assert.Equal(t, "2001:db8:85a3::/48", p.IP("2001:db8:85a3:8d3:1319:8a2e:370:7348"))
+	assert.Equal(t, "198.51.100.0/24", p.IP("::ffff:198.51.100.9"))
+	assert.Equal(t, "", p.IP("unknown"))
+}
+
+func TestTimestampIsRoundedDown(t *testing.T) {
+	p := NewPseudonymizer([]byte("secret"), time.Hour)
+	// 2026-10-19T12:34:56.789Z
+	assert.Equal(t, "1792411200000", p.Timestamp("1792413296789"))
+	assert.Equal(t, "2026-10-19T12:00:00Z", p.Timestamp("2026-10-19T12:34:56Z"))
+	assert.Equal(t, "", p.Timestamp("yesterday"))
+}
+
+func TestApplyRewritesKnownResponses(t *testing.T) {
+	p := NewPseudonymizer([]byte("secret"), time.Hour)
+	signIn := domain.SignInInfo{UniqueId: "MWA-1", TimeStamp: "1792413296789", IpAddress: "203.0.113.77", SourceId: "web"}
+	last, err := p.Apply(domain.LastSignInResponse{
+		LastSignIn: signIn,
+		Summary:    &domain.SignInSummary{UniqueId: "MWA-1", DistinctIps: []string{"203.0.113.77", "203.0.113.8"}, DistinctIpCount: 2},
+	})
+	assert.Nil(t, err)
+	response := last.(domain.LastSignInResponse)
+	assert.Equal(t, domain.SignInInfo{UniqueId: p.UniqueId("MWA-1"), TimeStamp: "1792411200000", IpAddress: "203.0.113.0/24", SourceId: "web"}, response.LastSignIn)
+	assert.Equal(t, []string{"203.0.113.0/24"}, response.Summary.DistinctIps)
+	assert.Equal(t, 2, response.Summary.DistinctIpCount)
+
+	_, err = p.Apply(domain.WebhookSubscription{})
+	assert.NotNil(t, err)
+}
+
+func TestSealedTokensOpenOnlyWithTheSameSecret(t *testing.T) {
+	p := NewPseudonymizer([]byte("secret"), time.Hour)
+	sealed, err := p.SealToken("eyJwIjoicXVlcnkifQ")
+	assert.Nil(t, err)
+	assert.NotContains(t, sealed, "eyJwIjoicXVlcnkifQ")
+	token, err := p.OpenToken(sealed)
+	assert.Nil(t, err)
+	assert.Equal(t, "eyJwIjoicXVlcnkifQ", token)
+
+	_, err = NewPseudonymizer([]byte("other"), time.Hour).OpenToken(sealed)
+	assert.Equal(t, ErrInvalidToken, err)
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// Limit is a token bucket refilled with Requests tokens every Period and holding at most Burst.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return float64(l.Requests)
+}
+
+// perSecond is the refill rate of the bucket.
+func (l Limit) perSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long a refused caller waits for the next token
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps the buckets. The memory store limits each instance on its own, the Redis store
+// shares the buckets of every instance.
+type Store interface {
+	Take(key string, limit Limit, now time.Time) (Decision, error)
+}
+
+// decide builds the decision from the tokens left in the bucket after the request.
+func decide(allowed bool, tokens float64, limit Limit) Decision {
+	rate := limit.perSecond()
+	decision := Decision{
+		Allowed:   allowed,
+		Limit:     int(limit.capacity()),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsToDuration((limit.capacity() - tokens) / rate),
+	}
+	if !allowed {
+		decision.RetryAfter = secondsToDuration((1 - tokens) / rate)
+	}
+	return decision
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Policies picks the limit of a request, the most specific entry wins: caller on a route,
+// then caller, then route, then the default.
+type Policies struct {
+	Default      Limit
+	Routes       map[string]Limit
+	Callers      map[string]Limit
+	CallerRoutes map[string]Limit
+}
+
+func (p Policies) For(route, callerId string) Limit {
+	if limit, ok := p.CallerRoutes[callerId+"@"+route]; ok {
+		return limit
+	}
+	if limit, ok := p.Callers[callerId]; ok {
+		return limit
+	}
+	if limit, ok := p.Routes[route]; ok {
+		return limit
+	}
+	return p.Default
+}
+
+// ParseOverrides reads per route and per caller limits in requests per minute, written as
+// "/v1/signIns/search=30;MWA=1200;MWA@/v1/saveSignInData=6000". A value of 0 disables the limit.
+func ParseOverrides(defaultLimit Limit, value string) (Policies, error) {
+	policies := Policies{
+		Default:      defaultLimit,
+		Routes:       make(map[string]Limit),
+		Callers:      make(map[string]Limit),
+		CallerRoutes: make(map[string]Limit),
+	}
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		target, perMinute, found := strings.Cut(entry, "=")
+		requests, err := strconv.Atoi(strings.TrimSpace(perMinute))
+		if !found || err != nil || requests < 0 {
+			return policies, fmt.Errorf("invalid rate limit override %q", entry)
+		}
+		limit := Limit{Requests: requests, Period: time.Minute}
+		target = strings.TrimSpace(target)
+		switch {
+		case strings.Contains(target, "@"):
+			policies.CallerRoutes[target] = limit
+		case strings.HasPrefix(target, "/"):
+			policies.Routes[target] = limit
+		default:
+			policies.Callers[target] = limit
+		}
+	}
+	return policies, nil
+}
+
+type Limiter struct {
+	store    Store
+	mu       sync.RWMutex
+	policies Policies
+	now      func() time.Time
+}
+
+func NewLimiter(store Store, policies Policies) *Limiter {
+	return &Limiter{store: store, policies: policies, now: time.Now}
+}
+
+// Allow takes a token from the bucket of callerId on route. Unlimited routes are always allowed
+// and report a zero Limit.
+func (l *Limiter) Allow(route, callerId string) (Decision, error) {
+	l.mu.RLock()
+	limit := l.policies.For(route, callerId)
+	l.mu.RUnlock()
+	if limit.Unlimited() {
+		return Decision{Allowed: true}, nil
+	}
+	return l.store.Take(callerId+"|"+route, limit, l.now())
+}
+
+// SetPolicies replaces the limits, buckets keep their tokens and refill at the new rate.
+func (l *Limiter) SetPolicies(policies Policies) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.policies = policies
+}
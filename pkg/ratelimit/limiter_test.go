@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreRefusesOnceTheBucketIsEmpty(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 60, Period: time.Minute, Burst: 2}
+	now := time.Unix(1700000000, 0)
+
+	first, _ := store.Take("MWA|/v1/saveSignInData", limit, now)
+	assert.Equal(t, Decision{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second}, first)
+	second, _ := store.Take("MWA|/v1/saveSignInData", limit, now)
+	assert.True(t, second.Allowed)
+	assert.Equal(t, 0, second.Remaining)
+
+	refused, _ := store.Take("MWA|/v1/saveSignInData", limit, now.Add(500*time.Millisecond))
+	assert.False(t, refused.Allowed)
+	assert.Equal(t, 500*time.Millisecond, refused.RetryAfter)
+
+	other, _ := store.Take("PAT|/v1/saveSignInData", limit, now)
+	assert.True(t, other.Allowed)
+
+	refilled, _ := store.Take("MWA|/v1/saveSignInData", limit, now.Add(time.Second))
+	assert.True(t, refilled.Allowed)
+}
+
+func TestMemoryStoreForgetsFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 1, Period: time.Hour}
+	now := time.Unix(1700000000, 0)
+	store.Take("fast", Limit{Requests: 600, Period: time.Minute}, now)
+	store.Take("slow", limit, now)
+
+	store.Take("slow", limit, now.Add(2*time.Minute))
+	assert.Equal(t, 1, store.Len())
+	refused, _ := store.Take("slow", limit, now.Add(3*time.Minute))
+	assert.False(t, refused.Allowed)
+}
+
+func TestPoliciesPreferTheMostSpecificOverride(t *testing.T) {
+	policies, err := ParseOverrides(Limit{Requests: 600, Period: time.Minute},
+		" /v1/signIns/search=30; MWA=1200;MWA@/v1/saveSignInData=6000;/admin/metrics=0")
+	assert.Nil(t, err)
+
+	assert.Equal(t, 6000, policies.For("/v1/saveSignInData", "MWA").Requests)
+	assert.Equal(t, 1200, policies.For("/v1/signIns/search", "MWA").Requests)
+	assert.Equal(t, 30, policies.For("/v1/signIns/search", "PAT").Requests)
+	assert.Equal(t, 600, policies.For("/v1/lastSignIn", "PAT").Requests)
+	assert.True(t, policies.For("/admin/metrics", "PAT").Unlimited())
+
+	_, err = ParseOverrides(Limit{}, "MWA=lots")
+	assert.NotNil(t, err)
+}
+
+func TestLimiterAllowsUnlimitedRoutesWithoutTheStore(t *testing.T) {
+	store := &fakeCommander{err: errors.New("connection refused")}
+	policies, _ := ParseOverrides(Limit{Requests: 10, Period: time.Minute}, "/admin/metrics=0")
+	limiter := NewLimiter(NewRedisStore(store), policies)
+
+	decision, err := limiter.Allow("/admin/metrics", "anonymous")
+	assert.Nil(t, err)
+	assert.Equal(t, Decision{Allowed: true}, decision)
+	_, err = limiter.Allow("/v1/lastSignIn", "anonymous")
+	assert.NotNil(t, err)
+}
+
+type fakeCommander struct {
+	args  []string
+	reply interface{}
+	err   error
+}
+
+func (f *fakeCommander) Do(args ...string) (interface{}, error) {
+	f.args = args
+	return f.reply, f.err
+}
+
+func TestRedisStoreRunsTheBucketScript(t *testing.T) {
+	redis := &fakeCommander{reply: []interface{}{int64(0), []byte("0.25")}}
+	store := NewRedisStore(redis)
+	now := time.UnixMilli(1700000000123)
+
+	decision, err := store.Take("MWA|/v1/saveSignInData", Limit{Requests: 60, Period: time.Minute, Burst: 5}, now)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"EVAL", tokenBucketScript, "1", "signindatatracker:ratelimit:MWA|/v1/saveSignInData", "5", "0.001", "1700000000123"}, redis.args)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 5, decision.Limit)
+	assert.Equal(t, 0, decision.Remaining)
+	assert.Equal(t, 750*time.Millisecond, decision.RetryAfter)
+
+	redis.reply = "OK"
+	_, err = store.Take("MWA|/v1/saveSignInData", Limit{Requests: 60, Period: time.Minute}, now)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// tokenBucketScript refills and takes from the bucket in one step so that instances racing on
+// the same caller cannot both spend the last token. The tokens are returned as a string because
+// Redis truncates Lua numbers to integers.
+const tokenBucketScript = `
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+	ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil((capacity - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`
+
+// Commander sends a raw Redis command, cache.RedisCache implements it.
+type Commander interface {
+	Do(args ...string) (interface{}, error)
+}
+
+type RedisStore struct {
+	redis  Commander
+	prefix string
+}
+
+func NewRedisStore(redis Commander) *RedisStore {
+	return &RedisStore{redis: redis, prefix: "signindatatracker:ratelimit:"}
+}
+
+func (s *RedisStore) Take(key string, limit Limit, now time.Time) (Decision, error) {
+	reply, err := s.redis.Do("EVAL", tokenBucketScript, "1", s.prefix+key,
+		strconv.FormatFloat(limit.capacity(), 'f', -1, 64),
+		strconv.FormatFloat(limit.perSecond()/1000, 'f', -1, 64),
+		strconv.FormatInt(now.UnixMilli(), 10))
+	if err != nil {
+		return Decision{}, err
+	}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return Decision{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	allowed, ok := values[0].(int64)
+	raw, isBytes := values[1].([]byte)
+	if !ok || !isBytes {
+		return Decision{}, fmt.Errorf("unexpected rate limit reply %v", reply)
+	}
+	tokens, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil {
+		return Decision{}, err
+	}
+	return decide(allowed == 1, tokens, limit), nil
+}
@@ -282,6 +282,11 @@ func main() {
 			},
 		},
 
+		StreamSpecification: &types.StreamSpecification{ // consumed by cmd/streamconsumer
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: types.StreamViewTypeNewImage,
+		},
+
 		TableName: aws.String(exampleTableName),
 		ProvisionedThroughput: &types.ProvisionedThroughput{
 			ReadCapacityUnits:  aws.Int64(10),
@@ -293,6 +298,97 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// per-profile summary maintained on every save (app.signindatatracker.dynamo.summarytablename)
+	summaryTableName := "signindatatrackersummary"
+	err = createTable(c, summaryTableName, &dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("uniqueId"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("uniqueId"),
+				KeyType:       types.KeyTypeHash,
+			},
+		},
+		TableName: aws.String(summaryTableName),
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(10),
+			WriteCapacityUnits: aws.Int64(10),
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// daily pre-aggregates for long statistics ranges (app.signindatatracker.dynamo.rolluptablename)
+	rollupTableName := "signindatatrackerrollup"
+	err = createTable(c, rollupTableName, &dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("uniqueId"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("bucket"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("uniqueId"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("bucket"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		TableName: aws.String(rollupTableName),
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(10),
+			WriteCapacityUnits: aws.Int64(10),
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// HyperLogLog sketches per day and source (app.signindatatracker.dynamo.activeuserstablename)
+	activeUsersTableName := "signindatatrackeractiveusers"
+	err = createTable(c, activeUsersTableName, &dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{
+			{
+				AttributeName: aws.String("sketchId"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+			{
+				AttributeName: aws.String("day"),
+				AttributeType: types.ScalarAttributeTypeS,
+			},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{
+				AttributeName: aws.String("sketchId"),
+				KeyType:       types.KeyTypeHash,
+			},
+			{
+				AttributeName: aws.String("day"),
+				KeyType:       types.KeyTypeRange,
+			},
+		},
+		TableName: aws.String(activeUsersTableName),
+		ProvisionedThroughput: &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(10),
+			WriteCapacityUnits: aws.Int64(10),
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// -----------------------------
 	// list tables (should return single table, since we only created one here!)
 	tables, err := listTables(c)
@@ -0,0 +1,181 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/hyperloglog"
+	"go.uber.org/zap"
+)
+
+const (
+	AllSourcesSketchId   = "all"
+	SourceSketchIdPrefix = "source#"
+	maxSketchRetries     = 5
+)
+
+var ErrSketchContention = errors.New("could not update active user sketch, too many concurrent writers")
+
+type DailySketch struct {
+	Day    string
+	Sketch *hyperloglog.Sketch
+}
+
+type ActiveUsersRepoInterface interface {
+	MergeSketch(sketchId string, day string, sketch *hyperloglog.Sketch) error
+	FindSketches(sketchId string, fromDay string, toDay string) ([]DailySketch, error)
+	Enabled() bool
+}
+
+// ActiveUsersRepo stores one HyperLogLog sketch per sketch id and UTC day. Sketches are
+// max-merged with optimistic concurrency on a version attribute.
+type ActiveUsersRepo struct {
+	logger    *zap.Logger
+	dbClient  bootstrap.DynamoDBClientInterface
+	tableName string
+}
+
+func ActiveUsersRepoFactory() *ActiveUsersRepo {
+	dbClient, err := bootstrap.GetApplicationContext().GetDB()
+	if err != nil {
+		log.Fatalf("Failed to initialize DynamoDB client: %v", err)
+	}
+	return &ActiveUsersRepo{
+		logger:    zap.L().Named("signindatatrackerws.activeUsersRepo"),
+		dbClient:  dbClient,
+		tableName: bootstrap.GetApplicationContext().AppConfigData.Dynamo.ActiveUsersTableName,
+	}
+}
+
+func SourceSketchId(sourceId string) string {
+	return SourceSketchIdPrefix + sourceId
+}
+
+func (repo *ActiveUsersRepo) Enabled() bool {
+	return repo.tableName != ""
+}
+
+// MergeSketch merges sketch into the stored one. A merge that loses the race against another
+// writer is read and merged again, merging twice does not count anyone twice.
+func (repo *ActiveUsersRepo) MergeSketch(sketchId string, day string, sketch *hyperloglog.Sketch) error {
+	for attempt := 0; attempt < maxSketchRetries; attempt++ {
+		stored, version, err := repo.getSketch(sketchId, day)
+		if err != nil {
+			return err
+		}
+		if !stored.Merge(sketch) {
+			// the stored sketch already holds every user, nothing to write
+			return nil
+		}
+		err = repo.putSketch(sketchId, day, stored, version)
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			continue
+		}
+		return err
+	}
+	return ErrSketchContention
+}
+
+func (repo *ActiveUsersRepo) getSketch(sketchId string, day string) (*hyperloglog.Sketch, int64, error) {
+	resp, err := repo.dbClient.GetItem(context.TODO(), &dynamodb.GetItemInput{
+		TableName: aws.String(repo.tableName),
+		Key: map[string]types.AttributeValue{
+			"sketchId": &types.AttributeValueMemberS{Value: sketchId},
+			"day":      &types.AttributeValueMemberS{Value: day},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.Item == nil {
+		return hyperloglog.New(), 0, nil
+	}
+	return decodeSketchItem(resp.Item)
+}
+
+func (repo *ActiveUsersRepo) putSketch(sketchId string, day string, sketch *hyperloglog.Sketch, version int64) error {
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(repo.tableName),
+		Item: map[string]types.AttributeValue{
+			"sketchId":  &types.AttributeValueMemberS{Value: sketchId},
+			"day":       &types.AttributeValueMemberS{Value: day},
+			"registers": &types.AttributeValueMemberB{Value: sketch.Bytes()},
+			"version":   &types.AttributeValueMemberN{Value: strconv.FormatInt(version+1, 10)},
+		},
+	}
+	if version == 0 {
+		input.ConditionExpression = aws.String("attribute_not_exists(#version)")
+		input.ExpressionAttributeNames = map[string]string{"#version": "version"}
+	} else {
+		input.ConditionExpression = aws.String("#version = :version")
+		input.ExpressionAttributeNames = map[string]string{"#version": "version"}
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+		}
+	}
+	_, err := repo.dbClient.PutItem(context.TODO(), input)
+	return err
+}
+
+func (repo *ActiveUsersRepo) FindSketches(sketchId string, fromDay string, toDay string) ([]DailySketch, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(repo.tableName),
+		KeyConditionExpression: aws.String("#id = :id AND #day BETWEEN :from_day AND :to_day"),
+		ExpressionAttributeNames: map[string]string{
+			"#id":  "sketchId",
+			"#day": "day",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":id":       &types.AttributeValueMemberS{Value: sketchId},
+			":from_day": &types.AttributeValueMemberS{Value: fromDay},
+			":to_day":   &types.AttributeValueMemberS{Value: toDay},
+		},
+	}
+
+	var sketches []DailySketch
+	for {
+		resp, err := repo.dbClient.Query(context.TODO(), input)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Items {
+			sketch, _, err := decodeSketchItem(item)
+			if err != nil {
+				return nil, err
+			}
+			day, _ := item["day"].(*types.AttributeValueMemberS)
+			if day == nil {
+				continue
+			}
+			sketches = append(sketches, DailySketch{Day: day.Value, Sketch: sketch})
+		}
+		if len(resp.LastEvaluatedKey) == 0 {
+			return sketches, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+func decodeSketchItem(item map[string]types.AttributeValue) (*hyperloglog.Sketch, int64, error) {
+	registers, ok := item["registers"].(*types.AttributeValueMemberB)
+	if !ok {
+		return nil, 0, hyperloglog.ErrInvalidSketch
+	}
+	sketch, err := hyperloglog.FromBytes(registers.Value)
+	if err != nil {
+		return nil, 0, err
+	}
+	var version int64
+	if v, ok := item["version"].(*types.AttributeValueMemberN); ok {
+		version, _ = strconv.ParseInt(v.Value, 10, 64)
+	}
+	return sketch, version, nil
+}
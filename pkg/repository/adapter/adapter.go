@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -14,6 +15,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
 	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/envelope"
+	"github.mathworks.com/development/signindatatrackerws/pkg/search"
 	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -21,18 +24,34 @@ import (
 type DynamoDBData map[string]types.AttributeValue
 
 type SignInRepoInterface interface {
-	SaveSignInTrackingInfo(request domain.SaveSignInInfo) (response domain.SaveSignInInfo, err error)
-	FindUniqueSignInInfo(condition map[string]interface{}) (response *dynamodb.GetItemOutput, err error)
-	FindSignInTrackingDetails(partitionKey string) (response []domain.SignInInfo, err error)
-	GetSignInBetweenTimeStamps(request domain.RequestTimestampInput) ([]domain.SignInInfo, error)
-	GetSignInForReferenceId(request domain.RequestReferenceIdInput) ([]domain.SignInInfo, error)
-	PingDB() (*dynamodb.ListTablesOutput, error)
+	SaveSignInTrackingInfo(ctx context.Context, request domain.SaveSignInInfo) (response domain.SaveSignInInfo, err error)
+	BatchSaveSignInTrackingInfo(requests []domain.SaveSignInInfo) (unprocessed []domain.SaveSignInInfo, consumedCapacity float64, err error)
+	FindUniqueSignInInfo(ctx context.Context, condition map[string]interface{}) (response *dynamodb.GetItemOutput, err error)
+	FindSignInTrackingDetails(ctx context.Context, partitionKey string) (response []domain.SignInInfo, err error)
+	GetSignInBetweenTimeStamps(ctx context.Context, request domain.RequestTimestampInput) ([]domain.SignInInfo, error)
+	GetSignInForReferenceId(ctx context.Context, request domain.RequestReferenceIdInput) ([]domain.SignInInfo, error)
+	FindLastSignIn(ctx context.Context, partitionKey string) (response domain.SignInInfo, found bool, err error)
+	UpdateSignInSummary(ctx context.Context, request domain.SaveSignInInfo) error
+	FindSignInSummary(ctx context.Context, partitionKey string) (response domain.SignInSummary, found bool, err error)
+	SummaryEnabled() bool
+	QuerySignInsBetween(ctx context.Context, partitionKey string, startMillis string, endMillis string) ([]domain.SignInInfo, error)
+	UpdateSignInRollup(ctx context.Context, request domain.SaveSignInInfo) error
+	QueryDailyRollups(ctx context.Context, partitionKey string, startDay time.Time, endDay time.Time) ([]domain.SignInRollup, error)
+	RollupEnabled() bool
+	SearchSignIns(ctx context.Context, plan search.Plan, limit int, nextToken string) (search.Page, error)
+	ScanSegmentPage(segment int, totalSegments int, limit int, startKey map[string]types.AttributeValue) (ScanPage, error)
+	PingDB(ctx context.Context) (*dynamodb.ListTablesOutput, error)
 }
 
 type SignInRepo struct {
-	logger    *zap.Logger
-	dbClient  bootstrap.DynamoDBClientInterface
-	tableName string
+	logger           *zap.Logger
+	dbClient         bootstrap.DynamoDBClientInterface
+	tableName        string
+	summaryTableName string
+	rollupTableName  string
+	// ipDigest replaces the IPs of the summary and rollup sets when ipAddress is encrypted, nil
+	// keeps them as they are
+	ipDigest *envelope.Digester
 }
 
 func SignInRepoFactory(tableName string) *SignInRepo {
@@ -41,28 +60,70 @@ func SignInRepoFactory(tableName string) *SignInRepo {
 		// Handle the error, maybe log it and exit
 		log.Fatalf("Failed to initialize DynamoDB client: %v", err)
 	}
-	return &SignInRepo{logger: zap.L().Named("signindatatrackerws.signinRepo"), dbClient: dbClient, tableName: tableName}
+	appConfig := bootstrap.GetApplicationContext().AppConfigData
+	repo := &SignInRepo{logger: zap.L().Named("signindatatrackerws.signinRepo"), dbClient: dbClient, tableName: tableName,
+		summaryTableName: appConfig.Dynamo.SummaryTableName, rollupTableName: appConfig.Dynamo.RollupTableName}
+	if appConfig.Encryption.IpDigested() {
+		repo.ipDigest = envelope.NewDigester([]byte(appConfig.Encryption.IpDigestSecret))
+	}
+	return repo
 }
 
-func (repo *SignInRepo) SaveSignInTrackingInfo(request domain.SaveSignInInfo) (response domain.SaveSignInInfo, err error) {
+func (repo *SignInRepo) SaveSignInTrackingInfo(ctx context.Context, request domain.SaveSignInInfo) (response domain.SaveSignInInfo, err error) {
 	entityParsed, err := attributevalue.MarshalMap(request)
 	if err != nil {
 		return domain.SaveSignInInfo{}, err
 	}
 
-	err = putItem(repo.dbClient, repo.tableName, entityParsed)
+	err = putItem(ctx, repo.dbClient, repo.tableName, entityParsed)
 	return request, err
 }
 
+// BatchSaveSignInTrackingInfo writes up to 25 items with one BatchWriteItem call. Items DynamoDB
+// did not process, typically because of throttling, are returned for the caller to retry.
+func (repo *SignInRepo) BatchSaveSignInTrackingInfo(requests []domain.SaveSignInInfo) (unprocessed []domain.SaveSignInInfo, consumedCapacity float64, err error) {
+	writes := make([]types.WriteRequest, 0, len(requests))
+	for _, request := range requests {
+		item, err := attributevalue.MarshalMap(request)
+		if err != nil {
+			return nil, 0, err
+		}
+		writes = append(writes, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+	resp, err := repo.dbClient.BatchWriteItem(context.TODO(), &dynamodb.BatchWriteItemInput{
+		RequestItems:           map[string][]types.WriteRequest{repo.tableName: writes},
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, consumed := range resp.ConsumedCapacity {
+		if consumed.CapacityUnits != nil {
+			consumedCapacity += *consumed.CapacityUnits
+		}
+	}
+	for _, write := range resp.UnprocessedItems[repo.tableName] {
+		if write.PutRequest == nil {
+			continue
+		}
+		var request domain.SaveSignInInfo
+		if err := attributevalue.UnmarshalMap(write.PutRequest.Item, &request); err != nil {
+			return nil, consumedCapacity, err
+		}
+		unprocessed = append(unprocessed, request)
+	}
+	return unprocessed, consumedCapacity, nil
+}
+
 // putItem inserts an item (key + attributes) in to a dynamodb table.
-func putItem(c bootstrap.DynamoDBClientInterface, tableName string, item DynamoDBData) (err error) {
-	_, err = c.PutItem(context.TODO(), &dynamodb.PutItemInput{
+func putItem(ctx context.Context, c bootstrap.DynamoDBClientInterface, tableName string, item DynamoDBData) (err error) {
+	_, err = c.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName: aws.String(tableName), Item: item,
 	})
 	return err
 }
 
-func (repo *SignInRepo) FindUniqueSignInInfo(condition map[string]interface{}) (response *dynamodb.GetItemOutput, err error) {
+func (repo *SignInRepo) FindUniqueSignInInfo(ctx context.Context, condition map[string]interface{}) (response *dynamodb.GetItemOutput, err error) {
 	conditionParsed, err := attributevalue.MarshalMap(condition) //condition - what we want to match
 	if err != nil {
 		return nil, err
@@ -71,14 +132,14 @@ func (repo *SignInRepo) FindUniqueSignInInfo(condition map[string]interface{}) (
 		TableName: aws.String(repo.tableName),
 		Key:       conditionParsed,
 	}
-	response, err = repo.dbClient.GetItem(context.TODO(), input)
+	response, err = repo.dbClient.GetItem(ctx, input)
 	if err != nil {
 		return nil, err
 	}
 	return response, nil
 }
 
-func (repo *SignInRepo) FindSignInTrackingDetails(partitionKeyValue string) (response []domain.SignInInfo, err error) {
+func (repo *SignInRepo) FindSignInTrackingDetails(ctx context.Context, partitionKeyValue string) (response []domain.SignInInfo, err error) {
 
 	input := &dynamodb.QueryInput{
 		TableName: aws.String(repo.tableName),
@@ -93,7 +154,7 @@ func (repo *SignInRepo) FindSignInTrackingDetails(partitionKeyValue string) (res
 	}
 
 	// Make the DynamoDB Query API call
-	resp, err := repo.dbClient.Query(context.TODO(), input)
+	resp, err := repo.dbClient.Query(ctx, input)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +168,7 @@ func (repo *SignInRepo) FindSignInTrackingDetails(partitionKeyValue string) (res
 	return items, nil
 }
 
-func (repo *SignInRepo) GetSignInBetweenTimeStamps(request domain.RequestTimestampInput) ([]domain.SignInInfo, error) {
+func (repo *SignInRepo) GetSignInBetweenTimeStamps(ctx context.Context, request domain.RequestTimestampInput) ([]domain.SignInInfo, error) {
 	// Define the filter expression and attribute values for the scan operation
 	expr := "#uid = :uid_value AND #ts BETWEEN :start_time AND :end_time"
 	// Set up the scan input
@@ -126,7 +187,7 @@ func (repo *SignInRepo) GetSignInBetweenTimeStamps(request domain.RequestTimesta
 	}
 
 	// Execute the scan operation
-	resp, err := repo.dbClient.Scan(context.TODO(), input)
+	resp, err := repo.dbClient.Scan(ctx, input)
 	if err != nil {
 		return nil, err
 	}
@@ -140,7 +201,7 @@ func (repo *SignInRepo) GetSignInBetweenTimeStamps(request domain.RequestTimesta
 	return items, nil
 }
 
-func (repo *SignInRepo) GetSignInForReferenceId(request domain.RequestReferenceIdInput) ([]domain.SignInInfo, error) {
+func (repo *SignInRepo) GetSignInForReferenceId(ctx context.Context, request domain.RequestReferenceIdInput) ([]domain.SignInInfo, error) {
 	// Define the filter expression and attribute values for the scan operation
 	expr := "#uid = :uid_value AND #refId = :refId_value"
 
@@ -159,7 +220,7 @@ func (repo *SignInRepo) GetSignInForReferenceId(request domain.RequestReferenceI
 	}
 
 	// Execute the scan operation
-	resp, err := repo.dbClient.Scan(context.TODO(), input)
+	resp, err := repo.dbClient.Scan(ctx, input)
 	if err != nil {
 		return nil, err
 	}
@@ -172,14 +233,195 @@ func (repo *SignInRepo) GetSignInForReferenceId(request domain.RequestReferenceI
 
 	return items, nil
 }
-func (repo *SignInRepo) PingDB() (*dynamodb.ListTablesOutput, error) {
+
+// FindLastSignIn reads only the newest item of the partition instead of the whole history.
+func (repo *SignInRepo) FindLastSignIn(ctx context.Context, partitionKeyValue string) (response domain.SignInInfo, found bool, err error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(repo.tableName),
+		KeyConditionExpression: aws.String("#uid = :uid_value"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": "uniqueId",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid_value": &types.AttributeValueMemberS{Value: partitionKeyValue},
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int32(1),
+	}
+
+	resp, err := repo.dbClient.Query(ctx, input)
+	if err != nil {
+		return domain.SignInInfo{}, false, err
+	}
+	if len(resp.Items) == 0 {
+		return domain.SignInInfo{}, false, nil
+	}
+
+	err = attributevalue.UnmarshalMap(resp.Items[0], &response)
+	if err != nil {
+		return domain.SignInInfo{}, false, err
+	}
+	return response, true, nil
+}
+
+func (repo *SignInRepo) SummaryEnabled() bool {
+	return repo.summaryTableName != ""
+}
+
+// MaxSummaryIps caps the distinct IPs kept on a summary item, the item would otherwise grow
+// without bound for profiles seen from many networks. Counts stop at the cap.
+const MaxSummaryIps = 500
+
+// UpdateSignInSummary maintains the per-profile summary item with a single atomic UpdateItem,
+// so concurrent saves never lose a count. The write is conditional on the sign-in being the
+// newest one and on the IP set having room; when either fails, which is rare, the counters are
+// updated on their own and lastSeen and the IP set only where their condition holds.
+func (repo *SignInRepo) UpdateSignInSummary(ctx context.Context, request domain.SaveSignInInfo) error {
+	if !repo.SummaryEnabled() {
+		return nil
+	}
+	ip := repo.ipDigest.Digest(request.IpAddress)
+	counters := summaryUpdate{
+		set:    []string{"#first = if_not_exists(#first, :ts)"},
+		add:    []string{"#total :one"},
+		names:  map[string]string{"#first": "firstSeen", "#total": "totalCount"},
+		values: map[string]types.AttributeValue{":ts": &types.AttributeValueMemberS{Value: request.TimeStamp}, ":one": &types.AttributeValueMemberN{Value: "1"}},
+	}
+	// string sets can't be empty, only add the values we actually have
+	if request.SourceId != "" {
+		counters.add = append(counters.add, "#sources :source")
+		counters.names["#sources"] = "distinctSources"
+		counters.values[":source"] = &types.AttributeValueMemberSS{Value: []string{request.SourceId}}
+	}
+	lastSeen := summaryUpdate{
+		set:       []string{"#last = :ts"},
+		names:     map[string]string{"#last": "lastSeen"},
+		values:    map[string]types.AttributeValue{":ts": &types.AttributeValueMemberS{Value: request.TimeStamp}},
+		condition: []string{"(attribute_not_exists(#last) OR #last < :ts)"},
+	}
+	ips := summaryUpdate{names: map[string]string{}, values: map[string]types.AttributeValue{}}
+	if ip != "" {
+		ips = summaryUpdate{
+			add:   []string{"#ips :ip"},
+			names: map[string]string{"#ips": "distinctIps"},
+			values: map[string]types.AttributeValue{
+				":ip":     &types.AttributeValueMemberSS{Value: []string{ip}},
+				":ipName": &types.AttributeValueMemberS{Value: ip},
+				":maxIps": &types.AttributeValueMemberN{Value: strconv.Itoa(MaxSummaryIps)},
+			},
+			condition: []string{"(attribute_not_exists(#ips) OR size(#ips) < :maxIps OR contains(#ips, :ipName))"},
+		}
+	}
+
+	err := repo.updateSummary(ctx, request.UniqueId, counters.with(lastSeen).with(ips))
+	var conditionFailed *types.ConditionalCheckFailedException
+	if !errors.As(err, &conditionFailed) {
+		return err
+	}
+	if err := repo.updateSummary(ctx, request.UniqueId, counters); err != nil {
+		return err
+	}
+	for _, update := range []summaryUpdate{lastSeen, ips} {
+		if len(update.condition) == 0 {
+			continue
+		}
+		if err := repo.updateSummary(ctx, request.UniqueId, update); err != nil && !errors.As(err, &conditionFailed) {
+			return err
+		}
+	}
+	return nil
+}
+
+// summaryUpdate is one part of a summary UpdateItem, parts are combined with with.
+type summaryUpdate struct {
+	set       []string
+	add       []string
+	names     map[string]string
+	values    map[string]types.AttributeValue
+	condition []string
+}
+
+func (u summaryUpdate) with(other summaryUpdate) summaryUpdate {
+	combined := summaryUpdate{
+		set:       append(append([]string{}, u.set...), other.set...),
+		add:       append(append([]string{}, u.add...), other.add...),
+		names:     make(map[string]string, len(u.names)+len(other.names)),
+		values:    make(map[string]types.AttributeValue, len(u.values)+len(other.values)),
+		condition: append(append([]string{}, u.condition...), other.condition...),
+	}
+	for _, part := range []summaryUpdate{u, other} {
+		for k, v := range part.names {
+			combined.names[k] = v
+		}
+		for k, v := range part.values {
+			combined.values[k] = v
+		}
+	}
+	return combined
+}
+
+func (repo *SignInRepo) updateSummary(ctx context.Context, uniqueId string, update summaryUpdate) error {
+	var expression []string
+	if len(update.set) > 0 {
+		expression = append(expression, "SET "+strings.Join(update.set, ", "))
+	}
+	if len(update.add) > 0 {
+		expression = append(expression, "ADD "+strings.Join(update.add, ", "))
+	}
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(repo.summaryTableName),
+		Key: map[string]types.AttributeValue{
+			"uniqueId": &types.AttributeValueMemberS{Value: uniqueId},
+		},
+		UpdateExpression:          aws.String(strings.Join(expression, " ")),
+		ExpressionAttributeNames:  update.names,
+		ExpressionAttributeValues: update.values,
+	}
+	if len(update.condition) > 0 {
+		input.ConditionExpression = aws.String(strings.Join(update.condition, " AND "))
+	}
+	_, err := repo.dbClient.UpdateItem(ctx, input)
+	return err
+}
+
+func (repo *SignInRepo) FindSignInSummary(ctx context.Context, partitionKeyValue string) (response domain.SignInSummary, found bool, err error) {
+	if !repo.SummaryEnabled() {
+		return domain.SignInSummary{}, false, nil
+	}
+	resp, err := repo.dbClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(repo.summaryTableName),
+		Key: map[string]types.AttributeValue{
+			"uniqueId": &types.AttributeValueMemberS{Value: partitionKeyValue},
+		},
+	})
+	if err != nil {
+		return domain.SignInSummary{}, false, err
+	}
+	if resp.Item == nil {
+		return domain.SignInSummary{}, false, nil
+	}
+	err = attributevalue.UnmarshalMap(resp.Item, &response)
+	if err != nil {
+		return domain.SignInSummary{}, false, err
+	}
+	response.DistinctIpCount = len(response.DistinctIps)
+	response.DistinctIpsCapped = response.DistinctIpCount >= MaxSummaryIps
+	if repo.ipDigest != nil {
+		// digests only count, they mean nothing to a caller
+		response.DistinctIps = nil
+	}
+	response.DistinctSourceCount = len(response.DistinctSources)
+	return response, true, nil
+}
+
+func (repo *SignInRepo) PingDB(ctx context.Context) (*dynamodb.ListTablesOutput, error) {
 
 	// Using ListTables as a way to check the connectivity
 	// Adjust as needed based on your DynamoDB setup and permissions
 	input := &dynamodb.ListTablesInput{
 		Limit: aws.Int32(1), // Limiting to one table just to reduce the response size
 	}
-	result, err := repo.dbClient.ListTables(context.TODO(), input)
+	result, err := repo.dbClient.ListTables(ctx, input)
 	if err != nil {
 		return nil, errors.New("failed to get connection to db: " + err.Error())
 	}
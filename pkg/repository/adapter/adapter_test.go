@@ -0,0 +1,148 @@
+package adapter
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/envelope"
+	"go.uber.org/zap"
+)
+
+// fakeDynamo records the calls of the repository, conditional updates fail while
+// failConditions matches their condition.
+type fakeDynamo struct {
+	updates        []*dynamodb.UpdateItemInput
+	queries        []*dynamodb.QueryInput
+	failConditions func(condition string) bool
+	queryItems     []map[string]types.AttributeValue
+	item           map[string]types.AttributeValue
+}
+
+func (f *fakeDynamo) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{}, nil
+}
+
+func (f *fakeDynamo) GetItem(ctx context.Context, input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.item}, nil
+}
+
+func (f *fakeDynamo) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	f.queries = append(f.queries, params)
+	return &dynamodb.QueryOutput{Items: f.queryItems}, nil
+}
+
+func (f *fakeDynamo) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	return &dynamodb.ScanOutput{}, nil
+}
+
+func (f *fakeDynamo) ListTables(ctx context.Context, params *dynamodb.ListTablesInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ListTablesOutput, error) {
+	return &dynamodb.ListTablesOutput{}, nil
+}
+
+func (f *fakeDynamo) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamo) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	f.updates = append(f.updates, params)
+	if condition := aws.ToString(params.ConditionExpression); condition != "" && f.failConditions != nil && f.failConditions(condition) {
+		return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+	}
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (f *fakeDynamo) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func testRepo(db *fakeDynamo) *SignInRepo {
+	return &SignInRepo{logger: zap.L().Named("test-log-zap"), dbClient: db, tableName: "signindatatracker", summaryTableName: "signindatatrackersummary"}
+}
+
+func TestUpdateSignInSummaryIsOneConditionalWrite(t *testing.T) {
+	db := &fakeDynamo{}
+	err := testRepo(db).UpdateSignInSummary(context.Background(), domain.SaveSignInInfo{UniqueId: "MWA-1", TimeStamp: "1661285996251", IpAddress: "10.0.0.1", SourceId: "web"})
+	assert.NoError(t, err)
+	assert.Len(t, db.updates, 1)
+
+	update := db.updates[0]
+	assert.Equal(t, "SET #first = if_not_exists(#first, :ts), #last = :ts ADD #total :one, #sources :source, #ips :ip", aws.ToString(update.UpdateExpression))
+	assert.Contains(t, aws.ToString(update.ConditionExpression), "#last < :ts")
+	assert.Contains(t, aws.ToString(update.ConditionExpression), "size(#ips) < :maxIps")
+	assert.Equal(t, []string{"10.0.0.1"}, update.ExpressionAttributeValues[":ip"].(*types.AttributeValueMemberSS).Value)
+}
+
+func TestUpdateSignInSummaryKeepsNewerLastSeen(t *testing.T) {
+	// a newer sign-in was summarized first, the lastSeen condition fails
+	db := &fakeDynamo{failConditions: func(condition string) bool { return strings.Contains(condition, "#last") }}
+	err := testRepo(db).UpdateSignInSummary(context.Background(), domain.SaveSignInInfo{UniqueId: "MWA-1", TimeStamp: "1661285996251", IpAddress: "10.0.0.1"})
+	assert.NoError(t, err)
+	assert.Len(t, db.updates, 4)
+
+	counters := db.updates[1]
+	assert.Equal(t, "SET #first = if_not_exists(#first, :ts) ADD #total :one", aws.ToString(counters.UpdateExpression))
+	assert.Nil(t, counters.ConditionExpression)
+	assert.Equal(t, "SET #last = :ts", aws.ToString(db.updates[2].UpdateExpression))
+	// the IP is still recorded
+	assert.Equal(t, "ADD #ips :ip", aws.ToString(db.updates[3].UpdateExpression))
+}
+
+func TestUpdateSignInSummaryStopsAddingIpsAtTheCap(t *testing.T) {
+	db := &fakeDynamo{failConditions: func(condition string) bool { return strings.Contains(condition, "#ips") }}
+	repo := testRepo(db)
+	repo.ipDigest = envelope.NewDigester([]byte("secret"))
+	err := repo.UpdateSignInSummary(context.Background(), domain.SaveSignInInfo{UniqueId: "MWA-1", TimeStamp: "1661285996251", IpAddress: "10.0.0.1"})
+	assert.NoError(t, err)
+	assert.Len(t, db.updates, 4)
+	for _, update := range db.updates {
+		if ip, ok := update.ExpressionAttributeValues[":ip"]; ok {
+			assert.True(t, strings.HasPrefix(ip.(*types.AttributeValueMemberSS).Value[0], envelope.DigestPrefix))
+		}
+	}
+
+	ips := make([]string, 0, MaxSummaryIps)
+	for i := 0; i < MaxSummaryIps; i++ {
+		ips = append(ips, repo.ipDigest.Digest("10.0."+strconv.Itoa(i/256)+"."+strconv.Itoa(i%256)))
+	}
+	db.item = map[string]types.AttributeValue{
+		"uniqueId":    &types.AttributeValueMemberS{Value: "MWA-1"},
+		"totalCount":  &types.AttributeValueMemberN{Value: "900"},
+		"distinctIps": &types.AttributeValueMemberSS{Value: ips},
+	}
+	summary, found, err := repo.FindSignInSummary(context.Background(), "MWA-1")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, MaxSummaryIps, summary.DistinctIpCount)
+	assert.True(t, summary.DistinctIpsCapped)
+	assert.Nil(t, summary.DistinctIps)
+}
+
+func TestFindLastSignInReadsOnlyTheNewestItem(t *testing.T) {
+	db := &fakeDynamo{}
+	repo := testRepo(db)
+	_, found, err := repo.FindLastSignIn(context.Background(), "MWA-1")
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	db.queryItems = []map[string]types.AttributeValue{{
+		"uniqueId":  &types.AttributeValueMemberS{Value: "MWA-1"},
+		"timestamp": &types.AttributeValueMemberS{Value: "1661372396251"},
+		"sourceId":  &types.AttributeValueMemberS{Value: "web"},
+	}}
+	signIn, found, err := repo.FindLastSignIn(context.Background(), "MWA-1")
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, domain.SignInInfo{UniqueId: "MWA-1", TimeStamp: "1661372396251", SourceId: "web"}, signIn)
+
+	query := db.queries[1]
+	assert.False(t, aws.ToBool(query.ScanIndexForward))
+	assert.Equal(t, int32(1), aws.ToInt32(query.Limit))
+	assert.Equal(t, "MWA-1", query.ExpressionAttributeValues[":uid_value"].(*types.AttributeValueMemberS).Value)
+}
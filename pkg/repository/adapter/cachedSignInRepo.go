@@ -0,0 +1,242 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/cache"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/envelope"
+	"github.mathworks.com/development/signindatatrackerws/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+const cacheKeyPrefix = "signindatatracker:"
+
+var (
+	readCacheOnce    sync.Once
+	readCache        cache.Cache
+	readCacheMetrics *cache.Metrics
+)
+
+// ReadCacheFactory returns the process wide read cache, nil when the backend is "none".
+func ReadCacheFactory() (cache.Cache, *cache.Metrics) {
+	readCacheOnce.Do(func() {
+		config := bootstrap.GetApplicationContext().AppConfigData.Cache
+		switch config.Backend {
+		case cache.BackendNone:
+		case cache.BackendMemory:
+			readCache = cache.NewLRUCache(config.Capacity)
+			zap.L().Warn("The memory read cache is not invalidated by writes on other instances, use it with a single instance only")
+		case cache.BackendRedis:
+			readCache = cache.NewRedisCache(cache.RedisConfig{
+				Address:  config.RedisAddress,
+				Password: config.RedisPassword,
+				DB:       config.RedisDB,
+				PoolSize: config.RedisPoolSize,
+				Timeout:  time.Duration(config.RedisTimeoutMillis) * time.Millisecond,
+			})
+			// cached reads are decrypted, a shared cache gets them encrypted again
+			encryptor, err := bootstrap.GetApplicationContext().FieldEncryptor()
+			if err != nil {
+				log.Fatalf("Failed to initialize read cache encryption: %v", err)
+			}
+			if encryptor != nil {
+				readCache = envelope.NewSealedCache(readCache, encryptor)
+			}
+		default:
+			log.Fatalf("Failed to initialize read cache: unknown backend %q", config.Backend)
+		}
+		readCacheMetrics = cache.NewMetrics(config.Backend)
+		metrics.RegisterCacheCounters(config.Backend,
+			func() float64 { return float64(readCacheMetrics.Snapshot().Hits) },
+			func() float64 { return float64(readCacheMetrics.Snapshot().Misses) },
+			func() float64 { return float64(readCacheMetrics.Snapshot().Errors) })
+	})
+	return readCache, readCacheMetrics
+}
+
+// CachedSignInRepoFactory wraps the repository with the read cache unless it is disabled, the
+// metrics see the latency callers get including cache hits.
+func CachedSignInRepoFactory(tableName string) SignInRepoInterface {
+	repo := SignInRepoFactory(tableName)
+	c, cacheMetrics := ReadCacheFactory()
+	if c == nil {
+		return NewInstrumentedSignInRepo(repo, tableName)
+	}
+	appContext := bootstrap.GetApplicationContext()
+	ttl := time.Duration(appContext.Config().Cache.TTLSeconds) * time.Second
+	cached := NewCachedSignInRepo(repo, c, ttl, cacheMetrics, zap.L().Named("signindatatrackerws.signinRepoCache"))
+	appContext.OnConfigReload(func(config *bootstrap.AppConfigData) {
+		cached.SetTTL(time.Duration(config.Cache.TTLSeconds) * time.Second)
+	})
+	return NewInstrumentedSignInRepo(cached, tableName)
+}
+
+// CachedSignInRepo is a read-through cache in front of the profile reads. Every write for a
+// uniqueId drops its entries, other instances sharing an in-process cache see the change once
+// the TTL ran out.
+type CachedSignInRepo struct {
+	SignInRepoInterface
+	logger *zap.Logger
+	cache  cache.Cache
+	// ttl is in nanoseconds, it follows app.signindatatracker.cache.ttlseconds on a reload
+	ttl     atomic.Int64
+	metrics *cache.Metrics
+}
+
+func NewCachedSignInRepo(repo SignInRepoInterface, c cache.Cache, ttl time.Duration, metrics *cache.Metrics, logger *zap.Logger) *CachedSignInRepo {
+	cached := &CachedSignInRepo{SignInRepoInterface: repo, logger: logger, cache: c, metrics: metrics}
+	cached.SetTTL(ttl)
+	return cached
+}
+
+// SetTTL applies to entries stored from now on.
+func (repo *CachedSignInRepo) SetTTL(ttl time.Duration) {
+	repo.ttl.Store(int64(ttl))
+}
+
+type cachedLastSignIn struct {
+	SignIn domain.SignInInfo `json:"signIn"`
+	Found  bool              `json:"found"`
+}
+
+type cachedSummary struct {
+	Summary domain.SignInSummary `json:"summary"`
+	Found   bool                 `json:"found"`
+}
+
+func detailsKey(uniqueId string) string {
+	return cacheKeyPrefix + "details:" + uniqueId
+}
+
+func lastSignInKey(uniqueId string) string {
+	return cacheKeyPrefix + "last:" + uniqueId
+}
+
+func summaryKey(uniqueId string) string {
+	return cacheKeyPrefix + "summary:" + uniqueId
+}
+
+func (repo *CachedSignInRepo) FindSignInTrackingDetails(ctx context.Context, partitionKey string) ([]domain.SignInInfo, error) {
+	var response []domain.SignInInfo
+	if repo.lookup(detailsKey(partitionKey), &response) {
+		return response, nil
+	}
+	response, err := repo.SignInRepoInterface.FindSignInTrackingDetails(ctx, partitionKey)
+	if err != nil {
+		return nil, err
+	}
+	repo.store(detailsKey(partitionKey), response)
+	return response, nil
+}
+
+func (repo *CachedSignInRepo) FindLastSignIn(ctx context.Context, partitionKey string) (domain.SignInInfo, bool, error) {
+	var cached cachedLastSignIn
+	if repo.lookup(lastSignInKey(partitionKey), &cached) {
+		return cached.SignIn, cached.Found, nil
+	}
+	response, found, err := repo.SignInRepoInterface.FindLastSignIn(ctx, partitionKey)
+	if err != nil {
+		return response, found, err
+	}
+	repo.store(lastSignInKey(partitionKey), cachedLastSignIn{SignIn: response, Found: found})
+	return response, found, nil
+}
+
+func (repo *CachedSignInRepo) FindSignInSummary(ctx context.Context, partitionKey string) (domain.SignInSummary, bool, error) {
+	var cached cachedSummary
+	if repo.lookup(summaryKey(partitionKey), &cached) {
+		return cached.Summary, cached.Found, nil
+	}
+	response, found, err := repo.SignInRepoInterface.FindSignInSummary(ctx, partitionKey)
+	if err != nil {
+		return response, found, err
+	}
+	repo.store(summaryKey(partitionKey), cachedSummary{Summary: response, Found: found})
+	return response, found, nil
+}
+
+func (repo *CachedSignInRepo) SaveSignInTrackingInfo(ctx context.Context, request domain.SaveSignInInfo) (domain.SaveSignInInfo, error) {
+	response, err := repo.SignInRepoInterface.SaveSignInTrackingInfo(ctx, request)
+	if err == nil {
+		repo.invalidate(request.UniqueId)
+	}
+	return response, err
+}
+
+func (repo *CachedSignInRepo) BatchSaveSignInTrackingInfo(requests []domain.SaveSignInInfo) ([]domain.SaveSignInInfo, float64, error) {
+	unprocessed, consumedCapacity, err := repo.SignInRepoInterface.BatchSaveSignInTrackingInfo(requests)
+	if err == nil {
+		// unprocessed items are retried and invalidate again once written
+		repo.invalidate(uniqueIds(requests)...)
+	}
+	return unprocessed, consumedCapacity, err
+}
+
+func (repo *CachedSignInRepo) UpdateSignInSummary(ctx context.Context, request domain.SaveSignInInfo) error {
+	err := repo.SignInRepoInterface.UpdateSignInSummary(ctx, request)
+	if err == nil {
+		repo.invalidate(request.UniqueId)
+	}
+	return err
+}
+
+// lookup treats a cache failure as a miss, the cache must never fail a read.
+func (repo *CachedSignInRepo) lookup(key string, target interface{}) bool {
+	value, found, err := repo.cache.Get(key)
+	if err == nil && found {
+		err = json.Unmarshal(value, target)
+		if err == nil {
+			repo.metrics.Hit()
+			return true
+		}
+	}
+	if err != nil {
+		repo.metrics.Error()
+		repo.logger.Warn("Read cache lookup failed", zap.String("key", key), zap.Error(err))
+	}
+	repo.metrics.Miss()
+	return false
+}
+
+func (repo *CachedSignInRepo) store(key string, value interface{}) {
+	encoded, err := json.Marshal(value)
+	if err == nil {
+		err = repo.cache.Set(key, encoded, time.Duration(repo.ttl.Load()))
+	}
+	if err != nil {
+		repo.metrics.Error()
+		repo.logger.Warn("Read cache store failed", zap.String("key", key), zap.Error(err))
+	}
+}
+
+func (repo *CachedSignInRepo) invalidate(uniqueIdList ...string) {
+	keys := make([]string, 0, 3*len(uniqueIdList))
+	for _, uniqueId := range uniqueIdList {
+		keys = append(keys, detailsKey(uniqueId), lastSignInKey(uniqueId), summaryKey(uniqueId))
+	}
+	if err := repo.cache.Delete(keys...); err != nil {
+		repo.metrics.Error()
+		repo.logger.Warn("Read cache invalidation failed", zap.Strings("uniqueIds", uniqueIdList), zap.Error(err))
+		return
+	}
+	repo.metrics.Invalidated(len(uniqueIdList))
+}
+
+func uniqueIds(requests []domain.SaveSignInInfo) []string {
+	seen := map[string]bool{}
+	ids := make([]string, 0, len(requests))
+	for _, request := range requests {
+		if !seen[request.UniqueId] {
+			seen[request.UniqueId] = true
+			ids = append(ids, request.UniqueId)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,165 @@
+package adapter
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/metrics"
+	"github.mathworks.com/development/signindatatrackerws/pkg/search"
+)
+
+// InstrumentedSignInRepoFactory is SignInRepoFactory with repository metrics.
+func InstrumentedSignInRepoFactory(tableName string) SignInRepoInterface {
+	return NewInstrumentedSignInRepo(SignInRepoFactory(tableName), tableName)
+}
+
+// InstrumentedSignInRepo records latency and outcome of every repository method and counts
+// saved sign-ins per sourceId.
+type InstrumentedSignInRepo struct {
+	repo  SignInRepoInterface
+	table string
+}
+
+func NewInstrumentedSignInRepo(repo SignInRepoInterface, table string) *InstrumentedSignInRepo {
+	return &InstrumentedSignInRepo{repo: repo, table: table}
+}
+
+func (r *InstrumentedSignInRepo) observe(operation string, start time.Time, err error) {
+	metrics.RepositoryLatency.WithLabelValues(operation, r.table, metrics.Outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+func (r *InstrumentedSignInRepo) SaveSignInTrackingInfo(ctx context.Context, request domain.SaveSignInInfo) (domain.SaveSignInInfo, error) {
+	start := time.Now()
+	response, err := r.repo.SaveSignInTrackingInfo(ctx, request)
+	r.observe("SaveSignInTrackingInfo", start, err)
+	metrics.SignInsSaved.WithLabelValues(metrics.SourceLabel(request.SourceId), metrics.Outcome(err)).Inc()
+	return response, err
+}
+
+func (r *InstrumentedSignInRepo) BatchSaveSignInTrackingInfo(requests []domain.SaveSignInInfo) ([]domain.SaveSignInInfo, float64, error) {
+	start := time.Now()
+	unprocessed, consumedCapacity, err := r.repo.BatchSaveSignInTrackingInfo(requests)
+	r.observe("BatchSaveSignInTrackingInfo", start, err)
+	if err != nil {
+		for _, request := range requests {
+			metrics.SignInsSaved.WithLabelValues(metrics.SourceLabel(request.SourceId), metrics.Outcome(err)).Inc()
+		}
+		return unprocessed, consumedCapacity, err
+	}
+	// unprocessed items are counted when a retry writes them
+	pending := map[string]int{}
+	for _, item := range unprocessed {
+		pending[item.UniqueId+"\x00"+item.TimeStamp]++
+	}
+	for _, request := range requests {
+		key := request.UniqueId + "\x00" + request.TimeStamp
+		if pending[key] > 0 {
+			pending[key]--
+			continue
+		}
+		metrics.SignInsSaved.WithLabelValues(metrics.SourceLabel(request.SourceId), metrics.OutcomeSuccess).Inc()
+	}
+	return unprocessed, consumedCapacity, nil
+}
+
+func (r *InstrumentedSignInRepo) FindUniqueSignInInfo(ctx context.Context, condition map[string]interface{}) (*dynamodb.GetItemOutput, error) {
+	start := time.Now()
+	response, err := r.repo.FindUniqueSignInInfo(ctx, condition)
+	r.observe("FindUniqueSignInInfo", start, err)
+	return response, err
+}
+
+func (r *InstrumentedSignInRepo) FindSignInTrackingDetails(ctx context.Context, partitionKey string) ([]domain.SignInInfo, error) {
+	start := time.Now()
+	response, err := r.repo.FindSignInTrackingDetails(ctx, partitionKey)
+	r.observe("FindSignInTrackingDetails", start, err)
+	return response, err
+}
+
+func (r *InstrumentedSignInRepo) GetSignInBetweenTimeStamps(ctx context.Context, request domain.RequestTimestampInput) ([]domain.SignInInfo, error) {
+	start := time.Now()
+	response, err := r.repo.GetSignInBetweenTimeStamps(ctx, request)
+	r.observe("GetSignInBetweenTimeStamps", start, err)
+	return response, err
+}
+
+func (r *InstrumentedSignInRepo) GetSignInForReferenceId(ctx context.Context, request domain.RequestReferenceIdInput) ([]domain.SignInInfo, error) {
+	start := time.Now()
+	response, err := r.repo.GetSignInForReferenceId(ctx, request)
+	r.observe("GetSignInForReferenceId", start, err)
+	return response, err
+}
+
+func (r *InstrumentedSignInRepo) FindLastSignIn(ctx context.Context, partitionKey string) (domain.SignInInfo, bool, error) {
+	start := time.Now()
+	response, found, err := r.repo.FindLastSignIn(ctx, partitionKey)
+	r.observe("FindLastSignIn", start, err)
+	return response, found, err
+}
+
+func (r *InstrumentedSignInRepo) UpdateSignInSummary(ctx context.Context, request domain.SaveSignInInfo) error {
+	start := time.Now()
+	err := r.repo.UpdateSignInSummary(ctx, request)
+	r.observe("UpdateSignInSummary", start, err)
+	return err
+}
+
+func (r *InstrumentedSignInRepo) FindSignInSummary(ctx context.Context, partitionKey string) (domain.SignInSummary, bool, error) {
+	start := time.Now()
+	response, found, err := r.repo.FindSignInSummary(ctx, partitionKey)
+	r.observe("FindSignInSummary", start, err)
+	return response, found, err
+}
+
+func (r *InstrumentedSignInRepo) SummaryEnabled() bool {
+	return r.repo.SummaryEnabled()
+}
+
+func (r *InstrumentedSignInRepo) QuerySignInsBetween(ctx context.Context, partitionKey string, startMillis string, endMillis string) ([]domain.SignInInfo, error) {
+	start := time.Now()
+	response, err := r.repo.QuerySignInsBetween(ctx, partitionKey, startMillis, endMillis)
+	r.observe("QuerySignInsBetween", start, err)
+	return response, err
+}
+
+func (r *InstrumentedSignInRepo) UpdateSignInRollup(ctx context.Context, request domain.SaveSignInInfo) error {
+	start := time.Now()
+	err := r.repo.UpdateSignInRollup(ctx, request)
+	r.observe("UpdateSignInRollup", start, err)
+	return err
+}
+
+func (r *InstrumentedSignInRepo) QueryDailyRollups(ctx context.Context, partitionKey string, startDay time.Time, endDay time.Time) ([]domain.SignInRollup, error) {
+	start := time.Now()
+	response, err := r.repo.QueryDailyRollups(ctx, partitionKey, startDay, endDay)
+	r.observe("QueryDailyRollups", start, err)
+	return response, err
+}
+
+func (r *InstrumentedSignInRepo) RollupEnabled() bool {
+	return r.repo.RollupEnabled()
+}
+
+func (r *InstrumentedSignInRepo) SearchSignIns(ctx context.Context, plan search.Plan, limit int, nextToken string) (search.Page, error) {
+	start := time.Now()
+	response, err := r.repo.SearchSignIns(ctx, plan, limit, nextToken)
+	r.observe("SearchSignIns", start, err)
+	return response, err
+}
+
+func (r *InstrumentedSignInRepo) ScanSegmentPage(segment int, totalSegments int, limit int, startKey map[string]types.AttributeValue) (ScanPage, error) {
+	start := time.Now()
+	response, err := r.repo.ScanSegmentPage(segment, totalSegments, limit, startKey)
+	r.observe("ScanSegmentPage", start, err)
+	return response, err
+}
+
+func (r *InstrumentedSignInRepo) PingDB(ctx context.Context) (*dynamodb.ListTablesOutput, error) {
+	start := time.Now()
+	response, err := r.repo.PingDB(ctx)
+	r.observe("PingDB", start, err)
+	return response, err
+}
@@ -0,0 +1,154 @@
+package adapter
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/search"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+)
+
+// a query stops after this many pages even when the filter discarded most items, the caller
+// continues with the returned token
+const maxSearchQueryPages = 10
+
+// SearchSignIns runs one page of a planned search. Queries return up to limit matching items,
+// scans read one page of at most limit/segments items from every pending segment in parallel.
+func (repo *SignInRepo) SearchSignIns(ctx context.Context, plan search.Plan, limit int, nextToken string) (search.Page, error) {
+	token, err := search.DecodeToken(plan, nextToken)
+	if err != nil {
+		return search.Page{}, err
+	}
+	var page search.Page
+	var next search.PageToken
+	if plan.AccessPath == search.AccessPathScan {
+		page, next, err = repo.scanSegments(ctx, plan, limit, token)
+	} else {
+		page, next, err = repo.queryPages(ctx, plan, limit, token.Key)
+	}
+	if err != nil {
+		return search.Page{}, err
+	}
+	page.NextToken, err = next.Encode(plan)
+	return page, err
+}
+
+func (repo *SignInRepo) queryPages(ctx context.Context, plan search.Plan, limit int, startKey map[string]types.AttributeValue) (search.Page, search.PageToken, error) {
+	page := search.Page{}
+	for pages := 0; pages < maxSearchQueryPages; pages++ {
+		resp, err := repo.dbClient.Query(ctx, plan.QueryInput(repo.tableName, int32(limit-len(page.Items)), startKey))
+		if err != nil {
+			return search.Page{}, search.PageToken{}, err
+		}
+		items, err := utils.UnmarshalItems(resp.Items)
+		if err != nil {
+			return search.Page{}, search.PageToken{}, err
+		}
+		page.Items = append(page.Items, items...)
+		page.ScannedCount += int(resp.ScannedCount)
+		startKey = resp.LastEvaluatedKey
+		if len(startKey) == 0 || len(page.Items) >= limit {
+			break
+		}
+	}
+	return page, search.PageToken{Key: startKey}, nil
+}
+
+func (repo *SignInRepo) scanSegments(ctx context.Context, plan search.Plan, limit int, token search.PageToken) (search.Page, search.PageToken, error) {
+	pending := token.Segments
+	if token.Empty() {
+		pending = map[int]map[string]types.AttributeValue{}
+		for segment := 0; segment < plan.Segments; segment++ {
+			pending[segment] = nil
+		}
+	}
+	perSegment := (limit + len(pending) - 1) / len(pending)
+
+	type segmentResult struct {
+		segment int
+		page    search.Page
+		lastKey map[string]types.AttributeValue
+		err     error
+	}
+	results := make(chan segmentResult, len(pending))
+	var wg sync.WaitGroup
+	for segment, startKey := range pending {
+		wg.Add(1)
+		go func(segment int, startKey map[string]types.AttributeValue) {
+			defer wg.Done()
+			resp, err := repo.dbClient.Scan(ctx, plan.ScanInput(repo.tableName, segment, int32(perSegment), startKey))
+			if err != nil {
+				results <- segmentResult{segment: segment, err: err}
+				return
+			}
+			items, err := utils.UnmarshalItems(resp.Items)
+			results <- segmentResult{
+				segment: segment,
+				page:    search.Page{Items: items, ScannedCount: int(resp.ScannedCount)},
+				lastKey: resp.LastEvaluatedKey,
+				err:     err,
+			}
+		}(segment, startKey)
+	}
+	wg.Wait()
+	close(results)
+
+	// results are ordered by segment so a page is stable for the same token
+	bySegment := make([]*segmentResult, plan.Segments)
+	for result := range results {
+		if result.err != nil {
+			return search.Page{}, search.PageToken{}, result.err
+		}
+		result := result
+		bySegment[result.segment] = &result
+	}
+	page := search.Page{}
+	next := search.PageToken{Segments: map[int]map[string]types.AttributeValue{}}
+	for _, result := range bySegment {
+		if result == nil {
+			continue
+		}
+		page.Items = append(page.Items, result.page.Items...)
+		page.ScannedCount += result.page.ScannedCount
+		if len(result.lastKey) > 0 {
+			next.Segments[result.segment] = result.lastKey
+		}
+	}
+	return page, next, nil
+}
+
+// ScanPage is one page of a parallel scan segment, LastKey is empty once the segment is done.
+type ScanPage struct {
+	Items            []domain.SignInInfo
+	LastKey          map[string]types.AttributeValue
+	ConsumedCapacity float64
+}
+
+// ScanSegmentPage reads one page of a single segment of a parallel scan over the whole table,
+// reporting the consumed read capacity so callers can throttle themselves.
+func (repo *SignInRepo) ScanSegmentPage(segment int, totalSegments int, limit int, startKey map[string]types.AttributeValue) (ScanPage, error) {
+	resp, err := repo.dbClient.Scan(context.TODO(), &dynamodb.ScanInput{
+		TableName:              aws.String(repo.tableName),
+		Segment:                aws.Int32(int32(segment)),
+		TotalSegments:          aws.Int32(int32(totalSegments)),
+		Limit:                  aws.Int32(int32(limit)),
+		ExclusiveStartKey:      startKey,
+		ReturnConsumedCapacity: types.ReturnConsumedCapacityTotal,
+	})
+	if err != nil {
+		return ScanPage{}, err
+	}
+	items, err := utils.UnmarshalItems(resp.Items)
+	if err != nil {
+		return ScanPage{}, err
+	}
+	page := ScanPage{Items: items, LastKey: resp.LastEvaluatedKey}
+	if resp.ConsumedCapacity != nil && resp.ConsumedCapacity.CapacityUnits != nil {
+		page.ConsumedCapacity = *resp.ConsumedCapacity.CapacityUnits
+	}
+	return page, nil
+}
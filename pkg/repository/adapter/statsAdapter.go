@@ -0,0 +1,128 @@
+package adapter
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+)
+
+const (
+	DailyRollupPrefix = "day#"
+	RollupDayLayout   = "2006-01-02"
+)
+
+// QuerySignInsBetween pages through the key range uniqueId + timestamp BETWEEN start AND end.
+// Both bounds are epoch milliseconds, matching how timestamps are stored.
+func (repo *SignInRepo) QuerySignInsBetween(ctx context.Context, partitionKeyValue string, startMillis string, endMillis string) ([]domain.SignInInfo, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(repo.tableName),
+		KeyConditionExpression: aws.String("#uid = :uid_value AND #ts BETWEEN :start_time AND :end_time"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid": "uniqueId",
+			"#ts":  "timestamp",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid_value":  &types.AttributeValueMemberS{Value: partitionKeyValue},
+			":start_time": &types.AttributeValueMemberS{Value: startMillis},
+			":end_time":   &types.AttributeValueMemberS{Value: endMillis},
+		},
+	}
+
+	result := []domain.SignInInfo{}
+	for {
+		resp, err := repo.dbClient.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		items, err := utils.UnmarshalItems(resp.Items)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+		if len(resp.LastEvaluatedKey) == 0 {
+			return result, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+func (repo *SignInRepo) RollupEnabled() bool {
+	return repo.rollupTableName != ""
+}
+
+// UpdateSignInRollup increments the daily pre-aggregate of the profile, used to answer
+// statistics over long ranges without reading every sign-in.
+func (repo *SignInRepo) UpdateSignInRollup(ctx context.Context, request domain.SaveSignInInfo) error {
+	if !repo.RollupEnabled() {
+		return nil
+	}
+	millis, err := strconv.ParseInt(request.TimeStamp, 10, 64)
+	if err != nil {
+		return err
+	}
+	update := "ADD #count :one"
+	names := map[string]string{"#count": "signInCount"}
+	values := map[string]types.AttributeValue{":one": &types.AttributeValueMemberN{Value: "1"}}
+	if request.IpAddress != "" {
+		update += ", #ips :ip"
+		names["#ips"] = "ips"
+		values[":ip"] = &types.AttributeValueMemberSS{Value: []string{repo.ipDigest.Digest(request.IpAddress)}}
+	}
+
+	_, err = repo.dbClient.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(repo.rollupTableName),
+		Key: map[string]types.AttributeValue{
+			"uniqueId": &types.AttributeValueMemberS{Value: request.UniqueId},
+			"bucket":   &types.AttributeValueMemberS{Value: DailyRollupBucket(time.UnixMilli(millis))},
+		},
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+	return err
+}
+
+// QueryDailyRollups returns the daily rollups of the profile between two UTC days, inclusive.
+func (repo *SignInRepo) QueryDailyRollups(ctx context.Context, partitionKeyValue string, startDay time.Time, endDay time.Time) ([]domain.SignInRollup, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(repo.rollupTableName),
+		KeyConditionExpression: aws.String("#uid = :uid_value AND #bucket BETWEEN :start_bucket AND :end_bucket"),
+		ExpressionAttributeNames: map[string]string{
+			"#uid":    "uniqueId",
+			"#bucket": "bucket",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid_value":    &types.AttributeValueMemberS{Value: partitionKeyValue},
+			":start_bucket": &types.AttributeValueMemberS{Value: DailyRollupBucket(startDay)},
+			":end_bucket":   &types.AttributeValueMemberS{Value: DailyRollupBucket(endDay)},
+		},
+	}
+
+	result := []domain.SignInRollup{}
+	for {
+		resp, err := repo.dbClient.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		var rollups []domain.SignInRollup
+		if err := attributevalue.UnmarshalListOfMaps(resp.Items, &rollups); err != nil {
+			return nil, err
+		}
+		result = append(result, rollups...)
+		if len(resp.LastEvaluatedKey) == 0 {
+			return result, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+func DailyRollupBucket(t time.Time) string {
+	return DailyRollupPrefix + t.UTC().Format(RollupDayLayout)
+}
@@ -0,0 +1,141 @@
+package resilience
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling DynamoDB while the breaker of an operation is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type State string
+
+const (
+	StateClosed   State = "closed"
+	StateOpen     State = "open"
+	StateHalfOpen State = "half-open"
+)
+
+// CircuitBreaker opens after FailureThreshold consecutive failures and lets a single trial
+// call through once OpenDuration has passed. The trial closes the breaker again on success.
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	openDuration     time.Duration
+	state            State
+	failures         int
+	openedAt         time.Time
+	trialInFlight    bool
+	now              func() time.Time
+}
+
+func NewCircuitBreaker(failureThreshold int, openDuration time.Duration) *CircuitBreaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	return &CircuitBreaker{failureThreshold: failureThreshold, openDuration: openDuration, state: StateClosed, now: time.Now}
+}
+
+// Allow reports whether a call may go through, every allowed call must be followed by Record.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openDuration {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.trialInFlight = true
+		return true
+	case StateHalfOpen:
+		if b.trialInFlight {
+			return false
+		}
+		b.trialInFlight = true
+		return true
+	}
+	return true
+}
+
+// Record reports the outcome of an allowed call. Only failures that say something about the
+// health of DynamoDB should be recorded as such.
+func (b *CircuitBreaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trialInFlight = false
+	if success {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.failureThreshold {
+		b.state = StateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Release gives back an allowed call that told nothing about DynamoDB, a cancelled one.
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trialInFlight = false
+}
+
+func (b *CircuitBreaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.openDuration {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// BreakerSet holds one breaker per operation so a failing Scan does not stop GetItem.
+type BreakerSet struct {
+	mu               sync.Mutex
+	failureThreshold int
+	openDuration     time.Duration
+	breakers         map[string]*CircuitBreaker
+	now              func() time.Time
+}
+
+func NewBreakerSet(failureThreshold int, openDuration time.Duration) *BreakerSet {
+	return &BreakerSet{failureThreshold: failureThreshold, openDuration: openDuration, breakers: map[string]*CircuitBreaker{}, now: time.Now}
+}
+
+func (s *BreakerSet) Get(operation string) *CircuitBreaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	breaker, ok := s.breakers[operation]
+	if !ok {
+		breaker = NewCircuitBreaker(s.failureThreshold, s.openDuration)
+		breaker.now = s.now
+		s.breakers[operation] = breaker
+	}
+	return breaker
+}
+
+type OperationState struct {
+	Operation string `json:"operation"`
+	State     State  `json:"state"`
+}
+
+// States lists the breakers that have seen traffic, sorted by operation.
+func (s *BreakerSet) States() []OperationState {
+	s.mu.Lock()
+	operations := make([]string, 0, len(s.breakers))
+	for operation := range s.breakers {
+		operations = append(operations, operation)
+	}
+	s.mu.Unlock()
+	sort.Strings(operations)
+	states := make([]OperationState, 0, len(operations))
+	for _, operation := range operations {
+		states = append(states, OperationState{Operation: operation, State: s.Get(operation).State()})
+	}
+	return states
+}
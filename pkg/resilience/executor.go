@@ -0,0 +1,103 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"go.uber.org/zap"
+)
+
+type Policy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Executor runs calls with retries and jittered exponential backoff behind the breaker of
+// their operation.
+type Executor struct {
+	logger   *zap.Logger
+	policy   Policy
+	breakers *BreakerSet
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+func NewExecutor(policy Policy, breakers *BreakerSet, logger *zap.Logger) *Executor {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	return &Executor{logger: logger, policy: policy, breakers: breakers, sleep: sleepContext}
+}
+
+func (e *Executor) Breakers() *BreakerSet {
+	return e.breakers
+}
+
+// Do returns ErrCircuitOpen, wrapped with the operation name, when the breaker rejects the call.
+// Errors that are not transient are returned at once and do not count against the breaker.
+func (e *Executor) Do(ctx context.Context, operation string, call func() error) error {
+	breaker := e.breakers.Get(operation)
+	var err error
+	for attempt := 0; attempt < e.policy.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			if sleepErr := e.sleep(ctx, e.backoff(attempt-1)); sleepErr != nil {
+				return err
+			}
+		}
+		if !breaker.Allow() {
+			return fmt.Errorf("%s: %w", operation, ErrCircuitOpen)
+		}
+		err = call()
+		if err == nil {
+			breaker.Record(true)
+			return nil
+		}
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			breaker.Release()
+			return err
+		}
+		if !IsTransient(err) {
+			// the call reached DynamoDB, a validation or condition failure says it is healthy
+			breaker.Record(true)
+			return err
+		}
+		breaker.Record(false)
+		e.logger.Warn("DynamoDB call failed", zap.String("operation", operation), zap.Int("attempt", attempt+1), zap.Error(err))
+	}
+	return err
+}
+
+// IsTransient uses the classification of the SDK's standard retryer: throttling, 5xx responses
+// and connection errors.
+func IsTransient(err error) bool {
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary ||
+		retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary
+}
+
+// backoff is exponential with full jitter.
+func (e *Executor) backoff(attempt int) time.Duration {
+	ceiling := e.policy.InitialBackoff << attempt
+	if ceiling <= 0 || ceiling > e.policy.MaxBackoff {
+		ceiling = e.policy.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,78 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+var (
+	throttled = &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	invalid   = &smithy.GenericAPIError{Code: "ValidationException", Message: "One or more parameter values were invalid"}
+)
+
+func newTestExecutor(maxAttempts int, failures int) (*Executor, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	breakers := NewBreakerSet(failures, 30*time.Second)
+	e := NewExecutor(Policy{MaxAttempts: maxAttempts, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond},
+		breakers, zap.L().Named("test-log-zap"))
+	e.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	clock := &now
+	breakers.now = func() time.Time { return *clock }
+	return e, clock
+}
+
+func TestExecutorRetriesTransientErrors(t *testing.T) {
+	e, _ := newTestExecutor(3, 10)
+	calls := 0
+	err := e.Do(context.Background(), "PutItem", func() error {
+		calls++
+		if calls < 3 {
+			return throttled
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = e.Do(context.Background(), "PutItem", func() error {
+		calls++
+		return invalid
+	})
+	assert.Equal(t, invalid, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, StateClosed, e.Breakers().Get("PutItem").State())
+}
+
+func TestBreakerOpensPerOperationAndRecoversAfterTrial(t *testing.T) {
+	e, now := newTestExecutor(2, 4)
+	fail := func() error { return throttled }
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, throttled, e.Do(context.Background(), "Query", fail))
+	}
+	assert.Equal(t, StateOpen, e.Breakers().Get("Query").State())
+
+	calls := 0
+	err := e.Do(context.Background(), "Query", func() error { calls++; return nil })
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, 0, calls)
+	// other operations are not affected
+	assert.NoError(t, e.Do(context.Background(), "GetItem", func() error { return nil }))
+
+	// after the open period a failed trial opens the breaker again, a successful one closes it
+	*now = now.Add(31 * time.Second)
+	assert.Equal(t, StateHalfOpen, e.Breakers().Get("Query").State())
+	err = e.Do(context.Background(), "Query", fail)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, StateOpen, e.Breakers().Get("Query").State())
+
+	*now = now.Add(31 * time.Second)
+	assert.NoError(t, e.Do(context.Background(), "Query", func() error { return nil }))
+	assert.Equal(t, []OperationState{{Operation: "GetItem", State: StateClosed}, {Operation: "Query", State: StateClosed}}, e.Breakers().States())
+}
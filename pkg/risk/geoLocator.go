@@ -0,0 +1,96 @@
+package risk
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+
+	"go.uber.org/zap"
+)
+
+const DefaultGeoDatabaseLocation = "configfiles/geoip.json"
+
+type GeoInfo struct {
+	Country   string
+	Asn       string
+	Latitude  float64
+	Longitude float64
+}
+
+type GeoLocatorInterface interface {
+	Lookup(ipAddress string) (GeoInfo, bool)
+}
+
+type geoRange struct {
+	Cidr      string  `json:"cidr"`
+	Country   string  `json:"country"`
+	Asn       string  `json:"asn"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type geoDatabase struct {
+	Ranges []geoRange `json:"ranges"`
+}
+
+type geoEntry struct {
+	network *net.IPNet
+	info    GeoInfo
+}
+
+// FileGeoLocator resolves IP addresses against a static list of CIDR ranges.
+// The most specific matching range wins.
+type FileGeoLocator struct {
+	entries []geoEntry
+}
+
+func NewFileGeoLocator(location string, logger *zap.Logger) *FileGeoLocator {
+	if location == "" {
+		location = DefaultGeoDatabaseLocation
+	}
+	locator := &FileGeoLocator{}
+	content, err := os.ReadFile(location)
+	if err != nil {
+		logger.Error("Error loading geo database: "+err.Error(), zap.String("location", location))
+		return locator
+	}
+	var db geoDatabase
+	if err := json.Unmarshal(content, &db); err != nil {
+		logger.Error("Error parsing geo database: "+err.Error(), zap.String("location", location))
+		return locator
+	}
+	for _, r := range db.Ranges {
+		_, network, err := net.ParseCIDR(r.Cidr)
+		if err != nil {
+			logger.Warn("Skipping invalid geo range", zap.String("cidr", r.Cidr))
+			continue
+		}
+		locator.entries = append(locator.entries, geoEntry{
+			network: network,
+			info:    GeoInfo{Country: r.Country, Asn: r.Asn, Latitude: r.Latitude, Longitude: r.Longitude},
+		})
+	}
+	return locator
+}
+
+func (l *FileGeoLocator) Lookup(ipAddress string) (GeoInfo, bool) {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() {
+		return GeoInfo{}, false
+	}
+	var best *geoEntry
+	bestSize := -1
+	for i := range l.entries {
+		entry := &l.entries[i]
+		if !entry.network.Contains(ip) {
+			continue
+		}
+		if size, _ := entry.network.Mask.Size(); size > bestSize {
+			best, bestSize = entry, size
+		}
+	}
+	if best == nil {
+		return GeoInfo{}, false
+	}
+	return best.info, true
+}
@@ -0,0 +1,185 @@
+package risk
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	ReasonImpossibleTravel = "impossible_travel"
+	ReasonNewDevice        = "new_device"
+	ReasonNewCountry       = "new_country"
+	ReasonNewAsn           = "new_asn"
+	ReasonSignInBurst      = "signin_burst"
+
+	MaxRiskScore  = 100
+	earthRadiusKm = 6371.0
+)
+
+// reasonWeights is the contribution of each detector to the overall risk score.
+var reasonWeights = map[string]int{
+	ReasonImpossibleTravel: 50,
+	ReasonNewDevice:        20,
+	ReasonNewCountry:       25,
+	ReasonNewAsn:           10,
+	ReasonSignInBurst:      25,
+}
+
+type Assessment struct {
+	Score   int
+	Reasons []string
+}
+
+type RiskEngineInterface interface {
+	Evaluate(event domain.SaveSignInInfo, history []domain.SignInInfo) Assessment
+	IsRisky(score int) bool
+}
+
+// Engine scores a new sign-in against the profile's previous sign-ins.
+type Engine struct {
+	logger  *zap.Logger
+	config  bootstrap.RiskConfig
+	locator GeoLocatorInterface
+}
+
+func NewEngine(config bootstrap.RiskConfig, locator GeoLocatorInterface, logger *zap.Logger) *Engine {
+	return &Engine{logger: logger, config: config, locator: locator}
+}
+
+func (e *Engine) IsRisky(score int) bool {
+	return score > 0 && score >= e.config.RiskyThreshold
+}
+
+func (e *Engine) Evaluate(event domain.SaveSignInInfo, history []domain.SignInInfo) Assessment {
+	assessment := Assessment{Reasons: []string{}}
+	if len(history) == 0 {
+		// nothing to compare a first sign-in against
+		return assessment
+	}
+	eventTime := parseMillis(event.TimeStamp)
+	sorted := sortByTimestamp(history)
+
+	if e.isImpossibleTravel(event, eventTime, sorted) {
+		assessment.add(ReasonImpossibleTravel)
+	}
+	if isNewDevice(event, sorted) {
+		assessment.add(ReasonNewDevice)
+	}
+	if geo, ok := e.locator.Lookup(event.IpAddress); ok {
+		countries, asns := e.knownNetworks(sorted)
+		if len(countries) > 0 && !countries[geo.Country] {
+			assessment.add(ReasonNewCountry)
+		}
+		if len(asns) > 0 && !asns[geo.Asn] {
+			assessment.add(ReasonNewAsn)
+		}
+	}
+	if e.isBurst(eventTime, sorted) {
+		assessment.add(ReasonSignInBurst)
+	}
+	if assessment.Score > MaxRiskScore {
+		assessment.Score = MaxRiskScore
+	}
+	return assessment
+}
+
+func (a *Assessment) add(reason string) {
+	a.Score += reasonWeights[reason]
+	a.Reasons = append(a.Reasons, reason)
+}
+
+// isImpossibleTravel compares the new sign-in with the most recent one that could be
+// geolocated and flags it when the implied speed exceeds the configured maximum.
+func (e *Engine) isImpossibleTravel(event domain.SaveSignInInfo, eventTime time.Time, history []domain.SignInInfo) bool {
+	current, ok := e.locator.Lookup(event.IpAddress)
+	if !ok {
+		return false
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		previous, ok := e.locator.Lookup(history[i].IpAddress)
+		if !ok {
+			continue
+		}
+		distance := haversineKm(previous, current)
+		if distance == 0 {
+			return false
+		}
+		hours := eventTime.Sub(parseMillis(history[i].TimeStamp)).Hours()
+		if hours <= 0 {
+			return true
+		}
+		return distance/hours > float64(e.config.MaxVelocityKmh)
+	}
+	return false
+}
+
+func isNewDevice(event domain.SaveSignInInfo, history []domain.SignInInfo) bool {
+	if event.UserAgent == "" {
+		return false
+	}
+	for _, previous := range history {
+		if previous.UserAgent == event.UserAgent {
+			return false
+		}
+		if event.Device != "" && previous.Device == event.Device && previous.Os == event.Os && previous.Browser == event.Browser {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Engine) knownNetworks(history []domain.SignInInfo) (countries map[string]bool, asns map[string]bool) {
+	countries, asns = map[string]bool{}, map[string]bool{}
+	for _, previous := range history {
+		if geo, ok := e.locator.Lookup(previous.IpAddress); ok {
+			countries[geo.Country] = true
+			asns[geo.Asn] = true
+		}
+	}
+	return countries, asns
+}
+
+func (e *Engine) isBurst(eventTime time.Time, history []domain.SignInInfo) bool {
+	if e.config.BurstThreshold <= 0 {
+		return false
+	}
+	windowStart := eventTime.Add(-time.Duration(e.config.BurstWindowSeconds) * time.Second)
+	count := 1 // the new sign-in itself
+	for _, previous := range history {
+		if !parseMillis(previous.TimeStamp).Before(windowStart) {
+			count++
+		}
+	}
+	return count >= e.config.BurstThreshold
+}
+
+func sortByTimestamp(history []domain.SignInInfo) []domain.SignInInfo {
+	sorted := make([]domain.SignInInfo, len(history))
+	copy(sorted, history)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return parseMillis(sorted[i].TimeStamp).Before(parseMillis(sorted[j].TimeStamp))
+	})
+	return sorted
+}
+
+func parseMillis(timestamp string) time.Time {
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
+
+func haversineKm(from, to GeoInfo) float64 {
+	lat1, lat2 := from.Latitude*math.Pi/180, to.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (to.Longitude - from.Longitude) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,86 @@
+package risk
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	testUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/101.0.4951.54"
+	bostonIP      = "144.212.1.1"
+	londonIP      = "81.128.1.1"
+)
+
+type stubLocator map[string]GeoInfo
+
+func (s stubLocator) Lookup(ipAddress string) (GeoInfo, bool) {
+	geo, ok := s[ipAddress]
+	return geo, ok
+}
+
+func newTestEngine() *Engine {
+	locator := stubLocator{
+		bostonIP: {Country: "US", Asn: "AS11433", Latitude: 42.3001, Longitude: -71.3504},
+		londonIP: {Country: "GB", Asn: "AS2856", Latitude: 51.5072, Longitude: -0.1276},
+	}
+	config := bootstrap.RiskConfig{MaxVelocityKmh: 900, BurstWindowSeconds: 300, BurstThreshold: 3, RiskyThreshold: 50}
+	return NewEngine(config, locator, zap.L().Named("test-log-zap"))
+}
+
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+func TestEvaluate(t *testing.T) {
+	engine := newTestEngine()
+	now := time.Now()
+
+	t.Run("First Sign In Is Not Risky", func(t *testing.T) {
+		assessment := engine.Evaluate(domain.SaveSignInInfo{IpAddress: bostonIP, TimeStamp: millis(now)}, nil)
+		assert.Equal(t, 0, assessment.Score)
+		assert.Empty(t, assessment.Reasons)
+	})
+
+	t.Run("Known Device And Location", func(t *testing.T) {
+		history := []domain.SignInInfo{{IpAddress: bostonIP, UserAgent: testUserAgent, TimeStamp: millis(now.Add(-24 * time.Hour))}}
+		assessment := engine.Evaluate(domain.SaveSignInInfo{IpAddress: bostonIP, UserAgent: testUserAgent, TimeStamp: millis(now)}, history)
+		assert.Equal(t, 0, assessment.Score)
+	})
+
+	t.Run("Impossible Travel From New Country", func(t *testing.T) {
+		history := []domain.SignInInfo{{IpAddress: bostonIP, UserAgent: testUserAgent, TimeStamp: millis(now.Add(-time.Hour))}}
+		assessment := engine.Evaluate(domain.SaveSignInInfo{IpAddress: londonIP, UserAgent: testUserAgent, TimeStamp: millis(now)}, history)
+		assert.Contains(t, assessment.Reasons, ReasonImpossibleTravel)
+		assert.Contains(t, assessment.Reasons, ReasonNewCountry)
+		assert.Contains(t, assessment.Reasons, ReasonNewAsn)
+		assert.True(t, engine.IsRisky(assessment.Score))
+	})
+
+	t.Run("Travel At Plausible Speed", func(t *testing.T) {
+		history := []domain.SignInInfo{{IpAddress: bostonIP, UserAgent: testUserAgent, TimeStamp: millis(now.Add(-12 * time.Hour))}}
+		assessment := engine.Evaluate(domain.SaveSignInInfo{IpAddress: londonIP, UserAgent: testUserAgent, TimeStamp: millis(now)}, history)
+		assert.NotContains(t, assessment.Reasons, ReasonImpossibleTravel)
+	})
+
+	t.Run("New Device", func(t *testing.T) {
+		history := []domain.SignInInfo{{IpAddress: bostonIP, UserAgent: testUserAgent, TimeStamp: millis(now.Add(-24 * time.Hour))}}
+		assessment := engine.Evaluate(domain.SaveSignInInfo{IpAddress: bostonIP, UserAgent: "curl/8.0", TimeStamp: millis(now)}, history)
+		assert.Equal(t, []string{ReasonNewDevice}, assessment.Reasons)
+		assert.False(t, engine.IsRisky(assessment.Score))
+	})
+
+	t.Run("Burst Of Sign Ins", func(t *testing.T) {
+		history := []domain.SignInInfo{
+			{UserAgent: testUserAgent, TimeStamp: millis(now.Add(-time.Minute))},
+			{UserAgent: testUserAgent, TimeStamp: millis(now.Add(-2 * time.Minute))},
+		}
+		assessment := engine.Evaluate(domain.SaveSignInInfo{UserAgent: testUserAgent, TimeStamp: millis(now)}, history)
+		assert.Equal(t, []string{ReasonSignInBurst}, assessment.Reasons)
+	})
+}
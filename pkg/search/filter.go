@@ -0,0 +1,265 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/utils"
+)
+
+const (
+	OpEq      = "eq"
+	OpPrefix  = "prefix"
+	OpIn      = "in"
+	OpBetween = "between"
+	OpGte     = "gte"
+	OpLte     = "lte"
+
+	TimestampAttribute = "timestamp"
+	// DynamoDB accepts at most 100 operands on the right hand side of IN
+	maxInValues = 100
+	maxDepth    = 8
+)
+
+var ErrEmptyFilter = errors.New("filter must contain a condition")
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindNumber
+)
+
+// attributes maps the json and the dynamodb names of the SignInInfo fields to their
+// attribute, so callers may use either spelling.
+var attributes = buildAttributes()
+
+type attribute struct {
+	name string
+	kind fieldKind
+}
+
+func buildAttributes() map[string]attribute {
+	fields := map[string]attribute{}
+	t := reflect.TypeOf(domain.SignInInfo{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("dynamodbav"), ",")[0]
+		var kind fieldKind
+		switch f.Type.Kind() {
+		case reflect.String:
+			kind = kindString
+		case reflect.Int, reflect.Int64:
+			kind = kindNumber
+		default:
+			// lists cannot be compared with the supported operators
+			continue
+		}
+		attr := attribute{name: name, kind: kind}
+		fields[name] = attr
+		if jsonName := strings.Split(f.Tag.Get("json"), ",")[0]; jsonName != "" {
+			fields[jsonName] = attr
+		}
+	}
+	return fields
+}
+
+// condition is a validated leaf of the filter with its operands converted to attribute values.
+type condition struct {
+	attr     string
+	op       string
+	operands []types.AttributeValue
+}
+
+// node is the validated form of domain.SearchFilter.
+type node struct {
+	and  []node
+	or   []node
+	cond *condition
+}
+
+func compile(filter domain.SearchFilter, depth int) (node, error) {
+	if depth > maxDepth {
+		return node{}, fmt.Errorf("filter is nested deeper than %d levels", maxDepth)
+	}
+	branches := 0
+	for _, set := range []bool{len(filter.And) > 0, len(filter.Or) > 0, filter.Field != ""} {
+		if set {
+			branches++
+		}
+	}
+	if branches == 0 {
+		return node{}, ErrEmptyFilter
+	}
+	if branches > 1 {
+		return node{}, errors.New("a filter must be exactly one of and, or, or a field condition")
+	}
+
+	switch {
+	case len(filter.And) > 0:
+		children, err := compileAll(filter.And, depth)
+		return node{and: children}, err
+	case len(filter.Or) > 0:
+		children, err := compileAll(filter.Or, depth)
+		return node{or: children}, err
+	}
+	cond, err := compileCondition(filter)
+	if err != nil {
+		return node{}, err
+	}
+	return node{cond: &cond}, nil
+}
+
+func compileAll(filters []domain.SearchFilter, depth int) ([]node, error) {
+	nodes := make([]node, 0, len(filters))
+	for _, child := range filters {
+		n, err := compile(child, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+func compileCondition(filter domain.SearchFilter) (condition, error) {
+	attr, ok := attributes[filter.Field]
+	if !ok {
+		return condition{}, fmt.Errorf("unknown field %q", filter.Field)
+	}
+	var raw []interface{}
+	switch filter.Op {
+	case OpEq, OpGte, OpLte, OpPrefix:
+		if filter.Value == nil {
+			return condition{}, fmt.Errorf("%s on %s requires a value", filter.Op, filter.Field)
+		}
+		raw = []interface{}{filter.Value}
+	case OpBetween:
+		if len(filter.Values) != 2 {
+			return condition{}, fmt.Errorf("between on %s requires exactly two values", filter.Field)
+		}
+		raw = filter.Values
+	case OpIn:
+		if len(filter.Values) == 0 || len(filter.Values) > maxInValues {
+			return condition{}, fmt.Errorf("in on %s requires between 1 and %d values", filter.Field, maxInValues)
+		}
+		raw = filter.Values
+	default:
+		return condition{}, fmt.Errorf("unsupported op %q", filter.Op)
+	}
+	if filter.Op == OpPrefix && attr.kind != kindString {
+		return condition{}, fmt.Errorf("prefix is not supported on numeric field %s", filter.Field)
+	}
+
+	cond := condition{attr: attr.name, op: filter.Op}
+	for _, value := range raw {
+		operand, err := toAttributeValue(attr, value, filter.Op == OpPrefix)
+		if err != nil {
+			return condition{}, fmt.Errorf("%s: %w", filter.Field, err)
+		}
+		cond.operands = append(cond.operands, operand)
+	}
+	return cond, nil
+}
+
+// toAttributeValue converts a json operand, timestamps are accepted in any format understood
+// by utils.ParseTimeParam and stored as epoch milliseconds like the sign-in items.
+func toAttributeValue(attr attribute, value interface{}, prefix bool) (types.AttributeValue, error) {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case float64:
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return nil, fmt.Errorf("unsupported value %v", value)
+	}
+
+	if attr.kind == kindNumber {
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return nil, fmt.Errorf("%q is not a number", text)
+		}
+		return &types.AttributeValueMemberN{Value: text}, nil
+	}
+	if attr.name == TimestampAttribute && !prefix {
+		parsed, err := utils.ParseTimeParam(text, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		text = strconv.FormatInt(parsed.UnixMilli(), 10)
+	}
+	return &types.AttributeValueMemberS{Value: text}, nil
+}
+
+// expressionBuilder renders conditions into an expression, collecting the placeholder names and
+// values shared by the key condition and the filter expression of one request.
+type expressionBuilder struct {
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newExpressionBuilder() *expressionBuilder {
+	return &expressionBuilder{names: map[string]string{}, values: map[string]types.AttributeValue{}}
+}
+
+func (b *expressionBuilder) name(attr string) string {
+	placeholder := "#" + attr
+	b.names[placeholder] = attr
+	return placeholder
+}
+
+func (b *expressionBuilder) value(av types.AttributeValue) string {
+	placeholder := fmt.Sprintf(":v%d", len(b.values))
+	b.values[placeholder] = av
+	return placeholder
+}
+
+func (b *expressionBuilder) condition(c condition) string {
+	name := b.name(c.attr)
+	switch c.op {
+	case OpPrefix:
+		return fmt.Sprintf("begins_with(%s, %s)", name, b.value(c.operands[0]))
+	case OpBetween:
+		return fmt.Sprintf("%s BETWEEN %s AND %s", name, b.value(c.operands[0]), b.value(c.operands[1]))
+	case OpIn:
+		placeholders := make([]string, 0, len(c.operands))
+		for _, operand := range c.operands {
+			placeholders = append(placeholders, b.value(operand))
+		}
+		return fmt.Sprintf("%s IN (%s)", name, strings.Join(placeholders, ", "))
+	case OpGte:
+		return fmt.Sprintf("%s >= %s", name, b.value(c.operands[0]))
+	case OpLte:
+		return fmt.Sprintf("%s <= %s", name, b.value(c.operands[0]))
+	default:
+		return fmt.Sprintf("%s = %s", name, b.value(c.operands[0]))
+	}
+}
+
+func (b *expressionBuilder) node(n node) string {
+	switch {
+	case n.cond != nil:
+		return b.condition(*n.cond)
+	case len(n.and) > 0:
+		return b.join(n.and, " AND ")
+	default:
+		return b.join(n.or, " OR ")
+	}
+}
+
+func (b *expressionBuilder) join(nodes []node, sep string) string {
+	if len(nodes) == 1 {
+		return b.node(nodes[0])
+	}
+	parts := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		parts = append(parts, b.node(n))
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
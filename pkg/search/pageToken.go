@@ -0,0 +1,124 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+var ErrInvalidToken = errors.New("invalid nextToken")
+
+// Page is one page of search results.
+type Page struct {
+	Items        []domain.SignInInfo
+	NextToken    string
+	ScannedCount int
+}
+
+// PageToken is the resume position of a search. Queries resume from Key, scans resume every
+// segment that still has items from its own key, finished segments are left out.
+type PageToken struct {
+	Key      map[string]types.AttributeValue
+	Segments map[int]map[string]types.AttributeValue
+}
+
+type encodedToken struct {
+	Path     string                    `json:"p"`
+	Key      map[string]string         `json:"k,omitempty"`
+	Segments map[int]map[string]string `json:"s,omitempty"`
+}
+
+func (t PageToken) Empty() bool {
+	return len(t.Key) == 0 && len(t.Segments) == 0
+}
+
+// Encode serialises the token, bound to the access path so it cannot resume a different plan.
+func (t PageToken) Encode(plan Plan) (string, error) {
+	if t.Empty() {
+		return "", nil
+	}
+	encoded := encodedToken{Path: tokenPath(plan)}
+	var err error
+	if encoded.Key, err = encodeKey(t.Key); err != nil {
+		return "", err
+	}
+	if len(t.Segments) > 0 {
+		encoded.Segments = map[int]map[string]string{}
+		for segment, key := range t.Segments {
+			if encoded.Segments[segment], err = encodeKey(key); err != nil {
+				return "", err
+			}
+		}
+	}
+	raw, err := json.Marshal(encoded)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func DecodeToken(plan Plan, token string) (PageToken, error) {
+	if token == "" {
+		return PageToken{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return PageToken{}, ErrInvalidToken
+	}
+	var encoded encodedToken
+	if err := json.Unmarshal(raw, &encoded); err != nil || encoded.Path != tokenPath(plan) {
+		return PageToken{}, ErrInvalidToken
+	}
+	decoded := PageToken{Key: decodeKey(encoded.Key)}
+	if len(encoded.Segments) > 0 {
+		decoded.Segments = map[int]map[string]types.AttributeValue{}
+		for segment, key := range encoded.Segments {
+			if segment < 0 || segment >= plan.Segments {
+				return PageToken{}, ErrInvalidToken
+			}
+			decoded.Segments[segment] = decodeKey(key)
+		}
+	}
+	if decoded.Empty() {
+		return PageToken{}, ErrInvalidToken
+	}
+	return decoded, nil
+}
+
+func tokenPath(plan Plan) string {
+	if plan.AccessPath == AccessPathScan {
+		return fmt.Sprintf("%s/%d", plan.AccessPath, plan.Segments)
+	}
+	return plan.AccessPath + "/" + plan.IndexName
+}
+
+// all key attributes of the table and its index are strings
+func encodeKey(key map[string]types.AttributeValue) (map[string]string, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+	encoded := make(map[string]string, len(key))
+	for name, value := range key {
+		s, ok := value.(*types.AttributeValueMemberS)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key attribute %s", name)
+		}
+		encoded[name] = s.Value
+	}
+	return encoded, nil
+}
+
+func decodeKey(key map[string]string) map[string]types.AttributeValue {
+	if len(key) == 0 {
+		return nil
+	}
+	decoded := make(map[string]types.AttributeValue, len(key))
+	for name, value := range key {
+		decoded[name] = &types.AttributeValueMemberS{Value: value}
+	}
+	return decoded
+}
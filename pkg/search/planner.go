@@ -0,0 +1,231 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+const (
+	AccessPathQuery = "query"
+	AccessPathIndex = "index"
+	AccessPathScan  = "scan"
+
+	PartitionKeyAttribute = "uniqueId"
+	IndexSortKeyAttribute = "referenceId"
+)
+
+var ErrScanNotAllowed = errors.New("filter needs an eq condition on uniqueId, table scans are disabled")
+
+// Planner turns a filter into the cheapest access path: a Query on the base table when uniqueId
+// is fixed, a Query on the referenceId index when that narrows the partition further, and a
+// parallel Scan otherwise.
+type Planner struct {
+	IndexName    string
+	ScanSegments int
+	AllowScan    bool
+	// Encrypted are the fields stored as ciphertext, a filter on them could never match
+	Encrypted []string
+}
+
+type Plan struct {
+	AccessPath       string
+	IndexName        string
+	KeyCondition     string
+	FilterExpression string
+	Names            map[string]string
+	Values           map[string]types.AttributeValue
+	Segments         int
+}
+
+func (p Planner) Plan(filter domain.SearchFilter) (Plan, error) {
+	root, err := compile(filter, 0)
+	if err != nil {
+		return Plan{}, err
+	}
+	for _, field := range p.Encrypted {
+		if attr, ok := attributes[field]; ok && references(root, attr.name) {
+			return Plan{}, fmt.Errorf("field %q is encrypted and cannot be used in a filter", field)
+		}
+	}
+	conjuncts := flatten(root)
+
+	partition := -1
+	for i, c := range conjuncts {
+		if c.cond != nil && c.cond.attr == PartitionKeyAttribute && c.cond.op == OpEq {
+			partition = i
+			break
+		}
+	}
+	if partition < 0 {
+		return p.scanPlan(root)
+	}
+
+	// an equality on a sort key beats a range, ties go to the base table
+	tableSort, tableScore := bestSortKey(conjuncts, TimestampAttribute)
+	plan := Plan{AccessPath: AccessPathQuery}
+	sortKey, sort := TimestampAttribute, tableSort
+	if p.IndexName != "" {
+		if indexSort, indexScore := bestSortKey(conjuncts, IndexSortKeyAttribute); indexScore > tableScore {
+			plan = Plan{AccessPath: AccessPathIndex, IndexName: p.IndexName}
+			sortKey, sort = IndexSortKeyAttribute, indexSort
+		}
+	}
+
+	b := newExpressionBuilder()
+	keyCondition := b.condition(*conjuncts[partition].cond)
+	used := map[int]bool{partition: true}
+	if sort >= 0 {
+		sortCondition := *conjuncts[sort].cond
+		used[sort] = true
+		// gte and lte on the sort key are folded into a single between
+		if other := complementaryBound(conjuncts, sortCondition, used); other >= 0 {
+			sortCondition = mergeBounds(sortCondition, *conjuncts[other].cond)
+			used[other] = true
+		}
+		keyCondition += " AND " + b.condition(sortCondition)
+	}
+
+	var rest []node
+	for i, c := range conjuncts {
+		if used[i] {
+			continue
+		}
+		for _, key := range []string{PartitionKeyAttribute, sortKey} {
+			if references(c, key) {
+				return Plan{}, fmt.Errorf("%s can only be constrained once when it is used as a key", key)
+			}
+		}
+		rest = append(rest, c)
+	}
+	if len(rest) > 0 {
+		plan.FilterExpression = b.join(rest, " AND ")
+	}
+	plan.KeyCondition = keyCondition
+	plan.Names, plan.Values = b.names, b.values
+	return plan, nil
+}
+
+func (p Planner) scanPlan(root node) (Plan, error) {
+	if !p.AllowScan {
+		return Plan{}, ErrScanNotAllowed
+	}
+	segments := p.ScanSegments
+	if segments < 1 {
+		segments = 1
+	}
+	b := newExpressionBuilder()
+	filter := b.node(root)
+	return Plan{AccessPath: AccessPathScan, FilterExpression: filter, Names: b.names, Values: b.values, Segments: segments}, nil
+}
+
+func (plan Plan) QueryInput(tableName string, limit int32, startKey map[string]types.AttributeValue) *dynamodb.QueryInput {
+	input := &dynamodb.QueryInput{
+		TableName:                 aws.String(tableName),
+		KeyConditionExpression:    aws.String(plan.KeyCondition),
+		ExpressionAttributeNames:  plan.Names,
+		ExpressionAttributeValues: plan.Values,
+		Limit:                     aws.Int32(limit),
+		ExclusiveStartKey:         startKey,
+	}
+	if plan.IndexName != "" {
+		input.IndexName = aws.String(plan.IndexName)
+	}
+	if plan.FilterExpression != "" {
+		input.FilterExpression = aws.String(plan.FilterExpression)
+	}
+	return input
+}
+
+func (plan Plan) ScanInput(tableName string, segment int, limit int32, startKey map[string]types.AttributeValue) *dynamodb.ScanInput {
+	return &dynamodb.ScanInput{
+		TableName:                 aws.String(tableName),
+		FilterExpression:          aws.String(plan.FilterExpression),
+		ExpressionAttributeNames:  plan.Names,
+		ExpressionAttributeValues: plan.Values,
+		Limit:                     aws.Int32(limit),
+		Segment:                   aws.Int32(int32(segment)),
+		TotalSegments:             aws.Int32(int32(plan.Segments)),
+		ExclusiveStartKey:         startKey,
+	}
+}
+
+func (plan Plan) Explain(scannedCount int) *domain.SearchExplain {
+	return &domain.SearchExplain{
+		AccessPath:       plan.AccessPath,
+		IndexName:        plan.IndexName,
+		KeyCondition:     plan.KeyCondition,
+		FilterExpression: plan.FilterExpression,
+		Segments:         plan.Segments,
+		ScannedCount:     scannedCount,
+	}
+}
+
+// flatten returns the top level conjunction, nested ands are pulled up.
+func flatten(n node) []node {
+	if len(n.and) == 0 {
+		return []node{n}
+	}
+	var conjuncts []node
+	for _, child := range n.and {
+		conjuncts = append(conjuncts, flatten(child)...)
+	}
+	return conjuncts
+}
+
+func bestSortKey(conjuncts []node, attr string) (int, int) {
+	best, bestScore := -1, 0
+	for i, c := range conjuncts {
+		if c.cond == nil || c.cond.attr != attr {
+			continue
+		}
+		score := 0
+		switch c.cond.op {
+		case OpEq:
+			score = 2
+		case OpBetween, OpGte, OpLte, OpPrefix:
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best, bestScore
+}
+
+func complementaryBound(conjuncts []node, sort condition, used map[int]bool) int {
+	want := map[string]string{OpGte: OpLte, OpLte: OpGte}[sort.op]
+	if want == "" {
+		return -1
+	}
+	for i, c := range conjuncts {
+		if !used[i] && c.cond != nil && c.cond.attr == sort.attr && c.cond.op == want {
+			return i
+		}
+	}
+	return -1
+}
+
+func mergeBounds(a condition, b condition) condition {
+	lower, upper := a, b
+	if a.op == OpLte {
+		lower, upper = b, a
+	}
+	return condition{attr: a.attr, op: OpBetween, operands: []types.AttributeValue{lower.operands[0], upper.operands[0]}}
+}
+
+func references(n node, attr string) bool {
+	if n.cond != nil {
+		return n.cond.attr == attr
+	}
+	for _, child := range append(n.and, n.or...) {
+		if references(child, attr) {
+			return true
+		}
+	}
+	return false
+}
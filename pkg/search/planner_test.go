@@ -0,0 +1,114 @@
+package search
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+var testPlanner = Planner{IndexName: "UniqueIdReferenceIdIndex", ScanSegments: 4, AllowScan: true}
+
+func parseFilter(t *testing.T, raw string) domain.SearchFilter {
+	var filter domain.SearchFilter
+	assert.NoError(t, json.Unmarshal([]byte(raw), &filter))
+	return filter
+}
+
+func TestPlanChoosesBaseTableQuery(t *testing.T) {
+	plan, err := testPlanner.Plan(parseFilter(t, `{"and":[
+		{"field":"uniqueId","op":"eq","value":"u1"},
+		{"field":"timeStamp","op":"between","values":["1700000000000","1700000100000"]},
+		{"field":"sourceId","op":"in","values":["a","b"]}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, AccessPathQuery, plan.AccessPath)
+	assert.Equal(t, "#uniqueId = :v0 AND #timestamp BETWEEN :v1 AND :v2", plan.KeyCondition)
+	assert.Equal(t, "#sourceId IN (:v3, :v4)", plan.FilterExpression)
+	assert.Equal(t, "timestamp", plan.Names["#timestamp"])
+}
+
+func TestPlanChoosesIndexForReferenceIdEquality(t *testing.T) {
+	plan, err := testPlanner.Plan(parseFilter(t, `{"and":[
+		{"field":"uniqueId","op":"eq","value":"u1"},
+		{"field":"referenceId","op":"eq","value":"r1"},
+		{"field":"timestamp","op":"gte","value":"2024-01-01"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, AccessPathIndex, plan.AccessPath)
+	assert.Equal(t, "UniqueIdReferenceIdIndex", plan.IndexName)
+	assert.Equal(t, "#uniqueId = :v0 AND #referenceId = :v1", plan.KeyCondition)
+	assert.Equal(t, "#timestamp >= :v2", plan.FilterExpression)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "1704067200000"}, plan.Values[":v2"])
+}
+
+func TestPlanFoldsBoundsIntoBetween(t *testing.T) {
+	plan, err := testPlanner.Plan(parseFilter(t, `{"and":[
+		{"field":"uniqueId","op":"eq","value":"u1"},
+		{"field":"timestamp","op":"lte","value":"1700000100000"},
+		{"field":"timestamp","op":"gte","value":"1700000000000"}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "#uniqueId = :v0 AND #timestamp BETWEEN :v1 AND :v2", plan.KeyCondition)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "1700000000000"}, plan.Values[":v1"])
+	assert.Empty(t, plan.FilterExpression)
+}
+
+func TestPlanFallsBackToScan(t *testing.T) {
+	plan, err := testPlanner.Plan(parseFilter(t, `{"or":[
+		{"field":"region","op":"prefix","value":"us-"},
+		{"and":[{"field":"riskScore","op":"gte","value":50},{"field":"browser","op":"eq","value":"Chrome"}]}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, AccessPathScan, plan.AccessPath)
+	assert.Equal(t, 4, plan.Segments)
+	assert.Equal(t, "(begins_with(#region, :v0) OR (#riskScore >= :v1 AND #browser = :v2))", plan.FilterExpression)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "50"}, plan.Values[":v1"])
+
+	_, err = Planner{}.Plan(parseFilter(t, `{"field":"region","op":"eq","value":"us-east-1"}`))
+	assert.Equal(t, ErrScanNotAllowed, err)
+}
+
+func TestPlanRejectsInvalidFilters(t *testing.T) {
+	for _, raw := range []string{
+		`{}`,
+		`{"field":"unknown","op":"eq","value":"x"}`,
+		`{"field":"region","op":"like","value":"x"}`,
+		`{"field":"region","op":"between","values":["a"]}`,
+		`{"field":"riskScore","op":"prefix","value":"1"}`,
+		`{"field":"riskScore","op":"eq","value":"high"}`,
+		`{"field":"region","op":"eq","value":"x","and":[{"field":"region","op":"eq","value":"y"}]}`,
+		`{"and":[{"field":"uniqueId","op":"eq","value":"u1"},{"or":[{"field":"uniqueId","op":"eq","value":"u2"}]}]}`,
+	} {
+		_, err := testPlanner.Plan(parseFilter(t, raw))
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestPlanRejectsEncryptedFields(t *testing.T) {
+	planner := testPlanner
+	planner.Encrypted = []string{"ipAddress", "userAgent"}
+	_, err := planner.Plan(parseFilter(t, `{"and":[{"field":"uniqueId","op":"eq","value":"u1"},{"or":[{"field":"region","op":"eq","value":"eu"},{"field":"ipAddress","op":"eq","value":"10.0.0.1"}]}]}`))
+	assert.EqualError(t, err, `field "ipAddress" is encrypted and cannot be used in a filter`)
+	_, err = planner.Plan(parseFilter(t, `{"field":"uniqueId","op":"eq","value":"u1"}`))
+	assert.NoError(t, err)
+}
+
+func TestPageTokenRoundTrip(t *testing.T) {
+	plan, err := testPlanner.Plan(parseFilter(t, `{"field":"region","op":"eq","value":"us-east-1"}`))
+	assert.NoError(t, err)
+	token := PageToken{Segments: map[int]map[string]types.AttributeValue{
+		2: {"uniqueId": &types.AttributeValueMemberS{Value: "u1"}, "timestamp": &types.AttributeValueMemberS{Value: "1"}},
+	}}
+	encoded, err := token.Encode(plan)
+	assert.NoError(t, err)
+
+	decoded, err := DecodeToken(plan, encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, token, decoded)
+
+	queryPlan, err := testPlanner.Plan(parseFilter(t, `{"field":"uniqueId","op":"eq","value":"u1"}`))
+	assert.NoError(t, err)
+	_, err = DecodeToken(queryPlan, encoded)
+	assert.Equal(t, ErrInvalidToken, err)
+	_, err = DecodeToken(plan, "not-a-token")
+	assert.Equal(t, ErrInvalidToken, err)
+}
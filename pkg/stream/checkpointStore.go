@@ -0,0 +1,67 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+type ShardCheckpoint struct {
+	SequenceNumber string `json:"sequenceNumber,omitempty"`
+	Finished       bool   `json:"finished"`
+}
+
+type CheckpointStoreInterface interface {
+	Get(shardId string) (ShardCheckpoint, bool)
+	Save(shardId string, checkpoint ShardCheckpoint) error
+}
+
+// FileCheckpointStore keeps the per-shard position in a single JSON file so a restarted
+// consumer resumes after the last record every sink acknowledged.
+type FileCheckpointStore struct {
+	mu          sync.Mutex
+	location    string
+	checkpoints map[string]ShardCheckpoint
+}
+
+func NewFileCheckpointStore(location string) (*FileCheckpointStore, error) {
+	store := &FileCheckpointStore{location: location, checkpoints: map[string]ShardCheckpoint{}}
+	content, err := os.ReadFile(location)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &store.checkpoints); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *FileCheckpointStore) Get(shardId string) (ShardCheckpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checkpoint, ok := s.checkpoints[shardId]
+	return checkpoint, ok
+}
+
+func (s *FileCheckpointStore) Save(shardId string, checkpoint ShardCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkpoints[shardId] = checkpoint
+	content, err := json.MarshalIndent(s.checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.location), 0750); err != nil {
+		return err
+	}
+	tmp := s.location + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.location)
+}
@@ -0,0 +1,228 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"go.uber.org/zap"
+)
+
+const recordsPerRequest = 1000
+
+// ItemDecryptor restores the encrypted attributes of a stream image, envelope.FieldEncryptor
+// implements it.
+type ItemDecryptor interface {
+	Decrypt(ctx context.Context, item map[string]dynamodbtypes.AttributeValue) (map[string]dynamodbtypes.AttributeValue, error)
+}
+
+// Consumer reads the table's stream shard by shard and fans new sign-in records out to
+// the sinks. Checkpoints only advance once every sink accepted a batch, so delivery is
+// at-least-once.
+type Consumer struct {
+	logger       *zap.Logger
+	client       bootstrap.DynamoDBStreamsClientInterface
+	streamArn    string
+	checkpoints  CheckpointStoreInterface
+	sinks        []Sink
+	pollInterval time.Duration
+	iterators    map[string]string
+	// decryptor is nil when the table is not encrypted
+	decryptor ItemDecryptor
+}
+
+func NewConsumer(client bootstrap.DynamoDBStreamsClientInterface, streamArn string, checkpoints CheckpointStoreInterface,
+	sinks []Sink, pollInterval time.Duration, decryptor ItemDecryptor, logger *zap.Logger) *Consumer {
+	return &Consumer{
+		logger:       logger,
+		client:       client,
+		streamArn:    streamArn,
+		checkpoints:  checkpoints,
+		sinks:        sinks,
+		pollInterval: pollInterval,
+		iterators:    map[string]string{},
+		decryptor:    decryptor,
+	}
+}
+
+func (c *Consumer) Run(ctx context.Context) error {
+	for {
+		if err := c.Poll(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			c.logger.Error("Stream poll failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
+
+// Poll makes one pass over every shard of the stream. A child shard is only read once
+// its parent has been drained, which keeps per-profile ordering across resharding.
+func (c *Consumer) Poll(ctx context.Context) error {
+	shards, err := c.listShards(ctx)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(shards))
+	for _, shard := range shards {
+		known[aws.ToString(shard.ShardId)] = true
+	}
+	for _, shard := range shards {
+		shardId := aws.ToString(shard.ShardId)
+		if checkpoint, ok := c.checkpoints.Get(shardId); ok && checkpoint.Finished {
+			continue
+		}
+		parentId := aws.ToString(shard.ParentShardId)
+		if parentId != "" && known[parentId] {
+			if parent, ok := c.checkpoints.Get(parentId); !ok || !parent.Finished {
+				continue
+			}
+		}
+		if err := c.drainShard(ctx, shardId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Consumer) listShards(ctx context.Context) ([]types.Shard, error) {
+	var shards []types.Shard
+	var lastShardId *string
+	for {
+		out, err := c.client.DescribeStream(ctx, &dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(c.streamArn),
+			ExclusiveStartShardId: lastShardId,
+		})
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, out.StreamDescription.Shards...)
+		lastShardId = out.StreamDescription.LastEvaluatedShardId
+		if lastShardId == nil {
+			return shards, nil
+		}
+	}
+}
+
+func (c *Consumer) drainShard(ctx context.Context, shardId string) error {
+	iterator, ok := c.iterators[shardId]
+	if !ok {
+		var err error
+		if iterator, err = c.shardIterator(ctx, shardId); err != nil {
+			return err
+		}
+	}
+	for iterator != "" {
+		out, err := c.client.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: aws.String(iterator),
+			Limit:         aws.Int32(recordsPerRequest),
+		})
+		if err != nil {
+			// the next poll starts again from the checkpoint
+			delete(c.iterators, shardId)
+			return err
+		}
+		if len(out.Records) > 0 {
+			if err := c.publish(ctx, out.Records); err != nil {
+				delete(c.iterators, shardId)
+				return err
+			}
+			last := out.Records[len(out.Records)-1]
+			if err := c.checkpoints.Save(shardId, ShardCheckpoint{SequenceNumber: aws.ToString(last.Dynamodb.SequenceNumber)}); err != nil {
+				delete(c.iterators, shardId)
+				return err
+			}
+		}
+		if out.NextShardIterator == nil {
+			// a closed shard is exhausted, its children can now be read
+			checkpoint, _ := c.checkpoints.Get(shardId)
+			checkpoint.Finished = true
+			delete(c.iterators, shardId)
+			c.logger.Info("Finished reading closed shard", zap.String("shardId", shardId))
+			return c.checkpoints.Save(shardId, checkpoint)
+		}
+		iterator = aws.ToString(out.NextShardIterator)
+		if len(out.Records) == 0 {
+			// caught up with an open shard, continue from here on the next poll
+			c.iterators[shardId] = iterator
+			return nil
+		}
+	}
+	return nil
+}
+
+func (c *Consumer) shardIterator(ctx context.Context, shardId string) (string, error) {
+	input := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(c.streamArn),
+		ShardId:           aws.String(shardId),
+		ShardIteratorType: types.ShardIteratorTypeTrimHorizon,
+	}
+	if checkpoint, ok := c.checkpoints.Get(shardId); ok && checkpoint.SequenceNumber != "" {
+		input.ShardIteratorType = types.ShardIteratorTypeAfterSequenceNumber
+		input.SequenceNumber = aws.String(checkpoint.SequenceNumber)
+	}
+	out, err := c.client.GetShardIterator(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.ShardIterator), nil
+}
+
+func (c *Consumer) publish(ctx context.Context, records []types.Record) error {
+	signIns, err := DecodeRecords(ctx, records, c.decryptor, c.logger)
+	if err != nil {
+		return err
+	}
+	if len(signIns) == 0 {
+		return nil
+	}
+	for _, sink := range c.sinks {
+		if err := sink.Write(ctx, signIns); err != nil {
+			c.logger.Error("Stream sink rejected batch", zap.String("sink", sink.Name()), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeRecords keeps only newly inserted sign-in items, updates and removals are not
+// sign-in events. Images are decrypted with decryptor first; a record that cannot be decrypted
+// fails the batch, so that it is read again rather than dropped while a key is unavailable.
+func DecodeRecords(ctx context.Context, records []types.Record, decryptor ItemDecryptor, logger *zap.Logger) ([]domain.SignInInfo, error) {
+	signIns := make([]domain.SignInInfo, 0, len(records))
+	for _, record := range records {
+		if record.EventName != types.OperationTypeInsert || record.Dynamodb == nil || record.Dynamodb.NewImage == nil {
+			continue
+		}
+		item, err := attributevalue.FromDynamoDBStreamsMap(record.Dynamodb.NewImage)
+		if err != nil {
+			logger.Warn("Skipping undecodable stream record", zap.String("eventId", aws.ToString(record.EventID)), zap.Error(err))
+			continue
+		}
+		if decryptor != nil {
+			if item, err = decryptor.Decrypt(ctx, item); err != nil {
+				return nil, fmt.Errorf("decrypt stream record %s: %w", aws.ToString(record.EventID), err)
+			}
+		}
+		var signIn domain.SignInInfo
+		if err := attributevalue.UnmarshalMap(item, &signIn); err != nil {
+			logger.Warn("Skipping undecodable stream record", zap.String("eventId", aws.ToString(record.EventID)), zap.Error(err))
+			continue
+		}
+		if signIn.UniqueId == "" || signIn.TimeStamp == "" {
+			continue
+		}
+		signIns = append(signIns, signIn)
+	}
+	return signIns, nil
+}
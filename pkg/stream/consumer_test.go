@@ -0,0 +1,258 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	dynamodbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/envelope"
+	"go.uber.org/zap"
+)
+
+const testStreamArn = "arn:aws:dynamodb:local:000000000000:table/signindatatracker/stream/test"
+
+type fakeShard struct {
+	id       string
+	parentId string
+	closed   bool
+	records  []types.Record
+}
+
+// fakeStream is an in-memory DynamoDB Stream, iterators are "<shardId>:<position>".
+type fakeStream struct {
+	shards []*fakeShard
+}
+
+func (f *fakeStream) shard(id string) *fakeShard {
+	for _, s := range f.shards {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (f *fakeStream) add(shardId string, eventName types.OperationType, uniqueId string, timestamp string) {
+	s := f.shard(shardId)
+	seq := strconv.Itoa(len(s.records) + 1)
+	s.records = append(s.records, types.Record{
+		EventID:   aws.String(shardId + "-" + seq),
+		EventName: eventName,
+		Dynamodb: &types.StreamRecord{
+			SequenceNumber: aws.String(seq),
+			NewImage: map[string]types.AttributeValue{
+				"uniqueId":  &types.AttributeValueMemberS{Value: uniqueId},
+				"timestamp": &types.AttributeValueMemberS{Value: timestamp},
+				"sourceId":  &types.AttributeValueMemberS{Value: "web"},
+			},
+		},
+	})
+}
+
+func (f *fakeStream) DescribeStream(ctx context.Context, params *dynamodbstreams.DescribeStreamInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.DescribeStreamOutput, error) {
+	// one shard per page to exercise pagination
+	start := 0
+	if params.ExclusiveStartShardId != nil {
+		for i, s := range f.shards {
+			if s.id == *params.ExclusiveStartShardId {
+				start = i + 1
+			}
+		}
+	}
+	description := &types.StreamDescription{}
+	if start < len(f.shards) {
+		s := f.shards[start]
+		shard := types.Shard{ShardId: aws.String(s.id)}
+		if s.parentId != "" {
+			shard.ParentShardId = aws.String(s.parentId)
+		}
+		description.Shards = []types.Shard{shard}
+		if start+1 < len(f.shards) {
+			description.LastEvaluatedShardId = aws.String(s.id)
+		}
+	}
+	return &dynamodbstreams.DescribeStreamOutput{StreamDescription: description}, nil
+}
+
+func (f *fakeStream) GetShardIterator(ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	position := 0
+	if params.ShardIteratorType == types.ShardIteratorTypeAfterSequenceNumber {
+		position, _ = strconv.Atoi(aws.ToString(params.SequenceNumber))
+	}
+	return &dynamodbstreams.GetShardIteratorOutput{ShardIterator: aws.String(fmt.Sprintf("%s:%d", *params.ShardId, position))}, nil
+}
+
+func (f *fakeStream) GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error) {
+	shardId, pos, _ := strings.Cut(*params.ShardIterator, ":")
+	position, _ := strconv.Atoi(pos)
+	s := f.shard(shardId)
+	records := s.records[position:]
+	out := &dynamodbstreams.GetRecordsOutput{Records: records}
+	if !s.closed || len(records) > 0 {
+		out.NextShardIterator = aws.String(fmt.Sprintf("%s:%d", shardId, len(s.records)))
+	}
+	return out, nil
+}
+
+type recordingSink struct {
+	records []domain.SignInInfo
+	fail    bool
+}
+
+func (r *recordingSink) Name() string { return "recording" }
+
+func (r *recordingSink) Write(ctx context.Context, records []domain.SignInInfo) error {
+	if r.fail {
+		return errors.New("sink unavailable")
+	}
+	r.records = append(r.records, records...)
+	return nil
+}
+
+func uniqueIds(records []domain.SignInInfo) []string {
+	ids := make([]string, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, r.UniqueId)
+	}
+	return ids
+}
+
+func newTestConsumer(t *testing.T, fake *fakeStream, location string, sinks ...Sink) *Consumer {
+	checkpoints, err := NewFileCheckpointStore(location)
+	assert.NoError(t, err)
+	return NewConsumer(fake, testStreamArn, checkpoints, sinks, 0, nil, zap.L().Named("test-log-zap"))
+}
+
+func TestPollAcrossResharding(t *testing.T) {
+	// the child is listed first to make sure it still waits for its parent
+	fake := &fakeStream{shards: []*fakeShard{{id: "child", parentId: "parent"}, {id: "parent", closed: true}}}
+	fake.add("parent", types.OperationTypeInsert, "MWA-1", "1")
+	fake.add("parent", types.OperationTypeModify, "MWA-1", "1")
+	fake.add("parent", types.OperationTypeInsert, "MWA-2", "2")
+	fake.add("child", types.OperationTypeInsert, "MWA-3", "3")
+
+	location := filepath.Join(t.TempDir(), "checkpoints.json")
+	sink := &recordingSink{}
+	consumer := newTestConsumer(t, fake, location, sink)
+
+	assert.NoError(t, consumer.Poll(context.Background()))
+	assert.Equal(t, []string{"MWA-1", "MWA-2"}, uniqueIds(sink.records))
+	assert.NoError(t, consumer.Poll(context.Background()))
+	assert.Equal(t, []string{"MWA-1", "MWA-2", "MWA-3"}, uniqueIds(sink.records))
+
+	fake.add("child", types.OperationTypeInsert, "MWA-4", "4")
+	assert.NoError(t, consumer.Poll(context.Background()))
+	assert.Equal(t, []string{"MWA-1", "MWA-2", "MWA-3", "MWA-4"}, uniqueIds(sink.records))
+
+	// a restarted consumer resumes from the checkpoints
+	restartedSink := &recordingSink{}
+	restarted := newTestConsumer(t, fake, location, restartedSink)
+	fake.add("child", types.OperationTypeInsert, "MWA-5", "5")
+	assert.NoError(t, restarted.Poll(context.Background()))
+	assert.Equal(t, []string{"MWA-5"}, uniqueIds(restartedSink.records))
+}
+
+func TestFailedSinkDoesNotAdvanceCheckpoint(t *testing.T) {
+	fake := &fakeStream{shards: []*fakeShard{{id: "shard"}}}
+	fake.add("shard", types.OperationTypeInsert, "MWA-1", "1")
+
+	sink := &recordingSink{fail: true}
+	aggregator, err := NewAggregatorSink("")
+	assert.NoError(t, err)
+	consumer := newTestConsumer(t, fake, filepath.Join(t.TempDir(), "checkpoints.json"), aggregator, sink)
+
+	assert.Error(t, consumer.Poll(context.Background()))
+	_, ok := consumer.checkpoints.Get("shard")
+	assert.False(t, ok)
+
+	sink.fail = false
+	assert.NoError(t, consumer.Poll(context.Background()))
+	assert.Equal(t, []string{"MWA-1"}, uniqueIds(sink.records))
+	// the aggregator saw the batch twice, sinks must tolerate redelivery
+	assert.Equal(t, 2, aggregator.Snapshot()[0].Total)
+}
+
+// streamImage turns an item as the encrypting client wrote it into the image the stream carries.
+func streamImage(item map[string]dynamodbtypes.AttributeValue) map[string]types.AttributeValue {
+	image := make(map[string]types.AttributeValue, len(item))
+	for name, value := range item {
+		switch v := value.(type) {
+		case *dynamodbtypes.AttributeValueMemberS:
+			image[name] = &types.AttributeValueMemberS{Value: v.Value}
+		case *dynamodbtypes.AttributeValueMemberB:
+			image[name] = &types.AttributeValueMemberB{Value: v.Value}
+		}
+	}
+	return image
+}
+
+func TestEncryptedImagesAreDecrypted(t *testing.T) {
+	keyring, err := envelope.NewLocalKeyring("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	assert.NoError(t, err)
+	encryptor := envelope.NewFieldEncryptor(keyring, []string{"ipAddress", "userAgent"}, time.Minute)
+	item, err := encryptor.Encrypt(context.Background(), map[string]dynamodbtypes.AttributeValue{
+		"uniqueId":  &dynamodbtypes.AttributeValueMemberS{Value: "MWA-1"},
+		"timestamp": &dynamodbtypes.AttributeValueMemberS{Value: "1"},
+		"ipAddress": &dynamodbtypes.AttributeValueMemberS{Value: "203.0.113.7"},
+		"userAgent": &dynamodbtypes.AttributeValueMemberS{Value: "Mozilla/5.0"},
+	})
+	assert.NoError(t, err)
+
+	fake := &fakeStream{shards: []*fakeShard{{id: "shard"}}}
+	fake.shard("shard").records = []types.Record{{
+		EventID:   aws.String("shard-1"),
+		EventName: types.OperationTypeInsert,
+		Dynamodb:  &types.StreamRecord{SequenceNumber: aws.String("1"), NewImage: streamImage(item)},
+	}}
+
+	// without the encryptor the binary attributes cannot be read and the batch is not delivered
+	undecrypted := &recordingSink{}
+	assert.NoError(t, newTestConsumer(t, fake, filepath.Join(t.TempDir(), "checkpoints.json"), undecrypted).Poll(context.Background()))
+	assert.Empty(t, undecrypted.records)
+
+	checkpoints, err := NewFileCheckpointStore(filepath.Join(t.TempDir(), "checkpoints.json"))
+	assert.NoError(t, err)
+	sink := &recordingSink{}
+	consumer := NewConsumer(fake, testStreamArn, checkpoints, []Sink{sink}, 0, encryptor, zap.L().Named("test-log-zap"))
+	assert.NoError(t, consumer.Poll(context.Background()))
+	assert.Len(t, sink.records, 1)
+	assert.Equal(t, "203.0.113.7", sink.records[0].IpAddress)
+	assert.Equal(t, "Mozilla/5.0", sink.records[0].UserAgent)
+}
+
+func TestAggregatorKeepsCountersWhenTheSnapshotFails(t *testing.T) {
+	directory := t.TempDir()
+	nested, err := NewAggregatorSink(filepath.Join(directory, "missing", "aggregates.json"))
+	assert.NoError(t, err)
+	assert.NoError(t, nested.Write(context.Background(), []domain.SignInInfo{{UniqueId: "MWA-1", TimeStamp: "1", SourceId: "web"}}))
+	reloaded, err := NewAggregatorSink(filepath.Join(directory, "missing", "aggregates.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reloaded.Snapshot()[0].Total)
+
+	// a directory in place of the temporary file fails the snapshot, the batch must not count
+	location := filepath.Join(directory, "aggregates.json")
+	sink, err := NewAggregatorSink(location)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Mkdir(location+".tmp", 0750))
+	batch := []domain.SignInInfo{{UniqueId: "MWA-1", TimeStamp: "1", SourceId: "web"}}
+	assert.Error(t, sink.Write(context.Background(), batch))
+	assert.Empty(t, sink.Snapshot())
+
+	assert.NoError(t, os.Remove(location+".tmp"))
+	assert.NoError(t, sink.Write(context.Background(), batch))
+	assert.Equal(t, 1, sink.Snapshot()[0].Total)
+	assert.Equal(t, map[string]int{"web": 1}, sink.Snapshot()[0].BySource)
+}
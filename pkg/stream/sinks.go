@@ -0,0 +1,233 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"github.mathworks.com/development/signindatatrackerws/pkg/webhooks"
+)
+
+const EventTypeSignInStreamed = "signin.streamed"
+
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, records []domain.SignInInfo) error
+}
+
+// NDJSONSink writes one JSON document per line to stdout or a file.
+type NDJSONSink struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewNDJSONSink(writer io.Writer) *NDJSONSink {
+	return &NDJSONSink{writer: writer}
+}
+
+func NewNDJSONFileSink(location string) (*NDJSONSink, *os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(location), 0750); err != nil {
+		return nil, nil, err
+	}
+	file, err := os.OpenFile(location, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewNDJSONSink(file), file, nil
+}
+
+func (s *NDJSONSink) Name() string {
+	return "ndjson"
+}
+
+func (s *NDJSONSink) Write(ctx context.Context, records []domain.SignInInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	encoder := json.NewEncoder(s.writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WebhookSink posts every record to a single endpoint, signed the same way as the
+// service's webhook subscriptions.
+type WebhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+type StreamedEvent struct {
+	Type string            `json:"type"`
+	Data domain.SignInInfo `json:"data"`
+}
+
+func NewWebhookSink(url string, secret string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{url: url, secret: secret, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Write(ctx context.Context, records []domain.SignInInfo) error {
+	for _, record := range records {
+		payload, err := json.Marshal(StreamedEvent{Type: EventTypeSignInStreamed, Data: record})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(webhooks.SignatureHeader, webhooks.Sign(s.secret, strconv.FormatInt(time.Now().Unix(), 10), payload))
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("webhook sink responded with status %d", resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+type ProfileCounters struct {
+	UniqueId  string         `json:"uniqueId"`
+	Total     int            `json:"total"`
+	FirstSeen string         `json:"firstSeen"`
+	LastSeen  string         `json:"lastSeen"`
+	BySource  map[string]int `json:"bySource"`
+}
+
+// AggregatorSink maintains per-profile counters and, when a location is given, writes
+// a snapshot of them after every batch.
+type AggregatorSink struct {
+	mu       sync.Mutex
+	location string
+	counters map[string]*ProfileCounters
+}
+
+func NewAggregatorSink(location string) (*AggregatorSink, error) {
+	sink := &AggregatorSink{location: location, counters: map[string]*ProfileCounters{}}
+	if location == "" {
+		return sink, nil
+	}
+	content, err := os.ReadFile(location)
+	if os.IsNotExist(err) {
+		return sink, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot []*ProfileCounters
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return nil, err
+	}
+	for _, counters := range snapshot {
+		sink.counters[counters.UniqueId] = counters
+	}
+	return sink, nil
+}
+
+func (s *AggregatorSink) Name() string {
+	return "aggregator"
+}
+
+// Write applies the batch to a copy of the counters and only keeps it once the snapshot is
+// stored. A batch that fails is redelivered, counting it in memory already would count it twice.
+func (s *AggregatorSink) Write(ctx context.Context, records []domain.SignInInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := make(map[string]*ProfileCounters, len(s.counters))
+	for uniqueId, counters := range s.counters {
+		next[uniqueId] = counters
+	}
+	copied := make(map[string]bool)
+	for _, record := range records {
+		counters, ok := next[record.UniqueId]
+		if !ok {
+			counters = &ProfileCounters{UniqueId: record.UniqueId, FirstSeen: record.TimeStamp, BySource: map[string]int{}}
+			next[record.UniqueId] = counters
+			copied[record.UniqueId] = true
+		} else if !copied[record.UniqueId] {
+			counters = counters.clone()
+			next[record.UniqueId] = counters
+			copied[record.UniqueId] = true
+		}
+		counters.Total++
+		counters.BySource[record.SourceId]++
+		if record.TimeStamp < counters.FirstSeen {
+			counters.FirstSeen = record.TimeStamp
+		}
+		if record.TimeStamp > counters.LastSeen {
+			counters.LastSeen = record.TimeStamp
+		}
+	}
+	if err := s.persist(next); err != nil {
+		return err
+	}
+	s.counters = next
+	return nil
+}
+
+func (s *AggregatorSink) persist(counters map[string]*ProfileCounters) error {
+	if s.location == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.location), 0750); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(sortedCounters(counters), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.location + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.location)
+}
+
+func (c *ProfileCounters) clone() *ProfileCounters {
+	clone := *c
+	clone.BySource = make(map[string]int, len(c.BySource))
+	for source, count := range c.BySource {
+		clone.BySource[source] = count
+	}
+	return &clone
+}
+
+func (s *AggregatorSink) Snapshot() []ProfileCounters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := make([]ProfileCounters, 0, len(s.counters))
+	for _, counters := range sortedCounters(s.counters) {
+		snapshot = append(snapshot, *counters)
+	}
+	return snapshot
+}
+
+func sortedCounters(counters map[string]*ProfileCounters) []*ProfileCounters {
+	list := make([]*ProfileCounters, 0, len(counters))
+	for _, c := range counters {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UniqueId < list[j].UniqueId })
+	return list
+}
@@ -0,0 +1,72 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// CapacityLimiter is a token bucket over DynamoDB capacity units shared by concurrent workers.
+// The cost of a page is only known after it was read, so consumption may drive the bucket
+// negative and the next Wait sleeps until the debt is paid back.
+type CapacityLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewCapacityLimiter allows unitsPerSecond on average with bursts of one second, zero or less
+// disables the limit.
+func NewCapacityLimiter(unitsPerSecond int) *CapacityLimiter {
+	return &CapacityLimiter{rate: float64(unitsPerSecond), tokens: float64(unitsPerSecond), last: time.Now(), now: time.Now}
+}
+
+// Wait blocks until capacity is available, it returns false when stop was closed first.
+func (l *CapacityLimiter) Wait(stop <-chan struct{}) bool {
+	for {
+		delay := l.reserveDelay()
+		if delay <= 0 {
+			return true
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-stop:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *CapacityLimiter) Consume(units float64) {
+	if l.rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens -= units
+}
+
+func (l *CapacityLimiter) reserveDelay() time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+func (l *CapacityLimiter) refill() {
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+}
@@ -0,0 +1,26 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapacityLimiterWaitsForDebt(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewCapacityLimiter(10)
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	assert.Equal(t, time.Duration(0), l.reserveDelay())
+	l.Consume(15)
+	assert.Equal(t, 500*time.Millisecond, l.reserveDelay())
+	now = now.Add(time.Second)
+	assert.Equal(t, time.Duration(0), l.reserveDelay())
+
+	stop := make(chan struct{})
+	close(stop)
+	l.Consume(100)
+	assert.False(t, l.Wait(stop))
+}
@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestServerSpanContinuesIncomingTrace(t *testing.T) {
+	shutdown, err := Init(Config{Exporter: ExporterNone, ServiceName: "test", SampleRatio: 1})
+	assert.Nil(t, err)
+	defer shutdown(context.Background())
+
+	request := httptest.NewRequest("POST", "/v1/signin", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := StartServerSpan(request, "AKFilter.Receive")
+	defer span.End()
+
+	spanContext := trace.SpanContextFromContext(ctx)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceID().String())
+	assert.NotEqual(t, "00f067aa0ba902b7", spanContext.SpanID().String())
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	Logger(ctx, zap.New(core)).Info("saved")
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["trace_id"])
+	assert.Equal(t, spanContext.SpanID().String(), fields["span_id"])
+}
+
+func TestInitRejectsUnknownExporter(t *testing.T) {
+	_, err := Init(Config{Exporter: "jaeger"})
+	assert.NotNil(t, err)
+}
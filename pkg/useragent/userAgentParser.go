@@ -0,0 +1,197 @@
+package useragent
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultRulesLocation = "configfiles/useragent.rules.json"
+	UnknownValue         = "Unknown"
+	// reloadCheckInterval bounds how often the rules file is stat'ed for changes
+	reloadCheckInterval = 30 * time.Second
+)
+
+// RuleDefinition is a single entry of the rules file. The first capture group of
+// the pattern, when present, is used as the version.
+type RuleDefinition struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// RulesFile is the on-disk shape of the user agent rules, rules are evaluated in order
+// and the first match wins.
+type RulesFile struct {
+	DefaultDevice string           `json:"defaultDevice"`
+	Devices       []RuleDefinition `json:"devices"`
+	Os            []RuleDefinition `json:"os"`
+	Browsers      []RuleDefinition `json:"browsers"`
+}
+
+type compiledRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+type ruleSet struct {
+	defaultDevice string
+	devices       []compiledRule
+	os            []compiledRule
+	browsers      []compiledRule
+}
+
+type UserAgentParserInterface interface {
+	Parse(userAgent string) domain.UserAgentDetails
+}
+
+// Parser turns raw user agent strings into structured attributes. The rules file is
+// re-read whenever its modification time changes so rules can be updated without a redeploy.
+type Parser struct {
+	logger        *zap.Logger
+	rulesLocation string
+	mu            sync.RWMutex
+	rules         *ruleSet
+	modTime       time.Time
+	lastCheck     time.Time
+}
+
+func NewParser(rulesLocation string, logger *zap.Logger) *Parser {
+	if rulesLocation == "" {
+		rulesLocation = DefaultRulesLocation
+	}
+	p := &Parser{logger: logger, rulesLocation: rulesLocation, rules: &ruleSet{}}
+	if err := p.reload(); err != nil {
+		logger.Error("Error loading user agent rules: "+err.Error(), zap.String("location", rulesLocation))
+	}
+	return p
+}
+
+func (p *Parser) Parse(userAgent string) domain.UserAgentDetails {
+	if userAgent == "" {
+		return domain.UserAgentDetails{}
+	}
+	p.reloadIfChanged()
+
+	p.mu.RLock()
+	rules := p.rules
+	p.mu.RUnlock()
+
+	details := domain.UserAgentDetails{
+		Device:  rules.defaultDevice,
+		Os:      UnknownValue,
+		Browser: UnknownValue,
+	}
+	if name, _, ok := match(rules.devices, userAgent); ok {
+		details.Device = name
+	}
+	if name, version, ok := match(rules.os, userAgent); ok {
+		details.Os = name
+		details.OsVersion = strings.ReplaceAll(version, "_", ".")
+	}
+	if name, version, ok := match(rules.browsers, userAgent); ok {
+		details.Browser = name
+		details.BrowserVersion = version
+	}
+	return details
+}
+
+func match(rules []compiledRule, userAgent string) (name string, version string, ok bool) {
+	for _, rule := range rules {
+		groups := rule.pattern.FindStringSubmatch(userAgent)
+		if groups == nil {
+			continue
+		}
+		if len(groups) > 1 {
+			version = groups[1]
+		}
+		return rule.name, version, true
+	}
+	return "", "", false
+}
+
+func (p *Parser) reloadIfChanged() {
+	p.mu.RLock()
+	due := time.Since(p.lastCheck) >= reloadCheckInterval
+	p.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	info, err := os.Stat(p.rulesLocation)
+	p.mu.Lock()
+	p.lastCheck = time.Now()
+	changed := err == nil && !info.ModTime().Equal(p.modTime)
+	p.mu.Unlock()
+	if !changed {
+		return
+	}
+	if err := p.reload(); err != nil {
+		// keep serving the previously loaded rules
+		p.logger.Error("Error reloading user agent rules: "+err.Error(), zap.String("location", p.rulesLocation))
+	}
+}
+
+func (p *Parser) reload() error {
+	info, err := os.Stat(p.rulesLocation)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(p.rulesLocation)
+	if err != nil {
+		return err
+	}
+	var file RulesFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return err
+	}
+	rules, err := compileRules(file)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.rules = rules
+	p.modTime = info.ModTime()
+	p.lastCheck = time.Now()
+	p.mu.Unlock()
+	p.logger.Info("Loaded user agent rules", zap.String("location", p.rulesLocation),
+		zap.Int("devices", len(rules.devices)), zap.Int("os", len(rules.os)), zap.Int("browsers", len(rules.browsers)))
+	return nil
+}
+
+func compileRules(file RulesFile) (*ruleSet, error) {
+	rules := &ruleSet{defaultDevice: file.DefaultDevice}
+	if rules.defaultDevice == "" {
+		rules.defaultDevice = UnknownValue
+	}
+	var err error
+	if rules.devices, err = compileDefinitions(file.Devices); err != nil {
+		return nil, err
+	}
+	if rules.os, err = compileDefinitions(file.Os); err != nil {
+		return nil, err
+	}
+	if rules.browsers, err = compileDefinitions(file.Browsers); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func compileDefinitions(definitions []RuleDefinition) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(definitions))
+	for _, def := range definitions {
+		re, err := regexp.Compile(def.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, compiledRule{name: def.Name, pattern: re})
+	}
+	return compiled, nil
+}
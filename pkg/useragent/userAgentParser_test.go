@@ -0,0 +1,71 @@
+package useragent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const repoRulesLocation = "../../configfiles/useragent.rules.json"
+
+func TestParse(t *testing.T) {
+	parser := NewParser(repoRulesLocation, zap.L().Named("test-log-zap"))
+
+	t.Run("Desktop Chrome on Windows", func(t *testing.T) {
+		details := parser.Parse("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/101.0.4951.54 Safari/537.36")
+		assert.Equal(t, "Desktop", details.Device)
+		assert.Equal(t, "Windows", details.Os)
+		assert.Equal(t, "10.0", details.OsVersion)
+		assert.Equal(t, "Chrome", details.Browser)
+		assert.Equal(t, "101.0.4951.54", details.BrowserVersion)
+	})
+
+	t.Run("Mobile Safari on iOS", func(t *testing.T) {
+		details := parser.Parse("Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1")
+		assert.Equal(t, "Mobile", details.Device)
+		assert.Equal(t, "iOS", details.Os)
+		assert.Equal(t, "17.1", details.OsVersion)
+		assert.Equal(t, "Safari", details.Browser)
+		assert.Equal(t, "17.1", details.BrowserVersion)
+	})
+
+	t.Run("Unknown User Agent", func(t *testing.T) {
+		details := parser.Parse("something-else")
+		assert.Equal(t, "Desktop", details.Device)
+		assert.Equal(t, UnknownValue, details.Os)
+		assert.Equal(t, UnknownValue, details.Browser)
+	})
+
+	t.Run("Empty User Agent", func(t *testing.T) {
+		assert.Empty(t, parser.Parse("").Device)
+	})
+}
+
+func TestReloadOnChange(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "rules.json")
+	assert.NoError(t, os.WriteFile(location, []byte(`{"defaultDevice":"Desktop","browsers":[{"name":"Old","pattern":"Test/([\\d.]+)"}]}`), 0600))
+	parser := NewParser(location, zap.L().Named("test-log-zap"))
+	assert.Equal(t, "Old", parser.Parse("Test/1.0").Browser)
+
+	assert.NoError(t, os.WriteFile(location, []byte(`{"defaultDevice":"Desktop","browsers":[{"name":"New","pattern":"Test/([\\d.]+)"}]}`), 0600))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(location, later, later))
+	parser.lastCheck = time.Time{}
+	assert.Equal(t, "New", parser.Parse("Test/1.0").Browser)
+}
+
+func TestInvalidRulesKeepPrevious(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "rules.json")
+	assert.NoError(t, os.WriteFile(location, []byte(`{"browsers":[{"name":"Kept","pattern":"Test"}]}`), 0600))
+	parser := NewParser(location, zap.L().Named("test-log-zap"))
+
+	assert.NoError(t, os.WriteFile(location, []byte(`{"browsers":[{"name":"Broken","pattern":"("}]}`), 0600))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(location, later, later))
+	parser.lastCheck = time.Time{}
+	assert.Equal(t, "Kept", parser.Parse("Test").Browser)
+}
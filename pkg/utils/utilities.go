@@ -1,11 +1,18 @@
 package utils
 
 import (
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
 )
 
+// values above this are epoch milliseconds rather than seconds
+const millisThreshold = 100000000000
+
 func GetValueFromMap(mp map[string]interface{}, key string, def string) string {
 	v, e := mp[key]
 	if e {
@@ -14,6 +21,22 @@ func GetValueFromMap(mp map[string]interface{}, key string, def string) string {
 	return def
 }
 
+func GetIntValueFromMap(mp map[string]interface{}, key string, def int) int {
+	v, err := strconv.Atoi(GetValueFromMap(mp, key, strconv.Itoa(def)))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func GetBoolValueFromMap(mp map[string]interface{}, key string, def bool) bool {
+	v, err := strconv.ParseBool(GetValueFromMap(mp, key, strconv.FormatBool(def)))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
 func UnmarshalItems(items []map[string]types.AttributeValue) ([]domain.SignInInfo, error) {
 
 	//Unmarshal the result items into a slice of domain.SignInInfo structs
@@ -28,3 +51,23 @@ func UnmarshalItems(items []map[string]types.AttributeValue) ([]domain.SignInInf
 	}
 	return result, nil
 }
+
+// ParseTimeParam accepts epoch seconds or milliseconds, RFC3339, "2006-01-02 15:04:05"
+// or a plain date, an empty value yields the default.
+func ParseTimeParam(value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	if epoch, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if epoch > millisThreshold {
+			return time.UnixMilli(epoch), nil
+		}
+		return time.Unix(epoch, 0), nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
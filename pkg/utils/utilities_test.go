@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeParam(t *testing.T) {
+	def := time.Unix(0, 0)
+	parsed, err := ParseTimeParam("", def)
+	assert.NoError(t, err)
+	assert.Equal(t, def, parsed)
+
+	parsed, err = ParseTimeParam("1661285996251", def)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1661285996251), parsed.UnixMilli())
+
+	parsed, err = ParseTimeParam("1661285996", def)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1661285996), parsed.Unix())
+
+	parsed, err = ParseTimeParam("2022-08-23", def)
+	assert.NoError(t, err)
+	assert.Equal(t, 23, parsed.Day())
+
+	_, err = ParseTimeParam("yesterday", def)
+	assert.Error(t, err)
+}
@@ -0,0 +1,219 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	pendingDirectory    = "pending"
+	deadLetterDirectory = "deadletter"
+	deliveryFileSuffix  = ".json"
+	// corruptSuffix is appended to delivery files that cannot be parsed, they are kept for
+	// inspection but no longer loaded
+	corruptSuffix = ".corrupt"
+)
+
+// DeliveryQueue is a durable queue of webhook deliveries. Every delivery is a file in
+// either the pending or the dead-letter directory, the in-memory maps are only an index.
+type DeliveryQueue struct {
+	mu          sync.Mutex
+	directory   string
+	pending     map[string]*Delivery
+	deadLetters map[string]*Delivery
+	// quarantined lists the files set aside by NewDeliveryQueue
+	quarantined []string
+}
+
+// Delivery is the queued unit of work, one per subscription per event.
+type Delivery struct {
+	Id             string `json:"id"`
+	SubscriptionId string `json:"subscriptionId"`
+	EventId        string `json:"eventId"`
+	Payload        []byte `json:"payload"`
+	Attempts       int    `json:"attempts"`
+	NextAttemptAt  int64  `json:"nextAttemptAt"`
+	LastError      string `json:"lastError,omitempty"`
+}
+
+func NewDeliveryQueue(directory string) (*DeliveryQueue, error) {
+	q := &DeliveryQueue{directory: directory}
+	var err error
+	if q.pending, err = q.loadDeliveries(filepath.Join(directory, pendingDirectory)); err != nil {
+		return nil, err
+	}
+	if q.deadLetters, err = q.loadDeliveries(filepath.Join(directory, deadLetterDirectory)); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Quarantined returns the delivery files that could not be parsed when the queue was loaded,
+// renamed with the corrupt suffix.
+func (q *DeliveryQueue) Quarantined() []string {
+	return q.quarantined
+}
+
+func (q *DeliveryQueue) Enqueue(delivery *Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.write(pendingDirectory, delivery); err != nil {
+		return err
+	}
+	q.pending[delivery.Id] = delivery
+	return nil
+}
+
+// Due returns copies of the pending deliveries whose next attempt is at or before now,
+// oldest first. Deliveries of the held subscriptions are left out, they would otherwise take
+// up the limit and starve everyone else.
+func (q *DeliveryQueue) Due(now int64, limit int, held map[string]bool) []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	due := make([]Delivery, 0)
+	for _, d := range q.pending {
+		if d.NextAttemptAt <= now && !held[d.SubscriptionId] {
+			due = append(due, *d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt < due[j].NextAttemptAt })
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	return due
+}
+
+func (q *DeliveryQueue) Complete(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, id)
+	return removeIfExists(q.path(pendingDirectory, id))
+}
+
+func (q *DeliveryQueue) Reschedule(delivery Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.pending[delivery.Id]; !ok {
+		return nil
+	}
+	if err := q.write(pendingDirectory, &delivery); err != nil {
+		return err
+	}
+	q.pending[delivery.Id] = &delivery
+	return nil
+}
+
+func (q *DeliveryQueue) DeadLetter(delivery Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.write(deadLetterDirectory, &delivery); err != nil {
+		return err
+	}
+	q.deadLetters[delivery.Id] = &delivery
+	delete(q.pending, delivery.Id)
+	return removeIfExists(q.path(pendingDirectory, delivery.Id))
+}
+
+func (q *DeliveryQueue) DeadLetters(subscriptionId string) []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	list := make([]Delivery, 0)
+	for _, d := range q.deadLetters {
+		if subscriptionId == "" || d.SubscriptionId == subscriptionId {
+			list = append(list, *d)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].NextAttemptAt < list[j].NextAttemptAt })
+	return list
+}
+
+// Replay moves the dead letters of a subscription back to the pending queue with a
+// fresh attempt counter, it returns the number of deliveries requeued.
+func (q *DeliveryQueue) Replay(subscriptionId string, now int64) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	replayed := 0
+	for id, d := range q.deadLetters {
+		if d.SubscriptionId != subscriptionId {
+			continue
+		}
+		requeued := *d
+		requeued.Attempts = 0
+		requeued.NextAttemptAt = now
+		requeued.LastError = ""
+		if err := q.write(pendingDirectory, &requeued); err != nil {
+			return replayed, err
+		}
+		q.pending[id] = &requeued
+		delete(q.deadLetters, id)
+		if err := removeIfExists(q.path(deadLetterDirectory, id)); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+func (q *DeliveryQueue) PendingCount() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+func (q *DeliveryQueue) path(state string, id string) string {
+	return filepath.Join(q.directory, state, id+deliveryFileSuffix)
+}
+
+func (q *DeliveryQueue) write(state string, delivery *Delivery) error {
+	content, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(q.path(state, delivery.Id), content)
+}
+
+// loadDeliveries reads every delivery file of directory. A file that does not parse, left by a
+// crash or edited by hand, is renamed aside instead of failing the load.
+func (q *DeliveryQueue) loadDeliveries(directory string) (map[string]*Delivery, error) {
+	deliveries := map[string]*Delivery{}
+	if err := os.MkdirAll(directory, 0750); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), deliveryFileSuffix) {
+			continue
+		}
+		location := filepath.Join(directory, entry.Name())
+		content, err := os.ReadFile(location)
+		if err != nil {
+			return nil, err
+		}
+		delivery := new(Delivery)
+		if err := json.Unmarshal(content, delivery); err != nil || delivery.Id == "" {
+			if err := os.Rename(location, location+corruptSuffix); err != nil {
+				return nil, err
+			}
+			q.quarantined = append(q.quarantined, location+corruptSuffix)
+			continue
+		}
+		deliveries[delivery.Id] = delivery
+	}
+	return deliveries, nil
+}
+
+func removeIfExists(location string) error {
+	err := os.Remove(location)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
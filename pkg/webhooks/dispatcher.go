@@ -0,0 +1,392 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"go.uber.org/zap"
+)
+
+const (
+	EventTypeSignInPersisted = "signin.persisted"
+	SignatureHeader          = "X-SignInTracker-Signature"
+	EventIdHeader            = "X-SignInTracker-Event-Id"
+	DeliveryIdHeader         = "X-SignInTracker-Delivery-Id"
+	subscriptionsFile        = "subscriptions.json"
+	deliveriesPerPass        = 100
+)
+
+var (
+	dispatcherOnce sync.Once
+	dispatcher     *Dispatcher
+)
+
+type PublisherInterface interface {
+	Publish(record domain.SaveSignInInfo)
+}
+
+// Dispatcher fans persisted sign-ins out to the matching subscriptions and delivers
+// them from the durable queue in the background.
+type Dispatcher struct {
+	logger *zap.Logger
+	config bootstrap.WebhookConfig
+	store  SubscriptionStoreInterface
+	queue  *DeliveryQueue
+	client *http.Client
+	// timeout is in nanoseconds, it follows app.signindatatracker.webhook.timeoutseconds on a reload
+	timeout  atomic.Int64
+	now      func() time.Time
+	started  atomic.Bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// DispatcherFactory returns the process wide dispatcher, the service and the admin
+// controller must share one queue.
+func DispatcherFactory() *Dispatcher {
+	dispatcherOnce.Do(func() {
+		logger := zap.L().Named("signindatatrackerws.webhooks")
+		config := bootstrap.GetApplicationContext().AppConfigData.Webhook
+		store, err := NewFileSubscriptionStore(filepath.Join(config.Directory, subscriptionsFile))
+		if err != nil {
+			log.Fatalf("Failed to initialize webhook subscriptions: %v", err)
+		}
+		queue, err := NewDeliveryQueue(config.Directory)
+		if err != nil {
+			log.Fatalf("Failed to initialize webhook delivery queue: %v", err)
+		}
+		for _, location := range queue.Quarantined() {
+			logger.Warn("Set aside an unreadable webhook delivery", zap.String("location", location))
+		}
+		dispatcher = NewDispatcher(config, store, queue, logger)
+		bootstrap.GetApplicationContext().OnConfigReload(func(config *bootstrap.AppConfigData) {
+			dispatcher.SetTimeout(time.Duration(config.Webhook.TimeoutSeconds) * time.Second)
+		})
+		if config.Enabled {
+			dispatcher.Start()
+		}
+	})
+	return dispatcher
+}
+
+// ShutdownDispatcher stops the deliveries of the process wide dispatcher, if one was created.
+// Deliveries in flight finish, the rest stay queued for the next start.
+func ShutdownDispatcher() {
+	if dispatcher != nil {
+		dispatcher.Stop()
+	}
+}
+
+func NewDispatcher(config bootstrap.WebhookConfig, store SubscriptionStoreInterface, queue *DeliveryQueue, logger *zap.Logger) *Dispatcher {
+	d := &Dispatcher{
+		logger: logger,
+		config: config,
+		store:  store,
+		queue:  queue,
+		client: &http.Client{},
+		now:    time.Now,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	d.SetTimeout(time.Duration(config.TimeoutSeconds) * time.Second)
+	return d
+}
+
+// SetTimeout bounds the deliveries started from now on.
+func (d *Dispatcher) SetTimeout(timeout time.Duration) {
+	d.timeout.Store(int64(timeout))
+}
+
+func (d *Dispatcher) Publish(record domain.SaveSignInInfo) {
+	if !d.config.Enabled {
+		return
+	}
+	event := domain.WebhookEvent{
+		Id:         newId(),
+		Type:       EventTypeSignInPersisted,
+		OccurredAt: d.now().UTC().Format(time.RFC3339Nano),
+		Data:       record,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Error("Failed to marshal webhook event", zap.Error(err))
+		return
+	}
+	for _, subscription := range d.store.List() {
+		if !matches(subscription, record) {
+			continue
+		}
+		delivery := &Delivery{
+			Id:             newId(),
+			SubscriptionId: subscription.Id,
+			EventId:        event.Id,
+			Payload:        payload,
+			NextAttemptAt:  d.now().UnixMilli(),
+		}
+		if err := d.queue.Enqueue(delivery); err != nil {
+			d.logger.Error("Failed to enqueue webhook delivery", zap.String("subscriptionId", subscription.Id), zap.Error(err))
+		}
+	}
+}
+
+func matches(subscription domain.WebhookSubscription, record domain.SaveSignInInfo) bool {
+	if len(subscription.SourceIds) > 0 && !contains(subscription.SourceIds, record.SourceId) {
+		return false
+	}
+	if len(subscription.SsoOrgIds) > 0 && !contains(subscription.SsoOrgIds, record.SsoOrgId) {
+		return false
+	}
+	return record.RiskScore >= subscription.MinRiskScore
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dispatcher) Start() {
+	if !d.started.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(time.Duration(d.config.PollIntervalMillis) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-d.stop:
+				return
+			case <-ticker.C:
+				d.processDue()
+			}
+		}
+	}()
+}
+
+func (d *Dispatcher) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		if d.started.Load() {
+			<-d.done
+		}
+	})
+}
+
+func (d *Dispatcher) processDue() {
+	// paused subscriptions keep their deliveries in the queue until they are resumed
+	paused := make(map[string]bool)
+	for _, subscription := range d.store.List() {
+		if subscription.Paused {
+			paused[subscription.Id] = true
+		}
+	}
+	var order []string
+	bySubscription := make(map[string][]Delivery)
+	for _, delivery := range d.queue.Due(d.now().UnixMilli(), deliveriesPerPass, paused) {
+		if _, ok := bySubscription[delivery.SubscriptionId]; !ok {
+			order = append(order, delivery.SubscriptionId)
+		}
+		bySubscription[delivery.SubscriptionId] = append(bySubscription[delivery.SubscriptionId], delivery)
+	}
+	// a slow subscriber only holds up its own deliveries, each subscription keeps its order
+	concurrency := d.config.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, subscriptionId := range order {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(deliveries []Delivery) {
+			defer func() { <-slots; wg.Done() }()
+			d.processSubscription(deliveries)
+		}(bySubscription[subscriptionId])
+	}
+	wg.Wait()
+}
+
+// processSubscription delivers the due deliveries of one subscription in order, it returns
+// early when the dispatcher is stopped and leaves the rest queued.
+func (d *Dispatcher) processSubscription(deliveries []Delivery) {
+	for _, delivery := range deliveries {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+		subscription, err := d.store.Get(delivery.SubscriptionId)
+		if errors.Is(err, ErrSubscriptionNotFound) {
+			delivery.LastError = err.Error()
+			d.deadLetter(delivery)
+			continue
+		}
+		if subscription.Paused {
+			// paused since the pass started
+			return
+		}
+		if err := d.deliver(subscription, delivery); err != nil {
+			d.retry(delivery, err)
+			continue
+		}
+		if err := d.queue.Complete(delivery.Id); err != nil {
+			d.logger.Error("Failed to complete webhook delivery", zap.String("deliveryId", delivery.Id), zap.Error(err))
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(subscription domain.WebhookSubscription, delivery Delivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.timeout.Load()))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, timestamp, delivery.Payload))
+	req.Header.Set(EventIdHeader, delivery.EventId)
+	req.Header.Set(DeliveryIdHeader, delivery.Id)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *Dispatcher) retry(delivery Delivery, cause error) {
+	delivery.Attempts++
+	delivery.LastError = cause.Error()
+	if delivery.Attempts >= d.config.MaxAttempts {
+		d.logger.Warn("Webhook delivery exhausted its retries", zap.String("deliveryId", delivery.Id),
+			zap.String("subscriptionId", delivery.SubscriptionId), zap.Error(cause))
+		d.deadLetter(delivery)
+		return
+	}
+	delivery.NextAttemptAt = d.now().Add(d.backoff(delivery.Attempts)).UnixMilli()
+	if err := d.queue.Reschedule(delivery); err != nil {
+		d.logger.Error("Failed to reschedule webhook delivery", zap.String("deliveryId", delivery.Id), zap.Error(err))
+	}
+}
+
+func (d *Dispatcher) deadLetter(delivery Delivery) {
+	if err := d.queue.DeadLetter(delivery); err != nil {
+		d.logger.Error("Failed to dead-letter webhook delivery", zap.String("deliveryId", delivery.Id), zap.Error(err))
+	}
+}
+
+// backoff doubles the initial delay for every failed attempt, capped at the maximum.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := time.Duration(d.config.InitialBackoffSeconds) * time.Second
+	maxDelay := time.Duration(d.config.MaxBackoffSeconds) * time.Second
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+func (d *Dispatcher) Register(request domain.WebhookAdminRequest) (domain.WebhookSubscription, error) {
+	target, err := url.Parse(request.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return domain.WebhookSubscription{}, errors.New("url must be an absolute http(s) url")
+	}
+	if request.Secret == "" {
+		return domain.WebhookSubscription{}, errors.New("secret cannot be empty")
+	}
+	subscription := domain.WebhookSubscription{
+		Id:           newId(),
+		Url:          request.Url,
+		Secret:       request.Secret,
+		SourceIds:    request.SourceIds,
+		SsoOrgIds:    request.SsoOrgIds,
+		MinRiskScore: request.MinRiskScore,
+		Paused:       request.Paused,
+		CreatedAt:    d.now().UTC().Format(time.RFC3339),
+	}
+	if err := d.store.Save(subscription); err != nil {
+		return domain.WebhookSubscription{}, err
+	}
+	return redact(subscription), nil
+}
+
+func (d *Dispatcher) List() []domain.WebhookSubscription {
+	subscriptions := d.store.List()
+	for i := range subscriptions {
+		subscriptions[i] = redact(subscriptions[i])
+	}
+	return subscriptions
+}
+
+func (d *Dispatcher) SetPaused(id string, paused bool) (domain.WebhookSubscription, error) {
+	subscription, err := d.store.SetPaused(id, paused)
+	return redact(subscription), err
+}
+
+func (d *Dispatcher) Replay(id string) (int, error) {
+	if _, err := d.store.Get(id); err != nil {
+		return 0, err
+	}
+	return d.queue.Replay(id, d.now().UnixMilli())
+}
+
+func (d *Dispatcher) DeadLetters(id string) []Delivery {
+	return d.queue.DeadLetters(id)
+}
+
+func redact(subscription domain.WebhookSubscription) domain.WebhookSubscription {
+	subscription.Secret = ""
+	return subscription
+}
+
+// Sign returns the signature header value for a payload, receivers recompute the
+// HMAC-SHA256 of "<timestamp>.<body>" with their secret and compare.
+func Sign(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func VerifySignature(secret string, header string, payload []byte) bool {
+	timestamp, _, found := strings.Cut(strings.TrimPrefix(header, "t="), ",")
+	if !found {
+		return false
+	}
+	return hmac.Equal([]byte(header), []byte(Sign(secret, timestamp, payload)))
+}
+
+func newId() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,222 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.mathworks.com/development/signindatatrackerws/pkg/bootstrap"
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+	"go.uber.org/zap"
+)
+
+const testSecret = "s3cr3t"
+
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	payloads [][]byte
+	valid    []bool
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.payloads = append(r.payloads, body)
+	r.valid = append(r.valid, VerifySignature(testSecret, req.Header.Get(SignatureHeader), body))
+	w.WriteHeader(r.status)
+}
+
+func newTestDispatcher(t *testing.T, directory string) *Dispatcher {
+	store, err := NewFileSubscriptionStore(filepath.Join(directory, subscriptionsFile))
+	assert.NoError(t, err)
+	queue, err := NewDeliveryQueue(directory)
+	assert.NoError(t, err)
+	config := bootstrap.WebhookConfig{Enabled: true, MaxAttempts: 2, InitialBackoffSeconds: 0, MaxBackoffSeconds: 0, TimeoutSeconds: 5, Concurrency: 4}
+	return NewDispatcher(config, store, queue, zap.L().Named("test-log-zap"))
+}
+
+func TestDeliverSignedPayload(t *testing.T) {
+	rcv := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	d := newTestDispatcher(t, t.TempDir())
+	_, err := d.Register(domain.WebhookAdminRequest{Url: server.URL, Secret: testSecret, SourceIds: []string{"web"}})
+	assert.NoError(t, err)
+
+	d.Publish(domain.SaveSignInInfo{UniqueId: "MWA-1", SourceId: "web"})
+	d.Publish(domain.SaveSignInInfo{UniqueId: "MWA-1", SourceId: "desktop"})
+	d.processDue()
+
+	assert.Len(t, rcv.payloads, 1)
+	assert.True(t, rcv.valid[0])
+	assert.Contains(t, string(rcv.payloads[0]), `"uniqueId":"MWA-1"`)
+	assert.Equal(t, 0, d.queue.PendingCount())
+}
+
+func TestRetryDeadLetterAndReplay(t *testing.T) {
+	rcv := &receiver{status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	directory := t.TempDir()
+	d := newTestDispatcher(t, directory)
+	subscription, err := d.Register(domain.WebhookAdminRequest{Url: server.URL, Secret: testSecret})
+	assert.NoError(t, err)
+	d.Publish(domain.SaveSignInInfo{UniqueId: "MWA-2"})
+
+	d.processDue()
+	assert.Equal(t, 1, d.queue.PendingCount())
+	d.processDue()
+	assert.Equal(t, 0, d.queue.PendingCount())
+	assert.Len(t, d.DeadLetters(subscription.Id), 1)
+
+	// dead letters survive a restart
+	restarted := newTestDispatcher(t, directory)
+	assert.Len(t, restarted.DeadLetters(subscription.Id), 1)
+
+	rcv.status = http.StatusNoContent
+	replayed, err := restarted.Replay(subscription.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+	restarted.processDue()
+	assert.Empty(t, restarted.DeadLetters(subscription.Id))
+	assert.Equal(t, 0, restarted.queue.PendingCount())
+	assert.Len(t, rcv.payloads, 3)
+}
+
+func TestCorruptDeliveryIsSetAsideOnLoad(t *testing.T) {
+	directory := t.TempDir()
+	d := newTestDispatcher(t, directory)
+	_, err := d.Register(domain.WebhookAdminRequest{Url: "http://localhost:1", Secret: testSecret})
+	assert.NoError(t, err)
+	d.Publish(domain.SaveSignInInfo{UniqueId: "MWA-1"})
+	corrupt := filepath.Join(directory, pendingDirectory, "truncated"+deliveryFileSuffix)
+	assert.NoError(t, os.WriteFile(corrupt, []byte(`{"id":"trunc`), 0600))
+
+	restarted := newTestDispatcher(t, directory)
+	assert.Equal(t, 1, restarted.queue.PendingCount())
+	assert.Equal(t, []string{corrupt + corruptSuffix}, restarted.queue.Quarantined())
+	_, err = os.Stat(corrupt)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSlowSubscriberDoesNotHoldUpOthers(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slow.Close()
+	rcv := &receiver{status: http.StatusOK}
+	fast := httptest.NewServer(rcv)
+	defer fast.Close()
+
+	d := newTestDispatcher(t, t.TempDir())
+	for _, url := range []string{slow.URL, fast.URL} {
+		_, err := d.Register(domain.WebhookAdminRequest{Url: url, Secret: testSecret})
+		assert.NoError(t, err)
+	}
+	d.Publish(domain.SaveSignInInfo{UniqueId: "MWA-1"})
+	passed := make(chan struct{})
+	go func() {
+		d.processDue()
+		close(passed)
+	}()
+	assert.Eventually(t, func() bool {
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		return len(rcv.payloads) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	close(release)
+	<-passed
+	assert.Equal(t, 0, d.queue.PendingCount())
+
+	// a dispatcher that never started stops at once
+	d.Stop()
+}
+
+func TestTimeoutAppliesToTheNextDelivery(t *testing.T) {
+	release := make(chan struct{})
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer hanging.Close()
+	defer close(release)
+
+	d := newTestDispatcher(t, t.TempDir())
+	_, err := d.Register(domain.WebhookAdminRequest{Url: hanging.URL, Secret: testSecret})
+	assert.NoError(t, err)
+	d.Publish(domain.SaveSignInInfo{UniqueId: "MWA-1"})
+	d.SetTimeout(50 * time.Millisecond)
+	started := time.Now()
+	d.processDue()
+	assert.Less(t, time.Since(started), 2*time.Second)
+	assert.Equal(t, 1, d.queue.PendingCount())
+}
+
+func TestPausedSubscriptionHoldsDeliveries(t *testing.T) {
+	rcv := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	d := newTestDispatcher(t, t.TempDir())
+	subscription, err := d.Register(domain.WebhookAdminRequest{Url: server.URL, Secret: testSecret, Paused: true})
+	assert.NoError(t, err)
+	d.Publish(domain.SaveSignInInfo{UniqueId: "MWA-3"})
+	d.processDue()
+	assert.Empty(t, rcv.payloads)
+	assert.Equal(t, 1, d.queue.PendingCount())
+
+	_, err = d.SetPaused(subscription.Id, false)
+	assert.NoError(t, err)
+	d.processDue()
+	assert.Len(t, rcv.payloads, 1)
+}
+
+func TestPausedSubscriptionDoesNotStarveOthers(t *testing.T) {
+	rcv := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(rcv)
+	defer server.Close()
+
+	d := newTestDispatcher(t, t.TempDir())
+	_, err := d.Register(domain.WebhookAdminRequest{Url: server.URL, Secret: testSecret, Paused: true})
+	assert.NoError(t, err)
+	for i := 0; i < deliveriesPerPass+10; i++ {
+		d.Publish(domain.SaveSignInInfo{UniqueId: "MWA-paused"})
+	}
+	d.now = func() time.Time { return time.Now().Add(time.Second) }
+	_, err = d.Register(domain.WebhookAdminRequest{Url: server.URL, Secret: testSecret})
+	assert.NoError(t, err)
+	d.Publish(domain.SaveSignInInfo{UniqueId: "MWA-active"})
+
+	d.now = func() time.Time { return time.Now().Add(time.Minute) }
+	d.processDue()
+	assert.Len(t, rcv.payloads, 1)
+	assert.Contains(t, string(rcv.payloads[0]), `"uniqueId":"MWA-active"`)
+	// the paused subscription also got the active one's event
+	assert.Equal(t, deliveriesPerPass+11, d.queue.PendingCount())
+}
+
+func TestRegisterValidation(t *testing.T) {
+	d := newTestDispatcher(t, t.TempDir())
+	_, err := d.Register(domain.WebhookAdminRequest{Url: "not-a-url", Secret: testSecret})
+	assert.Error(t, err)
+	_, err = d.Register(domain.WebhookAdminRequest{Url: "https://example.com/hook"})
+	assert.Error(t, err)
+}
+
+func TestBackoff(t *testing.T) {
+	d := &Dispatcher{config: bootstrap.WebhookConfig{InitialBackoffSeconds: 5, MaxBackoffSeconds: 60}}
+	assert.Equal(t, 5*time.Second, d.backoff(1))
+	assert.Equal(t, 20*time.Second, d.backoff(3))
+	assert.Equal(t, 60*time.Second, d.backoff(10))
+}
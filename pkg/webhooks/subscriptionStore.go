@@ -0,0 +1,141 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.mathworks.com/development/signindatatrackerws/pkg/domain"
+)
+
+var ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+
+type SubscriptionStoreInterface interface {
+	Save(subscription domain.WebhookSubscription) error
+	Get(id string) (domain.WebhookSubscription, error)
+	List() []domain.WebhookSubscription
+	SetPaused(id string, paused bool) (domain.WebhookSubscription, error)
+}
+
+// FileSubscriptionStore keeps subscriptions in memory and writes the full set to a
+// single JSON file on every change so registrations survive restarts.
+type FileSubscriptionStore struct {
+	mu            sync.RWMutex
+	location      string
+	subscriptions map[string]domain.WebhookSubscription
+}
+
+func NewFileSubscriptionStore(location string) (*FileSubscriptionStore, error) {
+	store := &FileSubscriptionStore{location: location, subscriptions: map[string]domain.WebhookSubscription{}}
+	content, err := os.ReadFile(location)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var subscriptions []domain.WebhookSubscription
+	if err := json.Unmarshal(content, &subscriptions); err != nil {
+		return nil, err
+	}
+	for _, s := range subscriptions {
+		store.subscriptions[s.Id] = s
+	}
+	return store, nil
+}
+
+func (s *FileSubscriptionStore) Save(subscription domain.WebhookSubscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions[subscription.Id] = subscription
+	return s.persist()
+}
+
+func (s *FileSubscriptionStore) Get(id string) (domain.WebhookSubscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subscription, ok := s.subscriptions[id]
+	if !ok {
+		return domain.WebhookSubscription{}, ErrSubscriptionNotFound
+	}
+	return subscription, nil
+}
+
+func (s *FileSubscriptionStore) List() []domain.WebhookSubscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sorted()
+}
+
+func (s *FileSubscriptionStore) SetPaused(id string, paused bool) (domain.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	subscription, ok := s.subscriptions[id]
+	if !ok {
+		return domain.WebhookSubscription{}, ErrSubscriptionNotFound
+	}
+	subscription.Paused = paused
+	s.subscriptions[id] = subscription
+	return subscription, s.persist()
+}
+
+func (s *FileSubscriptionStore) sorted() []domain.WebhookSubscription {
+	list := make([]domain.WebhookSubscription, 0, len(s.subscriptions))
+	for _, subscription := range s.subscriptions {
+		list = append(list, subscription)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return list
+}
+
+// persist must be called with the write lock held
+func (s *FileSubscriptionStore) persist() error {
+	content, err := json.MarshalIndent(s.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.location, content)
+}
+
+// writeFileAtomic writes to a temp file in the same directory and renames it over the
+// target so a crash never leaves a half-written file behind.
+func writeFileAtomic(location string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(location), 0750); err != nil {
+		return err
+	}
+	tmp := location + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	// the content has to be on disk before the rename makes it the file, or a crash can leave
+	// an empty file under the final name
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, location); err != nil {
+		return err
+	}
+	return syncDirectory(filepath.Dir(location))
+}
+
+// syncDirectory makes a rename within directory durable.
+func syncDirectory(directory string) error {
+	d, err := os.Open(directory)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}